@@ -0,0 +1,422 @@
+// Package config loads the API's settings from a YAML file, an optional
+// .env file and the environment, in increasing order of precedence, and
+// validates them at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the YAML file read when CONFIG_FILE is not set. It is
+// optional.
+const DefaultFile = "config.yaml"
+
+type Config struct {
+	Port           string           `yaml:"port"`
+	MigrateOnStart bool             `yaml:"migrate_on_start"`
+	JWTSecret      string           `yaml:"jwt_secret"`
+	Log            LogConfig        `yaml:"log"`
+	Metrics        MetricsConfig    `yaml:"metrics"`
+	Tracing        TracingConfig    `yaml:"tracing"`
+	Validation     ValidationConfig `yaml:"validation"`
+	Server         ServerConfig     `yaml:"server"`
+	Database       DatabaseConfig   `yaml:"database"`
+	Media          MediaConfig      `yaml:"media"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level"`  // debug, info, warn or error
+	Format string `yaml:"format"` // json or text
+}
+
+// MetricsConfig sets where /metrics is served: on its own listener at
+// Address, 127.0.0.1:9090 by default, or on the API port when Address is
+// empty. With a Token, scrapes must send it as a bearer token; the API port
+// requires one.
+type MetricsConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+}
+
+// TracingConfig sets where spans go: nowhere, to an OTLP/HTTP collector or
+// to stdout. Without an Endpoint, the OTEL_EXPORTER_OTLP_* variables apply.
+// SampleRatio is the share of traces started here that are recorded.
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter"` // none, otlp or stdout
+	Endpoint    string  `yaml:"endpoint"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// ValidationConfig turns on the checking of requests against the OpenAPI
+// document before they reach the handlers, with JSON bodies of at most
+// MaxBodyBytes.
+type ValidationConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	MaxBodyBytes int  `yaml:"max_body_bytes"`
+}
+
+// ServerConfig bounds the time a connection may take. ShutdownTimeout is
+// how long in-flight requests get to finish on SIGTERM or SIGINT.
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	// SSLMode is one of SSLModes; SSLRootCert is the CA file for
+	// verify-ca and verify-full.
+	SSLMode     string `yaml:"sslmode"`
+	SSLRootCert string `yaml:"sslrootcert"`
+	// connection pool; zero means no limit
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+// SSLModes are the sslmode values lib/pq supports.
+var SSLModes = []string{"disable", "require", "verify-ca", "verify-full"}
+
+type MediaConfig struct {
+	Storage  string   `yaml:"storage"` // disk or s3
+	Dir      string   `yaml:"dir"`
+	CacheDir string   `yaml:"cache_dir"`
+	S3       S3Config `yaml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"`
+}
+
+// Default returns the settings used for whatever is not configured.
+func Default() Config {
+	return Config{
+		Port:       "8080",
+		Log:        LogConfig{Level: "info", Format: "json"},
+		Metrics:    MetricsConfig{Enabled: true, Address: "127.0.0.1:9090"},
+		Tracing:    TracingConfig{Exporter: "none", ServiceName: "my-api", SampleRatio: 1},
+		Validation: ValidationConfig{Enabled: true, MaxBodyBytes: 1 << 20},
+		Server: ServerConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			// long enough for a batch of image uploads
+			ReadTimeout:     2 * time.Minute,
+			WriteTimeout:    2 * time.Minute,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 25 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            "5432",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Media: MediaConfig{
+			Storage:  "disk",
+			Dir:      "media",
+			CacheDir: "media-cache",
+			S3:       S3Config{UseSSL: true},
+		},
+	}
+}
+
+// Load reads the YAML file named by CONFIG_FILE (or DefaultFile when it
+// exists), then .env, then the environment, and validates the result.
+func Load() (Config, error) {
+	cfg := Default()
+
+	file, required := os.LookupEnv("CONFIG_FILE")
+	if !required {
+		file = DefaultFile
+	}
+	if err := readFile(&cfg, file, required); err != nil {
+		return cfg, err
+	}
+
+	// .env does not override variables that are already set
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, fmt.Errorf("config: .env: %w", err)
+	}
+	if err := readEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, cfg.Validate()
+}
+
+func readFile(cfg *Config, file string, required bool) error {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: %s: %w", file, err)
+	}
+	return nil
+}
+
+// envReader sets settings from the environment variables that are set,
+// collecting the values that do not parse.
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) str(dst *string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*dst = value
+	}
+}
+
+func (r *envReader) boolean(dst *bool, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a boolean", name, value))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) integer(dst *int, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not an integer", name, value))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) float(dst *float64, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a number", name, value))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (r *envReader) duration(dst *time.Duration, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a duration", name, value))
+			return
+		}
+		*dst = d
+	}
+}
+
+func readEnv(cfg *Config) error {
+	var r envReader
+	r.str(&cfg.Port, "PORT")
+	r.boolean(&cfg.MigrateOnStart, "MIGRATE_ON_START")
+	r.str(&cfg.JWTSecret, "JWT_SECRET")
+
+	r.str(&cfg.Log.Level, "LOG_LEVEL")
+	r.str(&cfg.Log.Format, "LOG_FORMAT")
+
+	r.boolean(&cfg.Metrics.Enabled, "METRICS_ENABLED")
+	r.str(&cfg.Metrics.Address, "METRICS_ADDRESS")
+	r.str(&cfg.Metrics.Token, "METRICS_TOKEN")
+
+	r.str(&cfg.Tracing.Exporter, "TRACING_EXPORTER")
+	r.str(&cfg.Tracing.Endpoint, "TRACING_ENDPOINT")
+	r.str(&cfg.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+	r.float(&cfg.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO")
+
+	r.boolean(&cfg.Validation.Enabled, "VALIDATION_ENABLED")
+	r.integer(&cfg.Validation.MaxBodyBytes, "VALIDATION_MAX_BODY_BYTES")
+
+	srv := &cfg.Server
+	r.duration(&srv.ReadHeaderTimeout, "SERVER_READ_HEADER_TIMEOUT")
+	r.duration(&srv.ReadTimeout, "SERVER_READ_TIMEOUT")
+	r.duration(&srv.WriteTimeout, "SERVER_WRITE_TIMEOUT")
+	r.duration(&srv.IdleTimeout, "SERVER_IDLE_TIMEOUT")
+	r.duration(&srv.ShutdownTimeout, "SHUTDOWN_TIMEOUT")
+
+	db := &cfg.Database
+	r.str(&db.Host, "DB_HOST")
+	r.str(&db.Port, "DB_PORT")
+	r.str(&db.User, "DB_USER")
+	r.str(&db.Password, "DB_PASSWORD")
+	r.str(&db.Name, "DB_NAME")
+	r.str(&db.SSLMode, "DB_SSLMODE")
+	r.str(&db.SSLRootCert, "DB_SSLROOTCERT")
+	r.integer(&db.MaxOpenConns, "DB_MAX_OPEN_CONNS")
+	r.integer(&db.MaxIdleConns, "DB_MAX_IDLE_CONNS")
+	r.duration(&db.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME")
+	r.duration(&db.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME")
+
+	media := &cfg.Media
+	r.str(&media.Storage, "MEDIA_STORAGE")
+	r.str(&media.Dir, "MEDIA_DIR")
+	r.str(&media.CacheDir, "MEDIA_CACHE_DIR")
+	r.str(&media.S3.Endpoint, "S3_ENDPOINT")
+	r.str(&media.S3.Region, "S3_REGION")
+	r.str(&media.S3.Bucket, "S3_BUCKET")
+	r.str(&media.S3.AccessKey, "S3_ACCESS_KEY")
+	r.str(&media.S3.SecretKey, "S3_SECRET_KEY")
+	r.boolean(&media.S3.UseSSL, "S3_USE_SSL")
+
+	if len(r.errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(r.errs...))
+	}
+	return nil
+}
+
+// Validate reports every missing or invalid setting.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(validPort(c.Port), "port %q is not a TCP port", c.Port)
+	check(c.JWTSecret != "", "jwt_secret (JWT_SECRET) is required")
+
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, c.Log.Level),
+		"log.level %q must be debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format %q must be json or text", c.Log.Format)
+
+	if c.Metrics.Address != "" {
+		_, port, err := net.SplitHostPort(c.Metrics.Address)
+		check(err == nil && validPort(port) && port != c.Port,
+			"metrics.address %q must be host:port, on another port than the API", c.Metrics.Address)
+	} else if c.Metrics.Enabled {
+		check(c.Metrics.Token != "",
+			"metrics.token (METRICS_TOKEN) is required to serve /metrics on the API port without metrics.address")
+	}
+
+	tracing := c.Tracing
+	check(slices.Contains([]string{"none", "otlp", "stdout"}, tracing.Exporter),
+		"tracing.exporter %q must be none, otlp or stdout", tracing.Exporter)
+	check(tracing.ServiceName != "", "tracing.service_name (TRACING_SERVICE_NAME) is required")
+	check(tracing.SampleRatio >= 0 && tracing.SampleRatio <= 1,
+		"tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+
+	check(c.Validation.MaxBodyBytes > 0, "validation.max_body_bytes (VALIDATION_MAX_BODY_BYTES) must be positive")
+
+	srv := c.Server
+	check(srv.ReadHeaderTimeout >= 0 && srv.ReadTimeout >= 0 && srv.WriteTimeout >= 0 && srv.IdleTimeout >= 0,
+		"server timeouts cannot be negative")
+	check(srv.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+
+	db := c.Database
+	check(db.Host != "", "database.host (DB_HOST) is required")
+	check(validPort(db.Port), "database.port %q is not a TCP port", db.Port)
+	check(db.User != "", "database.user (DB_USER) is required")
+	check(db.Name != "", "database.name (DB_NAME) is required")
+	check(slices.Contains(SSLModes, db.SSLMode), "database.sslmode %q must be one of %s", db.SSLMode, strings.Join(SSLModes, ", "))
+	check(db.MaxOpenConns >= 0 && db.MaxIdleConns >= 0, "database pool sizes cannot be negative")
+	check(db.ConnMaxLifetime >= 0 && db.ConnMaxIdleTime >= 0, "database connection lifetimes cannot be negative")
+
+	media := c.Media
+	switch media.Storage {
+	case "disk":
+		check(media.Dir != "", "media.dir (MEDIA_DIR) is required for disk storage")
+	case "s3":
+		check(media.S3.Endpoint != "", "media.s3.endpoint (S3_ENDPOINT) is required for s3 storage")
+		check(media.S3.Bucket != "", "media.s3.bucket (S3_BUCKET) is required for s3 storage")
+	default:
+		check(false, "media.storage %q must be disk or s3", media.Storage)
+	}
+	check(media.CacheDir != "", "media.cache_dir (MEDIA_CACHE_DIR) is required")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+// DSN is the lib/pq connection string of the database. Sessions run in UTC,
+// which the timestamp columns hold.
+func (d DatabaseConfig) DSN() string {
+	params := [][2]string{
+		{"host", d.Host},
+		{"port", d.Port},
+		{"user", d.User},
+		{"password", d.Password},
+		{"dbname", d.Name},
+		{"sslmode", d.SSLMode},
+		{"sslrootcert", d.SSLRootCert},
+		{"timezone", "UTC"},
+	}
+	var parts []string
+	for _, p := range params {
+		if p[1] == "" {
+			continue
+		}
+		// quote values so that spaces and quotes in passwords survive
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p[1])
+		parts = append(parts, p[0]+"='"+value+"'")
+	}
+	return strings.Join(parts, " ")
+}
+
+// redacted replaces a set secret.
+func redacted(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// Redacted returns the settings with secrets masked, for logging.
+func (c Config) Redacted() Config {
+	c.JWTSecret = redacted(c.JWTSecret)
+	c.Metrics.Token = redacted(c.Metrics.Token)
+	c.Database.Password = redacted(c.Database.Password)
+	c.Media.S3.AccessKey = redacted(c.Media.S3.AccessKey)
+	c.Media.S3.SecretKey = redacted(c.Media.S3.SecretKey)
+	return c
+}
+
+// Dump returns the effective settings as YAML, with secrets masked.
+func (c Config) Dump() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(data)
+}
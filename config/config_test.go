@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// inDir runs the test in a new directory holding the files, with the
+// variables the files and the test set restored afterwards.
+func inDir(t *testing.T, files map[string]string, vars ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	for _, name := range append(vars, "CONFIG_FILE") {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// valid is a configuration that passes Validate.
+func valid() Config {
+	cfg := Default()
+	cfg.JWTSecret = "secret"
+	cfg.Database.User = "api"
+	cfg.Database.Name = "api"
+	return cfg
+}
+
+// The environment overrides .env, which overrides the YAML file, which
+// overrides the defaults.
+func TestLoadPrecedence(t *testing.T) {
+	inDir(t, map[string]string{
+		"config.yaml": `
+port: "8001"
+jwt_secret: from-file
+log:
+  level: debug
+database:
+  user: file-user
+  name: file-db
+`,
+		".env": "PORT=8002\nLOG_LEVEL=warn\nDB_NAME=dotenv-db\n",
+	}, "PORT", "LOG_LEVEL", "DB_NAME", "JWT_SECRET", "DB_USER")
+	t.Setenv("PORT", "8003")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct{ setting, got, want string }{
+		{"port", cfg.Port, "8003"},
+		{"log.level", cfg.Log.Level, "warn"},
+		{"database.name", cfg.Database.Name, "dotenv-db"},
+		{"database.user", cfg.Database.User, "file-user"},
+		{"jwt_secret", cfg.JWTSecret, "from-file"},
+		{"log.format", cfg.Log.Format, "json"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.setting, tt.got, tt.want)
+		}
+	}
+}
+
+// An empty JWT secret is refused, also when the environment empties the
+// file's.
+func TestLoadEmptyJWTSecret(t *testing.T) {
+	for name, file := range map[string]string{
+		"unset":                  "database: {user: api, name: api}\n",
+		"emptied by environment": "jwt_secret: from-file\ndatabase: {user: api, name: api}\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			inDir(t, map[string]string{"config.yaml": file}, "JWT_SECRET")
+			if name == "emptied by environment" {
+				t.Setenv("JWT_SECRET", "")
+			}
+			if _, err := Load(); err == nil || !strings.Contains(err.Error(), "jwt_secret") {
+				t.Errorf("load error %v, want one about jwt_secret", err)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	inDir(t, nil, "JWT_SECRET", "DB_USER", "DB_NAME", "DB_MAX_OPEN_CONNS")
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("DB_USER", "api")
+	t.Setenv("DB_NAME", "api")
+	if _, err := Load(); err != nil {
+		t.Fatalf("without a config file: %v", err)
+	}
+
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "DB_MAX_OPEN_CONNS") {
+		t.Errorf("load error %v, want one about DB_MAX_OPEN_CONNS", err)
+	}
+	os.Unsetenv("DB_MAX_OPEN_CONNS")
+
+	// a file named by CONFIG_FILE must exist
+	t.Setenv("CONFIG_FILE", "missing.yaml")
+	if _, err := Load(); err == nil {
+		t.Error("loading a missing CONFIG_FILE succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := valid().Validate(); err != nil {
+		t.Fatalf("valid configuration: %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(*Config)
+		error  string
+	}{
+		{"port", func(c *Config) { c.Port = "http" }, "port"},
+		{"log level", func(c *Config) { c.Log.Level = "verbose" }, "log.level"},
+		{"metrics on the API port", func(c *Config) { c.Metrics.Address = "127.0.0.1:8080" }, "metrics.address"},
+		{"metrics without a token", func(c *Config) { c.Metrics.Address = "" }, "metrics.token"},
+		{"sample ratio", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, "sample_ratio"},
+		{"sslmode", func(c *Config) { c.Database.SSLMode = "prefer" }, "sslmode"},
+		{"s3 bucket", func(c *Config) { c.Media.Storage = "s3"; c.Media.S3.Endpoint = "s3.local" }, "media.s3.bucket"},
+	}
+	for _, tt := range tests {
+		cfg := valid()
+		tt.change(&cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: error %v, want one about %s", tt.name, err, tt.error)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := valid()
+	cfg.JWTSecret = "jwt-value"
+	cfg.Database.Password = "hunter2"
+	dump := cfg.Dump()
+	if strings.Contains(dump, "jwt-value") || strings.Contains(dump, "hunter2") || !strings.Contains(dump, "[REDACTED]") {
+		t.Errorf("dump shows secrets:\n%s", dump)
+	}
+}
@@ -0,0 +1,201 @@
+// Package countries is the ISO 3166-1 country list with the postal-code
+// format and address layout of each country, read from the embedded
+// countries.json. Layouts use the libaddressinput notation: %N recipient,
+// %A street lines, %C city, %S region, %Z postal code and %n a line break.
+package countries
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"my-api/models"
+)
+
+//go:embed countries.json
+var dataset []byte
+
+// defaultFormat lays out addresses of countries without their own entry.
+const defaultFormat = "%N%n%A%n%C"
+
+type Country struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+	// PostalCodePattern is matched against the whole upper-cased postal
+	// code. Countries without one accept any postal code.
+	PostalCodePattern string `json:"postal_code_pattern,omitempty"`
+	Format            string `json:"format,omitempty"`
+	// Require and Upper list the fields (as layout letters) that must not be
+	// empty and that are upper-cased on labels.
+	Require string `json:"require,omitempty"`
+	Upper   string `json:"upper,omitempty"`
+
+	postalCode *regexp.Regexp
+}
+
+var (
+	all    []Country
+	byCode = make(map[string]*Country)
+	byName = make(map[string]*Country)
+)
+
+func init() {
+	if err := json.Unmarshal(dataset, &all); err != nil {
+		panic("countries: " + err.Error())
+	}
+	for i := range all {
+		c := &all[i]
+		if c.PostalCodePattern != "" {
+			c.postalCode = regexp.MustCompile(`^(?:` + c.PostalCodePattern + `)$`)
+		}
+		if c.Format == "" {
+			c.Format = defaultFormat
+			c.Require = "AC"
+		}
+		byCode[c.Code] = c
+		byName[strings.ToLower(c.Name)] = c
+	}
+}
+
+// All returns every country, ordered by code.
+func All() []Country {
+	return slices.Clone(all)
+}
+
+// Lookup finds a country by its alpha-2 code or English name, ignoring case.
+func Lookup(codeOrName string) (Country, bool) {
+	key := strings.TrimSpace(codeOrName)
+	if c, ok := byCode[strings.ToUpper(key)]; ok {
+		return *c, true
+	}
+	if c, ok := byName[strings.ToLower(key)]; ok {
+		return *c, true
+	}
+	return Country{}, false
+}
+
+// ValidPostalCode reports whether code has the country's postal-code format.
+func (c Country) ValidPostalCode(code string) bool {
+	return c.postalCode == nil || c.postalCode.MatchString(NormalizePostalCode(code))
+}
+
+// NormalizePostalCode upper-cases the code and collapses its whitespace.
+func NormalizePostalCode(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), " "))
+}
+
+// ValidationError is returned for an address that does not meet the rules
+// of its country. Message can be shown to the client as is.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// requiredFields maps layout letters to the fields they stand for.
+var requiredFields = map[rune]string{
+	'A': "address_line1",
+	'C': "city",
+	'S': "region",
+	'Z': "postal_code",
+}
+
+// Normalize replaces the address's country by its alpha-2 code, normalizes
+// the postal code and checks the address against the country's rules.
+func Normalize(addr *models.Address) error {
+	country, ok := Lookup(addr.Country)
+	if !ok {
+		return &ValidationError{Field: "country", Message: "Unknown country: use an ISO 3166-1 alpha-2 code"}
+	}
+	addr.Country = country.Code
+	addr.PostalCode = NormalizePostalCode(addr.PostalCode)
+	if addr.Region != nil {
+		region := strings.TrimSpace(*addr.Region)
+		addr.Region = &region
+		if region == "" {
+			addr.Region = nil
+		}
+	}
+
+	values := map[string]string{
+		"address_line1": strings.TrimSpace(addr.AddressLine1),
+		"city":          strings.TrimSpace(addr.City),
+		"postal_code":   addr.PostalCode,
+	}
+	if addr.Region != nil {
+		values["region"] = *addr.Region
+	}
+	for _, letter := range country.Require {
+		if field := requiredFields[letter]; field != "" && values[field] == "" {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("%s is required for addresses in %s", field, country.Name)}
+		}
+	}
+	if addr.PostalCode != "" && !country.ValidPostalCode(addr.PostalCode) {
+		return &ValidationError{Field: "postal_code", Message: fmt.Sprintf("Invalid postal code for %s", country.Name)}
+	}
+	return nil
+}
+
+// Label lays the address out for a printed label. When the parcel is sent
+// from another country than the address's, the country name is added as
+// the last line.
+func Label(addr models.Address, fromCountry string) []string {
+	country, ok := Lookup(addr.Country)
+	if !ok {
+		country = Country{Code: addr.Country, Name: addr.Country, Format: defaultFormat}
+	}
+
+	street := addr.AddressLine1
+	if addr.AddressLine2 != nil && *addr.AddressLine2 != "" {
+		street += "\n" + *addr.AddressLine2
+	}
+	region := ""
+	if addr.Region != nil {
+		region = *addr.Region
+	}
+	fields := map[byte]string{
+		'N': addr.RecipientName,
+		'A': street,
+		'C': addr.City,
+		'S': region,
+		'Z': addr.PostalCode,
+	}
+
+	var b strings.Builder
+	format := country.Format
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteByte(format[i])
+			continue
+		}
+		i++
+		if format[i] == 'n' {
+			b.WriteByte('\n')
+			continue
+		}
+		value := fields[format[i]]
+		if strings.ContainsRune(country.Upper, rune(format[i])) {
+			value = strings.ToUpper(value)
+		}
+		b.WriteString(value)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		// separators around fields the address leaves empty
+		line = strings.Trim(line, " ,-/")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if from, ok := Lookup(fromCountry); !ok || from.Code != country.Code {
+		lines = append(lines, strings.ToUpper(country.Name))
+	}
+	return lines
+}
@@ -0,0 +1,113 @@
+package countries
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"my-api/models"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestLookup(t *testing.T) {
+	for _, key := range []string{"DE", "de", " Germany ", "GERMANY"} {
+		if c, ok := Lookup(key); !ok || c.Code != "DE" {
+			t.Errorf("Lookup(%q) = %+v, %t, want Germany", key, c, ok)
+		}
+	}
+	if c, ok := Lookup("Atlantis"); ok {
+		t.Errorf("Lookup(Atlantis) = %+v", c)
+	}
+}
+
+func TestValidPostalCode(t *testing.T) {
+	tests := []struct {
+		country, code string
+		want          bool
+	}{
+		{"DE", "10115", true},
+		{"DE", "1011", false},
+		{"DE", "10115-1", false}, // the pattern matches the whole code
+		{"US", "94103", true},
+		{"US", "94103-1234", true},
+		{"GB", "sw1a 1aa", true}, // upper-cased before matching
+		{"GB", "SW1A1AA", true},
+		{"GB", "12345", false},
+		{"NL", "1012  ab", true}, // whitespace collapsed
+		{"CA", "K1A 0B1", true},
+		{"CA", "D1A 0B1", false},
+		{"AE", "anything", true}, // no postal-code format
+	}
+	for _, tt := range tests {
+		c, _ := Lookup(tt.country)
+		if got := c.ValidPostalCode(tt.code); got != tt.want {
+			t.Errorf("%s.ValidPostalCode(%q) = %t, want %t", tt.country, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		addr      models.Address
+		wantField string // of the ValidationError, none when empty
+	}{
+		{"valid", models.Address{Country: "Germany", AddressLine1: "1 Main St", City: "Berlin", PostalCode: "10115"}, ""},
+		{"unknown country", models.Address{Country: "Atlantis", AddressLine1: "1 Main St", City: "Berlin"}, "country"},
+		{"missing city", models.Address{Country: "DE", AddressLine1: "1 Main St", City: " ", PostalCode: "10115"}, "city"},
+		{"missing postal code", models.Address{Country: "DE", AddressLine1: "1 Main St", City: "Berlin"}, "postal_code"},
+		{"invalid postal code", models.Address{Country: "DE", AddressLine1: "1 Main St", City: "Berlin", PostalCode: "ABC"}, "postal_code"},
+		{"missing region", models.Address{Country: "US", AddressLine1: "1 Main St", City: "Springfield", PostalCode: "62701",
+			Region: ptr("  ")}, "region"},
+		{"region where required", models.Address{Country: "US", AddressLine1: "1 Main St", City: "Springfield", PostalCode: "62701",
+			Region: ptr("IL")}, ""},
+		{"no postal code where optional", models.Address{Country: "AE", AddressLine1: "1 Palm St", Region: ptr("Dubai")}, ""},
+	}
+	for _, tt := range tests {
+		err := Normalize(&tt.addr)
+		var verr *ValidationError
+		switch {
+		case tt.wantField == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantField != "" && (!errors.As(err, &verr) || verr.Field != tt.wantField):
+			t.Errorf("%s: error %v, want a ValidationError of %s", tt.name, err, tt.wantField)
+		}
+	}
+
+	addr := models.Address{Country: "united kingdom", AddressLine1: "10 Downing St", City: "London",
+		PostalCode: " sw1a   2aa ", Region: ptr(" ")}
+	if err := Normalize(&addr); err != nil {
+		t.Fatal(err)
+	}
+	if addr.Country != "GB" || addr.PostalCode != "SW1A 2AA" || addr.Region != nil {
+		t.Errorf("normalized address: country %q, postal code %q, region %v", addr.Country, addr.PostalCode, addr.Region)
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		addr models.Address
+		from string
+		want []string
+	}{
+		{"domestic", models.Address{RecipientName: "Erika Mustermann", AddressLine1: "Hauptstr. 1", City: "Berlin",
+			PostalCode: "10115", Country: "DE"}, "DE",
+			[]string{"Erika Mustermann", "Hauptstr. 1", "10115 Berlin"}},
+		{"abroad, upper-cased fields and a second line", models.Address{RecipientName: "John Doe", AddressLine1: "1 Main St",
+			AddressLine2: ptr("Apt 4"), City: "Springfield", Region: ptr("il"), PostalCode: "62701", Country: "US"}, "DE",
+			[]string{"John Doe", "1 Main St", "Apt 4", "SPRINGFIELD, IL 62701", "UNITED STATES"}},
+		{"separators of empty fields are dropped", models.Address{RecipientName: "Taro", AddressLine1: "1-1 Chiyoda",
+			PostalCode: "100-0001", Region: ptr("Tokyo"), Country: "JP"}, "JP",
+			[]string{"Taro", "1-1 Chiyoda", "TOKYO", "100-0001"}},
+		{"unknown sender country", models.Address{RecipientName: "Ann", AddressLine1: "2 High St", City: "London",
+			PostalCode: "SW1A 2AA", Country: "GB"}, "",
+			[]string{"Ann", "2 High St", "LONDON", "SW1A 2AA", "UNITED KINGDOM"}},
+	}
+	for _, tt := range tests {
+		if got := Label(tt.addr, tt.from); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: label %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
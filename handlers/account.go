@@ -0,0 +1,255 @@
+// handlers/account.go
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"my-api/mail"
+	"my-api/models"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteAccount soft-deletes the current user's own account and ends all
+// of its sessions. An admin can restore it during UserRestoreWindow; after
+// that its personal data is erased. The user is emailed about it.
+func DeleteAccount(users store.UserStore, mailer mail.Mailer, now func() time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+		if _, impersonated := currentImpersonatorID(c); impersonated {
+			problem.Respond(c, problem.Forbidden("Accounts cannot be deleted while impersonating"))
+			return
+		}
+
+		user, err := deleteUser(c.Request.Context(), users, userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
+				return
+			}
+			respondError(c, err, "Error deleting account")
+			return
+		}
+
+		restorableUntil := now().Add(UserRestoreWindow)
+		sendAccountMail(c, mailer, user, deletedMail(restorableUntil))
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Account deleted",
+			"restorable_until": restorableUntil,
+		})
+	}
+}
+
+// deleteUser soft-deletes the user and returns them as they were, with the
+// email address to confirm the deletion to.
+func deleteUser(ctx context.Context, users store.UserStore, id int) (models.User, error) {
+	user, err := users.Get(ctx, id)
+	if err != nil {
+		return user, err
+	}
+	return user, users.Delete(ctx, id)
+}
+
+// accountMail is the subject and body of an email about the account.
+type accountMail struct {
+	Subject, Body string
+}
+
+func deletedMail(restorableUntil time.Time) accountMail {
+	return accountMail{
+		Subject: "Your account has been deleted",
+		Body: "Your account has been deleted and you have been logged out everywhere.\n\n" +
+			"It can be restored until " + restorableUntil.UTC().Format("2 January 2006") +
+			" by contacting support. After that, your personal data will be erased.\n",
+	}
+}
+
+var erasedMail = accountMail{
+	Subject: "Your personal data has been erased",
+	Body: "Your account has been closed and the personal data we kept about you has been erased.\n\n" +
+		"This is the last email you will receive from us.\n",
+}
+
+// sendAccountMail emails the user about a change of their account. The
+// change has been made by then, so a failure is only logged.
+func sendAccountMail(c *gin.Context, mailer mail.Mailer, user models.User, m accountMail) {
+	if err := mailer.Send(c.Request.Context(), user.Email, m.Subject, m.Body); err != nil {
+		requestLogger(c).Error("Error sending account email", "error", err, "subject", m.Subject)
+	}
+}
+
+// exportStores are the stores holding data of a user that the export
+// includes.
+type exportStores struct {
+	Users     store.UserStore
+	Addresses store.AddressStore
+	Sessions  store.SessionStore
+	Carts     store.CartStore
+	Wishlists store.WishlistStore
+	Reviews   store.ReviewStore
+	Orders    store.OrderStore
+}
+
+// userExport is everything the API keeps about a user. Each field is one
+// file of the ZIP archive.
+type userExport struct {
+	ExportedAt            time.Time                     `json:"exported_at"`
+	User                  models.User                   `json:"user"`
+	Addresses             []models.Address              `json:"addresses"`
+	LoginSessions         []models.LoginSession         `json:"login_sessions"`
+	CartItems             []models.CartItem             `json:"cart_items"`
+	Wishlists             []models.Wishlist             `json:"wishlists"`
+	WishlistNotifications []models.WishlistNotification `json:"wishlist_notifications"`
+	Reviews               []models.ProductReview        `json:"reviews"`
+	Orders                []models.Order                `json:"orders"`
+}
+
+func collectUserExport(ctx context.Context, st exportStores, userID int, now time.Time) (userExport, error) {
+	export := userExport{ExportedAt: now.UTC()}
+	var err error
+
+	if export.User, err = st.Users.Get(ctx, userID); err != nil {
+		return export, err
+	}
+	if export.Addresses, err = st.Addresses.List(ctx, userID, ""); err != nil {
+		return export, fmt.Errorf("addresses: %w", err)
+	}
+	if export.LoginSessions, err = st.Sessions.ListByUser(ctx, userID); err != nil {
+		return export, fmt.Errorf("login sessions: %w", err)
+	}
+	if export.CartItems, err = fetchCartItems(ctx, st.Carts, userID); err != nil {
+		return export, fmt.Errorf("cart items: %w", err)
+	}
+
+	lists, err := st.Wishlists.List(ctx, userID)
+	if err != nil {
+		return export, fmt.Errorf("wishlists: %w", err)
+	}
+	for _, list := range lists {
+		// List only counts the items; Get loads them
+		full, err := st.Wishlists.Get(ctx, userID, list.ID)
+		if err != nil {
+			return export, fmt.Errorf("wishlist %d: %w", list.ID, err)
+		}
+		export.Wishlists = append(export.Wishlists, full)
+	}
+	export.WishlistNotifications, err = st.Wishlists.ListNotifications(ctx, userID, false, math.MaxInt32, 0)
+	if err != nil {
+		return export, fmt.Errorf("wishlist notifications: %w", err)
+	}
+
+	export.Reviews, err = st.Reviews.List(ctx, store.ReviewFilter{UserID: userID, Limit: math.MaxInt32})
+	if err != nil {
+		return export, fmt.Errorf("reviews: %w", err)
+	}
+	export.Orders, err = st.Orders.List(ctx, store.OrderFilter{UserID: userID, Limit: math.MaxInt32})
+	if err != nil {
+		return export, fmt.Errorf("orders: %w", err)
+	}
+	return export, nil
+}
+
+// zipExport writes each section of the export as its own JSON file.
+func zipExport(export userExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"user.json", gin.H{"exported_at": export.ExportedAt, "user": export.User}},
+		{"addresses.json", export.Addresses},
+		{"login_sessions.json", export.LoginSessions},
+		{"cart_items.json", export.CartItems},
+		{"wishlists.json", export.Wishlists},
+		{"wishlist_notifications.json", export.WishlistNotifications},
+		{"reviews.json", export.Reviews},
+		{"orders.json", export.Orders},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportUserData downloads everything stored about the current user as one
+// JSON document, or as a ZIP archive of one JSON file per section with
+// ?format=zip
+func ExportUserData(users store.UserStore, addresses store.AddressStore, sessions store.SessionStore,
+	carts store.CartStore, wishlists store.WishlistStore, reviews store.ReviewStore, orders store.OrderStore,
+	now func() time.Time) gin.HandlerFunc {
+	st := exportStores{users, addresses, sessions, carts, wishlists, reviews, orders}
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		if _, impersonated := currentImpersonatorID(c); impersonated {
+			problem.Respond(c, problem.Forbidden("User data cannot be exported while impersonating"))
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		if format != "json" && format != "zip" {
+			problem.Respond(c, problem.Invalid("format", "oneof", "format must be json or zip"))
+			return
+		}
+
+		export, err := collectUserExport(c.Request.Context(), st, userID, now())
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
+				return
+			}
+			respondError(c, err, "Error exporting user data")
+			return
+		}
+
+		filename := fmt.Sprintf("user-%d-export-%s", userID, export.ExportedAt.Format("20060102"))
+		if format == "json" {
+			c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+			c.IndentedJSON(http.StatusOK, export)
+			return
+		}
+
+		archive, err := zipExport(export)
+		if err != nil {
+			respondError(c, err, "Error building export archive")
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+		c.Data(http.StatusOK, "application/zip", archive)
+	}
+}
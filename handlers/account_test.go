@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+func TestExportUserData(t *testing.T) {
+	e := newTestEnv(t)
+	exportedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	e.route(http.MethodGet, "/user/export", "user", ExportUserData(e.Stores.Users, e.Stores.Addresses, e.Stores.Sessions,
+		e.Stores.Carts, e.Stores.Wishlists, e.Stores.Reviews, e.Stores.Orders, func() time.Time { return exportedAt }))
+	u := e.User()
+	other := e.User()
+	productID := e.Product(nil).ID
+
+	ctx := context.Background()
+	for _, userID := range []int{u.ID, other.ID} {
+		if _, err := e.Stores.Addresses.Create(ctx, models.Address{UserID: userID, AddressLine1: "1 Main St", City: "Berlin",
+			Country: "DE", PostalCode: "10115", Type: "home", IsShipping: true}); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := e.Stores.Carts.AddItem(ctx, userID, productID, nil, 2); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Stores.Orders.Checkout(ctx, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.Stores.Reviews.Create(ctx, models.ProductReview{ProductID: productID, UserID: u.ID, Rating: 5, Title: "Great", Body: "Fits."}); err != nil {
+		t.Fatal(err)
+	}
+
+	type export struct {
+		ExportedAt    time.Time `json:"exported_at"`
+		User          models.User
+		Addresses     []models.Address
+		LoginSessions json.RawMessage `json:"login_sessions"`
+		Reviews       []models.ProductReview
+		Orders        []models.Order
+	}
+	got := testutil.Expect[export](t, e.Do(http.MethodGet, "/user/export", u.Token, nil), http.StatusOK)
+	if !got.ExportedAt.Equal(exportedAt) {
+		t.Errorf("exported at %v, want %v", got.ExportedAt, exportedAt)
+	}
+	// the user never logged in, which is an empty list rather than null
+	if string(got.LoginSessions) != "[]" {
+		t.Errorf("exported login sessions %s, want []", got.LoginSessions)
+	}
+	if got.User.ID != u.ID || len(got.Addresses) != 1 || len(got.Reviews) != 1 {
+		t.Errorf("export of user %d: user %d, %d addresses and %d reviews, want 1 each", u.ID, got.User.ID, len(got.Addresses), len(got.Reviews))
+	}
+	if len(got.Orders) != 1 || got.Orders[0].UserID != u.ID || len(got.Orders[0].Items) != 1 {
+		t.Errorf("exported orders %+v, want the user's order with its item", got.Orders)
+	}
+
+	w := e.Do(http.MethodGet, "/user/export?format=zip", u.Token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("zip export: status %d, want 200: %s", w.Code, w.Body)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"user.json", "addresses.json", "login_sessions.json", "cart_items.json",
+		"wishlists.json", "wishlist_notifications.json", "reviews.json", "orders.json"} {
+		if files[name] == nil {
+			t.Errorf("zip export lacks %s", name)
+		}
+	}
+	if f := files["orders.json"]; f != nil {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		var orders []models.Order
+		if err := json.NewDecoder(r).Decode(&orders); err != nil || len(orders) != 1 {
+			t.Errorf("orders.json %+v (%v), want the user's order", orders, err)
+		}
+	}
+
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/export?format=xml", u.Token, nil), http.StatusBadRequest, "validation_failed")
+}
+
+// sentMail is an email a mailerStub was asked to send.
+type sentMail struct {
+	To, Subject, Body string
+}
+
+// mailerStub records the emails instead of sending them.
+type mailerStub struct {
+	mu   sync.Mutex
+	sent []sentMail
+}
+
+func (m *mailerStub) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	return nil
+}
+
+// take returns the emails sent since the last call.
+func (m *mailerStub) take() []sentMail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sent := m.sent
+	m.sent = nil
+	return sent
+}
+
+// Deleting and erasing an account is confirmed to the address the user had.
+func TestAccountMail(t *testing.T) {
+	e := newTestEnv(t)
+	mailer := &mailerStub{}
+	deletedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	e.route(http.MethodDelete, "/user/account", "user", DeleteAccount(e.Stores.Users, mailer, func() time.Time { return deletedAt }))
+	e.route(http.MethodDelete, "/admin/users/:id", "admin", AdminDeleteUser(e.Stores.Users, mailer))
+	e.route(http.MethodPost, "/admin/users/:id/erase", "admin", AdminEraseUser(e.Stores.Users, mailer))
+	u := e.User()
+	other := e.User()
+	adminToken := e.Admin().Token
+
+	deleted := testutil.Expect[struct {
+		RestorableUntil time.Time `json:"restorable_until"`
+	}](t, e.Do(http.MethodDelete, "/user/account", u.Token, nil), http.StatusOK)
+	if want := deletedAt.Add(UserRestoreWindow); !deleted.RestorableUntil.Equal(want) {
+		t.Errorf("account restorable until %v, want %v", deleted.RestorableUntil, want)
+	}
+	testutil.Expect[map[string]any](t, e.Do(http.MethodDelete, fmt.Sprintf("/admin/users/%d", other.ID), adminToken, nil), http.StatusOK)
+	sent := mailer.take()
+	if len(sent) != 2 || sent[0].To != u.Email || sent[1].To != other.Email ||
+		!strings.Contains(sent[0].Subject, "deleted") || !strings.Contains(sent[0].Body, "restored until") {
+		t.Errorf("emails after deletions %+v, want one deletion notice to each user", sent)
+	}
+
+	erase := fmt.Sprintf("/admin/users/%d/erase", u.ID)
+	testutil.Expect[map[string]any](t, e.Do(http.MethodPost, erase, adminToken, nil), http.StatusOK)
+	if sent := mailer.take(); len(sent) != 1 || sent[0].To != u.Email || !strings.Contains(sent[0].Subject, "erased") {
+		t.Errorf("emails after erasure %+v, want an erasure notice to %s", sent, u.Email)
+	}
+	// nothing is left to notify of erasing again
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, erase, adminToken, nil), http.StatusNotFound, "not_found")
+	if sent := mailer.take(); len(sent) != 0 {
+		t.Errorf("emails after erasing again %+v, want none", sent)
+	}
+}
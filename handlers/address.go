@@ -1,364 +1,432 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"my-api/countries"
 	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
 )
 
+// AddressInput is the body of the address endpoints. Which of region and
+// postal_code are required, and the postal-code format, depend on the
+// country. The usage flags are optional; on update a missing flag keeps its
+// current value.
 type AddressInput struct {
-	AddressLine1 string `json:"address_line1" binding:"required"`
-	City         string `json:"city" binding:"required"`
-	Country      string `json:"country" binding:"required"`
-	PostalCode   string `json:"postal_code" binding:"required"`
-	Type         string `json:"type" binding:"required,oneof=home office other"`
+	RecipientName string  `json:"recipient_name" binding:"max=100"`
+	Phone         *string `json:"phone" binding:"omitempty,min=5,max=30"`
+	AddressLine1  string  `json:"address_line1" binding:"required,max=100"`
+	AddressLine2  *string `json:"address_line2" binding:"omitempty,max=100"`
+	City          string  `json:"city" binding:"required,max=50"`
+	Region        *string `json:"region" binding:"omitempty,max=50"`
+	Country       string  `json:"country" binding:"required"` // ISO 3166-1 alpha-2 code or English name
+	PostalCode    string  `json:"postal_code" binding:"max=20"`
+	Type          string  `json:"type" binding:"required,oneof=home office other"`
+	IsShipping    *bool   `json:"is_shipping"`
+	IsBilling     *bool   `json:"is_billing"`
 }
 
 type AdminAddressInput struct {
-	UserID       int    `json:"user_id" binding:"required,min=1"`
-	AddressLine1 string `json:"address_line1" binding:"required"`
-	City         string `json:"city" binding:"required"`
-	Country      string `json:"country" binding:"required"`
-	PostalCode   string `json:"postal_code" binding:"required"`
-	Type         string `json:"type" binding:"required,oneof=home office other"`
+	UserID int `json:"user_id" binding:"required,min=1"`
+	AddressInput
+}
+
+// apply copies the input onto addr, leaving the usage flags that were not
+// sent unchanged, and validates the result against the rules of its country.
+func (input AddressInput) apply(addr *models.Address) error {
+	addr.RecipientName = strings.TrimSpace(input.RecipientName)
+	addr.Phone = input.Phone
+	addr.AddressLine1 = input.AddressLine1
+	addr.AddressLine2 = input.AddressLine2
+	addr.City = input.City
+	addr.Region = input.Region
+	addr.Country = input.Country
+	addr.PostalCode = input.PostalCode
+	addr.Type = input.Type
+	if input.IsShipping != nil {
+		addr.IsShipping = *input.IsShipping
+	}
+	if input.IsBilling != nil {
+		addr.IsBilling = *input.IsBilling
+	}
+	return countries.Normalize(addr)
+}
+
+// invalidAddress writes the 400 response for an address that failed
+// validation and reports whether err was such a failure.
+func invalidAddress(c *gin.Context, err error) bool {
+	var invalid *countries.ValidationError
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	problem.Respond(c, problem.Invalid(invalid.Field, "format", invalid.Message))
+	return true
+}
+
+// addressBook is every address a user has, as returned by the profile endpoints.
+type addressBook struct {
+	Addresses         []models.Address
+	ShippingAddresses []models.ShippingAddress
+	BillingAddresses  []models.BillingAddress
+}
+
+func loadAddressBook(ctx context.Context, addresses store.AddressStore, userID int) (addressBook, error) {
+	var book addressBook
+	var err error
+	if book.Addresses, err = addresses.List(ctx, userID, ""); err != nil {
+		return book, err
+	}
+	for _, addr := range book.Addresses {
+		if addr.IsShipping {
+			book.ShippingAddresses = append(book.ShippingAddresses, addressView(addr, store.UsageShipping))
+		}
+		if addr.IsBilling {
+			book.BillingAddresses = append(book.BillingAddresses, models.BillingAddress(addressView(addr, store.UsageBilling)))
+		}
+	}
+	return book, nil
 }
 
 // AddAddress creates a new address for the user
-func AddAddress(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+func AddAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return openapi.Accepts[AddressInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
 		var input AddressInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		query := `
-			INSERT INTO addresses (user_id, address_line1, city, country, postal_code, type, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, NOW())
-			RETURNING id
-		`
-		var addressID int
-		err := db.QueryRow(query, userID, input.AddressLine1, input.City, input.Country, input.PostalCode, input.Type).Scan(&addressID)
+		addr := models.Address{UserID: userID}
+		if err := input.apply(&addr); invalidAddress(c, err) {
+			return
+		}
+		addr, err := addresses.Create(c.Request.Context(), addr)
 		if err != nil {
-			log.Println("Error inserting address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error inserting address")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":    "Address added",
-			"address_id": addressID,
+			"address_id": addr.ID,
 			"type":       input.Type,
+			"address":    addr,
 		})
-	}
+	})
 }
 
-// GetAddresses retrieves all addresses for the user
-func GetAddresses(db *sql.DB) gin.HandlerFunc {
+// GetAddresses retrieves all addresses for the user, optionally only those
+// used for ?usage=shipping or ?usage=billing
+func GetAddresses(addresses store.AddressStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
-		rows, err := db.Query(`
-			SELECT id, user_id, address_line1, city, country, postal_code, type, created_at
-			FROM addresses
-			WHERE user_id = $1
-		`, userID)
-		if err != nil {
-			log.Println("Error querying addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		usage := store.AddressUsage(c.Query("usage"))
+		if usage != "" && usage != store.UsageShipping && usage != store.UsageBilling {
+			problem.Respond(c, problem.Invalid("usage", "oneof", "Invalid usage"))
 			return
 		}
-		defer rows.Close()
-
-		var addresses []models.Address
-		for rows.Next() {
-			var addr models.Address
-			err := rows.Scan(&addr.ID, &addr.UserID, &addr.AddressLine1, &addr.City, &addr.Country,
-				&addr.PostalCode, &addr.Type, &addr.CreatedAt)
-			if err != nil {
-				log.Println("Error scanning address:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-			addresses = append(addresses, addr)
+
+		list, err := addresses.List(c.Request.Context(), userID, usage)
+		if err != nil {
+			respondError(c, err, "Error querying addresses")
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "Addresses retrieved",
-			"addresses": addresses,
+			"addresses": list,
 		})
 	}
 }
 
+// updateAddress applies the input to an address owned by userID (or any
+// user for store.AnyUser).
+func updateAddress(ctx context.Context, addresses store.AddressStore, userID, id int, input AddressInput) error {
+	addr, err := addresses.Get(ctx, userID, id, "")
+	if err != nil {
+		return err
+	}
+	if err := input.apply(&addr); err != nil {
+		return err
+	}
+	return addresses.Update(ctx, userID, addr)
+}
+
 // UpdateAddress updates an existing address
-func UpdateAddress(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+func UpdateAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return openapi.Accepts[AddressInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
 		addressIDStr := c.Param("id")
 		addressID, err := strconv.Atoi(addressIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			problem.Respond(c, problem.BadRequest("Invalid address ID"))
 			return
 		}
 
 		var input AddressInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		tx, err := db.Begin()
-		if err != nil {
-			log.Println("Error starting transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		if err := updateAddress(c.Request.Context(), addresses, userID, addressID, input); err != nil {
+			if invalidAddress(c, err) {
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found or not owned by user"))
+				return
+			}
+			respondError(c, err, "Error updating address")
 			return
 		}
-		defer tx.Rollback()
 
-		var existsAddr bool
-		err = tx.QueryRow(`
-			SELECT EXISTS (SELECT 1 FROM addresses WHERE id = $1 AND user_id = $2)
-		`, addressID, userID).Scan(&existsAddr)
-		if err != nil {
-			log.Println("Error verifying address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		if !existsAddr {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found or not owned by user"})
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Address updated",
+			"address_id": addressID,
+		})
+	})
+}
+
+func GetAddressByID(addresses store.AddressStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
-		result, err := tx.Exec(`
-			UPDATE addresses
-			SET address_line1 = $1, city = $2, country = $3, postal_code = $4, type = $5
-			WHERE id = $6 AND user_id = $7
-		`, input.AddressLine1, input.City, input.Country, input.PostalCode, input.Type, addressID, userID)
+		addressIDStr := c.Param("id")
+		addressID, err := strconv.Atoi(addressIDStr)
 		if err != nil {
-			log.Println("Error updating address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+			problem.Respond(c, problem.BadRequest("Invalid address ID"))
 			return
 		}
 
-		if err := tx.Commit(); err != nil {
-			log.Println("Error committing transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		addr, err := addresses.Get(c.Request.Context(), userID, addressID, "")
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found"))
+				return
+			}
+			respondError(c, err, "Error querying address")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message":    "Address updated",
-			"address_id": addressID,
+			"message": "Address retrieved",
+			"address": addr,
 		})
 	}
 }
 
-func GetAddressByID(db *sql.DB) gin.HandlerFunc {
+// DeleteAddress deletes an address
+func DeleteAddress(addresses store.AddressStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
 		addressIDStr := c.Param("id")
 		addressID, err := strconv.Atoi(addressIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			problem.Respond(c, problem.BadRequest("Invalid address ID"))
 			return
 		}
 
-		var addr models.Address
-		err = db.QueryRow(`
-			SELECT id, user_id, address_line1, city, country, postal_code, type, created_at
-			FROM addresses
-			WHERE id = $1 AND user_id = $2
-		`, addressID, userID).Scan(&addr.ID, &addr.UserID, &addr.AddressLine1,
-			&addr.City, &addr.Country, &addr.PostalCode, &addr.Type, &addr.CreatedAt)
-		if err != nil {
-			log.Println("Error querying address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		if err := addresses.Delete(c.Request.Context(), userID, addressID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found or not owned by user"))
+				return
+			}
+			respondError(c, err, "Error deleting address")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Address retrieved",
-			"address": addr,
+			"message":    "Address deleted",
+			"address_id": addressID,
 		})
 	}
 }
 
-// DeleteAddress deletes an address
-func DeleteAddress(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+type DefaultAddressInput struct {
+	Usage store.AddressUsage `json:"usage" binding:"required,oneof=shipping billing"`
+}
+
+// SetDefaultAddress makes the address the user's default for the usage in
+// the body; the address must already be used for it
+func SetDefaultAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return openapi.Accepts[DefaultAddressInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
 		addressIDStr := c.Param("id")
 		addressID, err := strconv.Atoi(addressIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			problem.Respond(c, problem.BadRequest("Invalid address ID"))
 			return
 		}
 
-		result, err := db.Exec(`
-			DELETE FROM addresses
-			WHERE id = $1 AND user_id = $2
-		`, addressID, userID)
-		if err != nil {
-			log.Println("Error deleting address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		var input DefaultAddressInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found or not owned by user"})
+
+		if err := addresses.SetDefault(c.Request.Context(), userID, addressID, input.Usage); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found or not used for "+string(input.Usage)))
+				return
+			}
+			respondError(c, err, "Error setting default address")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message":    "Address deleted",
+			"message":    "Address set as default " + string(input.Usage) + " address",
 			"address_id": addressID,
 		})
-	}
+	})
 }
 
 // AdminCreateAddress creates a new address for any user (admin only)
-func AdminCreateAddress(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func AdminCreateAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return openapi.Accepts[AdminAddressInput](func(c *gin.Context) {
 		var input AdminAddressInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		// Verify user exists
-		var existsUser bool
-		err := db.QueryRow(`
-			SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)
-		`, input.UserID).Scan(&existsUser)
-		if err != nil {
-			log.Println("Error verifying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		addr := models.Address{UserID: input.UserID}
+		if err := input.apply(&addr); invalidAddress(c, err) {
 			return
 		}
-		if !existsUser {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
-			return
-		}
-
-		query := `
-			INSERT INTO addresses (user_id, address_line1, city, country, postal_code, type, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, NOW())
-			RETURNING id
-		`
-		var addressID int
-		err = db.QueryRow(query, input.UserID, input.AddressLine1, input.City, input.Country, input.PostalCode, input.Type).Scan(&addressID)
+		addr, err := addresses.Create(c.Request.Context(), addr)
 		if err != nil {
-			log.Println("Error inserting address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			if errors.Is(err, store.ErrInvalidReference) {
+				problem.Respond(c, problem.BadRequest("User not found"))
+				return
+			}
+			respondError(c, err, "Error inserting address")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":    "Address created",
-			"address_id": addressID,
+			"address_id": addr.ID,
 		})
-	}
+	})
 }
 
 // AdminUpdateAddress updates an existing address by ID (admin only)
-func AdminUpdateAddress(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func AdminUpdateAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return openapi.Accepts[AddressInput](func(c *gin.Context) {
 		addressIDStr := c.Param("id")
 		addressID, err := strconv.Atoi(addressIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid address ID"})
+			problem.Respond(c, problem.BadRequest("Invalid address ID"))
 			return
 		}
 
 		var input AddressInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		tx, err := db.Begin()
-		if err != nil {
-			log.Println("Error starting transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		if err := updateAddress(c.Request.Context(), addresses, store.AnyUser, addressID, input); err != nil {
+			if invalidAddress(c, err) {
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found"))
+				return
+			}
+			respondError(c, err, "Error updating address")
 			return
 		}
-		defer tx.Rollback()
 
-		// Verify address exists
-		var existsAddr bool
-		err = tx.QueryRow(`
-			SELECT EXISTS (SELECT 1 FROM addresses WHERE id = $1)
-		`, addressID).Scan(&existsAddr)
-		if err != nil {
-			log.Println("Error verifying address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		if !existsAddr {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
-			return
-		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Address updated",
+			"address_id": addressID,
+		})
+	})
+}
 
-		result, err := tx.Exec(`
-			UPDATE addresses
-			SET address_line1 = $1, city = $2, country = $3, postal_code = $4, type = $5
-			WHERE id = $6
-		`, input.AddressLine1, input.City, input.Country, input.PostalCode, input.Type, addressID)
-		if err != nil {
-			log.Println("Error updating address:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Address not found"})
+// addressLabel responds with the address laid out for a printed label. The
+// country line is left out when ?from= names the address's own country.
+func addressLabel(c *gin.Context, addresses store.AddressStore, userID int) {
+	addressIDStr := c.Param("id")
+	addressID, err := strconv.Atoi(addressIDStr)
+	if err != nil {
+		problem.Respond(c, problem.BadRequest("Invalid address ID"))
+		return
+	}
+
+	addr, err := addresses.Get(c.Request.Context(), userID, addressID, "")
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Address not found"))
 			return
 		}
+		respondError(c, err, "Error querying address")
+		return
+	}
 
-		if err := tx.Commit(); err != nil {
-			log.Println("Error committing transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+	lines := countries.Label(addr, c.Query("from"))
+	c.JSON(http.StatusOK, gin.H{
+		"address_id": addr.ID,
+		"lines":      lines,
+		"label":      strings.Join(lines, "\n"),
+	})
+}
+
+// GetAddressLabel formats one of the user's addresses for a printed label
+func GetAddressLabel(addresses store.AddressStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
+		addressLabel(c, addresses, userID)
+	}
+}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Address updated",
-			"address_id": addressID,
-		})
+// AdminGetAddressLabel formats any user's address for a printed label (admin only)
+func AdminGetAddressLabel(addresses store.AddressStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addressLabel(c, addresses, store.AnyUser)
 	}
 }
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The shipping and billing address endpoints are views over the address
+// book: each one only sees the addresses flagged for its usage and reports
+// the usage's default as is_default.
+
+// usageLabel starts the messages of a usage's endpoints.
+var usageLabel = map[store.AddressUsage]string{
+	store.UsageShipping: "Shipping",
+	store.UsageBilling:  "Billing",
+}
+
+// addressView returns the address as the endpoints of usage show it.
+func addressView(addr models.Address, usage store.AddressUsage) models.ShippingAddress {
+	isDefault := addr.IsDefaultShipping
+	if usage == store.UsageBilling {
+		isDefault = addr.IsDefaultBilling
+	}
+	return models.ShippingAddress{
+		ID:            addr.ID,
+		UserID:        addr.UserID,
+		RecipientName: addr.RecipientName,
+		Phone:         addr.Phone,
+		AddressLine1:  addr.AddressLine1,
+		AddressLine2:  addr.AddressLine2,
+		City:          addr.City,
+		Region:        addr.Region,
+		Country:       addr.Country,
+		PostalCode:    addr.PostalCode,
+		Type:          addr.Type,
+		IsDefault:     isDefault,
+		CreatedAt:     addr.CreatedAt,
+	}
+}
+
+func usageAddressJSON(addr models.Address, usage store.AddressUsage) gin.H {
+	view := addressView(addr, usage)
+	return gin.H{
+		"id":             view.ID,
+		"recipient_name": view.RecipientName,
+		"phone":          view.Phone,
+		"address_line1":  view.AddressLine1,
+		"address_line2":  view.AddressLine2,
+		"city":           view.City,
+		"region":         view.Region,
+		"country":        view.Country,
+		"postal_code":    view.PostalCode,
+		"type":           view.Type,
+		"is_default":     view.IsDefault,
+	}
+}
+
+// usageAddressID returns the address the :id of the request names. The
+// endpoints also take the IDs their addresses had before the address book,
+// which the store maps to the addresses they became. It answers the request
+// itself when it fails.
+func usageAddressID(c *gin.Context, addresses store.AddressStore, usage store.AddressUsage) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		problem.Respond(c, problem.BadRequest("Invalid address ID"))
+		return 0, false
+	}
+	id, err = addresses.ResolveLegacyID(c.Request.Context(), usage, id)
+	if err != nil {
+		respondError(c, err, "Error fetching address", "usage", usage)
+		return 0, false
+	}
+	return id, true
+}
+
+func addUsageAddress(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return openapi.Accepts[AddressInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		var input AddressInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		addr := models.Address{
+			UserID:     userID,
+			IsShipping: usage == store.UsageShipping,
+			IsBilling:  usage == store.UsageBilling,
+		}
+		input.IsShipping, input.IsBilling = nil, nil
+		if err := input.apply(&addr); invalidAddress(c, err) {
+			return
+		}
+		addr, err := addresses.Create(c.Request.Context(), addr)
+		if err != nil {
+			respondError(c, err, "Error inserting address", "usage", usage)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    usageLabel[usage] + " address added",
+			"address_id": addr.ID,
+			"is_default": addressView(addr, usage).IsDefault,
+			"type":       input.Type,
+		})
+	})
+}
+
+func getUsageAddresses(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		list, err := addresses.List(c.Request.Context(), userID, usage)
+		if err != nil {
+			respondError(c, err, "Error fetching addresses", "usage", usage)
+			return
+		}
+
+		var result []gin.H
+		for _, addr := range list {
+			result = append(result, usageAddressJSON(addr, usage))
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   usageLabel[usage] + " addresses retrieved",
+			"addresses": result,
+		})
+	}
+}
+
+func getUsageAddress(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		addressID, ok := usageAddressID(c, addresses, usage)
+		if !ok {
+			return
+		}
+
+		addr, err := addresses.Get(c.Request.Context(), userID, addressID, usage)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound(usageLabel[usage]+" address not found"))
+			return
+		} else if err != nil {
+			respondError(c, err, "Error fetching address", "usage", usage)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"address": usageAddressJSON(addr, usage),
+		})
+	}
+}
+
+func updateUsageAddress(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return openapi.Accepts[AddressInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		addressID, ok := usageAddressID(c, addresses, usage)
+		if !ok {
+			return
+		}
+
+		var input AddressInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		ctx := c.Request.Context()
+		addr, err := addresses.Get(ctx, userID, addressID, usage)
+		if err == nil {
+			// the view only edits the address itself, never its usages
+			input.IsShipping, input.IsBilling = nil, nil
+			if err = input.apply(&addr); err == nil {
+				err = addresses.Update(ctx, userID, addr)
+			}
+		}
+		if err != nil {
+			if invalidAddress(c, err) {
+				return
+			}
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound(usageLabel[usage]+" address not found"))
+				return
+			}
+			respondError(c, err, "Error updating address", "usage", usage)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": usageLabel[usage] + " address updated"})
+	})
+}
+
+// deleteUsageAddress takes the address out of the view; it stays in the
+// address book while it is still used for something else.
+func deleteUsageAddress(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		addressID, ok := usageAddressID(c, addresses, usage)
+		if !ok {
+			return
+		}
+
+		if err := addresses.RemoveUsage(c.Request.Context(), userID, addressID, usage); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound(usageLabel[usage]+" address not found"))
+				return
+			}
+			respondError(c, err, "Error deleting address", "usage", usage)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": usageLabel[usage] + " address deleted"})
+	}
+}
+
+func setDefaultUsageAddress(addresses store.AddressStore, usage store.AddressUsage) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		addressID, ok := usageAddressID(c, addresses, usage)
+		if !ok {
+			return
+		}
+
+		if err := addresses.SetDefault(c.Request.Context(), userID, addressID, usage); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Address not found or not owned by user"))
+				return
+			}
+			respondError(c, err, "Error setting default address", "usage", usage)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":    usageLabel[usage] + " address set as default",
+			"address_id": addressID,
+		})
+	}
+}
+
+func AddShippingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return addUsageAddress(addresses, store.UsageShipping)
+}
+
+func GetShippingAddresses(addresses store.AddressStore) gin.HandlerFunc {
+	return getUsageAddresses(addresses, store.UsageShipping)
+}
+
+func GetSingleShippingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return getUsageAddress(addresses, store.UsageShipping)
+}
+
+func UpdateShippingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return updateUsageAddress(addresses, store.UsageShipping)
+}
+
+func DeleteShippingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return deleteUsageAddress(addresses, store.UsageShipping)
+}
+
+func SetDefaultShippingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return setDefaultUsageAddress(addresses, store.UsageShipping)
+}
+
+func AddBillingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return addUsageAddress(addresses, store.UsageBilling)
+}
+
+func GetBillingAddresses(addresses store.AddressStore) gin.HandlerFunc {
+	return getUsageAddresses(addresses, store.UsageBilling)
+}
+
+func GetSingleBillingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return getUsageAddress(addresses, store.UsageBilling)
+}
+
+func UpdateBillingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return updateUsageAddress(addresses, store.UsageBilling)
+}
+
+func DeleteBillingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return deleteUsageAddress(addresses, store.UsageBilling)
+}
+
+func SetDefaultBillingAddress(addresses store.AddressStore) gin.HandlerFunc {
+	return setDefaultUsageAddress(addresses, store.UsageBilling)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+	"my-api/store"
+	"my-api/store/memory"
+)
+
+func TestAddresses(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/user/address", "user", AddAddress(e.Stores.Addresses))
+	e.route(http.MethodGet, "/user/address/:id", "user", GetAddressByID(e.Stores.Addresses))
+	e.route(http.MethodDelete, "/user/address/:id", "user", DeleteAddress(e.Stores.Addresses))
+	token := e.User().Token
+	otherToken := e.User().Token
+
+	type added struct {
+		AddressID int `json:"address_id"`
+		Address   models.Address
+	}
+	first := testutil.Expect[added](t, e.Do(http.MethodPost, "/user/address", token, map[string]any{
+		"address_line1": "1 Main St", "city": "Berlin", "country": "Germany", "postal_code": "10115", "type": "home", "is_shipping": true,
+	}), http.StatusOK)
+	// the country is normalized and the first shipping address is the default
+	if first.Address.Country != "DE" || !first.Address.IsDefaultShipping {
+		t.Errorf("first address %+v, want a default shipping address in DE", first.Address)
+	}
+	second := testutil.Expect[added](t, e.Do(http.MethodPost, "/user/address", token, map[string]any{
+		"address_line1": "2 Side St", "city": "Berlin", "country": "DE", "postal_code": "10117", "type": "office", "is_shipping": true,
+	}), http.StatusOK)
+	if second.Address.IsDefaultShipping {
+		t.Errorf("second address %+v is the default too", second.Address)
+	}
+
+	p := testutil.ExpectProblem(t, e.Do(http.MethodPost, "/user/address", token, map[string]any{
+		"address_line1": "3 Main St", "city": "Berlin", "country": "DE", "postal_code": "ABC", "type": "home",
+	}), http.StatusBadRequest, "validation_failed")
+	if len(p.Errors) != 1 || p.Errors[0].Field != "postal_code" {
+		t.Errorf("errors of an invalid postal code %+v", p.Errors)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/user/address", token, map[string]any{
+		"address_line1": "3 Main St", "city": "Berlin", "country": "Atlantis", "type": "home",
+	}), http.StatusBadRequest, "validation_failed")
+
+	// the addresses of other users are not found
+	path := fmt.Sprintf("/user/address/%d", first.AddressID)
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, path, otherToken, nil), http.StatusNotFound, "not_found")
+	testutil.ExpectProblem(t, e.Do(http.MethodDelete, path, otherToken, nil), http.StatusNotFound, "not_found")
+	got := testutil.Expect[struct{ Address models.Address }](t, e.Do(http.MethodGet, path, token, nil), http.StatusOK)
+	if got.Address.ID != first.AddressID || got.Address.City != "Berlin" {
+		t.Errorf("address %+v, want %d", got.Address, first.AddressID)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/address/abc", token, nil), http.StatusBadRequest, "invalid_request")
+}
+
+// The shipping and billing endpoints still find the addresses by the IDs
+// they had in the tables the address book replaced.
+func TestLegacyUsageAddressIDs(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodGet, "/user/shipping-address/:id", "user", GetSingleShippingAddress(e.Stores.Addresses))
+	e.route(http.MethodGet, "/user/billing-address/:id", "user", GetSingleBillingAddress(e.Stores.Addresses))
+	u := e.User()
+
+	addr, err := e.Stores.Addresses.Create(context.Background(), models.Address{UserID: u.ID, AddressLine1: "1 Main St",
+		City: "Berlin", Country: "DE", PostalCode: "10115", Type: "home", IsShipping: true, IsBilling: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the address was shipping address 7 and billing address 3
+	legacy := e.Stores.Addresses.(*memory.AddressStore)
+	legacy.AddLegacyID(store.UsageShipping, 7, addr.ID)
+	legacy.AddLegacyID(store.UsageBilling, 3, addr.ID)
+
+	for _, path := range []string{"/user/shipping-address/7", "/user/billing-address/3",
+		fmt.Sprintf("/user/shipping-address/%d", addr.ID)} {
+		got := testutil.Expect[struct{ Address struct{ ID int } }](t, e.Do(http.MethodGet, path, u.Token, nil), http.StatusOK)
+		if got.Address.ID != addr.ID {
+			t.Errorf("%s: address %d, want %d", path, got.Address.ID, addr.ID)
+		}
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/billing-address/7", u.Token, nil), http.StatusNotFound, "not_found")
+}
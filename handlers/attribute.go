@@ -1,9 +1,14 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,42 +22,24 @@ type AttributeInput struct {
 	Status        int    `json:"status" binding:"required"`
 }
 
-type AttributeValue struct {
-	ID          int    `json:"id"`
-	AttributeID int    `json:"attribute_id"`
-	Value       string `json:"value"`
-	Status      int    `json:"status"`
-}
-
-type Attribute struct {
-	ID              int              `json:"id"`
-	AttributeName   string           `json:"attribute_name"`
-	Status          int              `json:"status"`
-	AttributeValues []AttributeValue `json:"attribute_values"`
-}
-
 // ==========================
 // Create Attribute
 // ==========================
 
-func CreateAttribute(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func CreateAttribute(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[AttributeInput](func(c *gin.Context) {
 		var input AttributeInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		var attributeID int
-		err := db.QueryRow(`
-			INSERT INTO attributes (attribute_name, status)
-			VALUES ($1, $2)
-			RETURNING id
-		`, input.AttributeName, input.Status).Scan(&attributeID)
-
+		attributeID, err := catalog.CreateAttribute(c.Request.Context(), models.Attribute{
+			AttributeName: input.AttributeName,
+			Status:        input.Status,
+		})
 		if err != nil {
-			log.Println("Error creating attribute:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error creating attribute")
 			return
 		}
 
@@ -61,62 +48,18 @@ func CreateAttribute(db *sql.DB) gin.HandlerFunc {
 			"attribute_id":   attributeID,
 			"attribute_name": input.AttributeName,
 		})
-	}
+	})
 }
 
 // ==========================
 // Get All Attributes + Values
 // ==========================
 
-func GetAllAttributes(db *sql.DB) gin.HandlerFunc {
+func GetAllAttributes(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Fetch all attributes
-		attrRows, err := db.Query(`SELECT id, attribute_name, status FROM attributes WHERE status = 1`)
+		attributes, err := catalog.ListAttributes(c.Request.Context())
 		if err != nil {
-			log.Println("Error fetching attributes:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		defer attrRows.Close()
-
-		var attributes []Attribute
-		for attrRows.Next() {
-			var attr Attribute
-			if err := attrRows.Scan(&attr.ID, &attr.AttributeName, &attr.Status); err != nil {
-				log.Println("Error scanning attribute:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-
-			// Fetch attribute values for this attribute
-			valueRows, err := db.Query(`
-				SELECT id, attribute_id, value, status
-				FROM attribute_values
-				WHERE attribute_id = $1 AND status = 1
-			`, attr.ID)
-			if err != nil {
-				log.Println("Error fetching attribute values:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-
-			for valueRows.Next() {
-				var val AttributeValue
-				if err := valueRows.Scan(&val.ID, &val.AttributeID, &val.Value, &val.Status); err != nil {
-					log.Println("Error scanning attribute value:", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-					return
-				}
-				attr.AttributeValues = append(attr.AttributeValues, val)
-			}
-			valueRows.Close()
-
-			attributes = append(attributes, attr)
-		}
-
-		if err := attrRows.Err(); err != nil {
-			log.Println("Error iterating attributes:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error fetching attributes")
 			return
 		}
 
@@ -130,48 +73,22 @@ func GetAllAttributes(db *sql.DB) gin.HandlerFunc {
 // Get Single Attribute By ID
 // ==========================
 
-func GetAttributeByID(db *sql.DB) gin.HandlerFunc {
+func GetAttributeByID(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		attributeID := c.Param("id")
-
-		var attribute Attribute
-		err := db.QueryRow(`
-			SELECT id, attribute_name, status
-			FROM attributes
-			WHERE id = $1 AND status = 1
-		`, attributeID).Scan(&attribute.ID, &attribute.AttributeName, &attribute.Status)
-
+		attributeID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Attribute not found"})
-				return
-			}
-			log.Println("Error fetching attribute:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			problem.Respond(c, problem.BadRequest("Invalid attribute ID"))
 			return
 		}
 
-		// Fetch attribute values for the attribute
-		valueRows, err := db.Query(`
-			SELECT id, attribute_id, value, status
-			FROM attribute_values
-			WHERE attribute_id = $1 AND status = 1
-		`, attribute.ID)
+		attribute, err := catalog.GetAttribute(c.Request.Context(), attributeID)
 		if err != nil {
-			log.Println("Error fetching attribute values:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		defer valueRows.Close()
-
-		for valueRows.Next() {
-			var val AttributeValue
-			if err := valueRows.Scan(&val.ID, &val.AttributeID, &val.Value, &val.Status); err != nil {
-				log.Println("Error scanning attribute value:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute not found"))
 				return
 			}
-			attribute.AttributeValues = append(attribute.AttributeValues, val)
+			respondError(c, err, "Error fetching attribute")
+			return
 		}
 
 		c.JSON(http.StatusOK, attribute)
@@ -182,25 +99,31 @@ func GetAttributeByID(db *sql.DB) gin.HandlerFunc {
 // Update Attribute
 // ==========================
 
-func UpdateAttribute(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		attributeID := c.Param("id")
+func UpdateAttribute(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[AttributeInput](func(c *gin.Context) {
+		attributeID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid attribute ID"))
+			return
+		}
 
 		var input AttributeInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		_, err := db.Exec(`
-			UPDATE attributes
-			SET attribute_name = $1, status = $2
-			WHERE id = $3
-		`, input.AttributeName, input.Status, attributeID)
-
+		err = catalog.UpdateAttribute(c.Request.Context(), models.Attribute{
+			ID:            attributeID,
+			AttributeName: input.AttributeName,
+			Status:        input.Status,
+		})
 		if err != nil {
-			log.Println("Error updating attribute:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute not found"))
+				return
+			}
+			respondError(c, err, "Error updating attribute")
 			return
 		}
 
@@ -209,26 +132,27 @@ func UpdateAttribute(db *sql.DB) gin.HandlerFunc {
 			"attribute_name": input.AttributeName,
 			"status":         input.Status,
 		})
-	}
+	})
 }
 
 // ==========================
 // Delete (Soft Delete) Attribute
 // ==========================
 
-func DeleteAttribute(db *sql.DB) gin.HandlerFunc {
+func DeleteAttribute(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		attributeID := c.Param("id")
-
-		_, err := db.Exec(`
-			UPDATE attributes
-			SET status = 0
-			WHERE id = $1
-		`, attributeID)
-
+		attributeID, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			log.Println("Error deleting attribute:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			problem.Respond(c, problem.BadRequest("Invalid attribute ID"))
+			return
+		}
+
+		if err := catalog.DeleteAttribute(c.Request.Context(), attributeID); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute not found"))
+				return
+			}
+			respondError(c, err, "Error deleting attribute")
 			return
 		}
 
@@ -2,12 +2,16 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
 // AttributeValueInput for creating or updating attribute values
@@ -17,58 +21,28 @@ type AttributeValueInput struct {
 	Status      int    `json:"status" binding:"required"`
 }
 
-// AttributeValueResponse represents a single attribute value
-type AttributeValueResponse struct {
-	ID          int    `json:"id"`
-	AttributeID int    `json:"attribute_id"`
-	Value       string `json:"value"`
-	Status      int    `json:"status"`
-}
-
 // CreateAttributeValue creates a new attribute value
-// CreateAttributeValue creates a new attribute value
-func CreateAttributeValue(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func CreateAttributeValue(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[AttributeValueInput](func(c *gin.Context) {
 		var input AttributeValueInput
 
 		// Bind JSON input
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-			return
-		}
-
-		// Step 1: Check if attribute_id exists
-		var exists bool
-		err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM attributes WHERE id = $1)`, input.AttributeID).Scan(&exists)
-		if err != nil {
-			log.Println("Error checking attribute_id existence:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-
-		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute_id, attribute does not exist"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		// Step 2: Insert attribute value
-		var valueID int
-		err = db.QueryRow(`
-			INSERT INTO attribute_values (attribute_id, value, status)
-			VALUES ($1, $2, $3)
-			RETURNING id
-		`, input.AttributeID, input.Value, input.Status).Scan(&valueID)
-
+		valueID, err := catalog.CreateAttributeValue(c.Request.Context(), models.AttributeValue{
+			AttributeID: input.AttributeID,
+			Value:       input.Value,
+			Status:      input.Status,
+		})
 		if err != nil {
-			// Check if foreign key error
-			if pgErr, ok := err.(*pq.Error); ok {
-				if pgErr.Code == "23503" { // foreign_key_violation
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute_id"})
-					return
-				}
+			if errors.Is(err, store.ErrInvalidReference) {
+				problem.Respond(c, problem.InvalidReference("attribute_id", "Invalid attribute_id, attribute does not exist"))
+				return
 			}
-			log.Println("Error creating attribute value:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error creating attribute value")
 			return
 		}
 
@@ -78,36 +52,23 @@ func CreateAttributeValue(db *sql.DB) gin.HandlerFunc {
 			"attribute_id": input.AttributeID,
 			"value":        input.Value,
 		})
-	}
+	})
 }
 
 // GetAllAttributeValues retrieves all attribute values for a given attribute ID
-func GetAllAttributeValues(db *sql.DB) gin.HandlerFunc {
+func GetAllAttributeValues(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		attributeID := c.Param("attribute_id")
-
-		rows, err := db.Query(`
-			SELECT id, attribute_id, value, status
-			FROM attribute_values
-			WHERE attribute_id = $1
-		`, attributeID)
+		attributeID, err := strconv.Atoi(c.Param("attribute_id"))
 		if err != nil {
-			log.Println("Error fetching attribute values:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attribute values"})
+			problem.Respond(c, problem.BadRequest("Invalid attribute ID"))
 			return
 		}
-		defer rows.Close()
 
-		var attributeValues []AttributeValueResponse
-
-		for rows.Next() {
-			var av AttributeValueResponse
-			if err := rows.Scan(&av.ID, &av.AttributeID, &av.Value, &av.Status); err != nil {
-				log.Println("Error scanning attribute value:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan attribute values"})
-				return
-			}
-			attributeValues = append(attributeValues, av)
+		attributeValues, err := catalog.ListAttributeValues(c.Request.Context(), attributeID)
+		if err != nil {
+			requestLogger(c).Error("Error fetching attribute values", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch attribute values"))
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -117,23 +78,22 @@ func GetAllAttributeValues(db *sql.DB) gin.HandlerFunc {
 }
 
 // GetAttributeValue retrieves a single attribute value by ID
-func GetAttributeValue(db *sql.DB) gin.HandlerFunc {
+func GetAttributeValue(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
-		var av AttributeValueResponse
-		err := db.QueryRow(`
-			SELECT id, attribute_id, value, status
-			FROM attribute_values
-			WHERE id = $1
-		`, id).Scan(&av.ID, &av.AttributeID, &av.Value, &av.Status)
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Attribute value not found"})
+			problem.Respond(c, problem.BadRequest("Invalid attribute value ID"))
+			return
+		}
+
+		av, err := catalog.GetAttributeValue(c.Request.Context(), id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute value not found"))
 				return
 			}
-			log.Println("Error fetching attribute value:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attribute value"})
+			requestLogger(c).Error("Error fetching attribute value", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch attribute value"))
 			return
 		}
 
@@ -141,60 +101,63 @@ func GetAttributeValue(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// UpdateAttributeValue updates an existing attribute value
-func UpdateAttributeValue(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id := c.Param("id")
+type AttributeValueUpdateInput struct {
+	Value  string `json:"value" binding:"required"`
+	Status int    `json:"status" binding:"required"`
+}
 
-		var input struct {
-			Value  string `json:"value" binding:"required"`
-			Status int    `json:"status" binding:"required"`
-		}
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+// UpdateAttributeValue updates an existing attribute value
+func UpdateAttributeValue(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[AttributeValueUpdateInput](func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid attribute value ID"))
 			return
 		}
 
-		result, err := db.Exec(`
-			UPDATE attribute_values
-			SET value = $1, status = $2
-			WHERE id = $3
-		`, input.Value, input.Status, id)
-		if err != nil {
-			log.Println("Error updating attribute value:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attribute value"})
+		var input AttributeValueUpdateInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Attribute value not found"})
+		err = catalog.UpdateAttributeValue(c.Request.Context(), models.AttributeValue{
+			ID:     id,
+			Value:  input.Value,
+			Status: input.Status,
+		})
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute value not found"))
+				return
+			}
+			requestLogger(c).Error("Error updating attribute value", "error", err)
+			problem.Respond(c, problem.Internal("Failed to update attribute value"))
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Attribute value updated successfully",
 		})
-	}
+	})
 }
 
 // DeleteAttributeValue deletes an attribute value by ID
-func DeleteAttributeValue(db *sql.DB) gin.HandlerFunc {
+func DeleteAttributeValue(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
-		result, err := db.Exec(`
-			DELETE FROM attribute_values WHERE id = $1
-		`, id)
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			log.Println("Error deleting attribute value:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attribute value"})
+			problem.Respond(c, problem.BadRequest("Invalid attribute value ID"))
 			return
 		}
 
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Attribute value not found"})
+		if err := catalog.DeleteAttributeValue(c.Request.Context(), id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Attribute value not found"))
+				return
+			}
+			requestLogger(c).Error("Error deleting attribute value", "error", err)
+			problem.Respond(c, problem.Internal("Failed to delete attribute value"))
 			return
 		}
 
@@ -0,0 +1,55 @@
+// handlers/audit.go
+package handlers
+
+import (
+	"my-api/problem"
+	"net/http"
+	"strconv"
+
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminGetAuditLog lists admin changes newest first, optionally filtered by
+// ?actor_id=, action, entity_type, entity_id and from / to (RFC 3339 or
+// YYYY-MM-DD, to inclusive for dates) (admin only)
+func AdminGetAuditLog(audit store.AuditStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parsePagination(c)
+		actorID, _ := strconv.Atoi(c.Query("actor_id"))
+		entityID, _ := strconv.Atoi(c.Query("entity_id"))
+
+		from, ok := parseDateBound(c, "from", false)
+		if !ok {
+			problem.Respond(c, problem.Invalid("from", "datetime", "Invalid from, use YYYY-MM-DD or RFC 3339"))
+			return
+		}
+		to, ok := parseDateBound(c, "to", true)
+		if !ok {
+			problem.Respond(c, problem.Invalid("to", "datetime", "Invalid to, use YYYY-MM-DD or RFC 3339"))
+			return
+		}
+
+		entries, err := audit.List(c.Request.Context(), store.AuditFilter{
+			ActorID:    actorID,
+			Action:     c.Query("action"),
+			EntityType: c.Query("entity_type"),
+			EntityID:   entityID,
+			From:       from,
+			To:         to,
+			Limit:      limit,
+			Offset:     offset,
+		})
+		if err != nil {
+			respondError(c, err, "Error querying audit log")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"entries": entries,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
@@ -2,16 +2,17 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"errors"
 	"net/http"
-	"strings"
 
+	"my-api/metrics"
 	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 	"my-api/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 	"github.com/mssola/useragent"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -24,46 +25,66 @@ type RegisterInput struct {
 	Image       *string `json:"image,omitempty"`
 }
 
-func Register(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func Register(users store.UserStore) gin.HandlerFunc {
+	return openapi.Accepts[RegisterInput](func(c *gin.Context) {
 		var input RegisterInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 		if err != nil {
-			log.Println("Error hashing password:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error hashing password")
 			return
 		}
 
 		role := "user" // Always default to user
 
-		query := `INSERT INTO users (username, email, password, role, phone_number, image, is_verified, is_blocked, created_at, updated_at)
-		          VALUES ($1, $2, $3, $4, $5, $6, FALSE, FALSE, NOW(), NOW()) RETURNING id`
-		var userID int
-		err = db.QueryRow(query, input.Username, input.Email, string(hashedPassword), role, input.PhoneNumber, input.Image).Scan(&userID)
+		userID, err := users.Create(c.Request.Context(), models.User{
+			Username:    input.Username,
+			Email:       input.Email,
+			Role:        role,
+			PhoneNumber: input.PhoneNumber,
+			Image:       input.Image,
+		}, string(hashedPassword))
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-				if strings.Contains(pqErr.Detail, "username") {
-					c.JSON(http.StatusConflict, gin.H{"error": "Username already exists"})
+			var conflict *store.ConflictError
+			if errors.As(err, &conflict) {
+				if conflict.Field == "username" {
+					problem.Respond(c, problem.AlreadyExists("username", "Username already exists"))
 				} else {
-					c.JSON(http.StatusConflict, gin.H{"error": "Email already exists"})
+					problem.Respond(c, problem.AlreadyExists("email", "Email already exists"))
 				}
 				return
 			}
-			log.Println("Error inserting user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error inserting user")
 			return
 		}
 
+		metrics.Registrations.Inc()
 		c.JSON(http.StatusOK, gin.H{
 			"message": "User created",
 			"user_id": userID,
 			"role":    role,
 		})
+	})
+}
+
+// newLoginSession describes the client of the request as a session of userID.
+func newLoginSession(c *gin.Context, userID int) models.LoginSession {
+	ua := useragent.New(c.GetHeader("User-Agent"))
+	browser, browserVersion := ua.Browser()
+	deviceInfo := ua.Model()
+	if deviceInfo == "" {
+		deviceInfo = "Unknown"
+	}
+	return models.LoginSession{
+		UserID:    userID,
+		Browser:   browser + " " + browserVersion,
+		OS:        ua.OS(),
+		Device:    deviceInfo,
+		IPAddress: c.ClientIP(),
 	}
 }
 
@@ -72,155 +93,132 @@ type LoginInput struct {
 	Password   string `json:"password" binding:"required"`
 }
 
-func Login(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func Login(users store.UserStore, sessions store.SessionStore, tokens *utils.TokenService) gin.HandlerFunc {
+	return openapi.Accepts[LoginInput](func(c *gin.Context) {
 		var input LoginInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		var user struct {
-			ID        int
-			Username  string
-			Email     string
-			Password  string
-			Role      string
-			IsBlocked bool
-		}
-		err := db.QueryRow(`
-			SELECT id, username, email, password, role, is_blocked
-			FROM users
-			WHERE username = $1 OR email = $1
-		`, input.Identifier).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.IsBlocked)
+		user, err := users.CredentialsByIdentifier(c.Request.Context(), input.Identifier)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			if errors.Is(err, store.ErrNotFound) {
+				metrics.Logins.WithLabelValues(metrics.Failure).Inc()
+				problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials"))
 				return
 			}
-			log.Println("Error querying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error querying user")
 			return
 		}
 
 		if user.IsBlocked {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Account is blocked"})
+			metrics.Logins.WithLabelValues(metrics.Blocked).Inc()
+			problem.Respond(c, problem.New(http.StatusForbidden, problem.CodeAccountBlocked, "Account is blocked"))
 			return
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+			metrics.Logins.WithLabelValues(metrics.Failure).Inc()
+			problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidCredentials, "Invalid credentials"))
 			return
 		}
 
-		ua := useragent.New(c.GetHeader("User-Agent"))
-		browser, browserVersion := ua.Browser()
-		browserInfo := browser + " " + browserVersion
-		osInfo := ua.OS()
-		deviceInfo := ua.Model()
-		if deviceInfo == "" {
-			deviceInfo = "Unknown"
-		}
-		ipAddress := c.ClientIP()
-
-		_, err = db.Exec(`
-			INSERT INTO login_sessions (user_id, browser, os, device, ip_address, login_at)
-			VALUES ($1, $2, $3, $4, $5, NOW())
-		`, user.ID, browserInfo, osInfo, deviceInfo, ipAddress)
+		_, err = sessions.Create(c.Request.Context(), newLoginSession(c, user.ID))
 		if err != nil {
-			log.Println("Error storing login session:", err)
+			requestLogger(c).Error("Error storing login session", "error", err)
 		}
 
-		accessToken, err := utils.GenerateAccessToken(uint(user.ID), user.Username, user.Email, user.Password, user.Role)
+		accessToken, err := tokens.GenerateAccessToken(uint(user.ID), user.Username, user.Email, user.PasswordHash, user.Role)
 		if err != nil {
-			log.Println("Error generating access token:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error generating access token")
 			return
 		}
-		refreshToken, err := utils.GenerateRefreshToken(uint(user.ID), user.Username, user.Email, user.Password, user.Role)
+		refreshToken, err := tokens.GenerateRefreshToken(uint(user.ID), user.Username, user.Email, user.PasswordHash, user.Role)
 		if err != nil {
-			log.Println("Error generating refresh token:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error generating refresh token")
 			return
 		}
 
+		metrics.Logins.WithLabelValues(metrics.Success).Inc()
 		c.JSON(http.StatusOK, gin.H{
 			"message":       "Login successful",
 			"access_token":  accessToken,
 			"refresh_token": refreshToken,
 			"role":          user.Role,
 		})
-	}
+	})
 }
 
-func RefreshToken(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var input struct {
-			RefreshToken string `json:"refresh_token" binding:"required"`
-		}
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+func RefreshToken(users store.UserStore, tokens *utils.TokenService) gin.HandlerFunc {
+	return openapi.Accepts[RefreshTokenInput](func(c *gin.Context) {
+		var input RefreshTokenInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		claims, err := utils.ParseToken(input.RefreshToken)
+		claims, err := tokens.ParseToken(input.RefreshToken)
 		if err != nil || claims.Type != "refresh" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			metrics.TokenRefreshes.WithLabelValues(metrics.Failure).Inc()
+			problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid refresh token"))
 			return
 		}
 
-		var user struct {
-			ID       int
-			Username string
-			Email    string
-			Password string
-			Role     string
-		}
-		err = db.QueryRow(`
-			SELECT id, username, email, password, role
-			FROM users
-			WHERE id = $1
-		`, claims.UserID).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role)
+		user, err := users.CredentialsByID(c.Request.Context(), int(claims.UserID))
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			if errors.Is(err, store.ErrNotFound) {
+				metrics.TokenRefreshes.WithLabelValues(metrics.Failure).Inc()
+				problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "User not found"))
 				return
 			}
-			log.Println("Error querying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error querying user")
 			return
 		}
 
-		accessToken, err := utils.GenerateAccessToken(uint(user.ID), user.Username, user.Email, user.Password, user.Role)
+		accessToken, err := tokens.GenerateAccessToken(uint(user.ID), user.Username, user.Email, user.PasswordHash, user.Role)
 		if err != nil {
-			log.Println("Error generating access token:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error generating access token")
 			return
 		}
 
+		metrics.TokenRefreshes.WithLabelValues(metrics.Success).Inc()
 		c.JSON(http.StatusOK, gin.H{
 			"message":      "Token refreshed",
 			"access_token": accessToken,
 			"role":         user.Role,
 		})
-	}
+	})
 }
 
-func Logout(db *sql.DB) gin.HandlerFunc {
+func Logout(sessions store.SessionStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
-		_, err := db.Exec(`
-			DELETE FROM login_sessions
-			WHERE user_id = $1
-		`, userID)
-		if err != nil {
-			log.Println("Error deleting login sessions:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		// an admin logging out of an impersonation only ends that session,
+		// never the user's own logins
+		if claims, impersonated := currentImpersonation(c); impersonated {
+			err := sessions.Delete(c.Request.Context(), userID, claims.SessionID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				respondError(c, err, "Error deleting impersonation session")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Logged out of the impersonation session",
+			})
+			return
+		}
+
+		if err := sessions.DeleteByUser(c.Request.Context(), userID); err != nil {
+			respondError(c, err, "Error deleting login sessions")
 			return
 		}
 
@@ -230,108 +228,29 @@ func Logout(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func GetUserDetails(db *sql.DB) gin.HandlerFunc {
+func GetUserDetails(users store.UserStore, addresses store.AddressStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
+		userID, exists := currentUserID(c)
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
 			return
 		}
 
-		var user models.User
-		err := db.QueryRow(`
-			SELECT id, username, email, role, phone_number, image, is_verified, is_blocked, created_at, updated_at
-			FROM users
-			WHERE id = $1
-		`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Role,
-			&user.PhoneNumber, &user.Image, &user.IsVerified, &user.IsBlocked, &user.CreatedAt, &user.UpdatedAt)
+		user, err := users.Get(c.Request.Context(), userID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
 				return
 			}
-			log.Println("Error querying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-
-		// Fetch addresses
-		rows, err := db.Query(`
-			SELECT id, user_id, address_line1, city, country, postal_code, type, created_at
-			FROM addresses
-			WHERE user_id = $1
-		`, userID)
-		if err != nil {
-			log.Println("Error querying addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error querying user")
 			return
 		}
-		defer rows.Close()
-
-		var addresses []models.Address
-		for rows.Next() {
-			var addr models.Address
-			err := rows.Scan(&addr.ID, &addr.UserID, &addr.AddressLine1, &addr.City, &addr.Country,
-				&addr.PostalCode, &addr.Type, &addr.CreatedAt)
-			if err != nil {
-				log.Println("Error scanning address:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-			addresses = append(addresses, addr)
-		}
 
-		// Fetch shipping addresses
-		shippingRows, err := db.Query(`
-			SELECT id, user_id, address_line1, city, country, postal_code, type, is_default, created_at
-			FROM shipping_addresses
-			WHERE user_id = $1
-		`, userID)
+		book, err := loadAddressBook(c.Request.Context(), addresses, userID)
 		if err != nil {
-			log.Println("Error querying shipping addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error querying addresses")
 			return
 		}
-		defer shippingRows.Close()
-
-		var shippingAddresses []models.ShippingAddress
-		for shippingRows.Next() {
-			var addr models.ShippingAddress
-			err := shippingRows.Scan(&addr.ID, &addr.UserID, &addr.AddressLine1, &addr.City, &addr.Country,
-				&addr.PostalCode, &addr.Type, &addr.IsDefault, &addr.CreatedAt)
-			if err != nil {
-				log.Println("Error scanning shipping address:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-			shippingAddresses = append(shippingAddresses, addr)
-		}
-
-		// Fetch billing addresses
-		billingRows, err := db.Query(`
-			SELECT id, user_id, address_line1, city, country, postal_code, type, is_default, created_at
-			FROM billing_addresses
-			WHERE user_id = $1
-		`, userID)
-		if err != nil {
-			log.Println("Error querying billing addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		defer billingRows.Close()
-
-		var billingAddresses []models.BillingAddress
-		for billingRows.Next() {
-			var addr models.BillingAddress
-			err := billingRows.Scan(&addr.ID, &addr.UserID, &addr.AddressLine1, &addr.City, &addr.Country,
-				&addr.PostalCode, &addr.Type, &addr.IsDefault, &addr.CreatedAt)
-			if err != nil {
-				log.Println("Error scanning billing address:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-				return
-			}
-			billingAddresses = append(billingAddresses, addr)
-		}
 
 		response := gin.H{
 			"user": gin.H{
@@ -346,9 +265,9 @@ func GetUserDetails(db *sql.DB) gin.HandlerFunc {
 				"created_at":   user.CreatedAt,
 				"updated_at":   user.UpdatedAt,
 			},
-			"addresses":          addresses,
-			"shipping_addresses": shippingAddresses,
-			"billing_addresses":  billingAddresses,
+			"addresses":          book.Addresses,
+			"shipping_addresses": book.ShippingAddresses,
+			"billing_addresses":  book.BillingAddresses,
 		}
 
 		c.JSON(http.StatusOK, response)
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+func TestRegister(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/register", "", Register(e.Stores.Users))
+	input := map[string]any{"username": "alice", "email": "alice@example.com", "password": "s3cret-enough"}
+
+	got := testutil.Expect[struct {
+		UserID int `json:"user_id"`
+		Role   string
+	}](t, e.Do(http.MethodPost, "/register", "", input), http.StatusOK)
+	if got.UserID == 0 || got.Role != "user" {
+		t.Errorf("registered %+v", got)
+	}
+
+	for field, change := range map[string]map[string]any{
+		"username": {"email": "other@example.com"},
+		"email":    {"username": "other"},
+	} {
+		body := map[string]any{"username": "alice", "email": "alice@example.com", "password": "s3cret-enough"}
+		for k, v := range change {
+			body[k] = v
+		}
+		p := testutil.ExpectProblem(t, e.Do(http.MethodPost, "/register", "", body), http.StatusConflict, "already_exists")
+		if len(p.Errors) != 1 || p.Errors[0].Field != field {
+			t.Errorf("duplicate %s: errors %+v", field, p.Errors)
+		}
+	}
+
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/register", "", map[string]any{"username": "bob", "email": "bob", "password": "short"}),
+		http.StatusBadRequest, "validation_failed")
+}
+
+func TestLogin(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/login", "", Login(e.Stores.Users, e.Stores.Sessions, e.Tokens))
+	u := e.User()
+	blocked := e.User(func(u *models.User) { u.IsBlocked = true })
+
+	for _, identifier := range []string{u.Username, u.Email} {
+		got := testutil.Expect[struct {
+			AccessToken  string `json:"access_token"`
+			RefreshToken string `json:"refresh_token"`
+		}](t, e.Do(http.MethodPost, "/login", "", map[string]any{"identifier": identifier, "password": testutil.Password}), http.StatusOK)
+		claims, err := e.Tokens.ParseToken(got.AccessToken)
+		if err != nil || int(claims.UserID) != u.ID || claims.Type != "access" {
+			t.Errorf("access token of %s: %+v, %v", identifier, claims, err)
+		}
+	}
+	sessions, err := e.Stores.Sessions.ListByUser(context.Background(), u.ID)
+	if err != nil || len(sessions) != 2 {
+		t.Errorf("login sessions %+v (%v), want 2", sessions, err)
+	}
+
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/login", "", map[string]any{"identifier": u.Username, "password": "wrong-password"}),
+		http.StatusUnauthorized, "invalid_credentials")
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/login", "", map[string]any{"identifier": "nobody", "password": testutil.Password}),
+		http.StatusUnauthorized, "invalid_credentials")
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/login", "", map[string]any{"identifier": blocked.Username, "password": testutil.Password}),
+		http.StatusForbidden, "account_blocked")
+}
+
+// Session and GetUserDetails answer the same address book.
+func TestProfileAddressBook(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodGet, "/user/session", "user", Session(e.Stores.Users, e.Stores.Addresses, e.Stores.Sessions))
+	e.route(http.MethodGet, "/user/me", "user", GetUserDetails(e.Stores.Users, e.Stores.Addresses))
+	u := e.User()
+	adminToken := e.Admin().Token
+
+	ctx := context.Background()
+	for _, addr := range []models.Address{
+		{UserID: u.ID, AddressLine1: "1 Main St", City: "Berlin", Country: "DE", PostalCode: "10115", Type: "home", IsShipping: true, IsBilling: true},
+		{UserID: u.ID, AddressLine1: "2 Side St", City: "Berlin", Country: "DE", PostalCode: "10117", Type: "office", IsShipping: true},
+	} {
+		if _, err := e.Stores.Addresses.Create(ctx, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type book struct {
+		Addresses         []models.Address
+		ShippingAddresses []models.ShippingAddress `json:"shipping_addresses"`
+		BillingAddresses  []models.BillingAddress  `json:"billing_addresses"`
+	}
+	for _, path := range []string{"/user/session", "/user/me"} {
+		got := testutil.Expect[book](t, e.Do(http.MethodGet, path, u.Token, nil), http.StatusOK)
+		if len(got.Addresses) != 2 || len(got.ShippingAddresses) != 2 || len(got.BillingAddresses) != 1 {
+			t.Errorf("%s: %d addresses, %d shipping and %d billing, want 2, 2 and 1",
+				path, len(got.Addresses), len(got.ShippingAddresses), len(got.BillingAddresses))
+		}
+	}
+
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/me", "", nil), http.StatusUnauthorized, "unauthorized")
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/me", adminToken, nil), http.StatusForbidden, "forbidden")
+}
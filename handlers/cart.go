@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CartItemInput struct {
+	ProductID   int  `json:"product_id" binding:"required,min=1"`
+	VariationID *int `json:"variation_id,omitempty"`
+	Quantity    int  `json:"quantity" binding:"required,min=1"`
+}
+
+// fetchCartItems loads the user's cart lines and prices each line.
+func fetchCartItems(ctx context.Context, carts store.CartStore, userID int) ([]models.CartItem, error) {
+	items, err := carts.ListItems(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		items[i].LineTotal = roundMoney(items[i].UnitPrice * float64(items[i].Quantity))
+	}
+	return items, nil
+}
+
+func cartSubtotal(items []models.CartItem) float64 {
+	var subtotal float64
+	for _, item := range items {
+		subtotal += item.LineTotal
+	}
+	return roundMoney(subtotal)
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GetCart returns the current user's cart with line totals
+func GetCart(carts store.CartStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		items, err := fetchCartItems(c.Request.Context(), carts, userID)
+		if err != nil {
+			respondError(c, err, "Error querying cart items")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items":    items,
+			"subtotal": cartSubtotal(items),
+		})
+	}
+}
+
+// AddCartItem adds a product (or variation) to the cart, increasing the
+// quantity if the same line is already present
+func AddCartItem(carts store.CartStore) gin.HandlerFunc {
+	return openapi.Accepts[CartItemInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		var input CartItemInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		itemID, quantity, err := carts.AddItem(c.Request.Context(), userID, input.ProductID, input.VariationID, input.Quantity)
+		if err != nil {
+			var invalidRef *store.InvalidRefError
+			if errors.As(err, &invalidRef) {
+				problem.Respond(c, problem.InvalidReference(invalidRef.Field, "Invalid "+invalidRef.Field))
+				return
+			}
+			respondError(c, err, "Error adding cart item")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Item added to cart",
+			"item_id":  itemID,
+			"quantity": quantity,
+		})
+	})
+}
+
+type CartItemQuantityInput struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// UpdateCartItem sets the quantity of a cart line
+func UpdateCartItem(carts store.CartStore) gin.HandlerFunc {
+	return openapi.Accepts[CartItemQuantityInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		itemID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid cart item ID"))
+			return
+		}
+
+		var input CartItemQuantityInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		err = carts.UpdateItem(c.Request.Context(), userID, itemID, input.Quantity)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Cart item not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error updating cart item")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Cart item updated",
+			"item_id":  itemID,
+			"quantity": input.Quantity,
+		})
+	})
+}
+
+// RemoveCartItem deletes a line from the cart
+func RemoveCartItem(carts store.CartStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		itemID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid cart item ID"))
+			return
+		}
+
+		err = carts.RemoveItem(c.Request.Context(), userID, itemID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Cart item not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting cart item")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Cart item removed",
+			"item_id": itemID,
+		})
+	}
+}
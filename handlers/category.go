@@ -1,276 +1,258 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
-	"my-api/models"
+	"errors"
 	"net/http"
-	"time"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
-func CreateCategory(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func CreateCategory(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[models.Category](func(c *gin.Context) {
 		var category models.Category
 		if err := c.ShouldBindJSON(&category); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
+		adminID, _ := currentUserID(c)
+		category.CreatedBy, category.UpdatedBy = &adminID, &adminID
 
-		if category.CategoryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		ctx := c.Request.Context()
+		categoryID, err := catalog.CreateCategory(ctx, category)
+		if errors.Is(err, store.ErrInvalidReference) {
+			problem.Respond(c, problem.InvalidReference("parent_id", "Parent category not found"))
 			return
 		}
-		if category.CreatedBy == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "CreatedBy is required"})
+		if err != nil {
+			respondError(c, err, "Failed to insert category")
 			return
 		}
 
-		now := time.Now()
-		query := `
-            INSERT INTO categories (
-                code, category_name, category_img, image, category_visibility,
-                is_special, is_featured, is_approved, is_published, position,
-                price_visibility, status, created_by, created_at, updated_at
-            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
-            RETURNING id
-        `
-
-		var categoryID int
-		err := db.QueryRow(query,
-			category.Code, category.CategoryName, category.CategoryImg, category.Image,
-			category.CategoryVisibility, category.IsSpecial, category.IsFeatured,
-			category.IsApproved, category.IsPublished, category.Position,
-			category.PriceVisibility, category.Status, category.CreatedBy, now, now,
-		).Scan(&categoryID)
+		// the store decides the category's place in the tree
+		category, err = catalog.GetCategory(ctx, categoryID)
 		if err != nil {
-			log.Printf("Failed to insert category: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category"})
+			respondError(c, err, "Error querying category")
 			return
 		}
 
-		category.ID = categoryID
-		category.CreatedAt = now
-		category.UpdatedAt = now
-		category.ProductsCount = 0
-		category.SubCategories = []models.SubCategory{}
-
 		c.JSON(http.StatusCreated, gin.H{
 			"message":  "Category created successfully",
 			"category": category,
 		})
-	}
+	})
 }
 
-func UpdateCategory(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		id := c.Param("id")
-		var category models.Category
-		if err := c.ShouldBindJSON(&category); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "details": err.Error()})
+func UpdateCategory(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[models.Category](func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
 			return
 		}
 
-		if category.CategoryName == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+		var category models.Category
+		if err := c.ShouldBindJSON(&category); err != nil {
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
+		category.ID = id
+		adminID, _ := currentUserID(c)
+		category.UpdatedBy = &adminID
 
-		now := time.Now()
-		query := `
-            UPDATE categories SET
-                code = $1, category_name = $2, category_img = $3, image = $4,
-                category_visibility = $5, is_special = $6, is_featured = $7,
-                is_approved = $8, is_published = $9, position = $10,
-                price_visibility = $11, status = $12, updated_at = $13
-            WHERE id = $14
-            RETURNING id
-        `
-
-		var updatedID int
-		err := db.QueryRow(query,
-			category.Code, category.CategoryName, category.CategoryImg, category.Image,
-			category.CategoryVisibility, category.IsSpecial, category.IsFeatured,
-			category.IsApproved, category.IsPublished, category.Position,
-			category.PriceVisibility, category.Status, now, id,
-		).Scan(&updatedID)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		err = catalog.UpdateCategory(c.Request.Context(), category)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Category not found"))
 			return
 		}
 		if err != nil {
-			log.Printf("Error updating category: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
+			respondError(c, err, "Error updating category")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Category updated successfully",
-			"id":      updatedID,
+			"id":      id,
 		})
-	}
+	})
 }
 
-func DeleteCategory(db *sql.DB) gin.HandlerFunc {
+func DeleteCategory(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		query := `DELETE FROM categories WHERE id = $1 RETURNING id`
-		var deletedID int
-		err := db.QueryRow(query, id).Scan(&deletedID)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
+			return
+		}
+
+		err = catalog.DeleteCategory(c.Request.Context(), id)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Category not found"))
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			problem.Respond(c, problem.New(http.StatusConflict, problem.CodeInUse, "Category still has subcategories or products"))
 			return
 		}
 		if err != nil {
-			log.Printf("Error deleting category: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category"})
+			respondError(c, err, "Error deleting category")
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Category deleted successfully",
-			"id":      deletedID,
+			"id":      id,
 		})
 	}
 }
 
-func GetCategoryByID(db *sql.DB) gin.HandlerFunc {
+func GetCategoryByID(catalog store.CatalogStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		var category models.Category
-		query := `
-            SELECT id, code, category_name, category_img, image, category_visibility,
-                   is_special, is_featured, is_approved, is_published, position,
-                   price_visibility, status, created_by, created_at, updated_at
-            FROM categories WHERE id = $1`
-		err := db.QueryRow(query, id).Scan(
-			&category.ID, &category.Code, &category.CategoryName, &category.CategoryImg,
-			&category.Image, &category.CategoryVisibility, &category.IsSpecial,
-			&category.IsFeatured, &category.IsApproved, &category.IsPublished,
-			&category.Position, &category.PriceVisibility, &category.Status,
-			&category.CreatedBy, &category.CreatedAt, &category.UpdatedAt,
-		)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
+			return
+		}
+
+		category, err := catalog.GetCategory(c.Request.Context(), id)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Category not found"))
 			return
 		}
 		if err != nil {
-			log.Printf("Error querying category: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch category"})
+			respondError(c, err, "Error querying category")
 			return
 		}
 
-		// Fetch subcategories
-		subQuery := `
-            SELECT id, category_id, subcategory_name, image, status
-            FROM subcategories WHERE category_id = $1`
-		rows, err := db.Query(subQuery, id)
+		c.JSON(http.StatusOK, gin.H{"category": category})
+	}
+}
+
+func GetAllCategories(catalog store.CatalogStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categories, err := catalog.ListCategories(c.Request.Context())
 		if err != nil {
-			log.Printf("Error querying subcategories: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subcategories"})
+			respondError(c, err, "Error querying categories")
 			return
 		}
-		defer rows.Close()
 
-		category.SubCategories = []models.SubCategory{}
-		for rows.Next() {
-			var subCategory models.SubCategory
-			err := rows.Scan(
-				&subCategory.ID, &subCategory.CategoryID, &subCategory.SubCategoryName,
-				&subCategory.Image, &subCategory.Status,
-			)
-			if err != nil {
-				log.Printf("Error scanning subcategory: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process subcategories"})
-				return
-			}
-			category.SubCategories = append(category.SubCategories, subCategory)
-		}
+		c.JSON(http.StatusOK, gin.H{"categories": categoryTree(categories)})
+	}
+}
 
-		category.ProductsCount = 0 // Update with actual count if needed
+// categoryTree nests categories, ordered by depth and then position, under
+// their parents, and returns the top level.
+func categoryTree(categories []models.Category) []models.Category {
+	var roots []models.Category
+	children := make(map[int][]models.Category)
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
 
-		c.JSON(http.StatusOK, gin.H{"category": category})
+	var nest func(level []models.Category) []models.Category
+	nest = func(level []models.Category) []models.Category {
+		if level == nil {
+			return []models.Category{}
+		}
+		for i := range level {
+			level[i].Children = nest(children[level[i].ID])
+		}
+		return level
 	}
+	return nest(roots)
 }
 
-func GetAllCategories(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		query := `
-            SELECT id, code, category_name, category_img, image, category_visibility,
-                   is_special, is_featured, is_approved, is_published, position,
-                   price_visibility, status, created_by, created_at, updated_at
-            FROM categories`
-		rows, err := db.Query(query)
+// CategoryMoveInput places a category in the tree: under ParentID (null for
+// the top level), at Position among its siblings (0 or omitted for last).
+type CategoryMoveInput struct {
+	ParentID *int `json:"parent_id"`
+	Position int  `json:"position" binding:"min=0"`
+}
+
+// MoveCategory moves a category, with everything below it, to another
+// parent or to another position among its siblings (admin only)
+func MoveCategory(catalog store.CatalogStore) gin.HandlerFunc {
+	return openapi.Accepts[CategoryMoveInput](func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
 		if err != nil {
-			log.Printf("Error querying categories: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
 			return
 		}
-		defer rows.Close()
 
-		var categories []models.Category
-		categoryIDs := []int{}
-		for rows.Next() {
-			var category models.Category
-			err := rows.Scan(
-				&category.ID, &category.Code, &category.CategoryName, &category.CategoryImg,
-				&category.Image, &category.CategoryVisibility, &category.IsSpecial,
-				&category.IsFeatured, &category.IsApproved, &category.IsPublished,
-				&category.Position, &category.PriceVisibility, &category.Status,
-				&category.CreatedBy, &category.CreatedAt, &category.UpdatedAt,
-			)
-			if err != nil {
-				log.Printf("Error scanning category: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process categories"})
-				return
-			}
-			category.SubCategories = []models.SubCategory{}
-			category.ProductsCount = 0
-			categories = append(categories, category)
-			categoryIDs = append(categoryIDs, category.ID)
+		var input CategoryMoveInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
 		}
 
-		if err = rows.Err(); err != nil {
-			log.Printf("Error iterating categories: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		ctx := c.Request.Context()
+		err = catalog.MoveCategory(ctx, id, input.ParentID, input.Position)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Category not found"))
+			return
+		}
+		if errors.Is(err, store.ErrInvalidReference) {
+			problem.Respond(c, problem.InvalidReference("parent_id", "Parent category not found"))
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			problem.Respond(c, problem.Conflict("A category cannot be moved below itself"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error moving category")
 			return
 		}
 
-		// Fetch subcategories for all categories
-		if len(categoryIDs) > 0 {
-			subQuery := `
-                SELECT id, category_id, subcategory_name, image, status
-                FROM subcategories WHERE category_id = ANY($1)`
-			rows, err := db.Query(subQuery, pq.Array(categoryIDs))
-			if err != nil {
-				log.Printf("Error querying subcategories: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subcategories"})
-				return
-			}
-			defer rows.Close()
+		category, err := catalog.GetCategory(ctx, id)
+		if err != nil {
+			respondError(c, err, "Error querying category")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Category moved successfully",
+			"category": category,
+		})
+	})
+}
 
-			subCategoriesMap := make(map[int][]models.SubCategory)
-			for rows.Next() {
-				var subCategory models.SubCategory
-				err := rows.Scan(
-					&subCategory.ID, &subCategory.CategoryID, &subCategory.SubCategoryName,
-					&subCategory.Image, &subCategory.Status,
-				)
-				if err != nil {
-					log.Printf("Error scanning subcategory: %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process subcategories"})
-					return
-				}
-				subCategoriesMap[subCategory.CategoryID] = append(subCategoriesMap[subCategory.CategoryID], subCategory)
-			}
+// GetCategoryProducts lists the products of a category and of every
+// category below it
+func GetCategoryProducts(catalog store.CatalogStore, products store.ProductStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
+			return
+		}
 
-			for i := range categories {
-				categories[i].SubCategories = subCategoriesMap[categories[i].ID]
+		ctx := c.Request.Context()
+		if _, err := catalog.GetCategory(ctx, id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Category not found"))
+				return
 			}
+			respondError(c, err, "Error querying category")
+			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"categories": categories})
+		list, err := products.ListInCategory(ctx, id)
+		if err != nil {
+			respondError(c, err, "Error querying products")
+			return
+		}
+		if list == nil {
+			list = []models.Product{}
+		}
+		c.JSON(http.StatusOK, gin.H{"products": list})
 	}
 }
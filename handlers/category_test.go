@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+func TestMoveCategory(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/admin/categories/:id/move", "admin", MoveCategory(e.Stores.Catalog))
+	token := e.Admin().Token
+	top := e.Category()
+	child := e.Category(func(c *models.Category) { c.ParentID = &top.ID })
+	grandchild := e.Category(func(c *models.Category) { c.ParentID = &child.ID })
+	move := func(id int, parentID any) *httptest.ResponseRecorder {
+		return e.Do(http.MethodPost, fmt.Sprintf("/admin/categories/%d/move", id), token, map[string]any{"parent_id": parentID})
+	}
+
+	// a category cannot end up below itself
+	for _, parentID := range []int{top.ID, child.ID, grandchild.ID} {
+		testutil.ExpectProblem(t, move(top.ID, parentID), http.StatusConflict, "conflict")
+	}
+	unknown := grandchild.ID + 1000
+	testutil.ExpectProblem(t, move(child.ID, unknown), http.StatusBadRequest, "invalid_reference")
+	testutil.ExpectProblem(t, move(unknown, nil), http.StatusNotFound, "not_found")
+
+	// moving to the top level takes the subtree along
+	moved := testutil.Expect[struct{ Category models.Category }](t, move(child.ID, nil), http.StatusOK).Category
+	if moved.ParentID != nil || moved.Depth != 0 {
+		t.Errorf("moved category %+v, want it at the top level", moved)
+	}
+	got, err := e.Stores.Catalog.GetCategory(context.Background(), grandchild.ID)
+	if err != nil || got.Depth != 1 || len(got.Breadcrumbs) != 1 || got.Breadcrumbs[0].ID != child.ID {
+		t.Errorf("category below the moved one %+v (%v), want it below %d only", got, err, child.ID)
+	}
+}
@@ -1,13 +1,19 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
+	"encoding/base64"
+	"errors"
 	"net/http"
+	"slices"
 	"strconv"
-	_ "time"
+	"strings"
+	"time"
 
+	"my-api/mail"
 	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -35,38 +41,33 @@ type AdminUserUpdateInput struct {
 }
 
 // AdminCreateUser creates a new user (admin only)
-func AdminCreateUser(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func AdminCreateUser(users store.UserStore) gin.HandlerFunc {
+	return openapi.Accepts[AdminUserInput](func(c *gin.Context) {
 		var input AdminUserInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
 		// Hash password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 		if err != nil {
-			log.Println("Error hashing password:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error hashing password")
 			return
 		}
 
-		query := `
-			INSERT INTO users (username, email, role, password, phone_number, image, is_verified, is_blocked, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
-			RETURNING id
-		`
-		var userID int
-		err = db.QueryRow(query, input.Username, input.Email, input.Role, hashedPassword,
-			input.PhoneNumber, input.Image, input.IsVerified, input.IsBlocked).Scan(&userID)
+		userID, err := users.Create(c.Request.Context(), models.User{
+			Username:    input.Username,
+			Email:       input.Email,
+			Role:        input.Role,
+			PhoneNumber: input.PhoneNumber,
+			Image:       input.Image,
+			IsVerified:  input.IsVerified,
+			IsBlocked:   input.IsBlocked,
+		}, string(hashedPassword))
 		if err != nil {
-			log.Println("Error inserting user:", err)
-			if err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"` ||
-				err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			// duplicates answer 409 naming the field
+			respondError(c, err, "Error inserting user")
 			return
 		}
 
@@ -74,70 +75,149 @@ func AdminCreateUser(db *sql.DB) gin.HandlerFunc {
 			"message": "User created",
 			"user_id": userID,
 		})
+	})
+}
+
+// encodeUserCursor and decodeUserCursor turn a position in the user listing
+// into the opaque cursor clients pass back as ?cursor=.
+func encodeUserCursor(user models.User) string {
+	raw := strconv.FormatInt(user.CreatedAt.UnixNano(), 10) + ":" + strconv.Itoa(user.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeUserCursor(cursor string) (*store.UserCursor, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, false
+	}
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return nil, false
+	}
+	createdAt, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, false
+	}
+	return &store.UserCursor{CreatedAt: time.Unix(0, createdAt).UTC(), ID: userID}, true
+}
+
+// parseOptionalBool reads a true/false query parameter; absent means any.
+func parseOptionalBool(c *gin.Context, name string) (*bool, bool) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, true
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, false
 	}
+	return &b, true
 }
 
-// AdminGetAllUsers retrieves all users (admin only)
-func AdminGetAllUsers(db *sql.DB) gin.HandlerFunc {
+// parseDateBound reads an RFC 3339 time or a YYYY-MM-DD date, as UTC. A
+// date given as an upper bound covers that whole day.
+func parseDateBound(c *gin.Context, name string, upper bool) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.UTC()
+		return &t, true
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, false
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, true
+}
+
+// AdminGetAllUsers lists users newest first, filtered by ?q= (username,
+// email or phone), role, is_verified, is_blocked and created_from /
+// created_to, one page of ?limit= at a time. The next page is requested with
+// the returned next_cursor; ?deleted=true lists soft-deleted users instead
+// (admin only)
+func AdminGetAllUsers(users store.UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query(`
-			SELECT id, username, email, role, phone_number, image, is_verified, is_blocked, created_at, updated_at
-			FROM users
-			ORDER BY id
-		`)
-		if err != nil {
-			log.Println("Error querying users:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		limit, _ := parsePagination(c)
+		filter := store.UserFilter{
+			Search:  strings.TrimSpace(c.Query("q")),
+			Role:    c.Query("role"),
+			Deleted: c.Query("deleted") == "true",
+			Limit:   limit + 1,
+		}
+
+		var ok bool
+		if filter.IsVerified, ok = parseOptionalBool(c, "is_verified"); !ok {
+			problem.Respond(c, problem.Invalid("is_verified", "boolean", "Invalid is_verified, use true or false"))
+			return
+		}
+		if filter.IsBlocked, ok = parseOptionalBool(c, "is_blocked"); !ok {
+			problem.Respond(c, problem.Invalid("is_blocked", "boolean", "Invalid is_blocked, use true or false"))
+			return
+		}
+		if filter.CreatedFrom, ok = parseDateBound(c, "created_from", false); !ok {
+			problem.Respond(c, problem.Invalid("created_from", "datetime", "Invalid created_from, use YYYY-MM-DD or RFC 3339"))
+			return
+		}
+		if filter.CreatedTo, ok = parseDateBound(c, "created_to", true); !ok {
+			problem.Respond(c, problem.Invalid("created_to", "datetime", "Invalid created_to, use YYYY-MM-DD or RFC 3339"))
 			return
 		}
-		defer rows.Close()
-
-		var users []models.User
-		for rows.Next() {
-			var user models.User
-			err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role,
-				&user.PhoneNumber, &user.Image, &user.IsVerified, &user.IsBlocked,
-				&user.CreatedAt, &user.UpdatedAt)
-			if err != nil {
-				log.Println("Error scanning user:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		if cursor := c.Query("cursor"); cursor != "" {
+			if filter.After, ok = decodeUserCursor(cursor); !ok {
+				problem.Respond(c, problem.Invalid("cursor", "format", "Invalid cursor"))
 				return
 			}
-			users = append(users, user)
+		}
+
+		list, err := users.List(c.Request.Context(), filter)
+		if err != nil {
+			respondError(c, err, "Error querying users")
+			return
+		}
+
+		// one user beyond the page tells whether there is a next one
+		var nextCursor *string
+		if len(list) > limit {
+			list = list[:limit]
+			cursor := encodeUserCursor(list[limit-1])
+			nextCursor = &cursor
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Users retrieved",
-			"users":   users,
+			"message":     "Users retrieved",
+			"users":       list,
+			"limit":       limit,
+			"next_cursor": nextCursor,
 		})
 	}
 }
 
 // AdminGetUser retrieves a single user by ID (admin only)
-func AdminGetUser(db *sql.DB) gin.HandlerFunc {
+func AdminGetUser(users store.UserStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr := c.Param("id")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
 			return
 		}
 
-		var user models.User
-		err = db.QueryRow(`
-			SELECT id, username, email, role, phone_number, image, is_verified, is_blocked, created_at, updated_at
-			FROM users
-			WHERE id = $1
-		`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Role,
-			&user.PhoneNumber, &user.Image, &user.IsVerified, &user.IsBlocked,
-			&user.CreatedAt, &user.UpdatedAt)
+		user, err := users.Get(c.Request.Context(), userID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
 				return
 			}
-			log.Println("Error querying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error querying user")
 			return
 		}
 
@@ -149,70 +229,37 @@ func AdminGetUser(db *sql.DB) gin.HandlerFunc {
 }
 
 // AdminUpdateUser updates a user by ID (admin only)
-func AdminUpdateUser(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func AdminUpdateUser(users store.UserStore) gin.HandlerFunc {
+	return openapi.Accepts[AdminUserUpdateInput](func(c *gin.Context) {
 		userIDStr := c.Param("id")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
 			return
 		}
 
 		var input AdminUserUpdateInput
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-			return
-		}
-
-		tx, err := db.Begin()
-		if err != nil {
-			log.Println("Error starting transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		defer tx.Rollback()
-
-		// Verify user exists
-		var existsUser bool
-		err = tx.QueryRow(`
-			SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)
-		`, userID).Scan(&existsUser)
-		if err != nil {
-			log.Println("Error verifying user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		if !existsUser {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
-		result, err := tx.Exec(`
-			UPDATE users
-			SET username = $1, email = $2, role = $3, phone_number = $4, image = $5,
-				is_verified = $6, is_blocked = $7, updated_at = NOW()
-			WHERE id = $8
-		`, input.Username, input.Email, input.Role, input.PhoneNumber, input.Image,
-			input.IsVerified, input.IsBlocked, userID)
+		err = users.Update(c.Request.Context(), models.User{
+			ID:          userID,
+			Username:    input.Username,
+			Email:       input.Email,
+			Role:        input.Role,
+			PhoneNumber: input.PhoneNumber,
+			Image:       input.Image,
+			IsVerified:  input.IsVerified,
+			IsBlocked:   input.IsBlocked,
+		})
 		if err != nil {
-			log.Println("Error updating user:", err)
-			if err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"` ||
-				err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"` {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Username or email already exists"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			return
-		}
-
-		if err := tx.Commit(); err != nil {
-			log.Println("Error committing transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			respondError(c, err, "Error updating user")
 			return
 		}
 
@@ -220,81 +267,191 @@ func AdminUpdateUser(db *sql.DB) gin.HandlerFunc {
 			"message": "User updated",
 			"user_id": userID,
 		})
-	}
+	})
 }
 
-// AdminDeleteUser deletes a user by ID (admin only)
-func AdminDeleteUser(db *sql.DB) gin.HandlerFunc {
+// UserRestoreWindow is how long a deleted user can be restored before the
+// purge-deleted-users command erases their personal data.
+const UserRestoreWindow = 30 * 24 * time.Hour
+
+// AdminDeleteUser soft-deletes a user by ID and emails them; the account
+// can be restored during UserRestoreWindow (admin only)
+func AdminDeleteUser(users store.UserStore, mailer mail.Mailer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDStr := c.Param("id")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
 			return
 		}
 
-		tx, err := db.Begin()
+		user, err := deleteUser(c.Request.Context(), users, userID)
 		if err != nil {
-			log.Println("Error starting transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
+				return
+			}
+			respondError(c, err, "Error deleting user")
 			return
 		}
-		defer tx.Rollback()
 
-		// Delete associated login_sessions
-		_, err = tx.Exec(`
-			DELETE FROM login_sessions WHERE user_id = $1
-		`, userID)
-		if err != nil {
-			log.Println("Error deleting login sessions:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
-			return
-		}
+		restorableUntil := time.Now().Add(UserRestoreWindow)
+		sendAccountMail(c, mailer, user, deletedMail(restorableUntil))
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "User deleted",
+			"user_id":          userID,
+			"restorable_until": restorableUntil,
+		})
+	}
+}
 
-		// Delete associated shipping_addresses
-		_, err = tx.Exec(`
-			DELETE FROM shipping_addresses WHERE user_id = $1
-		`, userID)
+// AdminRestoreUser undoes the deletion of a user still inside the restore
+// window (admin only)
+func AdminRestoreUser(users store.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDStr := c.Param("id")
+		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			log.Println("Error deleting shipping addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
 			return
 		}
 
-		// Delete associated billing_addresses
-		_, err = tx.Exec(`
-			DELETE FROM billing_addresses WHERE user_id = $1
-		`, userID)
+		err = users.Restore(c.Request.Context(), userID, time.Now().Add(-UserRestoreWindow))
 		if err != nil {
-			log.Println("Error deleting billing addresses:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("No restorable deleted user with this ID"))
+				return
+			}
+			respondError(c, err, "Error restoring user")
 			return
 		}
 
-		// Delete user (addresses table handled by ON DELETE CASCADE)
-		result, err := tx.Exec(`
-			DELETE FROM users WHERE id = $1
-		`, userID)
+		c.JSON(http.StatusOK, gin.H{
+			"message": "User restored",
+			"user_id": userID,
+		})
+	}
+}
+
+// AdminEraseUser anonymizes a user's personal data right away, deleted or
+// not, and emails them a last time. The user's row is kept so reviews and
+// orders stay intact (admin only)
+func AdminEraseUser(users store.UserStore, mailer mail.Mailer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := currentUserID(c)
+
+		userIDStr := c.Param("id")
+		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
-			log.Println("Error deleting user:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
 			return
 		}
-		rowsAffected, _ := result.RowsAffected()
-		if rowsAffected == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		if userID == adminID {
+			problem.Respond(c, problem.BadRequest("You cannot erase your own account"))
 			return
 		}
 
-		if err := tx.Commit(); err != nil {
-			log.Println("Error committing transaction:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		// the address is anonymized with the rest of the user
+		ctx := c.Request.Context()
+		user, err := users.Get(ctx, userID)
+		if err == nil {
+			err = users.Erase(ctx, userID)
+		}
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found or already erased"))
+				return
+			}
+			respondError(c, err, "Error erasing user")
 			return
 		}
 
+		sendAccountMail(c, mailer, user, erasedMail)
 		c.JSON(http.StatusOK, gin.H{
-			"message": "User deleted",
+			"message": "User erased",
 			"user_id": userID,
 		})
 	}
 }
+
+type BulkUsersInput struct {
+	UserIDs []int `json:"user_ids" binding:"required,min=1,max=500,dive,min=1"`
+}
+
+type BulkRoleInput struct {
+	UserIDs []int  `json:"user_ids" binding:"required,min=1,max=500,dive,min=1"`
+	Role    string `json:"role" binding:"required,oneof=user admin"`
+}
+
+// adminBulkUpdateUsers applies action to the users in the body, which also
+// names their new role for store.UserBulkSetRole.
+func adminBulkUpdateUsers(users store.UserStore, action store.UserBulkAction) gin.HandlerFunc {
+	if action == store.UserBulkSetRole {
+		return openapi.Accepts[BulkRoleInput](func(c *gin.Context) {
+			var input BulkRoleInput
+			if err := c.ShouldBindJSON(&input); err != nil {
+				problem.Respond(c, problem.Binding(err))
+				return
+			}
+			bulkUpdateUsers(c, users, action, input.UserIDs, input.Role)
+		})
+	}
+	return openapi.Accepts[BulkUsersInput](func(c *gin.Context) {
+		var input BulkUsersInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		bulkUpdateUsers(c, users, action, input.UserIDs, "")
+	})
+}
+
+// bulkUpdateUsers applies action to the users ids and reports the outcome
+// for each of them. Admins cannot block or demote themselves.
+func bulkUpdateUsers(c *gin.Context, users store.UserStore, action store.UserBulkAction, ids []int, role string) {
+	adminID, _ := currentUserID(c)
+	if (action == store.UserBulkBlock || action == store.UserBulkSetRole) && slices.Contains(ids, adminID) {
+		problem.Respond(c, problem.BadRequest("You cannot block or change the role of your own account"))
+		return
+	}
+
+	results, err := users.BulkUpdate(c.Request.Context(), action, ids, role)
+	if err != nil {
+		respondError(c, err, "Error updating users in bulk")
+		return
+	}
+
+	updated := 0
+	for _, result := range results {
+		if result.Status == store.BulkUpdated {
+			updated++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bulk action applied",
+		"action":  action,
+		"updated": updated,
+		"results": results,
+	})
+}
+
+// AdminBulkBlockUsers blocks several users at once (admin only)
+func AdminBulkBlockUsers(users store.UserStore) gin.HandlerFunc {
+	return adminBulkUpdateUsers(users, store.UserBulkBlock)
+}
+
+// AdminBulkUnblockUsers unblocks several users at once (admin only)
+func AdminBulkUnblockUsers(users store.UserStore) gin.HandlerFunc {
+	return adminBulkUpdateUsers(users, store.UserBulkUnblock)
+}
+
+// AdminBulkVerifyUsers marks several users as verified at once (admin only)
+func AdminBulkVerifyUsers(users store.UserStore) gin.HandlerFunc {
+	return adminBulkUpdateUsers(users, store.UserBulkVerify)
+}
+
+// AdminBulkSetUserRole gives several users the same role at once (admin only)
+func AdminBulkSetUserRole(users store.UserStore) gin.HandlerFunc {
+	return adminBulkUpdateUsers(users, store.UserBulkSetRole)
+}
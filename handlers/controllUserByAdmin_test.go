@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outsideUTC runs the test with time.Local ahead of UTC, where times read
+// in the local zone would shift by its offset.
+func outsideUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestUserCursorIsUTC(t *testing.T) {
+	outsideUTC(t)
+	user := models.User{ID: 42, CreatedAt: time.Date(2024, 5, 1, 10, 30, 0, 123456000, time.Local)}
+
+	cursor, ok := decodeUserCursor(encodeUserCursor(user))
+	if !ok {
+		t.Fatal("cursor does not decode")
+	}
+	if cursor.ID != user.ID || !cursor.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("cursor %+v, want user %d at %v", cursor, user.ID, user.CreatedAt)
+	}
+	if cursor.CreatedAt.Location() != time.UTC {
+		t.Errorf("cursor time in %v, want UTC", cursor.CreatedAt.Location())
+	}
+
+	for _, bad := range []string{"", "not base64!", "MTIz", "eDox", "MTIzOng"} {
+		if _, ok := decodeUserCursor(bad); ok {
+			t.Errorf("cursor %q decodes", bad)
+		}
+	}
+}
+
+func TestDateBoundsAreUTC(t *testing.T) {
+	outsideUTC(t)
+	tests := []struct {
+		value string
+		upper bool
+		want  time.Time
+	}{
+		{"2024-05-01T10:00:00+02:00", false, time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)},
+		{"2024-05-01T10:00:00Z", true, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"2024-05-01", false, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{"2024-05-01", true, time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/admin/users?created_from="+url.QueryEscape(tt.value), nil)
+
+		got, ok := parseDateBound(c, "created_from", tt.upper)
+		switch {
+		case !ok || got == nil:
+			t.Errorf("%s: not parsed", tt.value)
+		case !got.Equal(tt.want) || got.Location() != time.UTC:
+			t.Errorf("%s (upper %v): got %v, want %v", tt.value, tt.upper, got, tt.want)
+		}
+	}
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/admin/users?created_from=yesterday", nil)
+	if _, ok := parseDateBound(c, "created_from", false); ok {
+		t.Error("created_from=yesterday parsed")
+	}
+}
+
+func TestAdminGetAllUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodGet, "/admin/users", "admin", AdminGetAllUsers(e.Stores.Users))
+	admin := e.Admin()
+	alice := e.User(func(u *models.User) { u.Username = "alice"; u.Email = "alice@shop.example" })
+	blocked := e.User(func(u *models.User) { u.IsBlocked = true; u.IsVerified = false })
+	gone := e.User()
+	if err := e.Stores.Users.Delete(context.Background(), gone.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	type page struct {
+		Users      []models.User
+		NextCursor *string `json:"next_cursor"`
+	}
+	list := func(query string) []int {
+		t.Helper()
+		var ids []int
+		for _, u := range testutil.Expect[page](t, e.Do(http.MethodGet, "/admin/users?"+query, admin.Token, nil), http.StatusOK).Users {
+			ids = append(ids, u.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{blocked.ID, alice.ID, admin.ID}},
+		{"q=" + url.QueryEscape(" ALICE@shop "), []int{alice.ID}},
+		{"role=admin", []int{admin.ID}},
+		{"is_blocked=true", []int{blocked.ID}},
+		{"is_verified=true&is_blocked=false", []int{alice.ID, admin.ID}},
+		{"deleted=true", []int{gone.ID}},
+		{"q=nobody", nil},
+	}
+	for _, tt := range tests {
+		if got := list(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("users of %q: %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// two a page, newest first, until there is no next cursor
+	first := testutil.Expect[page](t, e.Do(http.MethodGet, "/admin/users?limit=2", admin.Token, nil), http.StatusOK)
+	if len(first.Users) != 2 || first.NextCursor == nil {
+		t.Fatalf("first page %+v, want two users and a cursor", first)
+	}
+	second := testutil.Expect[page](t, e.Do(http.MethodGet, "/admin/users?limit=2&cursor="+url.QueryEscape(*first.NextCursor), admin.Token, nil), http.StatusOK)
+	if len(second.Users) != 1 || second.Users[0].ID != admin.ID || second.NextCursor != nil {
+		t.Errorf("second page %+v, want the admin and no cursor", second)
+	}
+
+	for _, param := range []string{"is_verified=maybe", "is_blocked=2", "created_from=yesterday", "created_to=soon", "cursor=bogus"} {
+		testutil.ExpectProblem(t, e.Do(http.MethodGet, "/admin/users?"+param, admin.Token, nil), http.StatusBadRequest, "validation_failed")
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/admin/users", alice.Token, nil), http.StatusForbidden, "forbidden")
+}
+
+func TestAdminBulkUsers(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/admin/users/bulk/block", "admin", AdminBulkBlockUsers(e.Stores.Users))
+	e.route(http.MethodPost, "/admin/users/bulk/verify", "admin", AdminBulkVerifyUsers(e.Stores.Users))
+	e.route(http.MethodPost, "/admin/users/bulk/role", "admin", AdminBulkSetUserRole(e.Stores.Users))
+	admin := e.Admin()
+	u := e.User()
+	unverified := e.User(func(u *models.User) { u.IsVerified = false })
+
+	type outcome struct {
+		Action  string
+		Updated int
+		Results []store.BulkResult
+	}
+	got := testutil.Expect[outcome](t, e.Do(http.MethodPost, "/admin/users/bulk/verify", admin.Token,
+		map[string]any{"user_ids": []int{unverified.ID, u.ID, 9999}}), http.StatusOK)
+	want := []store.BulkResult{
+		{UserID: unverified.ID, Status: store.BulkUpdated},
+		{UserID: u.ID, Status: store.BulkUnchanged},
+		{UserID: 9999, Status: store.BulkNotFound},
+	}
+	if got.Action != "verify" || got.Updated != 1 || !slices.Equal(got.Results, want) {
+		t.Errorf("bulk verify %+v, want results %+v", got, want)
+	}
+
+	got = testutil.Expect[outcome](t, e.Do(http.MethodPost, "/admin/users/bulk/role", admin.Token,
+		map[string]any{"user_ids": []int{u.ID}, "role": "admin"}), http.StatusOK)
+	if promoted, err := e.Stores.Users.Get(context.Background(), u.ID); err != nil || got.Updated != 1 || promoted.Role != "admin" {
+		t.Errorf("bulk role %+v: user %+v (%v), want an admin", got, promoted, err)
+	}
+
+	// admins cannot lock themselves out
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/admin/users/bulk/block", admin.Token,
+		map[string]any{"user_ids": []int{u.ID, admin.ID}}), http.StatusBadRequest, "invalid_request")
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/admin/users/bulk/role", admin.Token,
+		map[string]any{"user_ids": []int{admin.ID}, "role": "user"}), http.StatusBadRequest, "invalid_request")
+
+	for _, body := range []map[string]any{
+		{"user_ids": []int{}},
+		{"user_ids": []int{0}},
+		{},
+	} {
+		testutil.ExpectProblem(t, e.Do(http.MethodPost, "/admin/users/bulk/block", admin.Token, body), http.StatusBadRequest, "validation_failed")
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/admin/users/bulk/role", admin.Token,
+		map[string]any{"user_ids": []int{u.ID}, "role": "root"}), http.StatusBadRequest, "validation_failed")
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"my-api/countries"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCountries lists the supported countries with their postal-code
+// patterns, address layouts and required fields.
+func GetCountries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"countries": countries.All()})
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/store/memory"
+	"my-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The handler tests run the handlers on the memory store, with routes
+// registered as in package server; the integration tests there run them on
+// Postgres. Both store their fixtures and send requests with package
+// testutil.
+
+type testEnv struct {
+	*testutil.Fixtures
+	*testutil.Client
+	r *gin.Engine
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	return &testEnv{
+		Fixtures: testutil.NewFixtures(t, memory.New(), utils.NewTokenService("test-secret", nil)),
+		Client:   testutil.NewClient(t, r),
+		r:        r,
+	}
+}
+
+// route registers handler behind the authentication of role, none when
+// role is empty.
+func (e *testEnv) route(method, path, role string, handler gin.HandlerFunc) {
+	if role == "" {
+		e.r.Handle(method, path, handler)
+		return
+	}
+	e.r.Handle(method, path, JWTAuthMiddleware(e.Tokens, e.Stores.Sessions, time.Now, role), handler)
+}
@@ -0,0 +1,57 @@
+// handlers/health.go
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadinessCheck is one dependency Readyz checks. Check returns an error
+// when the server should not take traffic because of it.
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// readinessTimeout bounds each check, so a hanging dependency fails the
+// probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+// Healthz is the liveness probe: it answers as long as the process serves
+// requests. It does not check the database, so that an outage there does
+// not get the server restarted.
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz is the readiness probe: it runs every check and answers 503 when
+// any fails. The probe is public, so the response only says which checks
+// failed; their errors are logged.
+func Readyz(checks ...ReadinessCheck) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, results := http.StatusOK, gin.H{}
+		for _, check := range checks {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+			err := check.Check(ctx)
+			cancel()
+			if err != nil {
+				status = http.StatusServiceUnavailable
+				results[check.Name] = "unavailable"
+				requestLogger(c).Warn("Readiness check failed", "check", check.Name, "error", err)
+				continue
+			}
+			results[check.Name] = "ok"
+		}
+
+		if status != http.StatusOK {
+			c.JSON(status, gin.H{"status": "unavailable", "checks": results})
+			return
+		}
+		c.JSON(status, gin.H{"status": "ready", "checks": results})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"my-api/internal/testutil"
+)
+
+// A failing check is reported by name only, without its error.
+func TestReadyzHidesErrors(t *testing.T) {
+	e := newTestEnv(t)
+	secret := "dial tcp db.internal:5432: connection refused"
+	e.route(http.MethodGet, "/readyz", "", Readyz(
+		ReadinessCheck{Name: "database", Check: func(ctx context.Context) error { return errors.New(secret) }},
+		ReadinessCheck{Name: "migrations", Check: func(ctx context.Context) error { return nil }},
+	))
+
+	w := e.Do(http.MethodGet, "/readyz", "", nil)
+	if strings.Contains(w.Body.String(), "db.internal") {
+		t.Errorf("readyz leaks the error: %s", w.Body)
+	}
+	got := testutil.Expect[struct {
+		Status string
+		Checks map[string]string
+	}](t, w, http.StatusServiceUnavailable)
+	if got.Status != "unavailable" || got.Checks["database"] != "unavailable" || got.Checks["migrations"] != "ok" {
+		t.Errorf("readyz %+v, want the database unavailable", got)
+	}
+}
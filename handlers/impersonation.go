@@ -0,0 +1,108 @@
+// handlers/impersonation.go
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"my-api/problem"
+	"my-api/store"
+	"my-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminImpersonateUser issues a short-lived access token that lets the
+// admin use the storefront as the user. The token names the admin, opens a
+// session that shows up in the user's session list, and every request made
+// with it is logged. Admins cannot be impersonated (admin only)
+func AdminImpersonateUser(users store.UserStore, sessions store.SessionStore, tokens *utils.TokenService, now func() time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := currentUserID(c)
+
+		userIDStr := c.Param("id")
+		userID, err := strconv.Atoi(userIDStr)
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid user ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		user, err := users.Get(ctx, userID)
+		if err == nil && user.DeletedAt != nil {
+			err = store.ErrNotFound
+		}
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
+				return
+			}
+			respondError(c, err, "Error querying user")
+			return
+		}
+		if user.Role == "admin" {
+			problem.Respond(c, problem.Forbidden("Admins cannot be impersonated"))
+			return
+		}
+
+		expiresAt := now().Add(utils.ImpersonationTokenTTL)
+		session := newLoginSession(c, userID)
+		session.ImpersonatorID = &adminID
+		session.ExpiresAt = &expiresAt
+		sessionID, err := sessions.Create(ctx, session)
+		if err != nil {
+			respondError(c, err, "Error storing impersonation session")
+			return
+		}
+
+		accessToken, err := tokens.GenerateImpersonationToken(uint(user.ID), user.Username, user.Email, user.Role,
+			uint(adminID), sessionID, expiresAt)
+		if err != nil {
+			respondError(c, err, "Error generating impersonation token")
+			return
+		}
+
+		requestLogger(c).Info("Impersonation started", "admin_id", adminID, "impersonated_user_id", userID, "session_id", sessionID)
+		c.JSON(http.StatusOK, gin.H{
+			"message":         "Impersonation started",
+			"access_token":    accessToken,
+			"expires_at":      expiresAt,
+			"session_id":      sessionID,
+			"impersonator_id": adminID,
+			"user": gin.H{
+				"id":       user.ID,
+				"username": user.Username,
+				"role":     user.Role,
+			},
+		})
+	}
+}
+
+// AdminGetImpersonations lists the requests made while impersonating,
+// newest first, optionally for one ?user_id= or ?impersonator_id= (admin only)
+func AdminGetImpersonations(sessions store.SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parsePagination(c)
+		userID, _ := strconv.Atoi(c.Query("user_id"))
+		impersonatorID, _ := strconv.Atoi(c.Query("impersonator_id"))
+
+		list, err := sessions.ListImpersonations(c.Request.Context(), store.ImpersonationFilter{
+			UserID:         userID,
+			ImpersonatorID: impersonatorID,
+			Limit:          limit,
+			Offset:         offset,
+		})
+		if err != nil {
+			respondError(c, err, "Error querying impersonation log")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"requests": list,
+			"limit":    limit,
+			"offset":   offset,
+		})
+	}
+}
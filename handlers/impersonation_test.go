@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+// impersonate starts an impersonation of userID and returns its session ID
+// and token.
+func (e *testEnv) impersonate(adminToken string, userID int) (int, string) {
+	e.T.Helper()
+	got := testutil.Expect[struct {
+		SessionID   int    `json:"session_id"`
+		AccessToken string `json:"access_token"`
+	}](e.T, e.Do(http.MethodPost, fmt.Sprintf("/admin/users/%d/impersonate", userID), adminToken, nil), http.StatusOK)
+	return got.SessionID, got.AccessToken
+}
+
+// routeImpersonation registers the impersonation routes as in package server.
+func (e *testEnv) routeImpersonation() {
+	e.route(http.MethodPost, "/admin/users/:id/impersonate", "admin", AdminImpersonateUser(e.Stores.Users, e.Stores.Sessions, e.Tokens, time.Now))
+	e.route(http.MethodGet, "/admin/impersonations", "admin", AdminGetImpersonations(e.Stores.Sessions))
+}
+
+// An admin logging out of an impersonation ends only the impersonation
+// session, and with it the impersonation token.
+func TestImpersonatedLogout(t *testing.T) {
+	e := newTestEnv(t)
+	e.routeImpersonation()
+	e.route(http.MethodPost, "/user/logout", "user", Logout(e.Stores.Sessions))
+	u := e.User()
+	adminToken := e.Admin().Token
+
+	ctx := context.Background()
+	if _, err := e.Stores.Sessions.Create(ctx, models.LoginSession{UserID: u.ID, Browser: "Firefox"}); err != nil {
+		t.Fatal(err)
+	}
+	sessionID, token := e.impersonate(adminToken, u.ID)
+
+	testutil.Expect[map[string]any](t, e.Do(http.MethodPost, "/user/logout", token, nil), http.StatusOK)
+	sessions, err := e.Stores.Sessions.ListByUser(ctx, u.ID)
+	if err != nil || len(sessions) != 1 || sessions[0].ID == sessionID || sessions[0].ImpersonatorID != nil {
+		t.Errorf("sessions after logging out of the impersonation %+v (%v), want the user's own login", sessions, err)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/user/logout", token, nil), http.StatusUnauthorized, "invalid_token")
+}
+
+func TestExportRefusedWhileImpersonating(t *testing.T) {
+	e := newTestEnv(t)
+	e.routeImpersonation()
+	e.route(http.MethodGet, "/user/export", "user", ExportUserData(e.Stores.Users, e.Stores.Addresses, e.Stores.Sessions,
+		e.Stores.Carts, e.Stores.Wishlists, e.Stores.Reviews, e.Stores.Orders, time.Now))
+	u := e.User()
+	adminToken := e.Admin().Token
+	_, impersonationToken := e.impersonate(adminToken, u.ID)
+
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/user/export", impersonationToken, nil), http.StatusForbidden, "forbidden")
+	if w := e.Do(http.MethodGet, "/user/export", u.Token, nil); w.Code != http.StatusOK {
+		t.Errorf("export by the user: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestImpersonationLogEmpty(t *testing.T) {
+	e := newTestEnv(t)
+	e.routeImpersonation()
+	adminToken := e.Admin().Token
+
+	got := testutil.Expect[map[string]any](t, e.Do(http.MethodGet, "/admin/impersonations", adminToken, nil), http.StatusOK)
+	if list, ok := got["requests"].([]any); !ok || len(list) != 0 {
+		t.Errorf("requests %#v, want an empty list", got["requests"])
+	}
+}
@@ -0,0 +1,538 @@
+// handlers/media.go
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"my-api/imaging"
+	"my-api/logging"
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxUploadSize is the largest file the upload endpoints accept.
+const MaxUploadSize = 10 << 20
+
+// MaxProductImages is the largest gallery a product can have, and the most
+// files one request can upload.
+const MaxProductImages = 10
+
+// imageTypes are the accepted upload types, detected from the content
+// rather than trusted from the client.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// uploadFailed responds to an error from uploadedFiles or saveUpload, which
+// return a *problem.Problem for uploads refused because of what the client
+// sent.
+func uploadFailed(c *gin.Context, err error) {
+	respondError(c, err, "Error storing upload")
+}
+
+// uploadedFiles returns the files of the multipart form field, at most max
+// of them.
+func uploadedFiles(c *gin.Context, field string, max int) ([]*multipart.FileHeader, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(max)*MaxUploadSize+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge, "Upload is too large")
+		}
+		return nil, problem.BadRequest("Expected a multipart/form-data upload")
+	}
+	files := form.File[field]
+	if len(files) == 0 {
+		return nil, problem.Invalid(field, "required", "No file uploaded in the "+field+" field")
+	}
+	if len(files) > max {
+		return nil, problem.Invalid(field, "max", fmt.Sprintf("At most %d files can be uploaded at once", max))
+	}
+	return files, nil
+}
+
+// mediaKey is the blob key of content with the hash. The prefix directory
+// keeps any one directory of a disk store small.
+func mediaKey(hash string) string {
+	return hash[:2] + "/" + hash
+}
+
+// saveUpload stores an uploaded image, unless the same content was uploaded
+// before, and returns its media and whether it is new.
+func saveUpload(ctx context.Context, media store.MediaStore, blobs store.BlobStore, file *multipart.FileHeader, uploadedBy int) (models.Media, bool, error) {
+	tooLarge := problem.New(http.StatusRequestEntityTooLarge, problem.CodePayloadTooLarge,
+		fmt.Sprintf("%s is larger than %d MB", file.Filename, MaxUploadSize>>20))
+	if file.Size > MaxUploadSize {
+		return models.Media{}, false, tooLarge
+	}
+	f, err := file.Open()
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, MaxUploadSize+1))
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	if len(content) > MaxUploadSize {
+		return models.Media{}, false, tooLarge
+	}
+
+	mime := mimetype.Detect(content).String()
+	if !mimetype.EqualsAny(mime, imageTypes...) {
+		return models.Media{}, false, problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			file.Filename+" is not a JPEG, PNG, GIF or WebP image")
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	// the key is the content's hash, so storing it again is harmless
+	if err := blobs.Put(ctx, mediaKey(hash), bytes.NewReader(content), int64(len(content)), mime); err != nil {
+		return models.Media{}, false, fmt.Errorf("storing blob: %w", err)
+	}
+	return media.Create(ctx, models.Media{
+		Hash:       hash,
+		MimeType:   mime,
+		Size:       int64(len(content)),
+		Filename:   filepath.Base(file.Filename),
+		UploadedBy: &uploadedBy,
+	})
+}
+
+// uploadImage saves the single image of the file field of the request,
+// responding to the client itself when that fails.
+func uploadImage(c *gin.Context, media store.MediaStore, blobs store.BlobStore) (models.Media, bool) {
+	files, err := uploadedFiles(c, "file", 1)
+	if err != nil {
+		uploadFailed(c, err)
+		return models.Media{}, false
+	}
+	userID, _ := currentUserID(c)
+	m, _, err := saveUpload(c.Request.Context(), media, blobs, files[0], userID)
+	if err != nil {
+		uploadFailed(c, err)
+		return models.Media{}, false
+	}
+	return m, true
+}
+
+// UploadMedia adds an image from the multipart field "file" to the media
+// library. Uploading a file that is already there returns the existing
+// media (admin only)
+func UploadMedia(media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		files, err := uploadedFiles(c, "file", 1)
+		if err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		adminID, _ := currentUserID(c)
+		m, created, err := saveUpload(c.Request.Context(), media, blobs, files[0], adminID)
+		if err != nil {
+			uploadFailed(c, err)
+			return
+		}
+
+		if !created {
+			c.JSON(http.StatusOK, gin.H{"message": "File was already uploaded", "media": m})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "File uploaded", "media": m})
+	}
+}
+
+// AdminGetMedia lists the media library, newest first (admin only)
+func AdminGetMedia(media store.MediaStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parsePagination(c)
+		list, err := media.List(c.Request.Context(), limit, offset)
+		if err != nil {
+			respondError(c, err, "Error querying media")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"media":  list,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
+
+// AdminDeleteMedia removes a file from the media library. Files in a
+// product gallery cannot be deleted; brand, category and user images are
+// plain URLs and stop resolving (admin only)
+func AdminDeleteMedia(media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid media ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		m, err := media.Get(ctx, id)
+		if err == nil {
+			err = media.Delete(ctx, id)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				problem.Respond(c, problem.NotFound("Media not found"))
+			case errors.Is(err, store.ErrConflict):
+				problem.Respond(c, problem.New(http.StatusConflict, problem.CodeInUse, "Media is shown by a product"))
+			default:
+				respondError(c, err, "Error deleting media")
+			}
+			return
+		}
+		// the row is gone, so a blob left behind is only wasted space
+		if err := blobs.Delete(ctx, mediaKey(m.Hash)); err != nil {
+			requestLogger(c).Error("Error deleting media blob", "error", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Media deleted", "id": id})
+	}
+}
+
+// variantKey is the cache key of a variant of content with the hash.
+func variantKey(hash string, v imaging.Variant) string {
+	return mediaKey(hash) + "/" + v.Key()
+}
+
+// ServeMedia returns the content of a media file, or with any of ?w=, h=,
+// fit= (contain or cover) and format= (jpeg, png or webp) a resized and
+// re-encoded variant of the image. Variants are rendered once and cached in
+// variants. Neither ever changes under its URL, so clients may cache them
+// indefinitely.
+func ServeMedia(media store.MediaStore, blobs, variants store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid media ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		m, err := media.Get(ctx, id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Media not found"))
+				return
+			}
+			respondError(c, err, "Error querying media")
+			return
+		}
+
+		var variant *imaging.Variant
+		query := c.Request.URL.Query()
+		if query.Has("w") || query.Has("h") || query.Has("fit") || query.Has("format") {
+			v, err := imaging.ParseVariant(c.Query("w"), c.Query("h"), c.Query("fit"), c.Query("format"), m.MimeType)
+			if err != nil {
+				problem.Respond(c, problem.BadRequest(err.Error()))
+				return
+			}
+			variant = &v
+		}
+
+		etag := `"` + m.Hash + `"`
+		if variant != nil {
+			etag = `"` + m.Hash + "-" + variant.Key() + `"`
+		}
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("X-Content-Type-Options", "nosniff")
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		if variant == nil {
+			content, err := blobs.Get(ctx, mediaKey(m.Hash))
+			if err != nil {
+				respondError(c, err, "Error reading media blob")
+				return
+			}
+			defer content.Close()
+			c.DataFromReader(http.StatusOK, m.Size, m.MimeType, content, nil)
+			return
+		}
+
+		data, err := renderVariant(ctx, blobs, variants, m.Hash, *variant)
+		if err != nil {
+			respondError(c, err, "Error rendering media variant")
+			return
+		}
+		c.Data(http.StatusOK, variant.ContentType(), data)
+	}
+}
+
+// renderVariant returns the variant of the image with the hash from the
+// cache, rendering and caching it first if needed.
+func renderVariant(ctx context.Context, blobs, variants store.BlobStore, hash string, v imaging.Variant) ([]byte, error) {
+	key := variantKey(hash, v)
+	if cached, err := variants.Get(ctx, key); err == nil {
+		defer cached.Close()
+		return io.ReadAll(cached)
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	original, err := blobs.Get(ctx, mediaKey(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer original.Close()
+	src, err := io.ReadAll(original)
+	if err != nil {
+		return nil, err
+	}
+	data, err := imaging.Render(src, v)
+	if err != nil {
+		return nil, err
+	}
+	// a failed write only costs rendering the variant again
+	if err := variants.Put(ctx, key, bytes.NewReader(data), int64(len(data)), v.ContentType()); err != nil {
+		logging.FromContext(ctx).Error("Error caching media variant", "error", err)
+	}
+	return data, nil
+}
+
+// setProductImages saves the gallery of a product and responds with it.
+func setProductImages(c *gin.Context, products store.ProductStore, media store.MediaStore, productID int, mediaIDs []int) {
+	ctx := c.Request.Context()
+	err := media.SetProductImages(ctx, productID, mediaIDs)
+	var refErr *store.InvalidRefError
+	if errors.As(err, &refErr) {
+		if refErr.Field == "product_id" {
+			problem.Respond(c, problem.NotFound("Product not found"))
+			return
+		}
+		problem.Respond(c, problem.InvalidReference("media_ids", "Unknown media ID"))
+		return
+	}
+	if err != nil {
+		respondError(c, err, "Error saving product images")
+		return
+	}
+
+	product, err := products.Get(ctx, productID)
+	if err != nil {
+		respondError(c, err, "Error querying product")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Product images updated",
+		"image":   product.Image,
+		"images":  product.Images,
+	})
+}
+
+// AddProductImages uploads the images of the multipart field "files" and
+// appends them to the product's gallery (admin only)
+func AddProductImages(products store.ProductStore, media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid product ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		product, err := products.Get(ctx, productID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("Product not found"))
+				return
+			}
+			respondError(c, err, "Error querying product")
+			return
+		}
+
+		files, err := uploadedFiles(c, "files", MaxProductImages)
+		if err != nil {
+			uploadFailed(c, err)
+			return
+		}
+		// checked before storing anything, so that a refused upload leaves
+		// no files behind
+		if len(product.Images)+len(files) > MaxProductImages {
+			problem.Respond(c, problem.Invalid("files", "max", fmt.Sprintf("A product can have at most %d images", MaxProductImages)))
+			return
+		}
+		var mediaIDs []int
+		for _, image := range product.Images {
+			mediaIDs = append(mediaIDs, image.MediaID)
+		}
+		adminID, _ := currentUserID(c)
+		for _, file := range files {
+			m, _, err := saveUpload(ctx, media, blobs, file, adminID)
+			if err != nil {
+				uploadFailed(c, err)
+				return
+			}
+			if !slices.Contains(mediaIDs, m.ID) {
+				mediaIDs = append(mediaIDs, m.ID)
+			}
+		}
+
+		setProductImages(c, products, media, productID, mediaIDs)
+	}
+}
+
+type ProductImagesInput struct {
+	MediaIDs []int `json:"media_ids"`
+}
+
+// SetProductImages replaces a product's gallery with media from the
+// library, in the given order; the first becomes the product's image. An
+// empty list removes all images (admin only)
+func SetProductImages(products store.ProductStore, media store.MediaStore) gin.HandlerFunc {
+	return openapi.Accepts[ProductImagesInput](func(c *gin.Context) {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid product ID"))
+			return
+		}
+
+		var input ProductImagesInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		if len(input.MediaIDs) > MaxProductImages {
+			problem.Respond(c, problem.Invalid("media_ids", "max", fmt.Sprintf("A product can have at most %d images", MaxProductImages)))
+			return
+		}
+		if len(slices.Compact(slices.Sorted(slices.Values(input.MediaIDs)))) != len(input.MediaIDs) {
+			problem.Respond(c, problem.Invalid("media_ids", "unique", "media_ids contains duplicates"))
+			return
+		}
+
+		setProductImages(c, products, media, productID, input.MediaIDs)
+	})
+}
+
+// UploadBrandImage replaces a brand's image with the upload of the
+// multipart field "file" (admin only)
+func UploadBrandImage(catalog store.CatalogStore, media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid brand ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		brand, err := catalog.GetBrand(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Brand not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying brand")
+			return
+		}
+
+		m, ok := uploadImage(c, media, blobs)
+		if !ok {
+			return
+		}
+		adminID, _ := currentUserID(c)
+		brand.Image = m.URL
+		brand.UpdatedBy = &adminID
+		if err := catalog.UpdateBrand(ctx, brand); err != nil {
+			respondError(c, err, "Error updating brand image")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Brand image updated", "id": id, "image": m.URL})
+	}
+}
+
+// UploadCategoryImage replaces a category's image with the upload of the
+// multipart field "file" (admin only)
+func UploadCategoryImage(catalog store.CatalogStore, media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid category ID"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		category, err := catalog.GetCategory(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Category not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying category")
+			return
+		}
+
+		m, ok := uploadImage(c, media, blobs)
+		if !ok {
+			return
+		}
+		adminID, _ := currentUserID(c)
+		category.Image = &m.URL
+		category.UpdatedBy = &adminID
+		if err := catalog.UpdateCategory(ctx, category); err != nil {
+			respondError(c, err, "Error updating category image")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Category image updated", "id": id, "image": m.URL})
+	}
+}
+
+// UploadUserImage replaces the current user's profile image with the
+// upload of the multipart field "file"
+func UploadUserImage(users store.UserStore, media store.MediaStore, blobs store.BlobStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		user, err := users.Get(ctx, userID)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				problem.Respond(c, problem.NotFound("User not found"))
+				return
+			}
+			respondError(c, err, "Error querying user")
+			return
+		}
+
+		m, ok := uploadImage(c, media, blobs)
+		if !ok {
+			return
+		}
+		user.Image = &m.URL
+		if err := users.Update(ctx, user); err != nil {
+			respondError(c, err, "Error updating user image")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Profile image updated", "image": m.URL})
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/store/blob"
+)
+
+// pngImage is a distinct 1x1 PNG for each shade.
+func pngImage(t *testing.T, shade uint8) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	img.SetGray(0, 0, color.Gray{Y: shade})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// upload sends the files as the multipart field.
+func (e *testEnv) upload(path, token, field string, files ...[]byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for i, content := range files {
+		w, err := form.CreateFormFile(field, fmt.Sprintf("image-%d.png", i))
+		if err != nil {
+			e.T.Fatal(err)
+		}
+		w.Write(content)
+	}
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	e.r.ServeHTTP(w, req)
+	return w
+}
+
+// An upload that would grow the gallery past MaxProductImages is refused
+// before any file is stored.
+func TestAddProductImagesLimit(t *testing.T) {
+	e := newTestEnv(t)
+	dir := t.TempDir()
+	blobs, err := blob.NewDisk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.route(http.MethodPost, "/admin/products/:id/images", "admin", AddProductImages(e.Stores.Products, e.Stores.Media, blobs))
+	adminToken := e.Admin().Token
+	path := fmt.Sprintf("/admin/products/%d/images", e.Product(nil).ID)
+
+	var full [][]byte
+	for i := range MaxProductImages - 1 {
+		full = append(full, pngImage(t, uint8(i)))
+	}
+	testutil.Expect[map[string]any](t, e.upload(path, adminToken, "files", full...), http.StatusOK)
+
+	p := testutil.ExpectProblem(t, e.upload(path, adminToken, "files", pngImage(t, 100), pngImage(t, 101)),
+		http.StatusBadRequest, "validation_failed")
+	if len(p.Errors) != 1 || p.Errors[0].Field != "files" {
+		t.Errorf("errors of too many images %+v", p.Errors)
+	}
+	if media, err := e.Stores.Media.List(context.Background(), 100, 0); err != nil || len(media) != MaxProductImages-1 {
+		t.Errorf("%d media after the refused upload (%v), want %d", len(media), err, MaxProductImages-1)
+	}
+	var blobCount int
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, _ error) error {
+		if !d.IsDir() {
+			blobCount++
+		}
+		return nil
+	})
+	if blobCount != MaxProductImages-1 {
+		t.Errorf("%d blobs after the refused upload, want %d", blobCount, MaxProductImages-1)
+	}
+
+	// the last free place still takes an image
+	testutil.Expect[map[string]any](t, e.upload(path, adminToken, "files", pngImage(t, 100)), http.StatusOK)
+}
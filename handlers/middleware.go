@@ -2,40 +2,289 @@
 package handlers
 
 import (
-	"my-api/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"regexp"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+
+	"my-api/logging"
+	"my-api/metrics"
+	"my-api/models"
+	"my-api/problem"
+	"my-api/store"
+	"my-api/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func JWTAuthMiddleware(requiredRole string) gin.HandlerFunc {
+// RequestIDHeader carries the ID of a request, from the client or the proxy
+// in front, back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID accepts incoming request IDs that are safe to log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// untracedPaths are the probe and scrape endpoints, which would otherwise
+// make up most traces.
+var untracedPaths = []string{"/healthz", "/readyz", "/metrics"}
+
+// Tracing starts a span per request, named after its route, as the child of
+// the span of an incoming traceparent. It must run before RequestLogger so
+// that request logs carry the trace ID.
+func Tracing(service string) gin.HandlerFunc {
+	return otelgin.Middleware(service, otelgin.WithFilter(func(r *http.Request) bool {
+		return !slices.Contains(untracedPaths, r.URL.Path)
+	}))
+}
+
+// RequestLogger gives every request an ID, honoring a valid incoming
+// X-Request-ID, and a logger carrying it (and the IDs of the request's
+// trace and span) in the request context. Once the request has been handled it
+// writes one access-log line with the route, status, latency and user.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		reqLogger := logger.With("request_id", requestID)
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			reqLogger = reqLogger.With("trace_id", span.TraceID().String(), "span_id", span.SpanID().String())
+		}
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), reqLogger))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"bytes", max(c.Writer.Size(), 0),
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
+		}
+		if userID, ok := currentUserID(c); ok {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if impersonatorID, ok := currentImpersonatorID(c); ok {
+			attrs = append(attrs, "impersonator_id", impersonatorID)
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		reqLogger.Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// unmatchedRoute labels requests that match no route, so that scanners
+// probing random paths do not add a series each.
+const unmatchedRoute = "unmatched"
+
+// Metrics counts and times every request by its route template. It must run
+// before Recovery to see the 500 of a panic.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Recovery turns a panic in a handler into a 500 response and logs it with
+// its stack. It must run after RequestLogger.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		requestLogger(c).Error("Panic while handling request", "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+		problem.Respond(c, problem.Internal("Server error"))
+	})
+}
+
+// NoRoute answers requests that match no route.
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		problem.Respond(c, problem.NotFound("No route for "+c.Request.Method+" "+c.Request.URL.Path))
+	}
+}
+
+// requestLogger returns the logger of the request, set up by RequestLogger.
+func requestLogger(c *gin.Context) *slog.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
+// respondError answers an error the handler did not handle itself: a
+// problem or store error with its problem, anything else by logging it as
+// msg with args and answering 500.
+func respondError(c *gin.Context, err error, msg string, args ...any) {
+	if p := problem.FromError(err); p != nil {
+		problem.Respond(c, p)
+		return
+	}
+	requestLogger(c).Error(msg, append([]any{"error", err}, args...)...)
+	problem.Respond(c, problem.Internal("Server error"))
+}
+
+// JWTAuthMiddleware authenticates the request by its access token and
+// requires requiredRole, unless it is empty. An impersonation token only
+// works while its session lasts: logging out or revoking it ends the
+// impersonation before the token expires.
+func JWTAuthMiddleware(tokens *utils.TokenService, sessions store.SessionStore, now func() time.Time, requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenStr := c.GetHeader("Authorization")
 		if tokenStr == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			problem.Respond(c, problem.Unauthorized("Authorization header required"))
 			return
 		}
 		if len(tokenStr) > 7 && strings.HasPrefix(tokenStr, "Bearer ") {
 			tokenStr = tokenStr[7:]
 		}
 
-		claims, err := utils.ParseToken(tokenStr)
+		claims, err := tokens.ParseToken(tokenStr)
 		if err != nil || claims.Type != "access" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid access token"})
-			c.Abort()
+			problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "Invalid access token"))
 			return
 		}
 
+		// set before the role check so that impersonated requests refused
+		// here are still audited
+		if claims.ImpersonatorID != 0 {
+			session, err := sessions.Get(c.Request.Context(), int(claims.UserID), claims.SessionID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				respondError(c, err, "Error fetching impersonation session")
+				return
+			}
+			if err != nil || session.ExpiresAt == nil || !now().Before(*session.ExpiresAt) {
+				problem.Respond(c, problem.New(http.StatusUnauthorized, problem.CodeInvalidToken, "Impersonation session has ended"))
+				return
+			}
+			c.Set("impersonation", claims)
+		}
+
 		if requiredRole != "" && claims.Role != requiredRole {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
-			c.Abort()
+			problem.Respond(c, problem.Forbidden("Insufficient permissions"))
 			return
 		}
 
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		// so that everything the handler logs names the user
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", claims.UserID))
+		c.Next()
+	}
+}
+
+// currentUserID returns the ID of the authenticated user set by JWTAuthMiddleware.
+func currentUserID(c *gin.Context) (int, bool) {
+	v, exists := c.Get("user_id")
+	if !exists {
+		return 0, false
+	}
+	id, ok := v.(uint)
+	return int(id), ok
+}
+
+// currentImpersonation returns the claims of the impersonation token the
+// request was made with, set by JWTAuthMiddleware.
+func currentImpersonation(c *gin.Context) (*utils.Claims, bool) {
+	v, exists := c.Get("impersonation")
+	if !exists {
+		return nil, false
+	}
+	claims, ok := v.(*utils.Claims)
+	return claims, ok
+}
+
+// currentImpersonatorID returns the admin acting as the current user when
+// the request was made with an impersonation token.
+func currentImpersonatorID(c *gin.Context) (int, bool) {
+	claims, ok := currentImpersonation(c)
+	if !ok {
+		return 0, false
+	}
+	return int(claims.ImpersonatorID), true
+}
+
+// ImpersonationAuditMiddleware logs every request made with an
+// impersonation token once it has been handled, including those
+// JWTAuthMiddleware refused.
+func ImpersonationAuditMiddleware(sessions store.SessionStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		claims, ok := currentImpersonation(c)
+		if !ok {
+			return
+		}
+		err := sessions.LogImpersonation(c.Request.Context(), models.ImpersonationRequest{
+			SessionID:      claims.SessionID,
+			ImpersonatorID: int(claims.ImpersonatorID),
+			UserID:         int(claims.UserID),
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			Status:         c.Writer.Status(),
+			IPAddress:      c.ClientIP(),
+			UserAgent:      c.GetHeader("User-Agent"),
+		})
+		if err != nil {
+			requestLogger(c).Error("Error logging impersonated request", "error", err)
+		}
+	}
+}
+
+// AuditActorMiddleware makes the authenticated admin the actor of the
+// changes the request makes, so the stores record them in the audit log.
+// It must run after JWTAuthMiddleware.
+func AuditActorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			c.Next()
+			return
+		}
+		ctx := store.WithActor(c.Request.Context(), store.Actor{
+			UserID:    userID,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.GetHeader("User-Agent"),
+		})
+		c.Request = c.Request.WithContext(ctx)
 		c.Next()
 	}
 }
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// A request gets a span named after its route, continuing the caller's
+// trace, and its access log carries the span's IDs. Probes are not traced.
+func TestTracing(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	r := gin.New()
+	r.Use(Tracing("test"), RequestLogger(slog.New(slog.NewJSONHandler(&logs, nil))))
+	r.GET("/products/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req := httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("%d spans, want the one of /products/:id", len(ended))
+	}
+	span := ended[0]
+	if span.Name() != "GET /products/:id" || span.SpanContext().TraceID().String() != traceID || span.Parent().SpanID().String() != parentID {
+		t.Errorf("span %s in trace %s below %s, want GET /products/:id in %s below %s",
+			span.Name(), span.SpanContext().TraceID(), span.Parent().SpanID(), traceID, parentID)
+	}
+
+	var entry struct {
+		Path    string
+		TraceID string `json:"trace_id"`
+		SpanID  string `json:"span_id"`
+	}
+	if err := json.NewDecoder(&logs).Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Path != "/products/7" || entry.TraceID != traceID || entry.SpanID != span.SpanContext().SpanID().String() {
+		t.Errorf("access log %+v, want the IDs of span %s", entry, span.SpanContext().SpanID())
+	}
+}
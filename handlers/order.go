@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaceOrder checks the user's cart out as a placed order at the cart's
+// prices and empties the cart. Admins impersonating the user cannot order
+// for them.
+func PlaceOrder(orders store.OrderStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+		if _, impersonated := currentImpersonatorID(c); impersonated {
+			problem.Respond(c, problem.Forbidden("Orders cannot be placed while impersonating"))
+			return
+		}
+
+		ctx := c.Request.Context()
+		orderID, err := orders.Checkout(ctx, userID)
+		if errors.Is(err, store.ErrInvalid) {
+			problem.Respond(c, problem.Invalid("items", "required", "The cart is empty"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error placing order")
+			return
+		}
+
+		order, err := orders.Get(ctx, userID, orderID)
+		if err != nil {
+			respondError(c, err, "Error querying order", "order_id", orderID)
+			return
+		}
+
+		requestLogger(c).Info("Order placed", "order_id", orderID, "total", order.Total)
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Order placed",
+			"order":   order,
+		})
+	}
+}
+
+// GetOrders lists the user's orders, newest first
+func GetOrders(orders store.OrderStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+		limit, offset := parsePagination(c)
+
+		list, err := orders.List(c.Request.Context(), store.OrderFilter{UserID: userID, Limit: limit, Offset: offset})
+		if err != nil {
+			respondError(c, err, "Error querying orders")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"orders": list,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
+
+// GetOrder returns one of the user's orders with its items
+func GetOrder(orders store.OrderStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		orderID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid order ID"))
+			return
+		}
+
+		order, err := orders.Get(c.Request.Context(), userID, orderID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Order not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying order")
+			return
+		}
+
+		c.JSON(http.StatusOK, order)
+	}
+}
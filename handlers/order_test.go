@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+func TestPlaceOrder(t *testing.T) {
+	e := newTestEnv(t)
+	e.routeImpersonation()
+	e.route(http.MethodPost, "/user/orders", "user", PlaceOrder(e.Stores.Orders))
+	e.route(http.MethodGet, "/user/orders/:id", "user", GetOrder(e.Stores.Orders))
+	e.route(http.MethodPost, "/user/products/:id/reviews", "user", CreateReview(e.Stores.Reviews, e.Stores.Orders))
+	u := e.User()
+	otherToken := e.User().Token
+	adminToken := e.Admin().Token
+	productID := e.Product(nil).ID
+
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/user/orders", u.Token, nil), http.StatusBadRequest, "validation_failed")
+	if _, _, err := e.Stores.Carts.AddItem(context.Background(), u.ID, productID, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+	_, impersonationToken := e.impersonate(adminToken, u.ID)
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, "/user/orders", impersonationToken, nil), http.StatusForbidden, "forbidden")
+
+	order := testutil.Expect[struct{ Order models.Order }](t, e.Do(http.MethodPost, "/user/orders", u.Token, nil), http.StatusCreated).Order
+	if order.Status != "placed" || len(order.Items) != 1 || order.Items[0].ProductID != productID || order.Items[0].Quantity != 2 {
+		t.Errorf("placed order %+v, want 2 of product %d", order, productID)
+	}
+	path := fmt.Sprintf("/user/orders/%d", order.ID)
+	testutil.Expect[models.Order](t, e.Do(http.MethodGet, path, u.Token, nil), http.StatusOK)
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, path, otherToken, nil), http.StatusNotFound, "not_found")
+
+	// ordering opens the product to the customer's review
+	testutil.Expect[map[string]any](t, e.Do(http.MethodPost, fmt.Sprintf("/user/products/%d/reviews", productID), u.Token,
+		map[string]any{"rating": 5, "title": "Great", "body": "Fits well."}), http.StatusCreated)
+}
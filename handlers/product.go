@@ -2,205 +2,88 @@
 package handlers
 
 import (
-	"database/sql"
-	"log"
-	"my-api/models"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"my-api/metrics"
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
 
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
 type CreateProductRequest struct {
 	Product    models.Product            `json:"product"`
 	Attributes []models.ProductAttribute `json:"attributes"`
-	Variations []models.VariationProduct `json:"variations"`
+	Variations []models.VariationProduct `json:"variations" binding:"dive"`
 }
 
-func AddProduct(db *sql.DB) gin.HandlerFunc {
-	return func(c *gin.Context) {
+func AddProduct(products store.ProductStore) gin.HandlerFunc {
+	return openapi.Accepts[CreateProductRequest](func(c *gin.Context) {
 		var req CreateProductRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		if err := c.ShouldBindJSON(&req); err != nil {
+			problem.Respond(c, problem.Binding(err))
 			return
 		}
 
 		product := req.Product
-		// Validate required fields
-		if product.ProductName == "" || product.ProductCode == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Product name and code are required"})
-			return
-		}
 		if product.ID != 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "ID should not be provided"})
-			return
-		}
-
-		// Validate foreign keys
-		var count int
-		if product.BrandID != 0 {
-			err := db.QueryRow("SELECT COUNT(*) FROM brands WHERE id = $1", product.BrandID).Scan(&count)
-			if err != nil || count == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid brand_id"})
-				return
-			}
-		}
-		err := db.QueryRow("SELECT COUNT(*) FROM categories WHERE id = $1", product.CategoryID).Scan(&count)
-		if err != nil || count == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category_id"})
+			problem.Respond(c, problem.Invalid("product.id", "excluded", "ID should not be provided"))
 			return
 		}
-		if product.SubCategoryID != nil {
-			err = db.QueryRow("SELECT COUNT(*) FROM subcategories WHERE id = $1", *product.SubCategoryID).Scan(&count)
-			if err != nil || count == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sub_category_id"})
-				return
-			}
-		}
 
-		// Log the received product
-		log.Printf("Received product: %+v, attributes: %+v, variations: %+v", product, req.Attributes, req.Variations)
-
-		// Start a transaction
-		tx, err := db.Begin()
-		if err != nil {
-			log.Printf("Transaction begin error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-			return
-		}
-		defer tx.Rollback()
+		requestLogger(c).Debug("Adding product", "product_code", product.ProductCode,
+			"attributes", len(req.Attributes), "variations", len(req.Variations))
 
-		// Insert product
-		query := `
-            INSERT INTO products (
-                brand_id, category_id, sub_category_id, p_code, weight, product_name, 
-                product_code, price, tax_type, image, serial_number, is_saleable, 
-                is_barcode, is_warranty, is_variation, status, rating, current_stock
-            ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
-            RETURNING id`
-		var productID int
-		err = tx.QueryRow(
-			query,
-			product.BrandID,
-			product.CategoryID,
-			product.SubCategoryID,
-			product.PCode,
-			product.Weight,
-			product.ProductName,
-			product.ProductCode,
-			product.Price,
-			product.TaxType,
-			product.Image,
-			product.SerialNumber,
-			product.IsSaleable,
-			product.IsBarcode,
-			product.IsWarranty,
-			product.IsVariation,
-			product.Status,
-			product.Rating,
-			product.CurrentStock,
-		).Scan(&productID)
+		productID, err := products.Create(c.Request.Context(), product, req.Attributes, req.Variations)
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
-				log.Printf("Duplicate key error: %v", pqErr)
-				c.JSON(http.StatusConflict, gin.H{"error": "Product already exists"})
-				return
-			}
-			log.Printf("Insert product error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create product"})
-			return
-		}
-
-		// Insert attributes
-		for _, attr := range req.Attributes {
-			// Validate attribute_id and attribute_value_id
-			err = tx.QueryRow("SELECT COUNT(*) FROM attributes WHERE id = $1", attr.AttributeID).Scan(&count)
-			if err != nil || count == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute_id"})
+			var invalidRef *store.InvalidRefError
+			if errors.As(err, &invalidRef) {
+				problem.Respond(c, problem.InvalidReference(invalidRef.Field, "Invalid "+invalidRef.Field))
 				return
 			}
-			err = tx.QueryRow("SELECT COUNT(*) FROM attribute_values WHERE id = $1 AND attribute_id = $2", attr.AttributeValueID, attr.AttributeID).Scan(&count)
-			if err != nil || count == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attribute_value_id"})
+			var conflict *store.ConflictError
+			if errors.As(err, &conflict) {
+				problem.Respond(c, problem.AlreadyExists(conflict.Field, "Product already exists"))
 				return
 			}
-
-			attrQuery := `
-                INSERT INTO product_attributes (product_id, attribute_id, attribute_value_id)
-                VALUES ($1, $2, $3)`
-			_, err = tx.Exec(attrQuery, productID, attr.AttributeID, attr.AttributeValueID)
-			if err != nil {
-				log.Printf("Insert attribute error: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add attributes"})
-				return
-			}
-		}
-
-		// Insert variations
-		for _, variation := range req.Variations {
-			if variation.SKU == "" {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Variation SKU is required"})
-				return
-			}
-			varQuery := `
-                INSERT INTO variation_products (product_id, sku, sale_price, default_sell_price, discount, image, current_stock)
-                VALUES ($1, $2, $3, $4, $5, $6, $7)`
-			_, err = tx.Exec(
-				varQuery,
-				productID,
-				variation.SKU,
-				variation.SalePrice,
-				variation.DefaultSellPrice,
-				variation.Discount,
-				variation.Image,
-				variation.CurrentStock,
-			)
-			if err != nil {
-				log.Printf("Insert variation error: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add variations"})
-				return
-			}
-		}
-
-		// Commit transaction
-		if err = tx.Commit(); err != nil {
-			log.Printf("Transaction commit error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			requestLogger(c).Error("Insert product error", "error", err)
+			problem.Respond(c, problem.Internal("Failed to create product"))
 			return
 		}
 
 		product.ID = productID
+		product.Rating = 0
+		product.RatingCount = 0
+		metrics.ProductsCreated.Inc()
 		c.JSON(http.StatusCreated, gin.H{
 			"message":    "Product added successfully",
 			"product":    product,
 			"attributes": req.Attributes,
 			"variations": req.Variations,
 		})
-	}
+	})
 }
 
-func GetProductByID(db *sql.DB) gin.HandlerFunc {
+func GetProductByID(products store.ProductStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-		var product models.Product
-		query := `
-            SELECT id, brand_id, category_id, sub_category_id, product_name, product_code, 
-                   tax_type, image, serial_number, is_saleable, is_barcode, is_warranty, 
-                   is_variation, status, rating, current_stock, price
-            FROM products WHERE id = $1`
-		err := db.QueryRow(query, id).Scan(
-			&product.ID, &product.BrandID, &product.CategoryID, &product.SubCategoryID,
-			&product.ProductName, &product.ProductCode, &product.TaxType, &product.Image,
-			&product.SerialNumber, &product.IsSaleable, &product.IsBarcode, &product.IsWarranty,
-			&product.IsVariation, &product.Status, &product.Rating, &product.CurrentStock, &product.Price,
-		)
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid product ID"))
+			return
+		}
+
+		product, err := products.Get(c.Request.Context(), id)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Product not found"))
 			return
 		}
 		if err != nil {
-			log.Println("Error querying product:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product"})
+			requestLogger(c).Error("Error querying product", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch product"))
 			return
 		}
 
@@ -208,44 +91,15 @@ func GetProductByID(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-func GetAllProducts(db *sql.DB) gin.HandlerFunc {
+func GetAllProducts(products store.ProductStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		query := `
-            SELECT id, brand_id, category_id, sub_category_id, product_name, product_code, 
-                   tax_type, image, serial_number, is_saleable, is_barcode, is_warranty, 
-                   is_variation, status, rating, current_stock, price
-            FROM products`
-		rows, err := db.Query(query)
+		list, err := products.List(c.Request.Context())
 		if err != nil {
-			log.Println("Error querying products:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
-			return
-		}
-		defer rows.Close()
-
-		var products []models.Product
-		for rows.Next() {
-			var product models.Product
-			err := rows.Scan(
-				&product.ID, &product.BrandID, &product.CategoryID, &product.SubCategoryID,
-				&product.ProductName, &product.ProductCode, &product.TaxType, &product.Image,
-				&product.SerialNumber, &product.IsSaleable, &product.IsBarcode, &product.IsWarranty,
-				&product.IsVariation, &product.Status, &product.Rating, &product.CurrentStock, &product.Price,
-			)
-			if err != nil {
-				log.Println("Error scanning product:", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process products"})
-				return
-			}
-			products = append(products, product)
-		}
-
-		if err = rows.Err(); err != nil {
-			log.Println("Error iterating products:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products"})
+			requestLogger(c).Error("Error querying products", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch products"))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"products": products})
+		c.JSON(http.StatusOK, gin.H{"products": list})
 	}
 }
@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewInput struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5"`
+	Title  string `json:"title" binding:"required,max=150"`
+	Body   string `json:"body" binding:"required"`
+}
+
+type ReviewStatusInput struct {
+	Status string `json:"status" binding:"required,oneof=approved rejected hidden pending"`
+}
+
+var reviewSorts = map[string]store.ReviewSort{
+	"recent":      store.ReviewSortRecent,
+	"helpful":     store.ReviewSortHelpful,
+	"rating_high": store.ReviewSortRatingHigh,
+	"rating_low":  store.ReviewSortRatingLow,
+}
+
+func parsePagination(c *gin.Context) (int, int) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
+
+// GetProductReviews lists the approved reviews of a product along with its
+// rating summary
+func GetProductReviews(reviews store.ReviewStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid product ID"))
+			return
+		}
+
+		sort, ok := reviewSorts[c.DefaultQuery("sort", "recent")]
+		if !ok {
+			problem.Respond(c, problem.Invalid("sort", "oneof", "Invalid sort, use recent, helpful, rating_high or rating_low"))
+			return
+		}
+		limit, offset := parsePagination(c)
+
+		summary, err := reviews.RatingSummary(c.Request.Context(), productID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Product not found"))
+			return
+		}
+		if err != nil {
+			requestLogger(c).Error("Error querying product rating", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch reviews"))
+			return
+		}
+
+		list, err := reviews.ListApproved(c.Request.Context(), productID, sort, limit, offset)
+		if err != nil {
+			requestLogger(c).Error("Error querying reviews", "error", err)
+			problem.Respond(c, problem.Internal("Failed to fetch reviews"))
+			return
+		}
+		for i := range list {
+			list[i].ModeratedBy = nil
+			list[i].ModeratedAt = nil
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"product_id":   productID,
+			"rating":       summary.Rating,
+			"rating_count": summary.RatingCount,
+			"distribution": summary.Distribution,
+			"reviews":      list,
+			"limit":        limit,
+			"offset":       offset,
+		})
+	}
+}
+
+// CreateReview posts a review for a product the user ordered; it stays
+// pending until an admin approves it. Each user can review a product once.
+func CreateReview(reviews store.ReviewStore, orders store.OrderStore) gin.HandlerFunc {
+	return openapi.Accepts[ReviewInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		productID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid product ID"))
+			return
+		}
+
+		var input ReviewInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		purchased, err := orders.HasPurchased(c.Request.Context(), userID, productID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Product not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error checking purchase")
+			return
+		}
+		if !purchased {
+			problem.Respond(c, problem.Forbidden("Only customers who ordered this product can review it"))
+			return
+		}
+
+		reviewID, err := reviews.Create(c.Request.Context(), models.ProductReview{
+			ProductID: productID,
+			UserID:    userID,
+			Rating:    input.Rating,
+			Title:     input.Title,
+			Body:      input.Body,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrConflict):
+				problem.Respond(c, problem.AlreadyExists("product_id", "You have already reviewed this product"))
+			case errors.Is(err, store.ErrInvalidReference):
+				problem.Respond(c, problem.NotFound("Product not found"))
+			default:
+				respondError(c, err, "Error inserting review")
+			}
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":   "Review submitted for moderation",
+			"review_id": reviewID,
+			"status":    "pending",
+		})
+	})
+}
+
+// UpdateReview edits the user's own review and sends it back to moderation
+func UpdateReview(reviews store.ReviewStore) gin.HandlerFunc {
+	return openapi.Accepts[ReviewInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		reviewID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid review ID"))
+			return
+		}
+
+		var input ReviewInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		err = reviews.Update(c.Request.Context(), models.ProductReview{
+			ID:     reviewID,
+			UserID: userID,
+			Rating: input.Rating,
+			Title:  input.Title,
+			Body:   input.Body,
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Review not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error updating review")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Review updated and resubmitted for moderation",
+			"review_id": reviewID,
+			"status":    "pending",
+		})
+	})
+}
+
+// DeleteReview removes the user's own review
+func DeleteReview(reviews store.ReviewStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		reviewID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid review ID"))
+			return
+		}
+
+		err = reviews.Delete(c.Request.Context(), userID, reviewID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Review not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting review")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Review deleted",
+			"review_id": reviewID,
+		})
+	}
+}
+
+type ReviewVoteInput struct {
+	Helpful *bool `json:"helpful" binding:"required"`
+}
+
+// VoteReview records (or changes) the user's helpfulness vote on an approved review
+func VoteReview(reviews store.ReviewStore) gin.HandlerFunc {
+	return openapi.Accepts[ReviewVoteInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		reviewID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid review ID"))
+			return
+		}
+
+		var input ReviewVoteInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		helpful, notHelpful, err := reviews.Vote(c.Request.Context(), userID, reviewID, *input.Helpful)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				problem.Respond(c, problem.NotFound("Review not found"))
+			case errors.Is(err, store.ErrForbidden):
+				problem.Respond(c, problem.Forbidden("You cannot vote on your own review"))
+			default:
+				respondError(c, err, "Error storing review vote")
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "Vote recorded",
+			"review_id":         reviewID,
+			"helpful_count":     helpful,
+			"not_helpful_count": notHelpful,
+		})
+	})
+}
+
+// AdminGetReviews lists reviews for moderation, optionally filtered by
+// status and product (admin only)
+func AdminGetReviews(reviews store.ReviewStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, offset := parsePagination(c)
+		productID, _ := strconv.Atoi(c.Query("product_id"))
+
+		list, err := reviews.List(c.Request.Context(), store.ReviewFilter{
+			Status:    c.Query("status"),
+			ProductID: productID,
+			Limit:     limit,
+			Offset:    offset,
+		})
+		if err != nil {
+			respondError(c, err, "Error querying reviews")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"reviews": list,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+// ModerateReview approves, rejects or hides a review and updates the
+// product's rating accordingly (admin only)
+func ModerateReview(reviews store.ReviewStore) gin.HandlerFunc {
+	return openapi.Accepts[ReviewStatusInput](func(c *gin.Context) {
+		adminID, _ := currentUserID(c)
+
+		reviewID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid review ID"))
+			return
+		}
+
+		var input ReviewStatusInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		err = reviews.Moderate(c.Request.Context(), reviewID, input.Status, adminID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Review not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error moderating review")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Review " + input.Status,
+			"review_id": reviewID,
+			"status":    input.Status,
+		})
+	})
+}
+
+// AdminDeleteReview permanently removes a review (admin only)
+func AdminDeleteReview(reviews store.ReviewStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid review ID"))
+			return
+		}
+
+		err = reviews.Delete(c.Request.Context(), store.AnyUser, reviewID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Review not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting review")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Review deleted",
+			"review_id": reviewID,
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+func TestCreateReviewNeedsPurchase(t *testing.T) {
+	e := newTestEnv(t)
+	e.route(http.MethodPost, "/user/products/:id/reviews", "user", CreateReview(e.Stores.Reviews, e.Stores.Orders))
+	u := e.User()
+	ordered, cancelled, other := e.Product(nil).ID, e.Product(nil).ID, e.Product(nil).ID
+
+	ctx := context.Background()
+	for _, order := range []models.Order{
+		{UserID: u.ID, Items: []models.OrderItem{{ProductID: ordered, Quantity: 1, UnitPrice: 10}}},
+		{UserID: u.ID, Status: "cancelled", Items: []models.OrderItem{{ProductID: cancelled, Quantity: 1, UnitPrice: 10}}},
+	} {
+		if _, err := e.Stores.Orders.Create(ctx, order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	review := func(productID int) string { return fmt.Sprintf("/user/products/%d/reviews", productID) }
+	input := map[string]any{"rating": 5, "title": "Great", "body": "Fits well."}
+
+	got := testutil.Expect[struct {
+		ReviewID int `json:"review_id"`
+		Status   string
+	}](t, e.Do(http.MethodPost, review(ordered), u.Token, input), http.StatusCreated)
+	if got.ReviewID == 0 || got.Status != "pending" {
+		t.Errorf("review of an ordered product: %+v", got)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, review(ordered), u.Token, input), http.StatusConflict, "already_exists")
+
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, review(cancelled), u.Token, input), http.StatusForbidden, "forbidden")
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, review(other), u.Token, input), http.StatusForbidden, "forbidden")
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, review(other+1000), u.Token, input), http.StatusNotFound, "not_found")
+}
@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"my-api/countries"
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShippingZoneInput struct {
+	ZoneName           string   `json:"zone_name" binding:"required"`
+	Countries          []string `json:"countries" binding:"required,min=1"`
+	PostalCodePatterns []string `json:"postal_code_patterns"`
+	Position           int      `json:"position"`
+	Status             *int     `json:"status"`
+}
+
+type ShippingRateTierInput struct {
+	MinValue float64  `json:"min_value" binding:"min=0"`
+	MaxValue *float64 `json:"max_value"`
+	Rate     float64  `json:"rate" binding:"min=0"`
+}
+
+type ShippingMethodInput struct {
+	MethodName            string                  `json:"method_name" binding:"required"`
+	RateType              string                  `json:"rate_type" binding:"required,oneof=flat weight price"`
+	BaseRate              float64                 `json:"base_rate" binding:"min=0"`
+	FreeShippingThreshold *float64                `json:"free_shipping_threshold"`
+	EstimatedDays         *string                 `json:"estimated_days"`
+	Status                *int                    `json:"status"`
+	Tiers                 []ShippingRateTierInput `json:"tiers" binding:"dive"`
+}
+
+// validateShippingZoneInput turns the zone's countries into ISO codes,
+// normalizes its postal-code patterns and rejects patterns that path.Match
+// cannot compile
+func validateShippingZoneInput(input *ShippingZoneInput) string {
+	for i, country := range input.Countries {
+		country = strings.TrimSpace(country)
+		if country == "*" {
+			input.Countries[i] = country
+			continue
+		}
+		known, ok := countries.Lookup(country)
+		if !ok {
+			return "Unknown country: " + country
+		}
+		input.Countries[i] = known.Code
+	}
+	if input.PostalCodePatterns == nil {
+		input.PostalCodePatterns = []string{}
+	}
+	for i, pattern := range input.PostalCodePatterns {
+		pattern = normalizePostalCode(pattern)
+		if pattern == "" {
+			return "Postal code patterns must not be empty"
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "Invalid postal code pattern: " + input.PostalCodePatterns[i]
+		}
+		input.PostalCodePatterns[i] = pattern
+	}
+	return ""
+}
+
+func validateShippingMethodInput(input *ShippingMethodInput) string {
+	if input.RateType != "flat" && len(input.Tiers) == 0 {
+		return "At least one rate tier is required for " + input.RateType + " based methods"
+	}
+	for _, tier := range input.Tiers {
+		if tier.MaxValue != nil && *tier.MaxValue <= tier.MinValue {
+			return "Tier max_value must be greater than min_value"
+		}
+	}
+	return ""
+}
+
+func statusOrDefault(status *int) int {
+	if status == nil {
+		return 1
+	}
+	return *status
+}
+
+func normalizePostalCode(code string) string {
+	return strings.ReplaceAll(countries.NormalizePostalCode(code), " ", "")
+}
+
+// Specificities of a zone's match of an address, from none to its postal
+// code; the most specific zone of an address ships it.
+const (
+	zoneUnmatched = iota
+	zoneWildcard
+	zoneCountry
+	zonePostalCode
+)
+
+// shippingZoneMatch reports how specifically an address falls into the
+// zone. A zone matches when the country is listed (or the zone lists "*")
+// and, if the zone has postal-code patterns, at least one of them matches
+// the postal code.
+func shippingZoneMatch(zone models.ShippingZone, country, postalCode string) int {
+	match := zoneUnmatched
+	for _, zc := range zone.Countries {
+		if strings.EqualFold(zc, strings.TrimSpace(country)) {
+			match = zoneCountry
+			break
+		}
+		if zc == "*" {
+			match = zoneWildcard
+		}
+	}
+	if match == zoneUnmatched || len(zone.PostalCodePatterns) == 0 {
+		return match
+	}
+	code := normalizePostalCode(postalCode)
+	for _, pattern := range zone.PostalCodePatterns {
+		if ok, _ := path.Match(pattern, code); ok {
+			return zonePostalCode
+		}
+	}
+	return zoneUnmatched
+}
+
+// shippingZoneFor returns the most specific of the zones matching the
+// address, the first by position among equally specific ones, or nil.
+func shippingZoneFor(zones []models.ShippingZone, country, postalCode string) *models.ShippingZone {
+	var zone *models.ShippingZone
+	best := zoneUnmatched
+	for i := range zones {
+		if match := shippingZoneMatch(zones[i], country, postalCode); match > best {
+			zone, best = &zones[i], match
+		}
+	}
+	return zone
+}
+
+// parseWeightKg converts the free-text products.weight column ("1.5",
+// "1,5 kg", "500g", "2 lb") to kilograms. Values without a unit are taken as
+// kilograms and a missing weight as zero; anything else is an error.
+func parseWeightKg(weight *string) (float64, error) {
+	if weight == nil || strings.TrimSpace(*weight) == "" {
+		return 0, nil
+	}
+	s := strings.ToLower(strings.TrimSpace(*weight))
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	number, unit := s, ""
+	if end >= 0 {
+		number, unit = s[:end], strings.TrimSpace(s[end:])
+	}
+	value, err := strconv.ParseFloat(strings.Replace(number, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("weight %q is not a number", *weight)
+	}
+	switch unit {
+	case "", "kg", "kgs", "kilogram", "kilograms":
+		return value, nil
+	case "g", "gm", "gms", "gram", "grams":
+		return value / 1000, nil
+	case "lb", "lbs":
+		return value * 0.45359237, nil
+	case "oz":
+		return value * 0.028349523125, nil
+	default:
+		return 0, fmt.Errorf("weight %q has an unknown unit", *weight)
+	}
+}
+
+// quoteShippingMethod returns the cost of a method for the given cart
+// subtotal and weight, or false when no tier covers the cart.
+func quoteShippingMethod(method models.ShippingMethod, subtotal, weightKg float64) (float64, bool) {
+	if method.FreeShippingThreshold != nil && subtotal >= *method.FreeShippingThreshold {
+		return 0, true
+	}
+
+	var measure float64
+	switch method.RateType {
+	case "flat":
+		return roundMoney(method.BaseRate), true
+	case "weight":
+		measure = weightKg
+	case "price":
+		measure = subtotal
+	default:
+		return 0, false
+	}
+
+	for _, tier := range method.Tiers {
+		if measure >= tier.MinValue && (tier.MaxValue == nil || measure < *tier.MaxValue) {
+			return roundMoney(method.BaseRate + tier.Rate), true
+		}
+	}
+	return 0, false
+}
+
+func shippingMethodFromInput(input ShippingMethodInput) models.ShippingMethod {
+	method := models.ShippingMethod{
+		MethodName:            input.MethodName,
+		RateType:              input.RateType,
+		BaseRate:              input.BaseRate,
+		FreeShippingThreshold: input.FreeShippingThreshold,
+		EstimatedDays:         input.EstimatedDays,
+		Status:                statusOrDefault(input.Status),
+	}
+	for _, tier := range input.Tiers {
+		method.Tiers = append(method.Tiers, models.ShippingRateTier{
+			MinValue: tier.MinValue,
+			MaxValue: tier.MaxValue,
+			Rate:     tier.Rate,
+		})
+	}
+	return method
+}
+
+func shippingZoneFromInput(input ShippingZoneInput) models.ShippingZone {
+	return models.ShippingZone{
+		ZoneName:           input.ZoneName,
+		Countries:          input.Countries,
+		PostalCodePatterns: input.PostalCodePatterns,
+		Position:           input.Position,
+		Status:             statusOrDefault(input.Status),
+	}
+}
+
+// CreateShippingZone creates a new shipping zone (admin only)
+func CreateShippingZone(shipping store.ShippingStore) gin.HandlerFunc {
+	return openapi.Accepts[ShippingZoneInput](func(c *gin.Context) {
+		var input ShippingZoneInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		if msg := validateShippingZoneInput(&input); msg != "" {
+			problem.Respond(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, msg))
+			return
+		}
+
+		zoneID, err := shipping.CreateZone(c.Request.Context(), shippingZoneFromInput(input))
+		if err != nil {
+			respondError(c, err, "Error inserting shipping zone")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Shipping zone created",
+			"zone_id": zoneID,
+		})
+	})
+}
+
+// GetAllShippingZones lists every zone with its methods (admin only)
+func GetAllShippingZones(shipping store.ShippingStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zones, err := shipping.ListZones(c.Request.Context(), false)
+		if err != nil {
+			respondError(c, err, "Error querying shipping zones")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"shipping_zones": zones})
+	}
+}
+
+// GetShippingZoneByID returns a single zone with its methods (admin only)
+func GetShippingZoneByID(shipping store.ShippingStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zoneID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid zone ID"))
+			return
+		}
+
+		zone, err := shipping.GetZone(c.Request.Context(), zoneID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping zone not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying shipping zone")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"shipping_zone": zone})
+	}
+}
+
+// UpdateShippingZone updates a zone's name, coverage and ordering (admin only)
+func UpdateShippingZone(shipping store.ShippingStore) gin.HandlerFunc {
+	return openapi.Accepts[ShippingZoneInput](func(c *gin.Context) {
+		zoneID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid zone ID"))
+			return
+		}
+
+		var input ShippingZoneInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		if msg := validateShippingZoneInput(&input); msg != "" {
+			problem.Respond(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, msg))
+			return
+		}
+
+		zone := shippingZoneFromInput(input)
+		zone.ID = zoneID
+		err = shipping.UpdateZone(c.Request.Context(), zone)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping zone not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error updating shipping zone")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Shipping zone updated",
+			"zone_id": zoneID,
+		})
+	})
+}
+
+// DeleteShippingZone deletes a zone and its methods (admin only)
+func DeleteShippingZone(shipping store.ShippingStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		zoneID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid zone ID"))
+			return
+		}
+
+		err = shipping.DeleteZone(c.Request.Context(), zoneID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping zone not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting shipping zone")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Shipping zone deleted",
+			"zone_id": zoneID,
+		})
+	}
+}
+
+// CreateShippingMethod adds a method with its rate tiers to a zone (admin only)
+func CreateShippingMethod(shipping store.ShippingStore) gin.HandlerFunc {
+	return openapi.Accepts[ShippingMethodInput](func(c *gin.Context) {
+		zoneID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid zone ID"))
+			return
+		}
+
+		var input ShippingMethodInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		if msg := validateShippingMethodInput(&input); msg != "" {
+			problem.Respond(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, msg))
+			return
+		}
+
+		method := shippingMethodFromInput(input)
+		method.ZoneID = zoneID
+		methodID, err := shipping.CreateMethod(c.Request.Context(), method)
+		if errors.Is(err, store.ErrInvalidReference) {
+			problem.Respond(c, problem.NotFound("Shipping zone not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error inserting shipping method")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":   "Shipping method created",
+			"method_id": methodID,
+			"zone_id":   zoneID,
+		})
+	})
+}
+
+// UpdateShippingMethod replaces a method's settings and rate tiers (admin only)
+func UpdateShippingMethod(shipping store.ShippingStore) gin.HandlerFunc {
+	return openapi.Accepts[ShippingMethodInput](func(c *gin.Context) {
+		methodID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid method ID"))
+			return
+		}
+
+		var input ShippingMethodInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+		if msg := validateShippingMethodInput(&input); msg != "" {
+			problem.Respond(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, msg))
+			return
+		}
+
+		method := shippingMethodFromInput(input)
+		method.ID = methodID
+		err = shipping.UpdateMethod(c.Request.Context(), method)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping method not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error updating shipping method")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Shipping method updated",
+			"method_id": methodID,
+		})
+	})
+}
+
+// DeleteShippingMethod deletes a method and its tiers (admin only)
+func DeleteShippingMethod(shipping store.ShippingStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		methodID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid method ID"))
+			return
+		}
+
+		err = shipping.DeleteMethod(c.Request.Context(), methodID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping method not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting shipping method")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "Shipping method deleted",
+			"method_id": methodID,
+		})
+	}
+}
+
+// ShippingOptionsInput selects the address to ship to, the default shipping
+// address when left out.
+type ShippingOptionsInput struct {
+	ShippingAddressID *int `json:"shipping_address_id"`
+}
+
+// GetShippingOptions quotes every active method of the most specific zone
+// that covers the chosen shipping address: one matching its postal code
+// over one listing its country over a "*" zone, and by position among
+// those. When no address is given the user's default shipping address is
+// used. Products whose weight cannot be read are refused.
+func GetShippingOptions(addresses store.AddressStore, carts store.CartStore, shipping store.ShippingStore) gin.HandlerFunc {
+	return openapi.AcceptsOptional[ShippingOptionsInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		// an empty body selects the default address like {} does
+		var input ShippingOptionsInput
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		ctx := c.Request.Context()
+		var address models.Address
+		var err error
+		if input.ShippingAddressID != nil {
+			address, err = addresses.Get(ctx, userID, *input.ShippingAddressID, store.UsageShipping)
+		} else {
+			address, err = addresses.GetDefault(ctx, userID, store.UsageShipping)
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Shipping address not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying shipping address")
+			return
+		}
+
+		items, err := fetchCartItems(ctx, carts, userID)
+		if err != nil {
+			respondError(c, err, "Error querying cart items")
+			return
+		}
+		if len(items) == 0 {
+			problem.Respond(c, problem.BadRequest("Cart is empty"))
+			return
+		}
+
+		subtotal := cartSubtotal(items)
+		var totalWeight float64
+		for _, item := range items {
+			weight, err := parseWeightKg(item.Weight)
+			if err != nil {
+				problem.Respond(c, problem.BadRequest(fmt.Sprintf("Cannot ship %s: its %v", item.ProductName, err)))
+				return
+			}
+			totalWeight += weight * float64(item.Quantity)
+		}
+
+		zones, err := shipping.ListZones(ctx, true)
+		if err != nil {
+			respondError(c, err, "Error querying shipping zones")
+			return
+		}
+
+		zone := shippingZoneFor(zones, address.Country, address.PostalCode)
+
+		options := []models.ShippingOption{}
+		if zone != nil {
+			for _, method := range zone.Methods {
+				cost, ok := quoteShippingMethod(method, subtotal, totalWeight)
+				if !ok {
+					continue
+				}
+				options = append(options, models.ShippingOption{
+					MethodID:      method.ID,
+					MethodName:    method.MethodName,
+					RateType:      method.RateType,
+					Cost:          cost,
+					EstimatedDays: method.EstimatedDays,
+				})
+			}
+		}
+
+		response := gin.H{
+			"shipping_address_id": address.ID,
+			"subtotal":            subtotal,
+			"total_weight":        totalWeight,
+			"shipping_options":    options,
+		}
+		if zone != nil {
+			response["zone_id"] = zone.ID
+			response["zone_name"] = zone.ZoneName
+		}
+
+		c.JSON(http.StatusOK, response)
+	})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+
+	"my-api/models"
+)
+
+func TestShippingZoneFor(t *testing.T) {
+	zones := []models.ShippingZone{
+		{ID: 1, Countries: []string{"*"}},
+		{ID: 2, Countries: []string{"DE", "AT"}},
+		{ID: 3, Countries: []string{"DE"}, PostalCodePatterns: []string{"10*"}},
+		{ID: 4, Countries: []string{"*"}, PostalCodePatterns: []string{"SW1*"}},
+		{ID: 5, Countries: []string{"AT"}},
+	}
+	tests := []struct {
+		country, postalCode string
+		want                int
+	}{
+		{"DE", "10115", 3},    // postal code over country and wildcard
+		{"de", "80331", 2},    // country over wildcard
+		{"AT", "1010", 2},     // first by position among countries
+		{"FR", "75001", 1},    // only the wildcard
+		{"GB", "sw1a 1aa", 4}, // postal codes are normalized
+	}
+	for _, tt := range tests {
+		zone := shippingZoneFor(zones, tt.country, tt.postalCode)
+		if zone == nil || zone.ID != tt.want {
+			t.Errorf("zone of %s %s: %+v, want %d", tt.country, tt.postalCode, zone, tt.want)
+		}
+	}
+	if zone := shippingZoneFor(zones[1:3], "FR", "75001"); zone != nil {
+		t.Errorf("zone of an uncovered address: %+v, want none", zone)
+	}
+}
+
+func TestParseWeightKg(t *testing.T) {
+	tests := []struct {
+		weight string
+		want   float64
+	}{
+		{"", 0},
+		{"1.5", 1.5},
+		{"1,5", 1.5},
+		{" 2 KG ", 2},
+		{"500g", 0.5},
+		{"250 grams", 0.25},
+		{"2 lb", 0.90718474},
+		{"16oz", 0.45359237},
+	}
+	for _, tt := range tests {
+		got, err := parseWeightKg(&tt.weight)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("parseWeightKg(%q) = %v, %v, want %v", tt.weight, got, err, tt.want)
+		}
+	}
+	if got, err := parseWeightKg(nil); got != 0 || err != nil {
+		t.Errorf("parseWeightKg(nil) = %v, %v, want 0", got, err)
+	}
+	for _, weight := range []string{"heavy", "1.2.3", "3 stone", "kg"} {
+		if got, err := parseWeightKg(&weight); err == nil {
+			t.Errorf("parseWeightKg(%q) = %v, want an error", weight, got)
+		}
+	}
+}
+
+func TestQuoteShippingMethod(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	tiers := []models.ShippingRateTier{
+		{MinValue: 0, MaxValue: ptr(1), Rate: 2},
+		{MinValue: 1, MaxValue: ptr(5), Rate: 4},
+		{MinValue: 5, Rate: 10},
+	}
+	weight := models.ShippingMethod{RateType: "weight", BaseRate: 1, Tiers: tiers}
+	price := models.ShippingMethod{RateType: "price", BaseRate: 0, Tiers: tiers[:2], FreeShippingThreshold: ptr(50)}
+	tests := []struct {
+		name               string
+		method             models.ShippingMethod
+		subtotal, weightKg float64
+		want               float64
+		ok                 bool
+	}{
+		{"flat", models.ShippingMethod{RateType: "flat", BaseRate: 4.999}, 10, 3, 5, true},
+		{"lowest weight tier", weight, 10, 0.5, 3, true},
+		{"tier minimum is inclusive", weight, 10, 1, 5, true},
+		{"open-ended tier", weight, 10, 80, 11, true},
+		{"price tier", price, 3, 0, 4, true},
+		{"no price tier", price, 20, 0, 0, false},
+		{"free shipping", price, 50, 0, 0, true},
+		{"unknown rate type", models.ShippingMethod{RateType: "volume"}, 10, 1, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := quoteShippingMethod(tt.method, tt.subtotal, tt.weightKg)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: quote %v, %t, want %v, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-api/openapi"
+	"my-api/problem"
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// savedForLaterList is the wishlist that cart items are moved into by
+// SaveCartItemForLater; it is created on first use.
+const savedForLaterList = "Saved for later"
+
+type WishlistInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type WishlistItemInput struct {
+	ProductID         int   `json:"product_id" binding:"required,min=1"`
+	VariationID       *int  `json:"variation_id,omitempty"`
+	NotifyPriceDrop   *bool `json:"notify_price_drop,omitempty"`
+	NotifyBackInStock *bool `json:"notify_back_in_stock,omitempty"`
+}
+
+func boolOrDefault(v *bool, def bool) bool {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// generateShareToken returns an unguessable, URL-safe token for public wishlist links
+func generateShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GetWishlists lists the user's wishlists with their item counts
+func GetWishlists(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		lists, err := wishlists.List(c.Request.Context(), userID)
+		if err != nil {
+			respondError(c, err, "Error querying wishlists")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"wishlists": lists})
+	}
+}
+
+// CreateWishlist creates a named wishlist for the user
+func CreateWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return openapi.Accepts[WishlistInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		var input WishlistInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		wishlistID, err := wishlists.Create(c.Request.Context(), userID, input.Name)
+		if errors.Is(err, store.ErrConflict) {
+			problem.Respond(c, problem.AlreadyExists("name", "A wishlist with this name already exists"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error inserting wishlist")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":     "Wishlist created",
+			"wishlist_id": wishlistID,
+			"name":        input.Name,
+		})
+	})
+}
+
+// GetWishlist returns one of the user's wishlists with its items
+func GetWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		w, err := wishlists.Get(c.Request.Context(), userID, wishlistID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"wishlist": w})
+	}
+}
+
+// RenameWishlist changes a wishlist's name
+func RenameWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return openapi.Accepts[WishlistInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		var input WishlistInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		err = wishlists.Rename(c.Request.Context(), userID, wishlistID, input.Name)
+		if errors.Is(err, store.ErrConflict) {
+			problem.Respond(c, problem.AlreadyExists("name", "A wishlist with this name already exists"))
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error updating wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Wishlist updated",
+			"wishlist_id": wishlistID,
+		})
+	})
+}
+
+// DeleteWishlist deletes a wishlist and its items
+func DeleteWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		err = wishlists.Delete(c.Request.Context(), userID, wishlistID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Wishlist deleted",
+			"wishlist_id": wishlistID,
+		})
+	}
+}
+
+// AddWishlistItem adds a product or a specific variation SKU to a wishlist
+func AddWishlistItem(wishlists store.WishlistStore) gin.HandlerFunc {
+	return openapi.Accepts[WishlistItemInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		var input WishlistItemInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			problem.Respond(c, problem.Binding(err))
+			return
+		}
+
+		itemID, err := wishlists.AddItem(c.Request.Context(), userID, wishlistID, store.WishlistItemInput{
+			ProductID:         input.ProductID,
+			VariationID:       input.VariationID,
+			NotifyPriceDrop:   boolOrDefault(input.NotifyPriceDrop, true),
+			NotifyBackInStock: boolOrDefault(input.NotifyBackInStock, true),
+		})
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if errors.Is(err, store.ErrInvalidReference) {
+			problem.Respond(c, problem.InvalidReference("product_id", "Invalid product_id or variation_id"))
+			return
+		}
+		if errors.Is(err, store.ErrConflict) {
+			problem.Respond(c, problem.AlreadyExists("product_id", "Item is already on this wishlist"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error inserting wishlist item")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":     "Item added to wishlist",
+			"wishlist_id": wishlistID,
+			"item_id":     itemID,
+		})
+	})
+}
+
+// RemoveWishlistItem removes an item from a wishlist
+func RemoveWishlistItem(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+		itemID, err := strconv.Atoi(c.Param("item_id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid item ID"))
+			return
+		}
+
+		err = wishlists.RemoveItem(c.Request.Context(), userID, wishlistID, itemID)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist item not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error deleting wishlist item")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Item removed from wishlist",
+			"item_id": itemID,
+		})
+	}
+}
+
+// WishlistMoveInput selects the items to move, all of them when left out.
+type WishlistMoveInput struct {
+	ItemIDs []int `json:"item_ids"`
+}
+
+// MoveWishlistToCart moves the given items (or every item when item_ids is
+// empty) from a wishlist into the cart in one transaction
+func MoveWishlistToCart(wishlists store.WishlistStore) gin.HandlerFunc {
+	return openapi.AcceptsOptional[WishlistMoveInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		var input WishlistMoveInput
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				problem.Respond(c, problem.Binding(err))
+				return
+			}
+		}
+
+		movedIDs, err := wishlists.MoveToCart(c.Request.Context(), userID, wishlistID, input.ItemIDs)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error moving wishlist items to cart")
+			return
+		}
+		if len(movedIDs) == 0 {
+			problem.Respond(c, problem.NotFound("No matching wishlist items"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Items moved to cart",
+			"wishlist_id":    wishlistID,
+			"moved_item_ids": movedIDs,
+		})
+	})
+}
+
+// SaveCartItemForLater moves a cart line into the user's "Saved for later" list
+func SaveCartItemForLater(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		itemID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid cart item ID"))
+			return
+		}
+
+		wishlistID, err := wishlists.SaveForLater(c.Request.Context(), userID, itemID, savedForLaterList)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Cart item not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error saving cart item for later")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Item saved for later",
+			"wishlist_id": wishlistID,
+		})
+	}
+}
+
+// ShareWishlist creates (or rotates) the public share token of a wishlist
+func ShareWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		token, err := generateShareToken()
+		if err != nil {
+			respondError(c, err, "Error generating share token")
+			return
+		}
+
+		err = wishlists.SetShareToken(c.Request.Context(), userID, wishlistID, &token)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error sharing wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Wishlist shared",
+			"wishlist_id": wishlistID,
+			"share_token": token,
+			"share_path":  "/wishlists/shared/" + token,
+		})
+	}
+}
+
+// UnshareWishlist revokes the public link of a wishlist
+func UnshareWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		wishlistID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			problem.Respond(c, problem.BadRequest("Invalid wishlist ID"))
+			return
+		}
+
+		err = wishlists.SetShareToken(c.Request.Context(), userID, wishlistID, nil)
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error unsharing wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Wishlist link revoked",
+			"wishlist_id": wishlistID,
+		})
+	}
+}
+
+// GetSharedWishlist returns a wishlist through its public share token
+func GetSharedWishlist(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w, err := wishlists.GetShared(c.Request.Context(), c.Param("token"))
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Respond(c, problem.NotFound("Wishlist not found"))
+			return
+		}
+		if err != nil {
+			respondError(c, err, "Error querying shared wishlist")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"wishlist": w})
+	}
+}
+
+// GetWishlistNotifications lists price-drop and back-in-stock notifications
+// for the user, newest first; pass unread=true to hide read ones
+func GetWishlistNotifications(wishlists store.WishlistStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		limit, offset := parsePagination(c)
+		notifications, err := wishlists.ListNotifications(c.Request.Context(), userID, c.Query("unread") == "true", limit, offset)
+		if err != nil {
+			respondError(c, err, "Error querying wishlist notifications")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"notifications": notifications,
+			"limit":         limit,
+			"offset":        offset,
+		})
+	}
+}
+
+// NotificationsReadInput selects the notifications to mark, all of them when
+// left out.
+type NotificationsReadInput struct {
+	IDs []int `json:"ids"`
+}
+
+// MarkWishlistNotificationsRead marks the given notifications (or all of
+// them when ids is empty) as read
+func MarkWishlistNotificationsRead(wishlists store.WishlistStore) gin.HandlerFunc {
+	return openapi.AcceptsOptional[NotificationsReadInput](func(c *gin.Context) {
+		userID, exists := currentUserID(c)
+		if !exists {
+			problem.Respond(c, problem.Unauthorized("Unauthorized"))
+			return
+		}
+
+		var input NotificationsReadInput
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				problem.Respond(c, problem.Binding(err))
+				return
+			}
+		}
+
+		updated, err := wishlists.MarkNotificationsRead(c.Request.Context(), userID, input.IDs)
+		if err != nil {
+			respondError(c, err, "Error marking wishlist notifications read")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Notifications marked as read",
+			"updated": updated,
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+)
+
+// wishlistRoutes registers the wishlist routes as package server does.
+func (e *testEnv) wishlistRoutes() {
+	w := e.Stores.Wishlists
+	e.route(http.MethodGet, "/wishlists/shared/:token", "", GetSharedWishlist(w))
+	e.route(http.MethodPost, "/user/wishlists", "user", CreateWishlist(w))
+	e.route(http.MethodGet, "/user/wishlists/:id", "user", GetWishlist(w))
+	e.route(http.MethodPost, "/user/wishlists/:id/items", "user", AddWishlistItem(w))
+	e.route(http.MethodPost, "/user/wishlists/:id/move-to-cart", "user", MoveWishlistToCart(w))
+	e.route(http.MethodPost, "/user/wishlists/:id/share", "user", ShareWishlist(w))
+	e.route(http.MethodDelete, "/user/wishlists/:id/share", "user", UnshareWishlist(w))
+}
+
+// wishlist creates a list of the user holding the products and returns its
+// ID and the IDs of its items.
+func (e *testEnv) wishlist(token string, productIDs ...int) (int, []int) {
+	e.T.Helper()
+	created := testutil.Expect[struct {
+		WishlistID int `json:"wishlist_id"`
+	}](e.T, e.Do(http.MethodPost, "/user/wishlists", token, map[string]any{"name": fmt.Sprintf("List %d", e.Next())}), http.StatusCreated)
+	path := fmt.Sprintf("/user/wishlists/%d", created.WishlistID)
+	var itemIDs []int
+	for _, id := range productIDs {
+		itemIDs = append(itemIDs, testutil.Expect[struct {
+			ItemID int `json:"item_id"`
+		}](e.T, e.Do(http.MethodPost, path+"/items", token, map[string]any{"product_id": id}), http.StatusCreated).ItemID)
+	}
+	return created.WishlistID, itemIDs
+}
+
+func TestShareWishlist(t *testing.T) {
+	e := newTestEnv(t)
+	e.wishlistRoutes()
+	u := e.User()
+	other := e.User()
+	listID, _ := e.wishlist(u.Token, e.Product(nil).ID)
+	path := fmt.Sprintf("/user/wishlists/%d", listID)
+
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, path+"/share", other.Token, nil), http.StatusNotFound, "not_found")
+	shared := testutil.Expect[struct {
+		ShareToken string `json:"share_token"`
+		SharePath  string `json:"share_path"`
+	}](t, e.Do(http.MethodPost, path+"/share", u.Token, nil), http.StatusOK)
+	if shared.ShareToken == "" || shared.SharePath != "/wishlists/shared/"+shared.ShareToken {
+		t.Fatalf("share %+v", shared)
+	}
+
+	// anyone with the link sees the list, without signing in, but not
+	// whose it is
+	got := testutil.Expect[struct{ Wishlist models.Wishlist }](t, e.Do(http.MethodGet, shared.SharePath, "", nil), http.StatusOK).Wishlist
+	if got.ID != listID || got.UserID != 0 || got.ShareToken != nil || len(got.Items) != 1 {
+		t.Errorf("shared wishlist %+v, want list %d with one item and no owner", got, listID)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, "/wishlists/shared/unknown", "", nil), http.StatusNotFound, "not_found")
+
+	// sharing again replaces the link
+	again := testutil.Expect[struct {
+		SharePath string `json:"share_path"`
+	}](t, e.Do(http.MethodPost, path+"/share", u.Token, nil), http.StatusOK)
+	if again.SharePath == shared.SharePath {
+		t.Error("sharing again kept the link")
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, shared.SharePath, "", nil), http.StatusNotFound, "not_found")
+
+	testutil.ExpectProblem(t, e.Do(http.MethodDelete, path+"/share", other.Token, nil), http.StatusNotFound, "not_found")
+	testutil.Expect[map[string]any](t, e.Do(http.MethodDelete, path+"/share", u.Token, nil), http.StatusOK)
+	testutil.ExpectProblem(t, e.Do(http.MethodGet, again.SharePath, "", nil), http.StatusNotFound, "not_found")
+}
+
+func TestMoveWishlistToCart(t *testing.T) {
+	e := newTestEnv(t)
+	e.wishlistRoutes()
+	u := e.User()
+	first, second, third := e.Product(nil).ID, e.Product(nil).ID, e.Product(nil).ID
+	listID, itemIDs := e.wishlist(u.Token, first, second, third)
+	path := fmt.Sprintf("/user/wishlists/%d", listID)
+
+	type moved struct {
+		MovedItemIDs []int `json:"moved_item_ids"`
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, path+"/move-to-cart", e.User().Token, nil), http.StatusNotFound, "not_found")
+
+	// the selected items only
+	got := testutil.Expect[moved](t, e.Do(http.MethodPost, path+"/move-to-cart", u.Token, map[string]any{"item_ids": itemIDs[:1]}), http.StatusOK)
+	if len(got.MovedItemIDs) != 1 || got.MovedItemIDs[0] != itemIDs[0] {
+		t.Errorf("moved %v, want [%d]", got.MovedItemIDs, itemIDs[0])
+	}
+	// an item already moved is no longer on the list
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, path+"/move-to-cart", u.Token, map[string]any{"item_ids": itemIDs[:1]}), http.StatusNotFound, "not_found")
+
+	// without a body, every item left
+	got = testutil.Expect[moved](t, e.Do(http.MethodPost, path+"/move-to-cart", u.Token, nil), http.StatusOK)
+	if len(got.MovedItemIDs) != 2 {
+		t.Errorf("moved %v, want the two items left", got.MovedItemIDs)
+	}
+	list := testutil.Expect[struct{ Wishlist models.Wishlist }](t, e.Do(http.MethodGet, path, u.Token, nil), http.StatusOK).Wishlist
+	if len(list.Items) != 0 {
+		t.Errorf("wishlist after moving %+v, want it empty", list.Items)
+	}
+	testutil.ExpectProblem(t, e.Do(http.MethodPost, path+"/move-to-cart", u.Token, nil), http.StatusNotFound, "not_found")
+
+	cart, err := e.Stores.Carts.ListItems(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inCart := map[int]int{}
+	for _, item := range cart {
+		inCart[item.ProductID] = item.Quantity
+	}
+	if len(cart) != 3 || inCart[first] != 1 || inCart[second] != 1 || inCart[third] != 1 {
+		t.Errorf("cart %+v, want one of each product", cart)
+	}
+}
@@ -0,0 +1,195 @@
+// Package imaging renders resized and re-encoded variants of uploaded
+// images, in pure Go.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"slices"
+	"strconv"
+
+	// decoders of the accepted upload types
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+)
+
+// AllowedSizes are the widths and heights a variant can have. Allowing
+// only a few bounds the number of variants of each image.
+var AllowedSizes = []int{64, 128, 256, 512, 1024}
+
+// MaxPixels is the largest image, in pixels, that is decoded. It keeps a
+// small file of huge dimensions from exhausting memory.
+const MaxPixels = 40_000_000
+
+// Ways to fit an image into a box of both width and height.
+const (
+	FitContain = "contain" // scale to fit inside the box
+	FitCover   = "cover"   // scale to fill the box, cropping the overflow
+)
+
+// Output formats.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatWebP = "webp"
+)
+
+// jpegQuality balances size and quality for thumbnails.
+const jpegQuality = 85
+
+// Variant describes a rendering of an image. A zero Width or Height follows
+// from the other by the image's aspect ratio. Images are never enlarged.
+type Variant struct {
+	Width  int
+	Height int
+	Fit    string
+	Format string
+}
+
+// Key names the variant uniquely, for caching.
+func (v Variant) Key() string {
+	return fmt.Sprintf("%dx%d-%s.%s", v.Width, v.Height, v.Fit, v.Format)
+}
+
+// ContentType is the MIME type of the variant's encoding.
+func (v Variant) ContentType() string {
+	return "image/" + v.Format
+}
+
+// ParseVariant validates the w, h, fit and format parameters of a request
+// for a variant of an image of type mimeType. An empty format keeps JPEG
+// and WebP images as they are and turns others into PNG.
+func ParseVariant(w, h, fit, format, mimeType string) (Variant, error) {
+	var v Variant
+	var err error
+	if v.Width, err = parseSize("w", w); err != nil {
+		return v, err
+	}
+	if v.Height, err = parseSize("h", h); err != nil {
+		return v, err
+	}
+
+	switch fit {
+	case "", FitContain:
+		v.Fit = FitContain
+	case FitCover:
+		if v.Width == 0 || v.Height == 0 {
+			return v, errors.New("fit=cover needs both w and h")
+		}
+		v.Fit = FitCover
+	default:
+		return v, errors.New("fit must be contain or cover")
+	}
+
+	switch format {
+	case FormatJPEG, FormatPNG, FormatWebP:
+		v.Format = format
+	case "":
+		switch mimeType {
+		case "image/jpeg":
+			v.Format = FormatJPEG
+		case "image/webp":
+			v.Format = FormatWebP
+		default:
+			v.Format = FormatPNG
+		}
+	default:
+		return v, errors.New("format must be jpeg, png or webp")
+	}
+	return v, nil
+}
+
+func parseSize(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains(AllowedSizes, size) {
+		return 0, fmt.Errorf("%s must be one of %v", name, AllowedSizes)
+	}
+	return size, nil
+}
+
+// Render decodes the image src and encodes it as the variant.
+func Render(src []byte, v Variant) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("imaging: %dx%d image is too large", cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	crop, size := layout(img.Bounds(), v)
+	dst := image.NewNRGBA(image.Rectangle{Max: size})
+	if v.Format == FormatJPEG {
+		// JPEG has no transparency; show it as white
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Over, nil)
+
+	var out bytes.Buffer
+	if err := encode(&out, dst, v.Format); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// layout returns the part of an image with bounds src that the variant
+// shows, and the variant's size.
+func layout(src image.Rectangle, v Variant) (image.Rectangle, image.Point) {
+	sw, sh := float64(src.Dx()), float64(src.Dy())
+	w, h := float64(v.Width), float64(v.Height)
+	switch {
+	case w == 0 && h == 0:
+		w, h = sw, sh
+	case w == 0:
+		w = sw * h / sh
+	case h == 0:
+		h = sh * w / sw
+	}
+
+	crop := src
+	scale := min(w/sw, h/sh)
+	if v.Fit == FitCover {
+		scale = max(w/sw, h/sh)
+		// the box in source pixels, centered on the image
+		cw, ch := int(w/scale+0.5), int(h/scale+0.5)
+		x := src.Min.X + (src.Dx()-cw)/2
+		y := src.Min.Y + (src.Dy()-ch)/2
+		crop = image.Rect(x, y, x+cw, y+ch)
+	}
+	// never enlarge
+	scale = min(scale, 1)
+	size := image.Pt(
+		max(1, int(float64(crop.Dx())*scale+0.5)),
+		max(1, int(float64(crop.Dy())*scale+0.5)),
+	)
+	return crop, size
+}
+
+func encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	case FormatWebP:
+		// lossless; there is no pure-Go lossy WebP encoder
+		return nativewebp.Encode(w, img, nil)
+	default:
+		return png.Encode(w, img)
+	}
+}
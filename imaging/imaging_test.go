@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestParseVariant(t *testing.T) {
+	tests := []struct {
+		w, h, fit, format, mimeType string
+		want                        Variant
+	}{
+		{"256", "", "", "", "image/jpeg", Variant{Width: 256, Fit: FitContain, Format: FormatJPEG}},
+		{"", "128", "contain", "", "image/webp", Variant{Height: 128, Fit: FitContain, Format: FormatWebP}},
+		{"64", "64", "cover", "", "image/gif", Variant{Width: 64, Height: 64, Fit: FitCover, Format: FormatPNG}},
+		{"", "", "", "webp", "image/png", Variant{Fit: FitContain, Format: FormatWebP}},
+	}
+	for _, tt := range tests {
+		got, err := ParseVariant(tt.w, tt.h, tt.fit, tt.format, tt.mimeType)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseVariant(%q, %q, %q, %q, %q) = %+v, %v, want %+v", tt.w, tt.h, tt.fit, tt.format, tt.mimeType, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][4]string{
+		{"100", "", "", ""},        // not an allowed size
+		{"", "abc", "", ""},        // not a number
+		{"256", "", "cover", ""},   // cover needs both sides
+		{"256", "256", "fill", ""}, // unknown fit
+		{"256", "", "", "tiff"},    // unknown format
+	} {
+		if v, err := ParseVariant(bad[0], bad[1], bad[2], bad[3], "image/png"); err == nil {
+			t.Errorf("ParseVariant(%q) = %+v, want an error", bad, v)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	wide := image.Rect(0, 0, 1000, 500)
+	small := image.Rect(0, 0, 100, 50)
+	tests := []struct {
+		name     string
+		src      image.Rectangle
+		v        Variant
+		wantCrop image.Rectangle
+		wantSize image.Point
+	}{
+		{"contain fits the longer side", wide, Variant{Width: 256, Height: 256, Fit: FitContain}, wide, image.Pt(256, 128)},
+		{"width only keeps the aspect ratio", wide, Variant{Width: 512, Fit: FitContain}, wide, image.Pt(512, 256)},
+		{"height only keeps the aspect ratio", wide, Variant{Height: 64, Fit: FitContain}, wide, image.Pt(128, 64)},
+		{"no size keeps the image's", wide, Variant{Fit: FitContain}, wide, image.Pt(1000, 500)},
+		{"cover crops the center", wide, Variant{Width: 256, Height: 256, Fit: FitCover}, image.Rect(250, 0, 750, 500), image.Pt(256, 256)},
+		{"contain never enlarges", small, Variant{Width: 256, Height: 256, Fit: FitContain}, small, image.Pt(100, 50)},
+		{"cover never enlarges", small, Variant{Width: 64, Height: 64, Fit: FitCover}, image.Rect(25, 0, 75, 50), image.Pt(50, 50)},
+	}
+	for _, tt := range tests {
+		crop, size := layout(tt.src, tt.v)
+		if crop != tt.wantCrop || size != tt.wantSize {
+			t.Errorf("%s: crop %v and size %v, want %v and %v", tt.name, crop, size, tt.wantCrop, tt.wantSize)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewNRGBA(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{FormatJPEG, FormatPNG, FormatWebP} {
+		out, err := Render(src.Bytes(), Variant{Width: 64, Fit: FitContain, Format: format})
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		cfg, decoded, err := image.DecodeConfig(bytes.NewReader(out))
+		if err != nil || decoded != format || cfg.Width != 64 || cfg.Height != 32 {
+			t.Errorf("%s variant: %s of %dx%d (%v), want 64x32", format, decoded, cfg.Width, cfg.Height, err)
+		}
+	}
+	if _, err := Render([]byte("not an image"), Variant{Format: FormatPNG}); err == nil {
+		t.Error("rendering a non-image succeeded")
+	}
+}
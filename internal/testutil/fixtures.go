@@ -0,0 +1,184 @@
+// Package testutil has the fixtures and request helpers shared by the
+// tests: the handler tests run them on the memory store, the integration
+// tests of package server on Postgres, and package storetest checks both
+// stores with them.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"my-api/models"
+	"my-api/store"
+	"my-api/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password is the password of every account of the fixtures.
+const Password = "correct-horse-battery"
+
+// Account is a stored user with an access token.
+type Account struct {
+	models.User
+	PasswordHash string
+	Token        string
+}
+
+// Fixtures are factories of the entities tests start from. They are stored
+// directly, bypassing the API, with unique names and sensible defaults that
+// the change functions may override.
+type Fixtures struct {
+	T      testing.TB
+	Stores *store.Stores
+	// Tokens signs the tokens of the accounts; without it they have none.
+	Tokens *utils.TokenService
+	// seq numbers the entities, to keep them unique
+	seq int
+}
+
+func NewFixtures(t testing.TB, stores *store.Stores, tokens *utils.TokenService) *Fixtures {
+	return &Fixtures{T: t, Stores: stores, Tokens: tokens}
+}
+
+// Next returns a number no other entity of the fixtures was named after.
+func (f *Fixtures) Next() int {
+	f.seq++
+	return f.seq
+}
+
+// User stores a verified user with the "user" role.
+func (f *Fixtures) User(change ...func(*models.User)) Account {
+	f.T.Helper()
+	n := f.Next()
+	u := models.User{
+		Username:   fmt.Sprintf("user%d", n),
+		Email:      fmt.Sprintf("user%d@example.com", n),
+		Role:       "user",
+		IsVerified: true,
+	}
+	for _, fn := range change {
+		fn(&u)
+	}
+	// the cheapest cost, for speed; login compares hashes of any cost
+	hash, err := bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	u.ID, err = f.Stores.Users.Create(context.Background(), u, string(hash))
+	if err != nil {
+		f.T.Fatal("Failed to store user: ", err)
+	}
+	account := Account{User: u, PasswordHash: string(hash)}
+	if f.Tokens != nil {
+		account.Token = f.Token(u, account.PasswordHash)
+	}
+	return account
+}
+
+// Admin stores a user with the "admin" role.
+func (f *Fixtures) Admin(change ...func(*models.User)) Account {
+	return f.User(append([]func(*models.User){func(u *models.User) { u.Role = "admin" }}, change...)...)
+}
+
+// Token returns an access token of u, as logging in would.
+func (f *Fixtures) Token(u models.User, passwordHash string) string {
+	f.T.Helper()
+	token, err := f.Tokens.GenerateAccessToken(uint(u.ID), u.Username, u.Email, passwordHash, u.Role)
+	if err != nil {
+		f.T.Fatal(err)
+	}
+	return token
+}
+
+// Brand stores a published brand.
+func (f *Fixtures) Brand(change ...func(*models.Brand)) models.Brand {
+	f.T.Helper()
+	b := models.Brand{
+		BrandName:         fmt.Sprintf("Brand %d", f.Next()),
+		Status:            1,
+		IsPublish:         true,
+		IsApprovedByAdmin: true,
+		IsVisibleToGuest:  true,
+	}
+	for _, fn := range change {
+		fn(&b)
+	}
+	var err error
+	if b.ID, err = f.Stores.Catalog.CreateBrand(context.Background(), b); err != nil {
+		f.T.Fatal("Failed to store brand: ", err)
+	}
+	return b
+}
+
+// Category stores a published top-level category; set ParentID for a
+// subcategory.
+func (f *Fixtures) Category(change ...func(*models.Category)) models.Category {
+	f.T.Helper()
+	c := models.Category{
+		CategoryName: fmt.Sprintf("Category %d", f.Next()),
+		Status:       1,
+		IsApproved:   true,
+		IsPublished:  true,
+	}
+	for _, fn := range change {
+		fn(&c)
+	}
+	var err error
+	if c.ID, err = f.Stores.Catalog.CreateCategory(context.Background(), c); err != nil {
+		f.T.Fatal("Failed to store category: ", err)
+	}
+	return c
+}
+
+// Attribute stores an attribute with the values.
+func (f *Fixtures) Attribute(values ...string) models.Attribute {
+	f.T.Helper()
+	ctx := context.Background()
+	attr := models.Attribute{AttributeName: fmt.Sprintf("Attribute %d", f.Next()), Status: 1}
+	var err error
+	if attr.ID, err = f.Stores.Catalog.CreateAttribute(ctx, attr); err != nil {
+		f.T.Fatal("Failed to store attribute: ", err)
+	}
+	for _, value := range values {
+		v := models.AttributeValue{AttributeID: attr.ID, Value: value, Status: 1}
+		if v.ID, err = f.Stores.Catalog.CreateAttributeValue(ctx, v); err != nil {
+			f.T.Fatal("Failed to store attribute value: ", err)
+		}
+		attr.AttributeValues = append(attr.AttributeValues, v)
+	}
+	return attr
+}
+
+// Product stores a published product priced 10 with 100 in stock, with the
+// variations given. It is of a new brand and category unless change sets
+// them.
+func (f *Fixtures) Product(change func(*models.Product), variations ...models.VariationProduct) models.Product {
+	f.T.Helper()
+	n := f.Next()
+	price := 10.0
+	p := models.Product{
+		ProductName:  fmt.Sprintf("Product %d", n),
+		ProductCode:  fmt.Sprintf("P%04d", n),
+		Price:        &price,
+		TaxType:      "exclusive",
+		IsSaleable:   1,
+		Status:       1,
+		CurrentStock: 100,
+	}
+	if change != nil {
+		change(&p)
+	}
+	if p.BrandID == 0 {
+		p.BrandID = f.Brand().ID
+	}
+	if p.CategoryID == 0 {
+		p.CategoryID = f.Category().ID
+	}
+	var err error
+	if p.ID, err = f.Stores.Products.Create(context.Background(), p, nil, variations); err != nil {
+		f.T.Fatal("Failed to store product: ", err)
+	}
+	return p
+}
@@ -0,0 +1,75 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Client sends requests to a handler in process.
+type Client struct {
+	t       testing.TB
+	handler http.Handler
+}
+
+func NewClient(t testing.TB, handler http.Handler) *Client {
+	return &Client{t: t, handler: handler}
+}
+
+// Do sends a request, with body as JSON unless it is nil and token as the
+// bearer token unless it is empty.
+func (c *Client) Do(method, path, token string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var raw []byte
+	if body != nil {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(raw))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, req)
+	return w
+}
+
+// Expect checks the status of the response w and decodes its JSON body.
+func Expect[T any](t testing.TB, w *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var body T
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return body
+}
+
+// Problem is the part of problem details the tests check.
+type Problem struct {
+	Status int    `json:"status"`
+	Code   string `json:"code"`
+	Errors []struct {
+		Field string `json:"field"`
+		Code  string `json:"code"`
+	} `json:"errors"`
+}
+
+// ExpectProblem checks that w is the problem of status with code.
+func ExpectProblem(t testing.TB, w *httptest.ResponseRecorder, status int, code string) Problem {
+	t.Helper()
+	p := Expect[Problem](t, w, status)
+	if p.Code != code {
+		t.Fatalf("code %q, want %q: %s", p.Code, code, w.Body)
+	}
+	return p
+}
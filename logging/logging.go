@@ -0,0 +1,103 @@
+// Package logging sets up the API's structured logger, which masks emails,
+// passwords and tokens, and carries a request's logger in its context.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Formats of the log output.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// New returns a logger writing to w in format at level and above. Level is
+// debug, info, warn or error.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("logging: %w", err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redactAttr}
+
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q, use json or text", format)
+	}
+}
+
+// Redacted replaces masked values.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys, or parts of them, whose values are never
+// logged.
+var sensitiveKeys = []string{"password", "token", "secret", "authorization", "cookie", "email"}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// JWTs, with or without a Bearer prefix
+	tokenPattern = regexp.MustCompile(`(?i)(bearer\s+)?eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+// Redact masks the emails and tokens in s.
+func Redact(s string) string {
+	s = emailPattern.ReplaceAllString(s, Redacted)
+	return tokenPattern.ReplaceAllString(s, Redacted)
+}
+
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeys {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr masks every attribute with a sensitive key, and emails and
+// tokens within messages, strings and errors.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+// WithContext returns ctx carrying logger.
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger ctx carries, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the logger ctx carries.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
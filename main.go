@@ -2,27 +2,58 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"log"
-	"my-api/handlers"
+	"log/slog"
+	"net/http"
 	"os"
 	"time"
 
-	"github.com/gin-contrib/cors"
-	"github.com/gin-gonic/gin"
+	"my-api/config"
+	"my-api/logging"
+	"my-api/metrics"
+	"my-api/migrate"
+	"my-api/server"
+	"my-api/store/postgres"
+	"my-api/tracing"
+
 	_ "github.com/lib/pq"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	// `config` subcommand: print the effective settings
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		fmt.Print(cfg.Dump())
+		return
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	// the standard logger writes through it too
+	slog.SetDefault(logger)
+	logger.Info("Configuration loaded", "config", cfg.Dump())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing: ", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Println("Failed to flush traces:", err)
+		}
+	}()
+
 	// Database connection
-	dbHost := os.Getenv("DB_HOST")
-	dbPort := os.Getenv("DB_PORT")
-	dbUser := os.Getenv("DB_USER")
-	dbPassword := os.Getenv("DB_PASSWORD")
-	dbName := os.Getenv("DB_NAME")
-
-	connStr := "host=" + dbHost + " port=" + dbPort + " user=" + dbUser + " password=" + dbPassword + " dbname=" + dbName + " sslmode=disable"
-	db, err := sql.Open("postgres", connStr)
+	db, err := openDB(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -32,109 +63,83 @@ func main() {
 		log.Fatal("Failed to ping database:", err)
 	}
 
-	// Initialize Gin router
-	r := gin.Default()
-
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
-
-	// Public routes
-	r.POST("/register", handlers.Register(db))
-	r.POST("/login", handlers.Login(db))
-	r.POST("/refresh", handlers.RefreshToken(db))
-	r.GET("/products", handlers.GetAllProducts(db))
-	r.GET("/products/:id", handlers.GetProductByID(db))
-	r.GET("/brands", handlers.GetAllBrands(db))
-	r.GET("/brands/:id", handlers.GetBrandByID(db))
-	r.GET("/categories", handlers.GetAllCategories(db))
-	r.GET("/categories/:id", handlers.GetCategoryByID(db))
-	r.GET("/subcategories", handlers.GetAllSubCategories(db))
-	r.GET("/subcategories/:id", handlers.GetSubCategoryByID(db))
-
-	// Protected routes (admin-only)
-	adminGroup := r.Group("/admin")
-	adminGroup.Use(handlers.JWTAuthMiddleware("admin"))
-	{
-		adminGroup.POST("/products", handlers.AddProduct(db))
-
-		adminGroup.POST("/attributes", handlers.CreateAttribute(db))
-		adminGroup.GET("/attributes", handlers.GetAllAttributes(db))
-		adminGroup.GET("/attributes/:id", handlers.GetAttributeByID(db))
-		adminGroup.PUT("/attributes/:id", handlers.UpdateAttribute(db))
-		adminGroup.DELETE("/attributes/:id", handlers.DeleteAttribute(db))
-
-		adminGroup.POST("/attribute-values", handlers.CreateAttributeValue(db))
-		adminGroup.GET("/attribute-values/:attribute_id", handlers.GetAllAttributeValues(db))
-		adminGroup.GET("/attribute-value/:id", handlers.GetAttributeValue(db))
-		adminGroup.PUT("/attribute-value/:id", handlers.UpdateAttributeValue(db))
-		adminGroup.DELETE("/attribute-value/:id", handlers.DeleteAttributeValue(db))
-
-		adminGroup.GET("/products", handlers.GetAllProducts(db))
-		adminGroup.GET("/products/:id", handlers.GetProductByID(db))
-		adminGroup.POST("/brands", handlers.CreateBrand(db))
-		adminGroup.PUT("/brands/:id", handlers.UpdateBrand(db))
-		adminGroup.DELETE("/brands/:id", handlers.DeleteBrand(db))
-		adminGroup.POST("/categories", handlers.CreateCategory(db))
-		adminGroup.PUT("/categories/:id", handlers.UpdateCategory(db))
-		adminGroup.DELETE("/categories/:id", handlers.DeleteCategory(db))
-		adminGroup.GET("/categories/:id", handlers.GetCategoryByID(db))
-		adminGroup.POST("/subcategories", handlers.CreateSubCategory(db))
-		adminGroup.PUT("/subcategories/:id", handlers.UpdateSubCategory(db))
-		adminGroup.DELETE("/subcategories/:id", handlers.DeleteSubCategory(db))
-		adminGroup.GET("/subcategories", handlers.GetAllSubCategories(db))
-		adminGroup.GET("/subcategories/:id", handlers.GetSubCategoryByID(db))
-		adminGroup.GET("/session", handlers.Session(db))
-		adminGroup.POST("/address", handlers.AdminCreateAddress(db))
-		adminGroup.PUT("/address/:id", handlers.AdminUpdateAddress(db))
-
-		adminGroup.POST("/users", handlers.AdminCreateUser(db))
-		adminGroup.GET("/users", handlers.AdminGetAllUsers(db))
-		adminGroup.GET("/users/:id", handlers.AdminGetUser(db))
-		adminGroup.PUT("/users/:id", handlers.AdminUpdateUser(db))
-		adminGroup.DELETE("/users/:id", handlers.AdminDeleteUser(db))
+	// `migrate up|down|status` subcommand
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(db, os.Args[2:]); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+
+	migrations, err := migrate.New(db)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	if cfg.MigrateOnStart {
+		applied, err := migrations.Up(context.Background())
+		if err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+	}
+
+	st := postgres.New(db)
+
+	// `purge-deleted-users` subcommand
+	if len(os.Args) > 1 && os.Args[1] == "purge-deleted-users" {
+		if err := runPurgeDeletedUsersCommand(st.Users); err != nil {
+			log.Fatal("Purge failed: ", err)
+		}
+		return
 	}
 
-	// User routes
-	userGroup := r.Group("/user")
-	userGroup.Use(handlers.JWTAuthMiddleware("user"))
-	{
-		userGroup.GET("/products/:id", handlers.GetProductByID(db))
-		userGroup.GET("/products", handlers.GetAllProducts(db))
-		userGroup.GET("/session", handlers.Session(db))
-		userGroup.GET("/me", handlers.GetUserDetails(db))
-		userGroup.POST("/logout", handlers.Logout(db))
-
-		userGroup.POST("/address", handlers.AddAddress(db))
-		userGroup.GET("/addresses", handlers.GetAddresses(db))
-		userGroup.PUT("/address/:id", handlers.UpdateAddress(db))
-		userGroup.GET("/address/:id", handlers.GetAddressByID(db))
-		userGroup.DELETE("/address/:id", handlers.DeleteAddress(db))
-
-		userGroup.POST("/shipping-address", handlers.AddShippingAddress(db))
-		userGroup.GET("/shipping-addresses", handlers.GetShippingAddresses(db))
-		userGroup.GET("/shipping-address/:id", handlers.GetSingleShippingAddress(db))
-		userGroup.PUT("/shipping-address/:id", handlers.UpdateShippingAddress(db))
-		userGroup.DELETE("/shipping-address/:id", handlers.DeleteShippingAddress(db))
-		userGroup.POST("/billing-address", handlers.AddBillingAddress(db))
-		userGroup.GET("/billing-addresses", handlers.GetBillingAddresses(db))
-		userGroup.GET("/billing-address/:id", handlers.GetSingleBillingAddress(db))
-		userGroup.PUT("/billing-address/:id", handlers.UpdateBillingAddress(db))
-		userGroup.DELETE("/billing-address/:id", handlers.DeleteBillingAddress(db))
-
-		userGroup.PATCH("/shipping-address/:id/default", handlers.SetDefaultShippingAddress(db))
-		userGroup.PATCH("/billing-address/:id/default", handlers.SetDefaultBillingAddress(db))
+	blobs, err := newBlobStore(cfg.Media)
+	if err != nil {
+		log.Fatal("Failed to open media storage:", err)
+	}
+	variants, err := newVariantCache(cfg.Media)
+	if err != nil {
+		log.Fatal("Failed to open media cache:", err)
+	}
+
+	r := server.NewRouter(server.Deps{
+		Config:     cfg,
+		Logger:     logger,
+		DB:         db,
+		Migrations: migrations,
+		Stores:     st,
+		Blobs:      blobs,
+		Variants:   variants,
+	})
+
+	// Metrics of the connection pool, and their own listener if configured
+	servers := []*http.Server{server.New(":"+cfg.Port, r, cfg.Server)}
+	if cfg.Metrics.Enabled {
+		metrics.RegisterDB(db, cfg.Database.Name)
+		if cfg.Metrics.Address != "" {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", metrics.Handler(cfg.Metrics.Token))
+			servers = append(servers, server.New(cfg.Metrics.Address, mux, cfg.Server))
+		}
 	}
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if err := server.Run(cfg.Server, servers...); err != nil {
+		log.Fatal("Server error: ", err)
+	}
+}
+
+// openDB opens the connection pool of the database.
+func openDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := tracing.OpenPostgres(cfg.DSN())
+	if err != nil {
+		return nil, err
 	}
-	log.Fatal(r.Run(":" + port))
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
 }
@@ -0,0 +1,37 @@
+// media_storage.go
+package main
+
+import (
+	"fmt"
+
+	"my-api/config"
+	"my-api/store"
+	"my-api/store/blob"
+)
+
+// newBlobStore returns the store for uploaded files selected by
+// cfg.Storage: "disk" keeps them under cfg.Dir, "s3" in a bucket of an
+// S3-compatible service.
+func newBlobStore(cfg config.MediaConfig) (store.BlobStore, error) {
+	switch cfg.Storage {
+	case "disk":
+		return blob.NewDisk(cfg.Dir)
+	case "s3":
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			UseSSL:    cfg.S3.UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media storage %q, use disk or s3", cfg.Storage)
+	}
+}
+
+// newVariantCache returns the disk cache of resized images, in
+// cfg.CacheDir. It can be emptied at any time.
+func newVariantCache(cfg config.MediaConfig) (store.BlobStore, error) {
+	return blob.NewDisk(cfg.CacheDir)
+}
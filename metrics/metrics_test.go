@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name          string
+		token, header string
+		status        int
+	}{
+		{"without a token", "", "", http.StatusOK},
+		{"token sent", "scrape-me", "Bearer scrape-me", http.StatusOK},
+		{"token missing", "scrape-me", "", http.StatusUnauthorized},
+		{"wrong token", "scrape-me", "Bearer scrape-you", http.StatusUnauthorized},
+		{"token as a prefix", "scrape-me", "Bearer scrape", http.StatusUnauthorized},
+		{"other scheme", "scrape-me", "Basic scrape-me", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		Handler(tt.token).ServeHTTP(w, r)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		body := w.Body.String()
+		if tt.status == http.StatusOK && !strings.Contains(body, "go_goroutines") {
+			t.Errorf("%s: scrape without the runtime metrics:\n%s", tt.name, body)
+		}
+		if tt.status == http.StatusUnauthorized && (w.Header().Get("WWW-Authenticate") != `Bearer realm="metrics"` || strings.Contains(body, "go_goroutines")) {
+			t.Errorf("%s: refusal %v with body %q", tt.name, w.Header(), body)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func files(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys[name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := load(files(
+		"0010_tenth.up.sql", "0010_tenth.down.sql",
+		"0002_second.down.sql", "0002_second.up.sql",
+		"0001_first.up.sql", "0001_first.down.sql",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	// by version, not by file name
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 10 {
+		t.Fatalf("versions %v, want [1 2 10]", versions)
+	}
+
+	second := migrations[1]
+	sum := sha256.Sum256([]byte("-- 0002_second.up.sql"))
+	if second.Name != "second" || second.Up != "-- 0002_second.up.sql" || second.Down != "-- 0002_second.down.sql" ||
+		second.Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("migration %+v", second)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{"missing down script", files("0001_first.up.sql"), "needs both up and down"},
+		{"missing up script", files("0001_first.down.sql"), "needs both up and down"},
+		{"version used twice", files("0001_first.up.sql", "0001_first.down.sql", "0001_other.up.sql"), "used by"},
+		{"unexpected file", files("0001_first.up.sql", "0001_first.down.sql", "notes.txt"), "unexpected file name"},
+	}
+	for _, tt := range tests {
+		if _, err := load(tt.fsys); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.error)
+		}
+	}
+}
+
+// The embedded migrations load, and their versions follow each other.
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %04d_%s at position %d", m.Version, m.Name, i+1)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS variation_products;
+DROP TABLE IF EXISTS product_attributes;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS attribute_values;
+DROP TABLE IF EXISTS attributes;
+DROP TABLE IF EXISTS subcategories;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS brands;
+DROP TABLE IF EXISTS billing_addresses;
+DROP TABLE IF EXISTS shipping_addresses;
+DROP TABLE IF EXISTS login_sessions;
+DROP TABLE IF EXISTS addresses;
+DROP TABLE IF EXISTS users;
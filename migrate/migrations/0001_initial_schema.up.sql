@@ -1,4 +1,4 @@
--- db/init.sql
+-- 0001_initial_schema: tables created by the original db/init.sql
 
 -- Users table
 CREATE TABLE IF NOT EXISTS users (
@@ -183,4 +183,4 @@ CREATE TABLE IF NOT EXISTS variation_products (
     image TEXT,
     current_stock INT DEFAULT 0,
     UNIQUE (product_id, sku)
-);
\ No newline at end of file
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS shipping_rate_tiers;
+DROP TABLE IF EXISTS shipping_methods;
+DROP TABLE IF EXISTS shipping_zones;
+DROP TABLE IF EXISTS cart_items;
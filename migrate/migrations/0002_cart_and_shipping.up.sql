@@ -0,0 +1,46 @@
+-- Cart items table
+CREATE TABLE IF NOT EXISTS cart_items (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variation_id INT REFERENCES variation_products(id) ON DELETE CASCADE,
+    quantity INT NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS cart_items_user_product_variation_idx
+    ON cart_items (user_id, product_id, COALESCE(variation_id, 0));
+
+-- Shipping zones table (matched by country and optional postal-code glob patterns)
+CREATE TABLE IF NOT EXISTS shipping_zones (
+    id SERIAL PRIMARY KEY,
+    zone_name VARCHAR(100) NOT NULL,
+    countries TEXT[] NOT NULL DEFAULT '{}', -- country names/codes, '*' matches any country
+    postal_code_patterns TEXT[] NOT NULL DEFAULT '{}', -- e.g. '1200', '12*', 'SW1?*'; empty matches all
+    position INT NOT NULL DEFAULT 0,
+    status INT DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Shipping methods table
+CREATE TABLE IF NOT EXISTS shipping_methods (
+    id SERIAL PRIMARY KEY,
+    zone_id INT NOT NULL REFERENCES shipping_zones(id) ON DELETE CASCADE,
+    method_name VARCHAR(100) NOT NULL,
+    rate_type VARCHAR(20) NOT NULL CHECK (rate_type IN ('flat', 'weight', 'price')),
+    base_rate NUMERIC NOT NULL DEFAULT 0,
+    free_shipping_threshold NUMERIC,
+    estimated_days VARCHAR(50),
+    status INT DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Shipping rate tiers table (weight in kg for 'weight' methods, cart subtotal for 'price' methods)
+CREATE TABLE IF NOT EXISTS shipping_rate_tiers (
+    id SERIAL PRIMARY KEY,
+    method_id INT NOT NULL REFERENCES shipping_methods(id) ON DELETE CASCADE,
+    min_value NUMERIC NOT NULL DEFAULT 0,
+    max_value NUMERIC,
+    rate NUMERIC NOT NULL
+);
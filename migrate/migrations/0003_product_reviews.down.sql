@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS review_votes;
+DROP TABLE IF EXISTS product_reviews;
+
+ALTER TABLE products DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE products ALTER COLUMN rating DROP NOT NULL;
+ALTER TABLE products ALTER COLUMN rating TYPE INT USING ROUND(rating)::INT;
@@ -0,0 +1,40 @@
+-- products.rating becomes the maintained average of approved reviews; the
+-- hand-set values are discarded since no reviews back them yet
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'products' AND column_name = 'rating') = 'integer' THEN
+        ALTER TABLE products ALTER COLUMN rating TYPE NUMERIC(3, 2) USING 0;
+    END IF;
+END $$;
+ALTER TABLE products ALTER COLUMN rating SET DEFAULT 0;
+ALTER TABLE products ALTER COLUMN rating SET NOT NULL;
+ALTER TABLE products ADD COLUMN IF NOT EXISTS rating_count INT NOT NULL DEFAULT 0;
+
+-- Product reviews table
+CREATE TABLE IF NOT EXISTS product_reviews (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    title VARCHAR(150) NOT NULL,
+    body TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected', 'hidden')),
+    helpful_count INT NOT NULL DEFAULT 0,
+    not_helpful_count INT NOT NULL DEFAULT 0,
+    moderated_by INT REFERENCES users(id) ON DELETE SET NULL,
+    moderated_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (product_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS product_reviews_product_status_idx ON product_reviews (product_id, status);
+
+-- Review helpfulness votes table
+CREATE TABLE IF NOT EXISTS review_votes (
+    review_id INT NOT NULL REFERENCES product_reviews(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    is_helpful BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (review_id, user_id)
+);
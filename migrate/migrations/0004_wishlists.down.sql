@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS variation_products_wishlist_watch ON variation_products;
+DROP TRIGGER IF EXISTS products_wishlist_watch ON products;
+DROP FUNCTION IF EXISTS notify_variation_wishlist_watchers();
+DROP FUNCTION IF EXISTS notify_product_wishlist_watchers();
+DROP TABLE IF EXISTS wishlist_notifications;
+DROP TABLE IF EXISTS wishlist_items;
+DROP TABLE IF EXISTS wishlists;
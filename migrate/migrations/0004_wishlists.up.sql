@@ -0,0 +1,90 @@
+-- Wishlists table
+CREATE TABLE IF NOT EXISTS wishlists (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    share_token VARCHAR(64) UNIQUE, -- set while the list is shared via a public link
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+-- Wishlist items table
+CREATE TABLE IF NOT EXISTS wishlist_items (
+    id SERIAL PRIMARY KEY,
+    wishlist_id INT NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variation_id INT REFERENCES variation_products(id) ON DELETE CASCADE,
+    price_at_add NUMERIC,
+    notify_price_drop BOOLEAN NOT NULL DEFAULT TRUE,
+    notify_back_in_stock BOOLEAN NOT NULL DEFAULT TRUE,
+    added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wishlist_items_list_product_variation_idx
+    ON wishlist_items (wishlist_id, product_id, COALESCE(variation_id, 0));
+
+-- Wishlist notifications table (filled by the triggers below)
+CREATE TABLE IF NOT EXISTS wishlist_notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    wishlist_item_id INT REFERENCES wishlist_items(id) ON DELETE SET NULL,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    variation_id INT REFERENCES variation_products(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('price_drop', 'back_in_stock')),
+    old_price NUMERIC,
+    new_price NUMERIC,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    read_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS wishlist_notifications_user_idx ON wishlist_notifications (user_id, read_at);
+
+-- Queue price-drop and back-in-stock notifications whenever a product's
+-- price or current_stock changes, whatever code path made the change
+CREATE OR REPLACE FUNCTION notify_product_wishlist_watchers() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.price IS NOT NULL AND OLD.price IS NOT NULL AND NEW.price < OLD.price THEN
+        INSERT INTO wishlist_notifications (user_id, wishlist_item_id, product_id, type, old_price, new_price)
+        SELECT w.user_id, wi.id, NEW.id, 'price_drop', OLD.price, NEW.price
+        FROM wishlist_items wi JOIN wishlists w ON w.id = wi.wishlist_id
+        WHERE wi.product_id = NEW.id AND wi.variation_id IS NULL AND wi.notify_price_drop;
+    END IF;
+    IF COALESCE(OLD.current_stock, 0) <= 0 AND COALESCE(NEW.current_stock, 0) > 0 THEN
+        INSERT INTO wishlist_notifications (user_id, wishlist_item_id, product_id, type)
+        SELECT w.user_id, wi.id, NEW.id, 'back_in_stock'
+        FROM wishlist_items wi JOIN wishlists w ON w.id = wi.wishlist_id
+        WHERE wi.product_id = NEW.id AND wi.variation_id IS NULL AND wi.notify_back_in_stock;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_wishlist_watch ON products;
+CREATE TRIGGER products_wishlist_watch
+    AFTER UPDATE OF price, current_stock ON products
+    FOR EACH ROW EXECUTE FUNCTION notify_product_wishlist_watchers();
+
+CREATE OR REPLACE FUNCTION notify_variation_wishlist_watchers() RETURNS TRIGGER AS $$
+DECLARE
+    prev_price NUMERIC := COALESCE(OLD.sale_price, OLD.default_sell_price);
+    next_price NUMERIC := COALESCE(NEW.sale_price, NEW.default_sell_price);
+BEGIN
+    IF next_price IS NOT NULL AND prev_price IS NOT NULL AND next_price < prev_price THEN
+        INSERT INTO wishlist_notifications (user_id, wishlist_item_id, product_id, variation_id, type, old_price, new_price)
+        SELECT w.user_id, wi.id, NEW.product_id, NEW.id, 'price_drop', prev_price, next_price
+        FROM wishlist_items wi JOIN wishlists w ON w.id = wi.wishlist_id
+        WHERE wi.variation_id = NEW.id AND wi.notify_price_drop;
+    END IF;
+    IF COALESCE(OLD.current_stock, 0) <= 0 AND COALESCE(NEW.current_stock, 0) > 0 THEN
+        INSERT INTO wishlist_notifications (user_id, wishlist_item_id, product_id, variation_id, type)
+        SELECT w.user_id, wi.id, NEW.product_id, NEW.id, 'back_in_stock'
+        FROM wishlist_items wi JOIN wishlists w ON w.id = wi.wishlist_id
+        WHERE wi.variation_id = NEW.id AND wi.notify_back_in_stock;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS variation_products_wishlist_watch ON variation_products;
+CREATE TRIGGER variation_products_wishlist_watch
+    AFTER UPDATE OF sale_price, default_sell_price, current_stock ON variation_products
+    FOR EACH ROW EXECUTE FUNCTION notify_variation_wishlist_watchers();
@@ -0,0 +1,69 @@
+CREATE TABLE IF NOT EXISTS shipping_addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    address_line1 VARCHAR(100) NOT NULL,
+    city VARCHAR(50) NOT NULL,
+    country VARCHAR(50) NOT NULL,
+    postal_code VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('home', 'office', 'other')),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS billing_addresses (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    address_line1 VARCHAR(100) NOT NULL,
+    city VARCHAR(50) NOT NULL,
+    country VARCHAR(50) NOT NULL,
+    postal_code VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('home', 'office', 'other')),
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- addresses copied from the old tables get their IDs back
+INSERT INTO shipping_addresses (id, user_id, address_line1, city, country, postal_code, type, is_default, created_at)
+SELECT l.legacy_id, a.user_id, a.address_line1, a.city, a.country, a.postal_code, a.type, a.is_default_shipping, a.created_at
+FROM addresses a
+JOIN legacy_address_ids l ON l.address_id = a.id AND l.usage = 'shipping'
+WHERE a.is_shipping;
+
+INSERT INTO billing_addresses (id, user_id, address_line1, city, country, postal_code, type, is_default, created_at)
+SELECT l.legacy_id, a.user_id, a.address_line1, a.city, a.country, a.postal_code, a.type, a.is_default_billing, a.created_at
+FROM addresses a
+JOIN legacy_address_ids l ON l.address_id = a.id AND l.usage = 'billing'
+WHERE a.is_billing;
+
+SELECT setval(pg_get_serial_sequence('shipping_addresses', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM shipping_addresses;
+SELECT setval(pg_get_serial_sequence('billing_addresses', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM billing_addresses;
+
+INSERT INTO shipping_addresses (user_id, address_line1, city, country, postal_code, type, is_default, created_at)
+SELECT a.user_id, a.address_line1, a.city, a.country, a.postal_code, a.type, a.is_default_shipping, a.created_at
+FROM addresses a
+WHERE a.is_shipping
+  AND NOT EXISTS (SELECT 1 FROM legacy_address_ids l WHERE l.address_id = a.id AND l.usage = 'shipping')
+ORDER BY a.id;
+
+INSERT INTO billing_addresses (user_id, address_line1, city, country, postal_code, type, is_default, created_at)
+SELECT a.user_id, a.address_line1, a.city, a.country, a.postal_code, a.type, a.is_default_billing, a.created_at
+FROM addresses a
+WHERE a.is_billing
+  AND NOT EXISTS (SELECT 1 FROM legacy_address_ids l WHERE l.address_id = a.id AND l.usage = 'billing')
+ORDER BY a.id;
+
+DROP TABLE legacy_address_ids;
+
+-- addresses flagged for shipping or billing now live in the tables above
+DELETE FROM addresses WHERE is_shipping OR is_billing;
+
+DROP INDEX IF EXISTS addresses_user_idx;
+DROP INDEX IF EXISTS addresses_default_billing_idx;
+DROP INDEX IF EXISTS addresses_default_shipping_idx;
+ALTER TABLE addresses
+    DROP CONSTRAINT addresses_default_billing_check,
+    DROP CONSTRAINT addresses_default_shipping_check,
+    DROP COLUMN is_default_billing,
+    DROP COLUMN is_default_shipping,
+    DROP COLUMN is_billing,
+    DROP COLUMN is_shipping;
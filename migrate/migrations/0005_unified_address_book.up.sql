@@ -0,0 +1,73 @@
+-- Unified address book: shipping and billing addresses become rows of
+-- `addresses` flagged for the purposes they serve, with at most one default
+-- address per purpose and user.
+ALTER TABLE addresses
+    ADD COLUMN is_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN is_billing BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN is_default_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN is_default_billing BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD CONSTRAINT addresses_default_shipping_check CHECK (is_shipping OR NOT is_default_shipping),
+    ADD CONSTRAINT addresses_default_billing_check CHECK (is_billing OR NOT is_default_billing);
+
+-- The old rows, with their usage. Only the oldest default of a user is
+-- kept, since the old tables did not enforce a single one.
+CREATE TEMPORARY TABLE legacy_addresses AS
+SELECT 'shipping' AS usage, s.id AS legacy_id, s.user_id, s.address_line1, s.city, s.country,
+       s.postal_code, s.type, s.created_at,
+       s.is_default AND s.id = (
+           SELECT MIN(d.id) FROM shipping_addresses d WHERE d.user_id = s.user_id AND d.is_default
+       ) AS is_default
+FROM shipping_addresses s
+WHERE s.user_id IS NOT NULL
+UNION ALL
+SELECT 'billing', b.id, b.user_id, b.address_line1, b.city, b.country,
+       b.postal_code, b.type, b.created_at,
+       b.is_default AND b.id = (
+           SELECT MIN(d.id) FROM billing_addresses d WHERE d.user_id = b.user_id AND d.is_default
+       )
+FROM billing_addresses b
+WHERE b.user_id IS NOT NULL;
+
+-- The IDs the shipping and billing endpoints knew the old rows by, which
+-- clients may have stored, and the address each one became.
+CREATE TABLE legacy_address_ids (
+    usage VARCHAR(10) NOT NULL CHECK (usage IN ('shipping', 'billing')),
+    legacy_id INTEGER NOT NULL,
+    address_id INTEGER NOT NULL REFERENCES addresses(id) ON DELETE CASCADE,
+    PRIMARY KEY (usage, legacy_id)
+);
+
+-- Copy the old rows over, one address for the rows of a user that are the
+-- same address, flagged for every usage they had.
+WITH copied AS (
+    INSERT INTO addresses (user_id, address_line1, city, country, postal_code, type,
+                           is_shipping, is_billing, is_default_shipping, is_default_billing, created_at)
+    SELECT user_id, address_line1, city, country, postal_code, type,
+           bool_or(usage = 'shipping'), bool_or(usage = 'billing'),
+           bool_or(usage = 'shipping' AND is_default), bool_or(usage = 'billing' AND is_default),
+           MIN(created_at)
+    FROM legacy_addresses
+    GROUP BY user_id, address_line1, city, country, postal_code, type
+    ORDER BY MIN(legacy_id)
+    RETURNING id, user_id, address_line1, city, country, postal_code, type
+)
+INSERT INTO legacy_address_ids (usage, legacy_id, address_id)
+SELECT l.usage, l.legacy_id, c.id
+FROM legacy_addresses l
+JOIN copied c ON (c.user_id, c.address_line1, c.city, c.country, c.postal_code, c.type) =
+                 (l.user_id, l.address_line1, l.city, l.country, l.postal_code, l.type);
+
+-- Addresses added from now on get IDs above the old ones, so that an ID
+-- sent to the shipping or billing endpoints names one address.
+SELECT setval(pg_get_serial_sequence('addresses', 'id'),
+              GREATEST((SELECT MAX(id) FROM addresses),
+                       (SELECT MAX(legacy_id) FROM legacy_addresses), 1));
+
+DROP TABLE legacy_addresses;
+
+CREATE UNIQUE INDEX IF NOT EXISTS addresses_default_shipping_idx ON addresses (user_id) WHERE is_default_shipping;
+CREATE UNIQUE INDEX IF NOT EXISTS addresses_default_billing_idx ON addresses (user_id) WHERE is_default_billing;
+CREATE INDEX IF NOT EXISTS addresses_user_idx ON addresses (user_id);
+
+DROP TABLE shipping_addresses;
+DROP TABLE billing_addresses;
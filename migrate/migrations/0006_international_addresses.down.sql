@@ -0,0 +1,7 @@
+-- Country codes are kept; only the new columns are dropped.
+ALTER TABLE addresses
+    ALTER COLUMN postal_code DROP DEFAULT,
+    DROP COLUMN region,
+    DROP COLUMN address_line2,
+    DROP COLUMN phone,
+    DROP COLUMN recipient_name;
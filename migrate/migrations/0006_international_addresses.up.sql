@@ -0,0 +1,295 @@
+-- International addresses: recipient, phone, second street line and region,
+-- and countries stored as ISO 3166-1 alpha-2 codes. Postal codes may be
+-- empty for countries that do not use them.
+ALTER TABLE addresses
+    ADD COLUMN recipient_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN phone VARCHAR(30),
+    ADD COLUMN address_line2 VARCHAR(100),
+    ADD COLUMN region VARCHAR(50),
+    ALTER COLUMN postal_code SET DEFAULT '';
+
+-- English names (and a few common aliases) of every country in
+-- countries/countries.json, for converting the free-text values.
+CREATE TEMPORARY TABLE country_names (name TEXT PRIMARY KEY, code CHAR(2) NOT NULL);
+INSERT INTO country_names (name, code) VALUES
+    ('andorra', 'AD'),
+    ('united arab emirates', 'AE'),
+    ('afghanistan', 'AF'),
+    ('antigua and barbuda', 'AG'),
+    ('anguilla', 'AI'),
+    ('albania', 'AL'),
+    ('armenia', 'AM'),
+    ('angola', 'AO'),
+    ('antarctica', 'AQ'),
+    ('argentina', 'AR'),
+    ('american samoa', 'AS'),
+    ('austria', 'AT'),
+    ('australia', 'AU'),
+    ('aruba', 'AW'),
+    ('åland islands', 'AX'),
+    ('azerbaijan', 'AZ'),
+    ('bosnia and herzegovina', 'BA'),
+    ('barbados', 'BB'),
+    ('bangladesh', 'BD'),
+    ('belgium', 'BE'),
+    ('burkina faso', 'BF'),
+    ('bulgaria', 'BG'),
+    ('bahrain', 'BH'),
+    ('burundi', 'BI'),
+    ('benin', 'BJ'),
+    ('saint barthélemy', 'BL'),
+    ('bermuda', 'BM'),
+    ('brunei darussalam', 'BN'),
+    ('bolivia', 'BO'),
+    ('caribbean nl', 'BQ'),
+    ('brazil', 'BR'),
+    ('bahamas', 'BS'),
+    ('bhutan', 'BT'),
+    ('bouvet island', 'BV'),
+    ('botswana', 'BW'),
+    ('belarus', 'BY'),
+    ('belize', 'BZ'),
+    ('canada', 'CA'),
+    ('cocos (keeling) islands', 'CC'),
+    ('congo, democratic republic of the', 'CD'),
+    ('central african rep.', 'CF'),
+    ('congo', 'CG'),
+    ('switzerland', 'CH'),
+    ('côte d''ivoire', 'CI'),
+    ('cook islands', 'CK'),
+    ('chile', 'CL'),
+    ('cameroon', 'CM'),
+    ('china', 'CN'),
+    ('colombia', 'CO'),
+    ('costa rica', 'CR'),
+    ('cuba', 'CU'),
+    ('cabo verde', 'CV'),
+    ('curaçao', 'CW'),
+    ('christmas island', 'CX'),
+    ('cyprus', 'CY'),
+    ('czechia', 'CZ'),
+    ('germany', 'DE'),
+    ('djibouti', 'DJ'),
+    ('denmark', 'DK'),
+    ('dominica', 'DM'),
+    ('dominican republic', 'DO'),
+    ('algeria', 'DZ'),
+    ('ecuador', 'EC'),
+    ('estonia', 'EE'),
+    ('egypt', 'EG'),
+    ('western sahara', 'EH'),
+    ('eritrea', 'ER'),
+    ('spain', 'ES'),
+    ('ethiopia', 'ET'),
+    ('finland', 'FI'),
+    ('fiji', 'FJ'),
+    ('falkland islands', 'FK'),
+    ('micronesia', 'FM'),
+    ('faroe islands', 'FO'),
+    ('france', 'FR'),
+    ('gabon', 'GA'),
+    ('united kingdom', 'GB'),
+    ('grenada', 'GD'),
+    ('georgia', 'GE'),
+    ('french guiana', 'GF'),
+    ('guernsey', 'GG'),
+    ('ghana', 'GH'),
+    ('gibraltar', 'GI'),
+    ('greenland', 'GL'),
+    ('gambia', 'GM'),
+    ('guinea', 'GN'),
+    ('guadeloupe', 'GP'),
+    ('equatorial guinea', 'GQ'),
+    ('greece', 'GR'),
+    ('south georgia and the south sandwich islands', 'GS'),
+    ('guatemala', 'GT'),
+    ('guam', 'GU'),
+    ('guinea-bissau', 'GW'),
+    ('guyana', 'GY'),
+    ('hong kong', 'HK'),
+    ('heard island and mcdonald islands', 'HM'),
+    ('honduras', 'HN'),
+    ('croatia', 'HR'),
+    ('haiti', 'HT'),
+    ('hungary', 'HU'),
+    ('indonesia', 'ID'),
+    ('ireland', 'IE'),
+    ('israel', 'IL'),
+    ('isle of man', 'IM'),
+    ('india', 'IN'),
+    ('british indian ocean territory', 'IO'),
+    ('iraq', 'IQ'),
+    ('iran', 'IR'),
+    ('iceland', 'IS'),
+    ('italy', 'IT'),
+    ('jersey', 'JE'),
+    ('jamaica', 'JM'),
+    ('jordan', 'JO'),
+    ('japan', 'JP'),
+    ('kenya', 'KE'),
+    ('kyrgyzstan', 'KG'),
+    ('cambodia', 'KH'),
+    ('kiribati', 'KI'),
+    ('comoros', 'KM'),
+    ('saint kitts and nevis', 'KN'),
+    ('korea, democratic people''s republic of', 'KP'),
+    ('korea, republic of', 'KR'),
+    ('kuwait', 'KW'),
+    ('cayman islands', 'KY'),
+    ('kazakhstan', 'KZ'),
+    ('lao people''s democratic republic', 'LA'),
+    ('lebanon', 'LB'),
+    ('saint lucia', 'LC'),
+    ('liechtenstein', 'LI'),
+    ('sri lanka', 'LK'),
+    ('liberia', 'LR'),
+    ('lesotho', 'LS'),
+    ('lithuania', 'LT'),
+    ('luxembourg', 'LU'),
+    ('latvia', 'LV'),
+    ('libya', 'LY'),
+    ('morocco', 'MA'),
+    ('monaco', 'MC'),
+    ('moldova', 'MD'),
+    ('montenegro', 'ME'),
+    ('saint martin (french part)', 'MF'),
+    ('madagascar', 'MG'),
+    ('marshall islands', 'MH'),
+    ('north macedonia', 'MK'),
+    ('mali', 'ML'),
+    ('myanmar', 'MM'),
+    ('mongolia', 'MN'),
+    ('macau', 'MO'),
+    ('northern mariana islands', 'MP'),
+    ('martinique', 'MQ'),
+    ('mauritania', 'MR'),
+    ('montserrat', 'MS'),
+    ('malta', 'MT'),
+    ('mauritius', 'MU'),
+    ('maldives', 'MV'),
+    ('malawi', 'MW'),
+    ('mexico', 'MX'),
+    ('malaysia', 'MY'),
+    ('mozambique', 'MZ'),
+    ('namibia', 'NA'),
+    ('new caledonia', 'NC'),
+    ('niger', 'NE'),
+    ('norfolk island', 'NF'),
+    ('nigeria', 'NG'),
+    ('nicaragua', 'NI'),
+    ('netherlands', 'NL'),
+    ('norway', 'NO'),
+    ('nepal', 'NP'),
+    ('nauru', 'NR'),
+    ('niue', 'NU'),
+    ('new zealand', 'NZ'),
+    ('oman', 'OM'),
+    ('panama', 'PA'),
+    ('peru', 'PE'),
+    ('french polynesia', 'PF'),
+    ('papua new guinea', 'PG'),
+    ('philippines', 'PH'),
+    ('pakistan', 'PK'),
+    ('poland', 'PL'),
+    ('saint pierre and miquelon', 'PM'),
+    ('pitcairn', 'PN'),
+    ('puerto rico', 'PR'),
+    ('palestine', 'PS'),
+    ('portugal', 'PT'),
+    ('palau', 'PW'),
+    ('paraguay', 'PY'),
+    ('qatar', 'QA'),
+    ('réunion', 'RE'),
+    ('romania', 'RO'),
+    ('serbia', 'RS'),
+    ('russian federation', 'RU'),
+    ('rwanda', 'RW'),
+    ('saudi arabia', 'SA'),
+    ('solomon islands', 'SB'),
+    ('seychelles', 'SC'),
+    ('sudan', 'SD'),
+    ('sweden', 'SE'),
+    ('singapore', 'SG'),
+    ('saint helena, ascension and tristan da cunha', 'SH'),
+    ('slovenia', 'SI'),
+    ('svalbard and jan mayen', 'SJ'),
+    ('slovakia', 'SK'),
+    ('sierra leone', 'SL'),
+    ('san marino', 'SM'),
+    ('senegal', 'SN'),
+    ('somalia', 'SO'),
+    ('suriname', 'SR'),
+    ('south sudan', 'SS'),
+    ('sao tome and principe', 'ST'),
+    ('el salvador', 'SV'),
+    ('sint maarten (dutch part)', 'SX'),
+    ('syria', 'SY'),
+    ('eswatini', 'SZ'),
+    ('turks and caicos islands', 'TC'),
+    ('chad', 'TD'),
+    ('french s. terr.', 'TF'),
+    ('togo', 'TG'),
+    ('thailand', 'TH'),
+    ('tajikistan', 'TJ'),
+    ('tokelau', 'TK'),
+    ('timor-leste', 'TL'),
+    ('turkmenistan', 'TM'),
+    ('tunisia', 'TN'),
+    ('tonga', 'TO'),
+    ('turkey', 'TR'),
+    ('trinidad and tobago', 'TT'),
+    ('tuvalu', 'TV'),
+    ('taiwan', 'TW'),
+    ('tanzania', 'TZ'),
+    ('ukraine', 'UA'),
+    ('uganda', 'UG'),
+    ('us minor outlying islands', 'UM'),
+    ('united states', 'US'),
+    ('uruguay', 'UY'),
+    ('uzbekistan', 'UZ'),
+    ('holy see', 'VA'),
+    ('saint vincent and the grenadines', 'VC'),
+    ('venezuela', 'VE'),
+    ('virgin islands (british)', 'VG'),
+    ('virgin islands (u.s.)', 'VI'),
+    ('vietnam', 'VN'),
+    ('vanuatu', 'VU'),
+    ('wallis and futuna', 'WF'),
+    ('samoa', 'WS'),
+    ('yemen', 'YE'),
+    ('mayotte', 'YT'),
+    ('south africa', 'ZA'),
+    ('zambia', 'ZM'),
+    ('zimbabwe', 'ZW'),
+    ('usa', 'US'),
+    ('united states of america', 'US'),
+    ('uk', 'GB'),
+    ('great britain', 'GB'),
+    ('england', 'GB'),
+    ('deutschland', 'DE'),
+    ('russia', 'RU'),
+    ('south korea', 'KR'),
+    ('czech republic', 'CZ'),
+    ('the netherlands', 'NL'),
+    ('holland', 'NL'),
+    ('uae', 'AE');
+
+UPDATE addresses SET country = UPPER(TRIM(country))
+WHERE UPPER(TRIM(country)) IN (SELECT code FROM country_names);
+
+-- Unknown names are left as they are; the API asks for a valid code the
+-- next time such an address is saved.
+UPDATE addresses a SET country = n.code
+FROM country_names n
+WHERE LOWER(TRIM(a.country)) = n.name;
+
+UPDATE shipping_zones z SET countries = ARRAY(
+    SELECT COALESCE(
+        (SELECT n.code FROM country_names n WHERE n.code = UPPER(TRIM(c)) LIMIT 1),
+        (SELECT n.code FROM country_names n WHERE n.name = LOWER(TRIM(c))),
+        c)
+    FROM unnest(z.countries) WITH ORDINALITY AS t(c, i)
+    ORDER BY i
+);
+
+DROP TABLE country_names;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_created_at_id_idx;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,5 @@
+-- Admin user listing: keyset pagination over (created_at, id), newest first.
+UPDATE users SET created_at = COALESCE(updated_at, NOW()) WHERE created_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS users_created_at_id_idx ON users (created_at DESC, id DESC);
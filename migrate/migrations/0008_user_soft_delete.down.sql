@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS users_deleted_at_idx;
+ALTER TABLE users
+    DROP COLUMN erased_at,
+    DROP COLUMN deleted_at;
@@ -0,0 +1,8 @@
+-- Soft-deleted users keep their row (and everything referencing it) until
+-- they are restored or erased. Erasure anonymizes the row instead of
+-- deleting it, so reviews and future orders keep a valid user_id.
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN erased_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS impersonation_requests;
+ALTER TABLE login_sessions
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS impersonator_id;
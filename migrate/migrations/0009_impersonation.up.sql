@@ -0,0 +1,22 @@
+-- Admin impersonation: sessions opened by an admin on behalf of a user, and
+-- the requests made with them.
+ALTER TABLE login_sessions
+    ADD COLUMN IF NOT EXISTS impersonator_id INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS impersonation_requests (
+    id SERIAL PRIMARY KEY,
+    -- not a foreign key: the log outlives the session
+    session_id INTEGER NOT NULL,
+    impersonator_id INTEGER NOT NULL REFERENCES users(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INTEGER NOT NULL,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS impersonation_requests_user_idx ON impersonation_requests (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS impersonation_requests_impersonator_idx ON impersonation_requests (impersonator_id, created_at DESC);
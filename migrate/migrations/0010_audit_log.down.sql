@@ -0,0 +1,15 @@
+ALTER TABLE categories ADD COLUMN created_by_name VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE categories c SET created_by_name = COALESCE(
+    (SELECT u.username FROM users u WHERE u.id = c.created_by), c.created_by_legacy, '');
+ALTER TABLE categories DROP COLUMN created_by, DROP COLUMN updated_by, DROP COLUMN created_by_legacy;
+ALTER TABLE categories RENAME COLUMN created_by_name TO created_by;
+ALTER TABLE categories ALTER COLUMN created_by DROP DEFAULT;
+
+ALTER TABLE brands ADD COLUMN created_by_name VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE brands b SET created_by_name = COALESCE(
+    (SELECT u.username FROM users u WHERE u.id = b.created_by), b.created_by_legacy, '');
+ALTER TABLE brands DROP COLUMN created_by, DROP COLUMN updated_by, DROP COLUMN created_by_legacy;
+ALTER TABLE brands RENAME COLUMN created_by_name TO created_by;
+ALTER TABLE brands ALTER COLUMN created_by DROP DEFAULT;
+
+DROP TABLE IF EXISTS audit_log;
@@ -0,0 +1,37 @@
+-- Audit log of admin changes, written in the same transaction as the change.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER NOT NULL REFERENCES users(id),
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, created_at DESC);
+
+-- created_by was free text sent by the client; it becomes the ID of the
+-- admin, set by the server like the new updated_by. The text is kept in
+-- created_by_legacy, so names that match no username are not lost; rows
+-- created from now on leave it NULL.
+ALTER TABLE brands
+    ADD COLUMN created_by_id INTEGER REFERENCES users(id),
+    ADD COLUMN updated_by INTEGER REFERENCES users(id);
+UPDATE brands b SET created_by_id = u.id FROM users u WHERE u.username = b.created_by;
+ALTER TABLE brands RENAME COLUMN created_by TO created_by_legacy;
+ALTER TABLE brands ALTER COLUMN created_by_legacy DROP NOT NULL;
+ALTER TABLE brands RENAME COLUMN created_by_id TO created_by;
+
+ALTER TABLE categories
+    ADD COLUMN created_by_id INTEGER REFERENCES users(id),
+    ADD COLUMN updated_by INTEGER REFERENCES users(id);
+UPDATE categories c SET created_by_id = u.id FROM users u WHERE u.username = c.created_by;
+ALTER TABLE categories RENAME COLUMN created_by TO created_by_legacy;
+ALTER TABLE categories ALTER COLUMN created_by_legacy DROP NOT NULL;
+ALTER TABLE categories RENAME COLUMN created_by_id TO created_by;
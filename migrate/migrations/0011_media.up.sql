@@ -0,0 +1,22 @@
+-- Uploaded files. The content is kept in the blob store under the hash, so
+-- each distinct file is stored once.
+CREATE TABLE IF NOT EXISTS media (
+    id SERIAL PRIMARY KEY,
+    hash CHAR(64) NOT NULL UNIQUE,
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    uploaded_by INTEGER REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Gallery of each product; products.image holds the URL of the first.
+CREATE TABLE IF NOT EXISTS product_images (
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    media_id INTEGER NOT NULL REFERENCES media(id),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (product_id, media_id),
+    UNIQUE (product_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS product_images_media_idx ON product_images (media_id);
@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS subcategories (
+    id SERIAL PRIMARY KEY,
+    category_id INT NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    subcategory_name VARCHAR(255) NOT NULL,
+    image TEXT,
+    status INT DEFAULT 1,
+    tree_id INTEGER
+);
+ALTER TABLE products ADD COLUMN sub_category_id INT REFERENCES subcategories(id);
+
+-- Every level below the top becomes a subcategory of its top-level category.
+INSERT INTO subcategories (category_id, subcategory_name, image, status, tree_id)
+SELECT split_part(path, '/', 2)::int, category_name, image, status, id
+FROM categories
+WHERE parent_id IS NOT NULL
+ORDER BY path;
+
+UPDATE products p SET category_id = s.category_id, sub_category_id = s.id
+FROM subcategories s
+WHERE s.tree_id = p.category_id;
+
+ALTER TABLE subcategories DROP COLUMN tree_id;
+DELETE FROM categories WHERE parent_id IS NOT NULL;
+
+DROP INDEX IF EXISTS categories_path_idx;
+DROP INDEX IF EXISTS categories_parent_idx;
+ALTER TABLE categories
+    DROP COLUMN parent_id,
+    DROP COLUMN path,
+    DROP COLUMN depth,
+    ALTER COLUMN position DROP NOT NULL;
@@ -0,0 +1,47 @@
+-- Categories become a tree of any depth. parent_id links a category to its
+-- parent, path lists the IDs from the top level down ('/1/5/9/') so that a
+-- subtree is one prefix match, and position orders siblings from 1.
+ALTER TABLE categories
+    ADD COLUMN parent_id INTEGER REFERENCES categories(id),
+    ADD COLUMN path TEXT NOT NULL DEFAULT '',
+    ADD COLUMN depth INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN subcategory_id INTEGER;
+
+UPDATE categories SET path = '/' || id || '/';
+
+-- Subcategories become children of their category, sharing its visibility.
+INSERT INTO categories (
+    category_name, image, status, category_visibility, is_approved, is_published,
+    price_visibility, parent_id, depth, subcategory_id
+)
+SELECT s.subcategory_name, s.image, s.status, c.category_visibility, c.is_approved, c.is_published,
+       c.price_visibility, c.id, 1, s.id
+FROM subcategories s
+JOIN categories c ON c.id = s.category_id
+ORDER BY s.id;
+
+UPDATE categories c SET path = p.path || c.id || '/'
+FROM categories p
+WHERE c.parent_id = p.id;
+
+UPDATE categories c SET position = o.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY parent_id ORDER BY position NULLS LAST, id) AS position
+    FROM categories
+) o
+WHERE o.id = c.id;
+
+-- Products of a subcategory move to the category that replaces it.
+UPDATE products p SET category_id = c.id
+FROM categories c
+WHERE c.subcategory_id = p.sub_category_id;
+
+ALTER TABLE products DROP COLUMN sub_category_id;
+DROP TABLE subcategories;
+
+ALTER TABLE categories DROP COLUMN subcategory_id;
+ALTER TABLE categories ALTER COLUMN path DROP DEFAULT;
+ALTER TABLE categories ALTER COLUMN position SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS categories_parent_idx ON categories (parent_id, position);
+CREATE INDEX IF NOT EXISTS categories_path_idx ON categories (path text_pattern_ops);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS product_attributes_value_idx;
+DROP INDEX IF EXISTS products_brand_status_idx;
+DROP INDEX IF EXISTS products_category_status_idx;
@@ -0,0 +1,5 @@
+-- Product counts of brands, categories and attribute values are grouped
+-- over these columns.
+CREATE INDEX IF NOT EXISTS products_category_status_idx ON products (category_id, status);
+CREATE INDEX IF NOT EXISTS products_brand_status_idx ON products (brand_id, status);
+CREATE INDEX IF NOT EXISTS product_attributes_value_idx ON product_attributes (attribute_value_id);
@@ -0,0 +1,23 @@
+-- Orders placed by customers. Only the customers who ordered a product, in
+-- an order that was not cancelled, may review it. Users are anonymized
+-- rather than deleted (0008), so orders keep a valid user_id.
+CREATE TABLE IF NOT EXISTS orders (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id),
+    status VARCHAR(20) NOT NULL DEFAULT 'placed' CHECK (status IN ('placed', 'paid', 'shipped', 'delivered', 'cancelled')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS orders_user_idx ON orders (user_id);
+
+-- Order items table, priced at the time of the order. Orders are placed
+-- from the cart, whose lines can be variations.
+CREATE TABLE IF NOT EXISTS order_items (
+    id SERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    variation_id INT REFERENCES variation_products(id) ON DELETE SET NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC NOT NULL
+);
+CREATE INDEX IF NOT EXISTS order_items_product_idx ON order_items (product_id, order_id);
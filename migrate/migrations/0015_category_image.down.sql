@@ -0,0 +1,2 @@
+ALTER TABLE categories ADD COLUMN category_img TEXT;
+UPDATE categories SET category_img = image;
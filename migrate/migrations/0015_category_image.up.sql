@@ -0,0 +1,4 @@
+-- Categories had both category_img and image; image is kept, as on brands
+-- and products, taking over category_img where it was the only one set.
+UPDATE categories SET image = category_img WHERE image IS NULL;
+ALTER TABLE categories DROP COLUMN category_img;
@@ -1,31 +1,46 @@
 // models/models.go
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type User struct {
-	ID          int       `json:"id"`
-	Username    string    `json:"username" binding:"required"`
-	Email       string    `json:"email" binding:"required,email"`
-	Password    string    `json:"password" binding:"required"`
-	Role        string    `json:"role" binding:"required"` // user, admin, manager
-	PhoneNumber *string   `json:"phone_number,omitempty"`
-	Image       *string   `json:"image,omitempty"`
-	IsVerified  bool      `json:"is_verified"`
-	IsBlocked   bool      `json:"is_blocked"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int        `json:"id"`
+	Username    string     `json:"username" binding:"required"`
+	Email       string     `json:"email" binding:"required,email"`
+	Password    string     `json:"password" binding:"required"`
+	Role        string     `json:"role" binding:"required"` // user, admin, manager
+	PhoneNumber *string    `json:"phone_number,omitempty"`
+	Image       *string    `json:"image,omitempty"`
+	IsVerified  bool       `json:"is_verified"`
+	IsBlocked   bool       `json:"is_blocked"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // soft-deleted, restorable until erased
+	ErasedAt    *time.Time `json:"erased_at,omitempty"`  // personal data anonymized
 }
 
+// Address is an entry of a user's address book, flagged for the purposes
+// (shipping, billing) it can be used for.
 type Address struct {
-	ID           int       `json:"id"`
-	UserID       int       `json:"user_id"`
-	AddressLine1 string    `json:"address_line1" binding:"required"`
-	City         string    `json:"city" binding:"required"`
-	Country      string    `json:"country" binding:"required"`
-	PostalCode   string    `json:"postal_code" binding:"required"`
-	Type         string    `json:"type" binding:"required,oneof=home office other"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID                int       `json:"id"`
+	UserID            int       `json:"user_id"`
+	RecipientName     string    `json:"recipient_name"`
+	Phone             *string   `json:"phone,omitempty"`
+	AddressLine1      string    `json:"address_line1" binding:"required"`
+	AddressLine2      *string   `json:"address_line2,omitempty"`
+	City              string    `json:"city" binding:"required"`
+	Region            *string   `json:"region,omitempty"`           // state, province or prefecture
+	Country           string    `json:"country" binding:"required"` // ISO 3166-1 alpha-2
+	PostalCode        string    `json:"postal_code"`
+	Type              string    `json:"type" binding:"required,oneof=home office other"`
+	IsShipping        bool      `json:"is_shipping"`
+	IsBilling         bool      `json:"is_billing"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `json:"created_at"`
 }
 
 type LoginSession struct {
@@ -36,30 +51,58 @@ type LoginSession struct {
 	Device    string    `json:"device"`
 	IPAddress string    `json:"ip_address"`
 	LoginAt   time.Time `json:"login_at"`
+	// ImpersonatorID is the admin who opened the session on the user's
+	// behalf; such sessions end at ExpiresAt.
+	ImpersonatorID *int       `json:"impersonator_id,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// ImpersonationRequest is one request made with an impersonation token.
+type ImpersonationRequest struct {
+	ID             int       `json:"id"`
+	SessionID      int       `json:"session_id"`
+	ImpersonatorID int       `json:"impersonator_id"`
+	UserID         int       `json:"user_id"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	Status         int       `json:"status"`
+	IPAddress      string    `json:"ip_address"`
+	UserAgent      string    `json:"user_agent"`
+	CreatedAt      time.Time `json:"created_at"`
 }
 
+// ShippingAddress and BillingAddress are the shapes the shipping and billing
+// address endpoints return for the address book entries flagged for them.
 type ShippingAddress struct {
-	ID           int       `json:"id"`
-	UserID       int       `json:"user_id"`
-	AddressLine1 string    `json:"address_line1" binding:"required"`
-	City         string    `json:"city" binding:"required"`
-	Country      string    `json:"country" binding:"required"`
-	PostalCode   string    `json:"postal_code" binding:"required"`
-	Type         string    `json:"type" binding:"required,oneof=home office other"`
-	IsDefault    bool      `json:"is_default"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         *string   `json:"phone,omitempty"`
+	AddressLine1  string    `json:"address_line1" binding:"required"`
+	AddressLine2  *string   `json:"address_line2,omitempty"`
+	City          string    `json:"city" binding:"required"`
+	Region        *string   `json:"region,omitempty"`
+	Country       string    `json:"country" binding:"required"`
+	PostalCode    string    `json:"postal_code"`
+	Type          string    `json:"type" binding:"required,oneof=home office other"`
+	IsDefault     bool      `json:"is_default"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type BillingAddress struct {
-	ID           int       `json:"id"`
-	UserID       int       `json:"user_id"`
-	AddressLine1 string    `json:"address_line1" binding:"required"`
-	City         string    `json:"city" binding:"required"`
-	Country      string    `json:"country" binding:"required"`
-	PostalCode   string    `json:"postal_code" binding:"required"`
-	Type         string    `json:"type" binding:"required,oneof=home office other"`
-	IsDefault    bool      `json:"is_default"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id"`
+	RecipientName string    `json:"recipient_name"`
+	Phone         *string   `json:"phone,omitempty"`
+	AddressLine1  string    `json:"address_line1" binding:"required"`
+	AddressLine2  *string   `json:"address_line2,omitempty"`
+	City          string    `json:"city" binding:"required"`
+	Region        *string   `json:"region,omitempty"`
+	Country       string    `json:"country" binding:"required"`
+	PostalCode    string    `json:"postal_code"`
+	Type          string    `json:"type" binding:"required,oneof=home office other"`
+	IsDefault     bool      `json:"is_default"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 type Brand struct {
@@ -72,38 +115,48 @@ type Brand struct {
 	IsSpecial         bool      `json:"is_special"`
 	IsApprovedByAdmin bool      `json:"is_approved_by_admin"`
 	IsVisibleToGuest  bool      `json:"is_visible_to_guest"`
-	CreatedBy         string    `json:"created_by" binding:"required"`
+	CreatedBy         *int      `json:"created_by"` // admin user ID, set by the server
+	UpdatedBy         *int      `json:"updated_by"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
+	// product counts, computed on reads; published products have status 1
+	ProductsCount      int `json:"products_count"`
+	TotalProductsCount int `json:"total_products_count"`
 }
 
-type SubCategory struct {
-	ID              int     `json:"id"`
-	CategoryID      int     `json:"category_id"`
-	SubCategoryName string  `json:"subcategory_name"`
-	Image           *string `json:"image,omitempty"`
-	Status          int     `json:"status"`
+// Category is a node of the category tree. Path lists the IDs from the
+// top-level category down to this one, as "/1/5/9/".
+type Category struct {
+	ID                 int       `json:"id"`
+	ParentID           *int      `json:"parent_id"` // nil for top-level categories
+	Path               string    `json:"-"`
+	Depth              int       `json:"depth"`    // 0 for top-level categories
+	Position           int       `json:"position"` // among its siblings, from 1
+	Code               *string   `json:"code,omitempty"`
+	CategoryName       string    `json:"category_name" binding:"required"`
+	Image              *string   `json:"image,omitempty"`
+	CategoryVisibility int       `json:"category_visibility"`
+	IsSpecial          int       `json:"is_special"`
+	IsFeatured         int       `json:"is_featured"`
+	IsApproved         bool      `json:"is_approved"`
+	IsPublished        bool      `json:"is_published"`
+	PriceVisibility    int       `json:"price_visibility"`
+	Status             int       `json:"status"`
+	CreatedBy          *int      `json:"created_by"` // admin user ID, set by the server
+	UpdatedBy          *int      `json:"updated_by"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	// product counts of the category and the categories below it, computed
+	// on reads; published products have status 1
+	ProductsCount      int             `json:"products_count"`
+	TotalProductsCount int             `json:"total_products_count"`
+	Children           []Category      `json:"children,omitempty"`
+	Breadcrumbs        []CategoryCrumb `json:"breadcrumbs,omitempty"` // ancestors, top-level first
 }
 
-type Category struct {
-	ID                 int           `json:"id"`
-	Code               *string       `json:"code,omitempty"`
-	CategoryName       string        `json:"category_name" binding:"required"`
-	CategoryImg        *string       `json:"category_img,omitempty"`
-	Image              *string       `json:"image,omitempty"`
-	CategoryVisibility int           `json:"category_visibility"`
-	IsSpecial          int           `json:"is_special"`
-	IsFeatured         int           `json:"is_featured"`
-	IsApproved         bool          `json:"is_approved"`
-	IsPublished        bool          `json:"is_published"`
-	Position           *int          `json:"position,omitempty"`
-	PriceVisibility    int           `json:"price_visibility"`
-	Status             int           `json:"status"`
-	CreatedBy          string        `json:"created_by" binding:"required"`
-	CreatedAt          time.Time     `json:"created_at"`
-	UpdatedAt          time.Time     `json:"updated_at"`
-	ProductsCount      int           `json:"products_count"`
-	SubCategories      []SubCategory `json:"sub_categories"`
+type CategoryCrumb struct {
+	ID           int    `json:"id"`
+	CategoryName string `json:"category_name"`
 }
 
 type Attribute struct {
@@ -111,7 +164,6 @@ type Attribute struct {
 	AttributeName   string           `json:"attribute_name"`
 	Status          int              `json:"status"`
 	AttributeValues []AttributeValue `json:"attribute_values"`
-	CreatedAt       time.Time        `json:"created_at"`
 }
 
 type AttributeValue struct {
@@ -119,17 +171,17 @@ type AttributeValue struct {
 	AttributeID int    `json:"attribute_id"`
 	Value       string `json:"value"`
 	Status      int    `json:"status"`
+	UsageCount  int    `json:"usage_count"` // products with this value, computed on reads
 }
 
 type Product struct {
 	ID              int      `json:"id"`
 	BrandID         int      `json:"brand_id"`
 	CategoryID      int      `json:"category_id"`
-	SubCategoryID   *int     `json:"sub_category_id"`
 	PCode           *string  `json:"p_code"`
 	Weight          *string  `json:"weight"`
-	ProductName     string   `json:"product_name"`
-	ProductCode     string   `json:"product_code"`
+	ProductName     string   `json:"product_name" binding:"required"`
+	ProductCode     string   `json:"product_code" binding:"required"`
 	Price           *float64 `json:"price"`
 	MTotalPrice     *float64 `json:"m_total_price"`
 	Unit            *string  `json:"unit"`
@@ -155,8 +207,19 @@ type Product struct {
 	IsVariation     int      `json:"is_variation"`
 	Status          int      `json:"status"`
 	UOMID           *int     `json:"UOM_id"`
-	Rating          int      `json:"rating"`
+	Rating          float64  `json:"rating"`
+	RatingCount     int      `json:"rating_count"`
 	CurrentStock    int      `json:"current_stock"`
+
+	// Images are the product's gallery in display order; Image is the
+	// URL of the first one. Only loaded for a single product.
+	Images []ProductImage `json:"images,omitempty"`
+}
+
+type ProductImage struct {
+	MediaID  int    `json:"media_id"`
+	Position int    `json:"position"`
+	URL      string `json:"url"`
 }
 
 type ProductAttribute struct {
@@ -169,10 +232,171 @@ type ProductAttribute struct {
 type VariationProduct struct {
 	ID               int      `json:"id"`
 	ProductID        int      `json:"product_id"`
-	SKU              string   `json:"sku"`
+	SKU              string   `json:"sku" binding:"required"`
 	SalePrice        *float64 `json:"sale_price"`
 	DefaultSellPrice *float64 `json:"default_sell_price"`
 	Discount         *float64 `json:"discount"`
 	Image            string   `json:"image"`
 	CurrentStock     int      `json:"current_stock"`
 }
+
+type CartItem struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"user_id"`
+	ProductID    int       `json:"product_id"`
+	VariationID  *int      `json:"variation_id"`
+	Quantity     int       `json:"quantity"`
+	ProductName  string    `json:"product_name"`
+	SKU          *string   `json:"sku,omitempty"`
+	Weight       *string   `json:"weight"`
+	UnitPrice    float64   `json:"unit_price"`
+	LineTotal    float64   `json:"line_total"`
+	CurrentStock int       `json:"current_stock"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Order struct {
+	ID        int         `json:"id"`
+	UserID    int         `json:"user_id"`
+	Status    string      `json:"status"` // placed, paid, shipped, delivered, cancelled
+	Items     []OrderItem `json:"items"`
+	Total     float64     `json:"total"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// OrderItem is a line of an order, priced at the time of the order.
+type OrderItem struct {
+	ID          int     `json:"id"`
+	ProductID   int     `json:"product_id"`
+	VariationID *int    `json:"variation_id"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+}
+
+type ShippingZone struct {
+	ID                 int              `json:"id"`
+	ZoneName           string           `json:"zone_name"`
+	Countries          []string         `json:"countries"`
+	PostalCodePatterns []string         `json:"postal_code_patterns"`
+	Position           int              `json:"position"`
+	Status             int              `json:"status"`
+	Methods            []ShippingMethod `json:"methods"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+type ShippingMethod struct {
+	ID                    int                `json:"id"`
+	ZoneID                int                `json:"zone_id"`
+	MethodName            string             `json:"method_name"`
+	RateType              string             `json:"rate_type"` // flat, weight, price
+	BaseRate              float64            `json:"base_rate"`
+	FreeShippingThreshold *float64           `json:"free_shipping_threshold"`
+	EstimatedDays         *string            `json:"estimated_days"`
+	Status                int                `json:"status"`
+	Tiers                 []ShippingRateTier `json:"tiers"`
+	CreatedAt             time.Time          `json:"created_at"`
+}
+
+type ShippingRateTier struct {
+	ID       int      `json:"id"`
+	MethodID int      `json:"method_id"`
+	MinValue float64  `json:"min_value"`
+	MaxValue *float64 `json:"max_value"`
+	Rate     float64  `json:"rate"`
+}
+
+type ShippingOption struct {
+	MethodID      int     `json:"method_id"`
+	MethodName    string  `json:"method_name"`
+	RateType      string  `json:"rate_type"`
+	Cost          float64 `json:"cost"`
+	EstimatedDays *string `json:"estimated_days"`
+}
+
+type ProductReview struct {
+	ID              int        `json:"id"`
+	ProductID       int        `json:"product_id"`
+	UserID          int        `json:"user_id"`
+	Username        string     `json:"username"`
+	Rating          int        `json:"rating"`
+	Title           string     `json:"title"`
+	Body            string     `json:"body"`
+	Status          string     `json:"status"` // pending, approved, rejected, hidden
+	HelpfulCount    int        `json:"helpful_count"`
+	NotHelpfulCount int        `json:"not_helpful_count"`
+	ModeratedBy     *int       `json:"moderated_by,omitempty"`
+	ModeratedAt     *time.Time `json:"moderated_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type Wishlist struct {
+	ID         int            `json:"id"`
+	UserID     int            `json:"user_id"`
+	Name       string         `json:"name"`
+	ShareToken *string        `json:"share_token,omitempty"`
+	ItemCount  int            `json:"item_count"`
+	Items      []WishlistItem `json:"items,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+type WishlistItem struct {
+	ID                int       `json:"id"`
+	WishlistID        int       `json:"wishlist_id"`
+	ProductID         int       `json:"product_id"`
+	VariationID       *int      `json:"variation_id"`
+	ProductName       string    `json:"product_name"`
+	SKU               *string   `json:"sku,omitempty"`
+	Image             string    `json:"image"`
+	CurrentPrice      *float64  `json:"current_price"`
+	PriceAtAdd        *float64  `json:"price_at_add"`
+	CurrentStock      int       `json:"current_stock"`
+	NotifyPriceDrop   bool      `json:"notify_price_drop"`
+	NotifyBackInStock bool      `json:"notify_back_in_stock"`
+	AddedAt           time.Time `json:"added_at"`
+}
+
+type WishlistNotification struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"user_id"`
+	WishlistItemID *int       `json:"wishlist_item_id"`
+	ProductID      int        `json:"product_id"`
+	VariationID    *int       `json:"variation_id"`
+	ProductName    string     `json:"product_name"`
+	Type           string     `json:"type"` // price_drop, back_in_stock
+	OldPrice       *float64   `json:"old_price"`
+	NewPrice       *float64   `json:"new_price"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReadAt         *time.Time `json:"read_at"`
+}
+
+// AuditEntry records one change made by an admin. Before and After hold the
+// entity's changed fields, or all of them on creation and deletion.
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	ActorID    int             `json:"actor_id"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int             `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	IPAddress  string          `json:"ip_address"`
+	UserAgent  string          `json:"user_agent"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// Media is an uploaded file. Its content is kept in a blob store under its
+// hash, so uploading the same file twice yields the same media.
+type Media struct {
+	ID         int       `json:"id"`
+	Hash       string    `json:"hash"` // hex SHA-256 of the content
+	MimeType   string    `json:"mime_type"`
+	Size       int64     `json:"size"`
+	Filename   string    `json:"filename"` // name of the first upload
+	URL        string    `json:"url"`
+	UploadedBy *int      `json:"uploaded_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,59 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// body is the JSON request body a handler binds.
+type body struct {
+	value    any
+	optional bool
+}
+
+// bodies holds the body of each handler declaring one, by the name gin
+// reports for it in gin.RouteInfo.Handler.
+var bodies sync.Map
+
+// Accepts declares that h binds a JSON body of type T, and returns h. The
+// document describes the body of its routes from the json and binding tags
+// of T, so that it follows the handler.
+func Accepts[T any](h gin.HandlerFunc) gin.HandlerFunc {
+	return accepts[T](h, false)
+}
+
+// AcceptsOptional is Accepts for a handler that also takes no body at all.
+func AcceptsOptional[T any](h gin.HandlerFunc) gin.HandlerFunc {
+	return accepts[T](h, true)
+}
+
+func accepts[T any](h gin.HandlerFunc, optional bool) gin.HandlerFunc {
+	var value T
+	name := handlerName(h)
+	b := body{value, optional}
+	if prev, loaded := bodies.LoadOrStore(name, b); loaded {
+		// handlers made by the same function literal share their name
+		if p := prev.(body); reflect.TypeOf(p.value) != reflect.TypeOf(value) || p.optional != optional {
+			panic(fmt.Sprintf("openapi: %s accepts both %T and %T", name, p.value, value))
+		}
+	}
+	return h
+}
+
+// bodyOf returns the body the handler named name declared.
+func bodyOf(name string) (body, bool) {
+	b, ok := bodies.Load(name)
+	if !ok {
+		return body{}, false
+	}
+	return b.(body), true
+}
+
+// handlerName names h as gin does.
+func handlerName(h gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+}
@@ -0,0 +1,21 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A function literal making handlers of different bodies cannot tell its
+// routes apart, and is refused.
+func TestAcceptsConflict(t *testing.T) {
+	handler := func(c *gin.Context) {}
+	Accepts[testBrand](handler)
+	Accepts[testBrand](handler)
+	defer func() {
+		if recover() == nil {
+			t.Error("declaring another body of the handler did not panic")
+		}
+	}()
+	Accepts[testInput](handler)
+}
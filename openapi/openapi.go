@@ -0,0 +1,294 @@
+// Package openapi generates the OpenAPI 3.1 document of the API from its
+// gin routes and a description of each: the Go types of its request body,
+// declared by its handler, and of its response, whose json and binding tags
+// give their schemas.
+package openapi
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"my-api/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation describes a route. Response is an example of the Go value
+// answered, such as openapi.Object{...}. The JSON body of the route is the
+// one its handler declares with Accepts.
+type Operation struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Role of the access token required, "user" or "admin"; empty for
+	// public routes.
+	Role  string
+	Query []Param
+	// File and Files name the multipart field of one or several uploaded
+	// files, for routes taking a form instead of a JSON body.
+	File  string
+	Files string
+	// Status of success, http.StatusOK by default.
+	Status   int
+	Response any
+	// ContentType of a response that is not JSON, such as an image.
+	ContentType string
+}
+
+// Param is a query parameter. Value is an example of its Go type and
+// Binding holds its rules, as a binding tag would.
+type Param struct {
+	Name        string
+	Value       any
+	Binding     string
+	Description string
+}
+
+// Require returns ops with their Role set to role.
+func Require(role string, ops map[string]Operation) map[string]Operation {
+	secured := make(map[string]Operation, len(ops))
+	for key, op := range ops {
+		op.Role = role
+		secured[key] = op
+	}
+	return secured
+}
+
+// Document is an OpenAPI 3.1 document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lower-case method.
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+const bearerAuth = "bearerAuth"
+
+// Build returns the document of the routes described in ops, keyed by
+// "METHOD /path" as registered with gin (e.g. "GET /products/:id").
+// Routes without a description are left out; see Undocumented.
+func Build(info Info, routes gin.RoutesInfo, ops map[string]Operation) *Document {
+	doc, _ := build(info, routes, ops)
+	return doc
+}
+
+// build also returns the operations of the document by the keys of ops.
+func build(info Info, routes gin.RoutesInfo, ops map[string]Operation) (*Document, map[string]*OperationObject) {
+	s := newSchemas()
+	problemRef := s.of(problem.Problem{})
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	index := map[string]*OperationObject{}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		op, ok := ops[key]
+		if !ok {
+			continue
+		}
+		path, params := pathTemplate(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		index[key] = operation(s, route, op, params, problemRef)
+		doc.Paths[path][strings.ToLower(route.Method)] = index[key]
+	}
+	return doc, index
+}
+
+func operation(s *schemas, route gin.RouteInfo, op Operation, params []Parameter, problemRef *Schema) *OperationObject {
+	o := &OperationObject{
+		OperationID: operationID(route.Method, route.Path),
+		Summary:     op.Summary,
+		Description: op.Description,
+		Parameters:  params,
+		Responses: map[string]Response{
+			"default": {
+				Description: "Problem details of the error",
+				Content:     map[string]MediaType{problem.ContentType: {Schema: problemRef}},
+			},
+		},
+	}
+	if op.Tag != "" {
+		o.Tags = []string{op.Tag}
+	}
+	if op.Role != "" {
+		o.Security = []map[string][]string{{bearerAuth: {}}}
+		note := fmt.Sprintf("Requires an access token of the %s role.", op.Role)
+		if o.Description == "" {
+			o.Description = note
+		} else {
+			o.Description += "\n\n" + note
+		}
+	}
+
+	for _, q := range op.Query {
+		schema := s.of(q.Value)
+		o.Parameters = append(o.Parameters, Parameter{
+			Name:        q.Name,
+			In:          "query",
+			Description: q.Description,
+			Required:    constrain(schema, q.Binding),
+			Schema:      schema,
+		})
+	}
+
+	body, accepts := bodyOf(route.Handler)
+	switch {
+	case accepts:
+		o.RequestBody = &RequestBody{Required: !body.optional, Content: map[string]MediaType{
+			"application/json": {Schema: s.of(body.value)},
+		}}
+	case op.File != "" || op.Files != "":
+		file := &Schema{Type: "string", ContentMediaType: "application/octet-stream"}
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		if op.File != "" {
+			form.Properties[op.File] = file
+			form.Required = []string{op.File}
+		} else {
+			form.Properties[op.Files] = &Schema{Type: "array", Items: file}
+			form.Required = []string{op.Files}
+		}
+		o.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: form},
+		}}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status)}
+	switch {
+	case op.ContentType != "":
+		success.Content = map[string]MediaType{op.ContentType: {Schema: &Schema{}}}
+	case op.Response != nil:
+		success.Content = map[string]MediaType{"application/json": {Schema: s.of(op.Response)}}
+	}
+	o.Responses[fmt.Sprint(status)] = success
+	return o
+}
+
+// pathTemplate turns the gin path of a route into an OpenAPI path template,
+// with its parameters. IDs are integers, other parameters strings.
+func pathTemplate(path string) (string, []Parameter) {
+	var params []Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			name, ok = strings.CutPrefix(segment, "*")
+		}
+		if !ok {
+			continue
+		}
+		segments[i] = "{" + name + "}"
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = &Schema{Type: "integer"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID names the operation after its method and path, e.g.
+// getAdminUsersById for GET /admin/users/:id.
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			b.WriteString("By")
+			segment = name
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// Undocumented returns the routes without a description in ops, and the
+// descriptions of routes that do not exist, as "METHOD /path".
+func Undocumented(routes gin.RoutesInfo, ops map[string]Operation) (missing, stale []string) {
+	routed := map[string]bool{}
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		routed[key] = true
+		if _, ok := ops[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(ops)) {
+		if !routed[key] {
+			stale = append(stale, key)
+		}
+	}
+	return missing, stale
+}
@@ -0,0 +1,322 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is a JSON Schema (draft 2020-12, as OpenAPI 3.1 uses it). Type is a
+// string, or a list of them for values that may also be null.
+//
+// Objects described by a struct or an Object are closed: unknown fields are
+// rejected by Validate, like a typo in a field name would be lost by the
+// handler.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	// Closed objects have no properties besides Properties.
+	Closed bool      `json:"-"`
+	OneOf  []*Schema `json:"oneOf,omitempty"`
+}
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Closed {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		AdditionalProperties bool `json:"additionalProperties"`
+	}{plain(s), false})
+}
+
+// Object describes a JSON object built in place, like a gin.H response:
+// each value is an example of the property's Go type, e.g.
+//
+//	openapi.Object{"message": "", "id": 0}
+//
+// Values may be Objects, or one-element []Object for arrays of them.
+type Object map[string]any
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemas generates the schemas of Go types the way encoding/json encodes
+// them, with their binding tags as constraints. Named struct types become
+// components, referenced by $ref.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema of v, an example of the described value.
+func (s *schemas) of(v any) *Schema {
+	switch v := v.(type) {
+	case Object:
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}, Closed: true}
+		for name, value := range v {
+			schema.Properties[name] = s.of(value)
+		}
+		return schema
+	case []Object:
+		if len(v) == 0 {
+			return &Schema{Type: "array"}
+		}
+		return &Schema{Type: "array", Items: s.of(v[0])}
+	}
+	return s.typeOf(reflect.TypeOf(v))
+}
+
+func (s *schemas) typeOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{} // any JSON value
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(s.typeOf(t.Elem()))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.typeOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.typeOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structOf(t)
+		}
+		return s.ref(t)
+	}
+	return &Schema{}
+}
+
+// ref returns a reference to the component of the named struct type t,
+// adding it on first use.
+func (s *schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.components[name]; taken {
+			pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+			name = string(unicode.ToUpper(rune(pkg[0]))) + pkg[1:] + name
+		}
+		s.names[t] = name
+		// reserved before generating, for types that refer to themselves
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.structOf(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemas) structOf(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}, Closed: true}
+	s.addFields(schema, t)
+	return schema
+}
+
+// addFields adds the properties of the fields of t to schema, and those of
+// the structs it embeds.
+func (s *schemas) addFields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			s.addFields(schema, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.typeOf(f.Type)
+		// nil slices and maps are encoded as null
+		if k := f.Type.Kind(); (k == reflect.Slice && f.Type.Elem().Kind() != reflect.Uint8 || k == reflect.Map) &&
+			!strings.Contains(opts, "omitempty") {
+			prop = nullable(prop)
+		}
+		if constrain(prop, f.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// nullable returns schema also allowing null.
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{OneOf: []*Schema{schema, {Type: "null"}}}
+	}
+	if typ, ok := schema.Type.(string); ok {
+		schema.Type = []string{typ, "null"}
+	}
+	return schema
+}
+
+// notNull undoes nullable, for required values.
+func notNull(schema *Schema) *Schema {
+	if len(schema.OneOf) == 2 && schema.OneOf[1].Type == "null" {
+		return schema.OneOf[0]
+	}
+	if types, ok := schema.Type.([]string); ok && len(types) == 2 && types[1] == "null" {
+		schema.Type = types[0]
+	}
+	return schema
+}
+
+// constrain adds the rules of a binding tag to schema, with those after
+// "dive" applying to the items of an array. It reports whether the value
+// is required.
+func constrain(schema *Schema, binding string) (required bool) {
+	if binding == "" {
+		return false
+	}
+	target := schema
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if target == schema {
+				required = true
+				*schema = *notNull(schema)
+				// required fails on zero values; of those, only empty
+				// strings can be told from missing values in a schema
+				if hasType(schema, "string") {
+					one := 1
+					schema.MinLength = &one
+				}
+			}
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+		case "min", "gte":
+			setBound(target, param, true, false)
+		case "max", "lte":
+			setBound(target, param, false, false)
+		case "gt":
+			setBound(target, param, true, true)
+		case "lt":
+			setBound(target, param, false, true)
+		case "len":
+			setBound(target, param, true, false)
+			setBound(target, param, false, false)
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				if hasType(target, "integer") {
+					if n, err := strconv.Atoi(value); err == nil {
+						target.Enum = append(target.Enum, n)
+						continue
+					}
+				}
+				target.Enum = append(target.Enum, value)
+			}
+		case "email":
+			target.Format = "email"
+		case "url", "http_url":
+			target.Format = "uri"
+		case "unique":
+			target.UniqueItems = true
+		}
+	}
+	return required
+}
+
+// setBound sets the lower or upper bound of schema: a length, a number of
+// items or a value, depending on its type.
+func setBound(schema *Schema, param string, lower, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	if hasType(schema, "string") || hasType(schema, "array") {
+		size := int(n)
+		// sizes are whole: > n is >= n+1
+		if exclusive && lower {
+			size++
+		} else if exclusive {
+			size--
+		}
+		switch {
+		case hasType(schema, "string") && lower:
+			schema.MinLength = &size
+		case hasType(schema, "string"):
+			schema.MaxLength = &size
+		case lower:
+			schema.MinItems = &size
+		default:
+			schema.MaxItems = &size
+		}
+		return
+	}
+	switch {
+	case lower && exclusive:
+		schema.ExclusiveMinimum = &n
+	case lower:
+		schema.Minimum = &n
+	case exclusive:
+		schema.ExclusiveMaximum = &n
+	default:
+		schema.Maximum = &n
+	}
+}
+
+func hasType(schema *Schema, typ string) bool {
+	switch t := schema.Type.(type) {
+	case string:
+		return t == typ
+	case []string:
+		return len(t) > 0 && t[0] == typ
+	}
+	return false
+}
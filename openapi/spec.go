@@ -0,0 +1,86 @@
+package openapi
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerPage is Swagger UI's index page, loading the document from
+// /openapi.json instead of the demo's.
+const swaggerPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>API documentation</title>
+  <link rel="stylesheet" href="swagger-ui.css">
+  <link rel="icon" type="image/png" href="favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="swagger-ui-bundle.js"></script>
+  <script src="swagger-ui-standalone-preset.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: "/openapi.json",
+      dom_id: "#swagger-ui",
+      deepLinking: true,
+      persistAuthorization: true,
+      presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+      layout: "StandaloneLayout"
+    });
+  </script>
+</body>
+</html>
+`
+
+// Spec is the document of the routes of a router, built on first use, once
+// every route is registered.
+type Spec struct {
+	built func() built
+}
+
+type built struct {
+	doc        *Document
+	operations map[string]*OperationObject
+}
+
+// New returns the spec of the routes of r described in ops.
+func New(r *gin.Engine, info Info, ops map[string]Operation) *Spec {
+	return &Spec{built: sync.OnceValue(func() built {
+		doc, operations := build(info, r.Routes(), ops)
+		return built{doc, operations}
+	})}
+}
+
+// Document returns the OpenAPI document.
+func (s *Spec) Document() *Document {
+	return s.built().doc
+}
+
+// operation returns the description of the route of c, or nil.
+func (s *Spec) operation(c *gin.Context) *OperationObject {
+	return s.built().operations[c.Request.Method+" "+c.FullPath()]
+}
+
+// Serve adds GET /openapi.json, the document, and the Swagger UI at /docs/
+// to r.
+func (s *Spec) Serve(r gin.IRouter) {
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Document())
+	})
+
+	assets := http.StripPrefix("/docs/", http.FileServerFS(swaggerFiles.FS))
+	r.GET("/docs", func(c *gin.Context) {
+		c.Redirect(http.StatusMovedPermanently, "/docs/")
+	})
+	r.GET("/docs/*file", func(c *gin.Context) {
+		if file := c.Param("file"); file == "/" || file == "/index.html" {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerPage))
+			return
+		}
+		assets.ServeHTTP(c.Writer, c.Request)
+	})
+}
@@ -0,0 +1,311 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"my-api/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Validate returns middleware checking each request against the description
+// of its route before the handler runs: its path and query parameters, and
+// its JSON body, which may not have fields the schema does not know nor be
+// over maxBody bytes. Invalid requests are answered with every field at
+// fault. Routes the spec does not describe, and unknown query parameters,
+// are let through.
+func (s *Spec) Validate(maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := s.operation(c)
+		if op == nil {
+			return
+		}
+
+		v := &validator{schemas: s.Document().Components.Schemas}
+		for _, param := range op.Parameters {
+			var raw string
+			if param.In == "path" {
+				raw = c.Param(param.Name)
+			} else {
+				raw = c.Query(param.Name)
+			}
+			// empty query parameters count as missing, as in the handlers
+			if raw == "" {
+				if param.Required {
+					v.fail(param.Name, "required", "is required")
+				}
+				continue
+			}
+			v.param(param.Name, param.Schema, raw)
+		}
+
+		if op.RequestBody != nil {
+			if p := v.body(c, op.RequestBody, maxBody); p != nil {
+				problem.Respond(c, p)
+				return
+			}
+		}
+
+		if len(v.errs) > 0 {
+			problem.Respond(c, problem.New(http.StatusBadRequest, problem.CodeValidationFailed, "Invalid input").
+				WithFields(v.errs...))
+		}
+	}
+}
+
+type validator struct {
+	schemas map[string]*Schema
+	errs    []problem.FieldError
+}
+
+func (v *validator) fail(field, code, message string) {
+	if field == "" {
+		field = "body"
+	}
+	v.errs = append(v.errs, problem.FieldError{Field: field, Code: code, Message: field + " " + message})
+}
+
+// param checks the path or query parameter with the raw value.
+func (v *validator) param(name string, schema *Schema, raw string) {
+	var value any = raw
+	switch {
+	case hasType(schema, "integer"):
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			v.fail(name, "type", "must be an integer")
+			return
+		}
+		value = json.Number(raw)
+	case hasType(schema, "number"):
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			v.fail(name, "type", "must be a number")
+			return
+		}
+		value = json.Number(raw)
+	case hasType(schema, "boolean"):
+		if raw != "true" && raw != "false" {
+			v.fail(name, "type", "must be true or false")
+			return
+		}
+		value = raw == "true"
+	}
+	v.check(name, schema, value)
+}
+
+// body reads the JSON body of the request, leaving it for the handler to
+// read again, and checks it. It returns the problem of a body that cannot
+// be read as JSON; the fields at fault are added to v.errs.
+func (v *validator) body(c *gin.Context, body *RequestBody, maxBody int64) *problem.Problem {
+	media, ok := body.Content["application/json"]
+	if !ok {
+		// uploads are bounded by their handlers
+		return nil
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBody)
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return problem.Binding(err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if body.Required {
+			return problem.Binding(io.EOF)
+		}
+		return nil
+	}
+	if ct := c.ContentType(); ct != "" && ct != "application/json" {
+		return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType,
+			"Content-Type must be application/json")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return problem.Binding(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return problem.BadRequest("Malformed JSON")
+	}
+	v.check("", media.Schema, value)
+	return nil
+}
+
+// check checks the decoded JSON value at field against schema.
+func (v *validator) check(field string, schema *Schema, value any) {
+	if name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/"); ok {
+		schema = v.schemas[name]
+	}
+	if len(schema.OneOf) > 0 {
+		// a nullable reference
+		if value != nil {
+			v.check(field, schema.OneOf[0], value)
+		}
+		return
+	}
+	if schema.Type == nil {
+		return
+	}
+	if !allows(schema, value) {
+		v.fail(field, "type", "must be "+typeNoun(schema))
+		return
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		var values []string
+		for _, e := range schema.Enum {
+			values = append(values, fmt.Sprint(e))
+		}
+		v.fail(field, "oneof", "must be one of "+strings.Join(values, ", "))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		n := utf8.RuneCountInString(value)
+		switch {
+		case schema.MinLength != nil && n < *schema.MinLength:
+			// the minimum of required strings
+			if *schema.MinLength == 1 {
+				v.fail(field, "required", "is required")
+			} else {
+				v.fail(field, "min", "must be at least "+strconv.Itoa(*schema.MinLength)+" characters long")
+			}
+		case schema.MaxLength != nil && n > *schema.MaxLength:
+			v.fail(field, "max", "must be at most "+strconv.Itoa(*schema.MaxLength)+" characters long")
+		case schema.Format == "email" && !validEmail(value):
+			v.fail(field, "email", "must be a valid email address")
+		}
+
+	case json.Number:
+		n, _ := value.Float64()
+		switch {
+		case schema.Minimum != nil && n < *schema.Minimum:
+			v.fail(field, "min", "must be at least "+formatNumber(*schema.Minimum))
+		case schema.ExclusiveMinimum != nil && n <= *schema.ExclusiveMinimum:
+			v.fail(field, "gt", "must be greater than "+formatNumber(*schema.ExclusiveMinimum))
+		case schema.Maximum != nil && n > *schema.Maximum:
+			v.fail(field, "max", "must be at most "+formatNumber(*schema.Maximum))
+		case schema.ExclusiveMaximum != nil && n >= *schema.ExclusiveMaximum:
+			v.fail(field, "lt", "must be less than "+formatNumber(*schema.ExclusiveMaximum))
+		}
+
+	case []any:
+		switch {
+		case schema.MinItems != nil && len(value) < *schema.MinItems:
+			v.fail(field, "min", "must have at least "+strconv.Itoa(*schema.MinItems)+" items")
+			return
+		case schema.MaxItems != nil && len(value) > *schema.MaxItems:
+			v.fail(field, "max", "must have at most "+strconv.Itoa(*schema.MaxItems)+" items")
+			return
+		case schema.UniqueItems && hasDuplicates(value):
+			v.fail(field, "unique", "must not contain duplicates")
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range value {
+				v.check(field+"["+strconv.Itoa(i)+"]", schema.Items, item)
+			}
+		}
+
+	case map[string]any:
+		prefix := field
+		if prefix != "" {
+			prefix += "."
+		}
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				v.fail(prefix+name, "required", "is required")
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(value)) {
+			switch prop, ok := schema.Properties[name]; {
+			case ok:
+				v.check(prefix+name, prop, value[name])
+			case schema.AdditionalProperties != nil:
+				v.check(prefix+name, schema.AdditionalProperties, value[name])
+			case schema.Closed:
+				v.fail(prefix+name, "unknown", "is not a known field")
+			}
+		}
+	}
+}
+
+// allows reports whether the type of the decoded JSON value is one of
+// schema's.
+func allows(schema *Schema, value any) bool {
+	types, ok := schema.Type.([]string)
+	if !ok {
+		types = []string{schema.Type.(string)}
+	}
+	var typ string
+	switch value := value.(type) {
+	case nil:
+		typ = "null"
+	case bool:
+		typ = "boolean"
+	case string:
+		typ = "string"
+	case []any:
+		typ = "array"
+	case map[string]any:
+		typ = "object"
+	case json.Number:
+		if _, err := value.Int64(); err == nil && slices.Contains(types, "integer") {
+			return true
+		}
+		typ = "number"
+	}
+	return slices.Contains(types, typ)
+}
+
+// typeNoun names the type of schema, with its article.
+func typeNoun(schema *Schema) string {
+	typ, ok := schema.Type.(string)
+	if !ok {
+		typ = schema.Type.([]string)[0]
+	}
+	switch typ {
+	case "integer", "array", "object":
+		return "an " + typ
+	}
+	return "a " + typ
+}
+
+func inEnum(enum []any, value any) bool {
+	s := fmt.Sprint(value)
+	return slices.ContainsFunc(enum, func(e any) bool { return fmt.Sprint(e) == s })
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// validEmail reports whether s is a bare email address.
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+func hasDuplicates(values []any) bool {
+	seen := map[string]bool{}
+	for _, value := range values {
+		key, _ := json.Marshal(value)
+		if seen[string(key)] {
+			return true
+		}
+		seen[string(key)] = true
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testItem struct {
+	SKU      string `json:"sku" binding:"required"`
+	Quantity int    `json:"quantity" binding:"min=1"`
+}
+
+type testBrand struct {
+	Name string `json:"name"`
+}
+
+type testInput struct {
+	Name   string     `json:"name" binding:"required,max=10"`
+	Email  string     `json:"email,omitempty" binding:"omitempty,email"`
+	Status string     `json:"status" binding:"required,oneof=draft published"`
+	Level  int        `json:"level,omitempty" binding:"omitempty,oneof=1 2 3"`
+	Tags   []string   `json:"tags" binding:"omitempty,max=3,dive,min=2"`
+	Items  []testItem `json:"items,omitempty" binding:"dive"`
+	Brand  *testBrand `json:"brand"`
+}
+
+// validated returns a router serving ops behind Validate(maxBody); its
+// handlers echo the body they read.
+func validated(maxBody int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ops := map[string]Operation{
+		"POST /things/:id": {Query: []Param{
+			{Name: "limit", Value: 0, Binding: "min=1,max=100"},
+			{Name: "sort", Value: "", Binding: "oneof=recent rating"},
+		}},
+		"POST /things/:id/touch": {},
+		"POST /things/:id/image": {File: "file"},
+	}
+	spec := New(r, Info{Title: "test", Version: "1"}, ops)
+	r.Use(spec.Validate(maxBody))
+
+	echo := func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	}
+	// handlers declaring a body are named after their function literal
+	r.POST("/things/:id", Accepts[testInput](func(c *gin.Context) { echo(c) }))
+	r.POST("/things/:id/touch", AcceptsOptional[testBrand](func(c *gin.Context) { echo(c) }))
+	r.POST("/things/:id/image", echo)
+	r.POST("/undocumented", echo)
+	return r
+}
+
+func TestValidate(t *testing.T) {
+	const valid = `{"name":"box","status":"draft","tags":["ab"],"items":[{"sku":"a","quantity":1}],"brand":{"name":"b"}}`
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		code        string
+		// fields at fault, as field:rule
+		errors []string
+	}{
+		{name: "valid", path: "/things/1?limit=10&sort=recent", body: valid, status: http.StatusOK},
+		{name: "empty query parameters", path: "/things/1?limit=&sort=", body: valid, status: http.StatusOK},
+
+		// required
+		{name: "missing required fields", path: "/things/1", body: `{}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"name:required", "status:required"}},
+		{name: "empty required string", path: "/things/1", body: `{"name":"","status":"draft"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"name:required"}},
+		{name: "required item field", path: "/things/1", body: `{"name":"a","status":"draft","items":[{"quantity":1}]}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"items[0].sku:required"}},
+		{name: "empty body", path: "/things/1", body: ``,
+			status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "empty optional body", path: "/things/1/touch", body: ``, status: http.StatusOK},
+
+		// closed objects
+		{name: "unknown field", path: "/things/1", body: `{"name":"a","status":"draft","colour":"red"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"colour:unknown"}},
+		{name: "unknown nested fields", path: "/things/1",
+			body:   `{"name":"a","status":"draft","items":[{"sku":"a","quantity":1,"size":2}],"brand":{"name":"b","logo":""}}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"brand.logo:unknown", "items[0].size:unknown"}},
+
+		// nullable
+		{name: "null reference", path: "/things/1", body: `{"name":"a","status":"draft","brand":null}`, status: http.StatusOK},
+		{name: "null slice", path: "/things/1", body: `{"name":"a","status":"draft","tags":null}`, status: http.StatusOK},
+		{name: "reference of the wrong type", path: "/things/1", body: `{"name":"a","status":"draft","brand":"b"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"brand:type"}},
+		{name: "null required string", path: "/things/1", body: `{"name":null,"status":"draft"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"name:type"}},
+
+		// types and bounds
+		{name: "wrong type", path: "/things/1", body: `{"name":5,"status":"draft"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"name:type"}},
+		{name: "too long", path: "/things/1", body: `{"name":"abcdefghijk","status":"draft"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"name:max"}},
+		{name: "email", path: "/things/1", body: `{"name":"a","status":"draft","email":"nope"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"email:email"}},
+
+		// enums
+		{name: "string enum", path: "/things/1", body: `{"name":"a","status":"gone"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"status:oneof"}},
+		{name: "integer enum", path: "/things/1", body: `{"name":"a","status":"draft","level":4}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"level:oneof"}},
+		{name: "integer in enum", path: "/things/1", body: `{"name":"a","status":"published","level":2}`, status: http.StatusOK},
+
+		// dive
+		{name: "rules of the items", path: "/things/1", body: `{"name":"a","status":"draft","tags":["ab","c"]}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"tags[1]:min"}},
+		{name: "rules of the array", path: "/things/1", body: `{"name":"a","status":"draft","tags":["ab","cd","ef","gh"]}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"tags:max"}},
+		{name: "struct items", path: "/things/1", body: `{"name":"a","status":"draft","items":[{"sku":"a","quantity":1},{"sku":"","quantity":0}]}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"items[1].quantity:min", "items[1].sku:required"}},
+
+		// parameters
+		{name: "path and query parameters", path: "/things/abc?limit=0&sort=bad", body: valid,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"id:type", "limit:min", "sort:oneof"}},
+		{name: "errors of parameters and body together", path: "/things/1?limit=x", body: `{"status":"draft"}`,
+			status: http.StatusBadRequest, code: "validation_failed", errors: []string{"limit:type", "name:required"}},
+
+		// bodies that are not JSON
+		{name: "body too large", path: "/things/1", body: `{"name":"` + strings.Repeat("a", 300) + `"}`,
+			status: http.StatusRequestEntityTooLarge, code: "payload_too_large"},
+		{name: "not JSON", path: "/things/1", contentType: "text/plain", body: valid,
+			status: http.StatusUnsupportedMediaType, code: "unsupported_media_type"},
+		{name: "malformed JSON", path: "/things/1", body: `{"name":`,
+			status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "trailing data", path: "/things/1", body: valid + ` {}`,
+			status: http.StatusBadRequest, code: "invalid_request"},
+
+		// let through
+		{name: "uploads", path: "/things/1/image", contentType: "multipart/form-data; boundary=x", body: "--x--", status: http.StatusOK},
+		{name: "undocumented routes", path: "/undocumented", contentType: "text/plain", body: "anything", status: http.StatusOK},
+	}
+
+	r := validated(256)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				// the handler reads the body again
+				if w.Body.String() != tt.body {
+					t.Errorf("handler read %q, want %q", w.Body, tt.body)
+				}
+				return
+			}
+
+			var p struct {
+				Code   string
+				Errors []struct{ Field, Code string }
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != tt.code {
+				t.Errorf("code %q, want %q", p.Code, tt.code)
+			}
+			var errors []string
+			for _, e := range p.Errors {
+				errors = append(errors, e.Field+":"+e.Code)
+			}
+			slices.Sort(errors)
+			if !slices.Equal(errors, tt.errors) {
+				t.Errorf("errors %v, want %v", errors, tt.errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// report fields by the names clients send them as
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form", "uri"} {
+				name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// Binding is the problem of input that gin could not bind: the fields that
+// failed validation, or why the body could not be read.
+func Binding(err error) *Problem {
+	var invalid validator.ValidationErrors
+	var syntax *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &invalid):
+		p := New(http.StatusBadRequest, CodeValidationFailed, "Invalid input")
+		for _, fe := range invalid {
+			p.Errors = append(p.Errors, fieldError(fe))
+		}
+		return p
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return Invalid(typeErr.Field, "type", fmt.Sprintf("%s must be %s", typeErr.Field, jsonType(typeErr.Type)))
+	case errors.As(err, &syntax), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return BadRequest("Malformed JSON")
+	case errors.Is(err, io.EOF):
+		return BadRequest("Request body is empty")
+	case errors.As(err, &tooLarge):
+		return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Request body is too large")
+	}
+	return BadRequest("Invalid input")
+}
+
+// fieldError describes the failed validation in the words of the API.
+func fieldError(fe validator.FieldError) FieldError {
+	// the namespace starts with the bound struct's type
+	field := fe.Field()
+	if _, path, ok := strings.Cut(fe.Namespace(), "."); ok {
+		field = path
+	}
+
+	var msg string
+	switch fe.Tag() {
+	case "required":
+		msg = "is required"
+	case "email":
+		msg = "must be a valid email address"
+	case "oneof":
+		msg = "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min", "gte":
+		msg = "must be at least " + bound(fe)
+	case "max", "lte":
+		msg = "must be at most " + bound(fe)
+	case "gt":
+		msg = "must be greater than " + fe.Param()
+	case "lt":
+		msg = "must be less than " + fe.Param()
+	case "len":
+		msg = "must be exactly " + bound(fe)
+	case "url", "http_url":
+		msg = "must be a valid URL"
+	case "unique":
+		msg = "must not contain duplicates"
+	default:
+		msg = "is invalid"
+	}
+	return FieldError{Field: field, Code: fe.Tag(), Message: field + " " + msg}
+}
+
+// bound is the parameter of a size rule, with its unit.
+func bound(fe validator.FieldError) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return fe.Param() + " characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fe.Param() + " items"
+	}
+	return fe.Param()
+}
+
+// jsonType names t as a JSON type, with its article.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + t.String()
+}
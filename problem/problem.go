@@ -0,0 +1,161 @@
+// Package problem is the API's error model: RFC 7807 problem details with a
+// stable, machine-readable code, and the fields at fault for invalid input.
+package problem
+
+import (
+	"errors"
+	"net/http"
+
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// Codes of problems. They are part of the API: clients branch on them, so
+// they never change once published, unlike details.
+const (
+	CodeInvalidRequest       = "invalid_request"   // malformed body, query or path
+	CodeValidationFailed     = "validation_failed" // see the field errors
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeInvalidToken         = "invalid_token"
+	CodeAccountBlocked       = "account_blocked"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodeAlreadyExists        = "already_exists" // the field errors name the duplicate
+	CodeInUse                = "in_use"         // still referenced by something else
+	CodeInvalidReference     = "invalid_reference"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInternal             = "internal_error"
+)
+
+// FieldError is a problem with one field of the input. Field is its JSON
+// path (e.g. "email", "product.name") and Code the rule it broke (e.g.
+// "required", "min", "unique").
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is an error answered as RFC 7807 problem details. Type is always
+// about:blank, so Title is the status text; Code says what went wrong and
+// Detail explains it to a human.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Code
+	}
+	return p.Code + ": " + p.Detail
+}
+
+// New returns the problem with status, code and detail.
+func New(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// WithFields adds field errors to p.
+func (p *Problem) WithFields(errs ...FieldError) *Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+func Unauthorized(detail string) *Problem {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Problem {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+func Internal(detail string) *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// Invalid is a validation failure of one field.
+func Invalid(field, code, message string) *Problem {
+	return New(http.StatusBadRequest, CodeValidationFailed, message).
+		WithFields(FieldError{Field: field, Code: code, Message: message})
+}
+
+// AlreadyExists is a conflict with an existing value of field.
+func AlreadyExists(field, detail string) *Problem {
+	return New(http.StatusConflict, CodeAlreadyExists, detail).
+		WithFields(FieldError{Field: field, Code: "unique", Message: field + " is already taken"})
+}
+
+// InvalidReference is a reference in field to something that does not
+// exist.
+func InvalidReference(field, detail string) *Problem {
+	return New(http.StatusBadRequest, CodeInvalidReference, detail).
+		WithFields(FieldError{Field: field, Code: "exists", Message: field + " does not exist"})
+}
+
+// FromError maps the errors of the stores to their problems, and returns
+// nil for any other error. Handlers check the store errors they expect
+// themselves, for a more precise detail; this covers the rest.
+func FromError(err error) *Problem {
+	var p *Problem
+	var conflict *store.ConflictError
+	var invalidRef *store.InvalidRefError
+	var check *store.CheckError
+	switch {
+	case errors.As(err, &p):
+		return p
+	case errors.As(err, &conflict):
+		return AlreadyExists(conflict.Field, "A record with this "+conflict.Field+" already exists")
+	case errors.As(err, &invalidRef):
+		return InvalidReference(invalidRef.Field, "Invalid "+invalidRef.Field)
+	case errors.As(err, &check):
+		return New(http.StatusBadRequest, CodeValidationFailed, "A value is out of its allowed range").
+			WithFields(FieldError{Field: check.Field, Code: "check", Message: check.Field + " is out of its allowed range"})
+	case errors.Is(err, store.ErrNotFound):
+		return NotFound("Not found")
+	case errors.Is(err, store.ErrConflict):
+		return New(http.StatusConflict, CodeInUse, "The record is in use or in conflict with another")
+	case errors.Is(err, store.ErrForbidden):
+		return Forbidden("Not allowed")
+	}
+	return nil
+}
+
+// Respond answers the request with p and stops the handler chain.
+func Respond(c *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,146 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-api/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStatusAndCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		p      *Problem
+		status int
+		code   string
+	}{
+		{"bad request", BadRequest("x"), http.StatusBadRequest, CodeInvalidRequest},
+		{"unauthorized", Unauthorized("x"), http.StatusUnauthorized, CodeUnauthorized},
+		{"forbidden", Forbidden("x"), http.StatusForbidden, CodeForbidden},
+		{"not found", NotFound("x"), http.StatusNotFound, CodeNotFound},
+		{"conflict", Conflict("x"), http.StatusConflict, CodeConflict},
+		{"internal", Internal("x"), http.StatusInternalServerError, CodeInternal},
+		{"invalid", Invalid("email", "email", "x"), http.StatusBadRequest, CodeValidationFailed},
+		{"already exists", AlreadyExists("email", "x"), http.StatusConflict, CodeAlreadyExists},
+		{"invalid reference", InvalidReference("product_id", "x"), http.StatusBadRequest, CodeInvalidReference},
+	}
+	for _, tt := range tests {
+		if tt.p.Status != tt.status || tt.p.Code != tt.code || tt.p.Title != http.StatusText(tt.status) || tt.p.Type != "about:blank" {
+			t.Errorf("%s: %+v, want status %d and code %s", tt.name, tt.p, tt.status, tt.code)
+		}
+	}
+}
+
+func TestFromError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		field  string // of the one field error, none when empty
+	}{
+		{"problem", fmt.Errorf("wrapped: %w", Forbidden("no")), http.StatusForbidden, CodeForbidden, ""},
+		{"conflict error", &store.ConflictError{Field: "email"}, http.StatusConflict, CodeAlreadyExists, "email"},
+		{"invalid reference", fmt.Errorf("saving: %w", &store.InvalidRefError{Field: "category_id"}), http.StatusBadRequest, CodeInvalidReference, "category_id"},
+		{"check", &store.CheckError{Field: "quantity"}, http.StatusBadRequest, CodeValidationFailed, "quantity"},
+		{"not found", store.ErrNotFound, http.StatusNotFound, CodeNotFound, ""},
+		{"conflict", store.ErrConflict, http.StatusConflict, CodeInUse, ""},
+		{"forbidden", store.ErrForbidden, http.StatusForbidden, CodeForbidden, ""},
+	}
+	for _, tt := range tests {
+		p := FromError(tt.err)
+		if p == nil || p.Status != tt.status || p.Code != tt.code {
+			t.Errorf("%s: %+v, want status %d and code %s", tt.name, p, tt.status, tt.code)
+			continue
+		}
+		if tt.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.field) {
+			t.Errorf("%s: field errors %+v, want one of %s", tt.name, p.Errors, tt.field)
+		}
+	}
+	if p := FromError(errors.New("connection refused")); p != nil {
+		t.Errorf("unexpected error mapped to %+v, want nil", p)
+	}
+}
+
+type signup struct {
+	Email    string   `json:"email" binding:"required,email"`
+	Age      int      `json:"age" binding:"min=18"`
+	Role     string   `json:"role" binding:"omitempty,oneof=user admin"`
+	Tags     []string `json:"tags" binding:"max=2"`
+	Password string   `json:"password" binding:"required,min=8"`
+}
+
+// bind binds body as gin does for the handlers.
+func bind(body string, limit int64) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if limit > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+	var s signup
+	return c.ShouldBindJSON(&s)
+}
+
+func TestBinding(t *testing.T) {
+	p := Binding(bind(`{"email": "nope", "age": 16, "role": "root", "tags": ["a", "b", "c"], "password": "short"}`, 0))
+	want := map[string]string{
+		"email":    "email must be a valid email address",
+		"age":      "age must be at least 18",
+		"role":     "role must be one of user, admin",
+		"tags":     "tags must be at most 2 items",
+		"password": "password must be at least 8 characters long",
+	}
+	if p.Status != http.StatusBadRequest || p.Code != CodeValidationFailed || len(p.Errors) != len(want) {
+		t.Fatalf("validation problem %+v", p)
+	}
+	for _, fe := range p.Errors {
+		if want[fe.Field] != fe.Message {
+			t.Errorf("error of %s: %q, want %q", fe.Field, fe.Message, want[fe.Field])
+		}
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		limit  int64
+		status int
+		code   string
+		detail string
+	}{
+		{"syntax", `{"email": `, 0, http.StatusBadRequest, CodeInvalidRequest, "Malformed JSON"},
+		{"type", `{"age": "old"}`, 0, http.StatusBadRequest, CodeValidationFailed, "age must be an integer"},
+		{"empty", ``, 0, http.StatusBadRequest, CodeInvalidRequest, "Request body is empty"},
+		{"too large", `{"email": "a@example.com", "password": "long enough"}`, 10, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, ""},
+	}
+	for _, tt := range tests {
+		p := Binding(bind(tt.body, tt.limit))
+		if p.Status != tt.status || p.Code != tt.code || (tt.detail != "" && p.Detail != tt.detail) {
+			t.Errorf("%s: %+v, want status %d, code %s and detail %q", tt.name, p, tt.status, tt.code, tt.detail)
+		}
+	}
+}
+
+func TestRespond(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/products/7", nil)
+	Respond(c, NotFound("Product not found"))
+
+	var got Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ContentType || got.Instance != "/products/7" ||
+		got.Code != CodeNotFound || got.Detail != "Product not found" || !c.IsAborted() {
+		t.Errorf("response %d %s: %+v", w.Code, w.Header().Get("Content-Type"), got)
+	}
+}
@@ -0,0 +1,24 @@
+// purge_command.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"my-api/handlers"
+	"my-api/store"
+)
+
+// runPurgeDeletedUsersCommand implements the `purge-deleted-users`
+// subcommand: it erases the personal data of users deleted longer than the
+// restore window ago. Run it daily, e.g. from cron.
+func runPurgeDeletedUsersCommand(users store.UserStore) error {
+	cutoff := time.Now().Add(-handlers.UserRestoreWindow)
+	erased, err := users.PurgeDeleted(context.Background(), cutoff)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("erased %d user(s) deleted before %s\n", erased, cutoff.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,413 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+	"my-api/store"
+)
+
+func TestProbes(t *testing.T) {
+	a := newTestApp(t)
+
+	testutil.Expect[map[string]any](t, a.Do(http.MethodGet, "/healthz", "", nil), http.StatusOK)
+	ready := testutil.Expect[struct{ Status string }](t, a.Do(http.MethodGet, "/readyz", "", nil), http.StatusOK)
+	if ready.Status != "ready" {
+		t.Errorf("readyz status %q, want ready", ready.Status)
+	}
+}
+
+func TestRegisterLoginRefresh(t *testing.T) {
+	a := newTestApp(t)
+	input := map[string]any{"username": "alice", "email": "alice@example.com", "password": "s3cret-enough"}
+
+	registered := testutil.Expect[struct {
+		UserID int    `json:"user_id"`
+		Role   string `json:"role"`
+	}](t, a.Do(http.MethodPost, "/register", "", input), http.StatusOK)
+	if registered.UserID == 0 || registered.Role != "user" {
+		t.Fatalf("registered %+v", registered)
+	}
+	p := testutil.ExpectProblem(t, a.Do(http.MethodPost, "/register", "", input), http.StatusConflict, "already_exists")
+	if len(p.Errors) != 1 || p.Errors[0].Field != "username" {
+		t.Errorf("duplicate registration errors %+v, want username", p.Errors)
+	}
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/login", "", map[string]any{"identifier": "alice", "password": "wrong-password"}),
+		http.StatusUnauthorized, "invalid_credentials")
+	tokens := testutil.Expect[struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}](t, a.Do(http.MethodPost, "/login", "", map[string]any{"identifier": "alice@example.com", "password": "s3cret-enough"}), http.StatusOK)
+
+	me := testutil.Expect[struct{ User models.User }](t, a.Do(http.MethodGet, "/user/me", tokens.AccessToken, nil), http.StatusOK)
+	if me.User.ID != registered.UserID || me.User.Email != "alice@example.com" {
+		t.Errorf("me %+v", me.User)
+	}
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/refresh", "", map[string]any{"refresh_token": tokens.AccessToken}),
+		http.StatusUnauthorized, "invalid_token")
+	refreshed := testutil.Expect[struct {
+		AccessToken string `json:"access_token"`
+	}](t, a.Do(http.MethodPost, "/refresh", "", map[string]any{"refresh_token": tokens.RefreshToken}), http.StatusOK)
+	testutil.Expect[map[string]any](t, a.Do(http.MethodGet, "/user/me", refreshed.AccessToken, nil), http.StatusOK)
+}
+
+func TestBlockedUserCannotLogIn(t *testing.T) {
+	a := newTestApp(t)
+	u := a.User(func(u *models.User) { u.IsBlocked = true })
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/login", "", map[string]any{"identifier": u.Username, "password": testutil.Password}),
+		http.StatusForbidden, "account_blocked")
+}
+
+func TestRoles(t *testing.T) {
+	a := newTestApp(t)
+	user, admin := a.User(), a.Admin()
+
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, "/user/me", "", nil), http.StatusUnauthorized, "unauthorized")
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, "/user/me", "not-a-token", nil), http.StatusUnauthorized, "invalid_token")
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, "/admin/users", user.Token, nil), http.StatusForbidden, "forbidden")
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, "/user/me", admin.Token, nil), http.StatusForbidden, "forbidden")
+	testutil.Expect[map[string]any](t, a.Do(http.MethodGet, "/admin/users", admin.Token, nil), http.StatusOK)
+}
+
+func TestCategoryLifecycle(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.Admin()
+
+	type category struct {
+		Category models.Category
+	}
+	parent := testutil.Expect[category](t, a.Do(http.MethodPost, "/admin/categories", admin.Token,
+		map[string]any{"category_name": "Shoes"}), http.StatusCreated).Category
+	child := testutil.Expect[category](t, a.Do(http.MethodPost, "/admin/categories", admin.Token,
+		map[string]any{"category_name": "Boots", "parent_id": parent.ID}), http.StatusCreated).Category
+	if child.Depth != 1 || child.ParentID == nil || *child.ParentID != parent.ID {
+		t.Errorf("subcategory %+v, want depth 1 under %d", child, parent.ID)
+	}
+
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPut, fmt.Sprintf("/admin/categories/%d", parent.ID), admin.Token,
+		map[string]any{"category_name": "Footwear"}), http.StatusOK)
+	got := testutil.Expect[category](t, a.Do(http.MethodGet, fmt.Sprintf("/categories/%d", parent.ID), "", nil), http.StatusOK).Category
+	if got.CategoryName != "Footwear" {
+		t.Errorf("renamed category is %q", got.CategoryName)
+	}
+
+	testutil.ExpectProblem(t, a.Do(http.MethodDelete, fmt.Sprintf("/admin/categories/%d", parent.ID), admin.Token, nil),
+		http.StatusConflict, "in_use")
+	testutil.Expect[map[string]any](t, a.Do(http.MethodDelete, fmt.Sprintf("/admin/categories/%d", child.ID), admin.Token, nil), http.StatusOK)
+	testutil.Expect[map[string]any](t, a.Do(http.MethodDelete, fmt.Sprintf("/admin/categories/%d", parent.ID), admin.Token, nil), http.StatusOK)
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, fmt.Sprintf("/categories/%d", parent.ID), "", nil), http.StatusNotFound, "not_found")
+}
+
+func TestAddProduct(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.Admin()
+	brand, category := a.Brand(), a.Category()
+
+	p := testutil.ExpectProblem(t, a.Do(http.MethodPost, "/admin/products", admin.Token, map[string]any{
+		"product":    map[string]any{"brand_id": brand.ID, "category_id": category.ID, "product_code": "TEE-1"},
+		"variations": []map[string]any{{"sku": ""}},
+	}), http.StatusBadRequest, "validation_failed")
+	fields := map[string]bool{}
+	for _, e := range p.Errors {
+		fields[e.Field] = true
+	}
+	if !fields["product.product_name"] || !fields["variations[0].sku"] {
+		t.Errorf("errors %+v, want product.product_name and variations[0].sku", p.Errors)
+	}
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/admin/products", admin.Token, map[string]any{
+		"product": map[string]any{"brand_id": brand.ID, "category_id": category.ID + 1000, "product_name": "Tee", "product_code": "TEE-1"},
+	}), http.StatusBadRequest, "invalid_reference")
+
+	created := testutil.Expect[struct{ Product models.Product }](t, a.Do(http.MethodPost, "/admin/products", admin.Token, map[string]any{
+		"product":    map[string]any{"brand_id": brand.ID, "category_id": category.ID, "product_name": "Tee", "product_code": "TEE-1", "status": 1},
+		"variations": []map[string]any{{"sku": "TEE-1-S", "current_stock": 5}, {"sku": "TEE-1-M", "current_stock": 3}},
+	}), http.StatusCreated).Product
+
+	got := testutil.Expect[struct{ Product models.Product }](t, a.Do(http.MethodGet, fmt.Sprintf("/products/%d", created.ID), "", nil), http.StatusOK).Product
+	if got.ProductName != "Tee" || got.BrandID != brand.ID {
+		t.Errorf("product %+v", got)
+	}
+	listed := testutil.Expect[struct{ Products []models.Product }](t, a.Do(http.MethodGet, fmt.Sprintf("/categories/%d/products", category.ID), "", nil), http.StatusOK)
+	if len(listed.Products) != 1 || listed.Products[0].ID != created.ID {
+		t.Errorf("products of the category %+v", listed.Products)
+	}
+}
+
+func TestCart(t *testing.T) {
+	a := newTestApp(t)
+	user := a.User()
+	product := a.Product(nil)
+
+	type added struct {
+		ItemID   int `json:"item_id"`
+		Quantity int `json:"quantity"`
+	}
+	item := testutil.Expect[added](t, a.Do(http.MethodPost, "/user/cart/items", user.Token,
+		map[string]any{"product_id": product.ID, "quantity": 2}), http.StatusOK)
+	if again := testutil.Expect[added](t, a.Do(http.MethodPost, "/user/cart/items", user.Token,
+		map[string]any{"product_id": product.ID, "quantity": 1}), http.StatusOK); again.ItemID != item.ItemID || again.Quantity != 3 {
+		t.Errorf("adding the product again gave %+v, want item %d with 3", again, item.ItemID)
+	}
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/user/cart/items", user.Token,
+		map[string]any{"product_id": product.ID + 1000, "quantity": 1}), http.StatusBadRequest, "invalid_reference")
+
+	type cart struct {
+		Items    []models.CartItem
+		Subtotal float64
+	}
+	if got := testutil.Expect[cart](t, a.Do(http.MethodGet, "/user/cart", user.Token, nil), http.StatusOK); len(got.Items) != 1 || got.Subtotal != 30 {
+		t.Errorf("cart %+v, want one line of 30", got)
+	}
+	// carts are per user
+	if got := testutil.Expect[cart](t, a.Do(http.MethodGet, "/user/cart", a.User().Token, nil), http.StatusOK); len(got.Items) != 0 {
+		t.Errorf("cart of another user %+v, want it empty", got)
+	}
+
+	path := fmt.Sprintf("/user/cart/items/%d", item.ItemID)
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPut, path, user.Token, map[string]any{"quantity": 1}), http.StatusOK)
+	if got := testutil.Expect[cart](t, a.Do(http.MethodGet, "/user/cart", user.Token, nil), http.StatusOK); got.Subtotal != 10 {
+		t.Errorf("subtotal %v after update, want 10", got.Subtotal)
+	}
+	testutil.Expect[map[string]any](t, a.Do(http.MethodDelete, path, user.Token, nil), http.StatusOK)
+	testutil.ExpectProblem(t, a.Do(http.MethodDelete, path, user.Token, nil), http.StatusNotFound, "not_found")
+}
+
+func TestOrders(t *testing.T) {
+	a := newTestApp(t)
+	user, other := a.User(), a.User()
+	product := a.Product(nil)
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/user/orders", user.Token, nil), http.StatusBadRequest, "validation_failed")
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPost, "/user/cart/items", user.Token,
+		map[string]any{"product_id": product.ID, "quantity": 3}), http.StatusOK)
+
+	placed := testutil.Expect[struct{ Order models.Order }](t, a.Do(http.MethodPost, "/user/orders", user.Token, nil), http.StatusCreated).Order
+	if placed.Status != "placed" || len(placed.Items) != 1 || placed.Items[0].Quantity != 3 || placed.Total != 30 {
+		t.Errorf("placed order %+v, want 3 of the product for 30", placed)
+	}
+	if got := testutil.Expect[struct{ Items []models.CartItem }](t, a.Do(http.MethodGet, "/user/cart", user.Token, nil), http.StatusOK); len(got.Items) != 0 {
+		t.Errorf("cart after checkout %+v, want it empty", got.Items)
+	}
+
+	path := fmt.Sprintf("/user/orders/%d", placed.ID)
+	if got := testutil.Expect[models.Order](t, a.Do(http.MethodGet, path, user.Token, nil), http.StatusOK); got.ID != placed.ID || got.Total != 30 {
+		t.Errorf("order %+v, want %+v", got, placed)
+	}
+	testutil.ExpectProblem(t, a.Do(http.MethodGet, path, other.Token, nil), http.StatusNotFound, "not_found")
+	if got := testutil.Expect[struct{ Orders []models.Order }](t, a.Do(http.MethodGet, "/user/orders", other.Token, nil), http.StatusOK); len(got.Orders) != 0 {
+		t.Errorf("orders of another user %+v, want none", got.Orders)
+	}
+	if got := testutil.Expect[struct{ Orders []models.Order }](t, a.Do(http.MethodGet, "/user/orders", user.Token, nil), http.StatusOK); len(got.Orders) != 1 || got.Orders[0].ID != placed.ID {
+		t.Errorf("orders of the user %+v, want the placed order", got.Orders)
+	}
+}
+
+func TestShippingOptions(t *testing.T) {
+	a := newTestApp(t)
+	user := a.User()
+	ctx := context.Background()
+
+	home, err := a.Stores.Addresses.Create(ctx, models.Address{UserID: user.ID, RecipientName: "Alice", AddressLine1: "1 Main St",
+		City: "Berlin", Country: "DE", PostalCode: "10115", Type: "home", IsShipping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	office, err := a.Stores.Addresses.Create(ctx, models.Address{UserID: user.ID, RecipientName: "Alice", AddressLine1: "2 Rue Neuve",
+		City: "Paris", Country: "FR", PostalCode: "75001", Type: "office", IsShipping: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoneID, err := a.Stores.Shipping.CreateZone(ctx, models.ShippingZone{ZoneName: "Germany", Countries: []string{"DE"}, Position: 1, Status: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Stores.Shipping.CreateMethod(ctx, models.ShippingMethod{ZoneID: zoneID, MethodName: "Standard", RateType: "flat", BaseRate: 4.5, Status: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/user/cart/shipping-options", user.Token, nil), http.StatusBadRequest, "invalid_request")
+	if _, _, err := a.Stores.Carts.AddItem(ctx, user.ID, a.Product(nil).ID, nil, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	type quote struct {
+		ShippingAddressID int                     `json:"shipping_address_id"`
+		ZoneID            int                     `json:"zone_id"`
+		ShippingOptions   []models.ShippingOption `json:"shipping_options"`
+	}
+	// no body and an empty object both ship to the default address
+	for _, body := range []any{nil, map[string]any{}} {
+		got := testutil.Expect[quote](t, a.Do(http.MethodPost, "/user/cart/shipping-options", user.Token, body), http.StatusOK)
+		if got.ShippingAddressID != home.ID || got.ZoneID != zoneID || len(got.ShippingOptions) != 1 || got.ShippingOptions[0].Cost != 4.5 {
+			t.Errorf("options for body %v: %+v, want Standard at 4.5 to address %d", body, got, home.ID)
+		}
+	}
+	got := testutil.Expect[quote](t, a.Do(http.MethodPost, "/user/cart/shipping-options", user.Token,
+		map[string]any{"shipping_address_id": office.ID}), http.StatusOK)
+	if got.ShippingAddressID != office.ID || len(got.ShippingOptions) != 0 {
+		t.Errorf("options to an address outside every zone: %+v, want none", got)
+	}
+}
+
+// Changes to a product's price and stock notify the users watching it,
+// whatever made them: the database's triggers queue the notifications.
+func TestWishlistAlerts(t *testing.T) {
+	a := newTestApp(t)
+	watcher, muted := a.User(), a.User()
+	product := a.Product(nil)
+	ctx := context.Background()
+
+	for _, u := range []struct {
+		id    int
+		alert bool
+	}{{watcher.ID, true}, {muted.ID, false}} {
+		listID, err := a.Stores.Wishlists.Create(ctx, u.id, "Wishlist")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Stores.Wishlists.AddItem(ctx, u.id, listID, store.WishlistItemInput{
+			ProductID: product.ID, NotifyPriceDrop: u.alert, NotifyBackInStock: u.alert,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, query := range []string{
+		`UPDATE products SET price = 12 WHERE id = $1`, // a rise notifies nobody
+		`UPDATE products SET price = 8 WHERE id = $1`,
+		`UPDATE products SET current_stock = 0 WHERE id = $1`,
+		`UPDATE products SET current_stock = 5 WHERE id = $1`,
+	} {
+		if _, err := a.db.ExecContext(ctx, query, product.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type notifications struct{ Notifications []models.WishlistNotification }
+	got := testutil.Expect[notifications](t, a.Do(http.MethodGet, "/user/wishlist-notifications", watcher.Token, nil), http.StatusOK).Notifications
+	types := map[string]models.WishlistNotification{}
+	for _, n := range got {
+		types[n.Type] = n
+	}
+	drop, restocked := types["price_drop"], types["back_in_stock"]
+	if len(got) != 2 || drop.ProductID != product.ID || drop.OldPrice == nil || *drop.OldPrice != 12 || drop.NewPrice == nil || *drop.NewPrice != 8 ||
+		restocked.ProductID != product.ID || drop.ReadAt != nil {
+		t.Fatalf("notifications %+v, want a drop from 12 to 8 and a restock", got)
+	}
+	if got := testutil.Expect[notifications](t, a.Do(http.MethodGet, "/user/wishlist-notifications", muted.Token, nil), http.StatusOK); len(got.Notifications) != 0 {
+		t.Errorf("notifications of a user without alerts %+v, want none", got.Notifications)
+	}
+
+	type marked struct{ Updated int64 }
+	if got := testutil.Expect[marked](t, a.Do(http.MethodPost, "/user/wishlist-notifications/read", watcher.Token,
+		map[string]any{"ids": []int{drop.ID}}), http.StatusOK); got.Updated != 1 {
+		t.Errorf("marked %d read, want 1", got.Updated)
+	}
+	unread := testutil.Expect[notifications](t, a.Do(http.MethodGet, "/user/wishlist-notifications?unread=true", watcher.Token, nil), http.StatusOK).Notifications
+	if len(unread) != 1 || unread[0].ID != restocked.ID {
+		t.Errorf("unread notifications %+v, want the restock", unread)
+	}
+	// without ids, every unread one
+	if got := testutil.Expect[marked](t, a.Do(http.MethodPost, "/user/wishlist-notifications/read", watcher.Token, nil), http.StatusOK); got.Updated != 1 {
+		t.Errorf("marked %d read, want 1", got.Updated)
+	}
+	if got := testutil.Expect[notifications](t, a.Do(http.MethodGet, "/user/wishlist-notifications?unread=true", watcher.Token, nil), http.StatusOK); len(got.Notifications) != 0 {
+		t.Errorf("unread notifications %+v after marking all, want none", got.Notifications)
+	}
+}
+
+func TestReviewModeration(t *testing.T) {
+	a := newTestApp(t)
+	user, admin := a.User(), a.Admin()
+	product := a.Product(nil)
+	reviewsPath := fmt.Sprintf("/products/%d/reviews", product.ID)
+
+	// only customers who ordered the product review it
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/user"+reviewsPath, user.Token,
+		map[string]any{"rating": 4, "title": "Good", "body": "Fits well."}), http.StatusForbidden, "forbidden")
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPost, "/user/cart/items", user.Token,
+		map[string]any{"product_id": product.ID, "quantity": 1}), http.StatusOK)
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPost, "/user/orders", user.Token, nil), http.StatusCreated)
+
+	review := testutil.Expect[struct {
+		ReviewID int `json:"review_id"`
+	}](t, a.Do(http.MethodPost, "/user"+reviewsPath, user.Token,
+		map[string]any{"rating": 4, "title": "Good", "body": "Fits well."}), http.StatusCreated)
+	testutil.ExpectProblem(t, a.Do(http.MethodPost, "/user"+reviewsPath, user.Token,
+		map[string]any{"rating": 5, "title": "Again", "body": "Still good."}), http.StatusConflict, "already_exists")
+
+	type reviews struct {
+		Rating      float64 `json:"rating"`
+		RatingCount int     `json:"rating_count"`
+		Reviews     []models.ProductReview
+	}
+	// pending reviews are not public
+	if got := testutil.Expect[reviews](t, a.Do(http.MethodGet, reviewsPath, "", nil), http.StatusOK); len(got.Reviews) != 0 {
+		t.Errorf("reviews before moderation %+v, want none", got.Reviews)
+	}
+
+	testutil.Expect[map[string]any](t, a.Do(http.MethodPatch, fmt.Sprintf("/admin/reviews/%d/status", review.ReviewID), admin.Token,
+		map[string]any{"status": "approved"}), http.StatusOK)
+	got := testutil.Expect[reviews](t, a.Do(http.MethodGet, reviewsPath, "", nil), http.StatusOK)
+	if len(got.Reviews) != 1 || got.Reviews[0].Username != user.Username || got.RatingCount != 1 || got.Rating != 4 {
+		t.Errorf("reviews after approval %+v", got)
+	}
+}
+
+func TestAdminUserListingOutsideUTC(t *testing.T) {
+	local := time.Local
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	time.Local = zone
+	t.Cleanup(func() { time.Local = local })
+
+	a := newTestApp(t)
+	admin := a.Admin()
+	created := []int{admin.ID, a.User().ID, a.User().ID, a.User().ID}
+
+	type page struct {
+		Users      []models.User
+		NextCursor *string `json:"next_cursor"`
+	}
+	list := func(query url.Values) page {
+		t.Helper()
+		return testutil.Expect[page](t, a.Do(http.MethodGet, "/admin/users?"+query.Encode(), admin.Token, nil), http.StatusOK)
+	}
+
+	// one user a page, following the cursor, newest first
+	var seen []int
+	query := url.Values{"limit": {"1"}}
+	for range created {
+		p := list(query)
+		for _, u := range p.Users {
+			seen = append(seen, u.ID)
+		}
+		if p.NextCursor == nil {
+			break
+		}
+		query.Set("cursor", *p.NextCursor)
+	}
+	if want := []int{created[3], created[2], created[1], created[0]}; !slices.Equal(seen, want) {
+		t.Errorf("paged users %v, want %v", seen, want)
+	}
+
+	now := time.Now().In(zone)
+	if got := list(url.Values{"created_from": {now.Add(-time.Minute).Format(time.RFC3339)}}); len(got.Users) != len(created) {
+		t.Errorf("users created from a minute ago: %d, want %d", len(got.Users), len(created))
+	}
+	if got := list(url.Values{"created_to": {now.Add(-time.Minute).Format(time.RFC3339)}}); len(got.Users) != 0 {
+		t.Errorf("users created until a minute ago: %d, want none", len(got.Users))
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-api/config"
+	"my-api/store/blob"
+	"my-api/store/memory"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter returns the API on the memory store, with cfg changed by
+// change.
+func newTestRouter(t *testing.T, change func(cfg *config.Config)) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	blobs, err := blob.NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.JWTSecret = "secret"
+	change(&cfg)
+	return NewRouter(Deps{Config: cfg, Logger: slog.New(slog.DiscardHandler), Stores: memory.New(), Blobs: blobs, Variants: blobs})
+}
+
+func get(r http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// On the API port, /metrics needs the metrics token; the requests it counts
+// are labeled by route template.
+func TestMetricsOnAPIPort(t *testing.T) {
+	r := newTestRouter(t, func(cfg *config.Config) {
+		cfg.Metrics.Address = ""
+		cfg.Metrics.Token = "scrape-me"
+	})
+
+	if w := get(r, "/metrics", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("scrape without the token: %d, want 401", w.Code)
+	}
+	if w := get(r, "/metrics", "scrape-you"); w.Code != http.StatusUnauthorized {
+		t.Errorf("scrape with a wrong token: %d, want 401", w.Code)
+	}
+
+	get(r, "/healthz", "")
+	get(r, "/no/such/path/4711", "")
+	w := get(r, "/metrics", "scrape-me")
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape with the token: %d, want 200", w.Code)
+	}
+	for _, series := range []string{
+		`http_requests_total{method="GET",route="/healthz",status="200"}`,
+		`http_requests_total{method="GET",route="unmatched",status="404"}`,
+	} {
+		if !strings.Contains(w.Body.String(), series) {
+			t.Errorf("scrape without %s", series)
+		}
+	}
+	if strings.Contains(w.Body.String(), "4711") {
+		t.Error("unmatched paths are labeled with themselves")
+	}
+}
+
+func TestMetricsElsewhere(t *testing.T) {
+	for name, change := range map[string]func(cfg *config.Config){
+		"own address": func(cfg *config.Config) { cfg.Metrics.Address = "127.0.0.1:9090" },
+		"disabled":    func(cfg *config.Config) { cfg.Metrics.Enabled, cfg.Metrics.Address = false, "" },
+	} {
+		if w := get(newTestRouter(t, change), "/metrics", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: /metrics on the API port answers %d, want 404", name, w.Code)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"my-api/migrate"
+)
+
+// migrationDB is an empty database the migrations of migrations are run on
+// one by one, by version; version is the last one applied.
+type migrationDB struct {
+	t          *testing.T
+	db         *sql.DB
+	migrations []migrate.Migration
+	version    int
+}
+
+func newMigrationDB(t *testing.T) *migrationDB {
+	t.Helper()
+	name := fmt.Sprintf("%s_%d", prefix, databases.Add(1))
+	if _, err := admin.Exec(`CREATE DATABASE ` + name); err != nil {
+		t.Fatal("Failed to create the test database: ", err)
+	}
+	t.Cleanup(func() { dropDatabase(name) })
+	db, err := sql.Open("postgres", databaseURL(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrations, err := migrate.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &migrationDB{t: t, db: db, migrations: migrations}
+}
+
+// up applies the migrations after the last one applied up to version,
+// down reverts version.
+func (m *migrationDB) up(version int) {
+	m.t.Helper()
+	for _, migration := range m.migrations {
+		if migration.Version > m.version && migration.Version <= version {
+			m.exec(migration.Up)
+			m.version = migration.Version
+		}
+	}
+}
+
+func (m *migrationDB) down(version int) {
+	m.t.Helper()
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			m.exec(migration.Down)
+			m.version = version - 1
+		}
+	}
+}
+
+func (m *migrationDB) exec(query string, args ...any) {
+	m.t.Helper()
+	if _, err := m.db.Exec(query, args...); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// Migration 0005 keeps the old shipping and billing addresses reachable by
+// their IDs, and merges an address kept in both tables into one.
+func TestUnifiedAddressBookMigration(t *testing.T) {
+	m := newMigrationDB(t)
+	m.up(4)
+	m.exec(`INSERT INTO users (id, username, email, role, password) VALUES (1, 'alice', 'alice@example.com', 'user', 'hash')`)
+	m.exec(`INSERT INTO shipping_addresses (id, user_id, address_line1, city, country, postal_code, type, is_default) VALUES
+		(5, 1, '1 Main St', 'Berlin', 'Germany', '10115', 'home', TRUE),
+		(6, 1, '2 Side St', 'Berlin', 'Germany', '10117', 'office', FALSE)`)
+	m.exec(`INSERT INTO billing_addresses (id, user_id, address_line1, city, country, postal_code, type, is_default) VALUES
+		(2, 1, '1 Main St', 'Berlin', 'Germany', '10115', 'home', TRUE)`)
+	m.up(5)
+
+	address := func(usage string, legacyID int) (id int, shipping, billing, defaultShipping, defaultBilling bool) {
+		t.Helper()
+		err := m.db.QueryRow(`
+			SELECT a.id, a.is_shipping, a.is_billing, a.is_default_shipping, a.is_default_billing
+			FROM legacy_address_ids l JOIN addresses a ON a.id = l.address_id
+			WHERE l.usage = $1 AND l.legacy_id = $2
+		`, usage, legacyID).Scan(&id, &shipping, &billing, &defaultShipping, &defaultBilling)
+		if err != nil {
+			t.Fatalf("%s address %d: %v", usage, legacyID, err)
+		}
+		return
+	}
+	home, shipping, billing, defaultShipping, defaultBilling := address("shipping", 5)
+	if !shipping || !billing || !defaultShipping || !defaultBilling {
+		t.Errorf("address in both tables: shipping %t, billing %t, defaults %t and %t, want all",
+			shipping, billing, defaultShipping, defaultBilling)
+	}
+	if id, _, _, _, _ := address("billing", 2); id != home {
+		t.Errorf("billing address 2 became address %d, shipping address 5 %d, want the same", id, home)
+	}
+	if office, shipping, billing, _, _ := address("shipping", 6); office == home || !shipping || billing {
+		t.Errorf("shipping address 6 became address %d (shipping %t, billing %t)", office, shipping, billing)
+	}
+	var count int
+	if err := m.db.QueryRow(`SELECT COUNT(*) FROM addresses`).Scan(&count); err != nil || count != 2 {
+		t.Errorf("%d addresses (%v), want 2", count, err)
+	}
+
+	// reverting gives the rows their IDs back
+	m.down(5)
+	for table, ids := range map[string]string{"shipping_addresses": "5,6", "billing_addresses": "2"} {
+		var got string
+		if err := m.db.QueryRow(`SELECT string_agg(id::text, ',' ORDER BY id) FROM ` + table).Scan(&got); err != nil || got != ids {
+			t.Errorf("%s after reverting: IDs %s (%v), want %s", table, got, err, ids)
+		}
+	}
+}
+
+// Migration 0010 turns created_by into a user ID, keeping the text it had,
+// and reverting gives the text back.
+func TestAuditLogMigration(t *testing.T) {
+	m := newMigrationDB(t)
+	m.up(9)
+	m.exec(`INSERT INTO users (id, username, email, role, password) VALUES (1, 'alice', 'alice@example.com', 'admin', 'hash')`)
+	m.exec(`INSERT INTO brands (id, brand_name, created_by) VALUES (1, 'Known', 'alice'), (2, 'Unknown', 'Bob from sales')`)
+	m.up(10)
+
+	brand := func(id int) (createdBy sql.NullInt64, legacy sql.NullString) {
+		t.Helper()
+		err := m.db.QueryRow(`SELECT created_by, created_by_legacy FROM brands WHERE id = $1`, id).Scan(&createdBy, &legacy)
+		if err != nil {
+			t.Fatalf("brand %d: %v", id, err)
+		}
+		return
+	}
+	if createdBy, legacy := brand(1); createdBy.Int64 != 1 || legacy.String != "alice" {
+		t.Errorf("brand of a username: created_by %v, legacy %v", createdBy, legacy)
+	}
+	if createdBy, legacy := brand(2); createdBy.Valid || legacy.String != "Bob from sales" {
+		t.Errorf("brand of an unknown name: created_by %v, legacy %v", createdBy, legacy)
+	}
+
+	m.down(10)
+	var got string
+	if err := m.db.QueryRow(`SELECT string_agg(created_by, ',' ORDER BY id) FROM brands`).Scan(&got); err != nil || got != "alice,Bob from sales" {
+		t.Errorf("created_by after reverting: %q (%v)", got, err)
+	}
+}
+
+// Migration 0015 keeps the image of categories that only had category_img.
+func TestCategoryImageMigration(t *testing.T) {
+	m := newMigrationDB(t)
+	m.up(14)
+	m.exec(`INSERT INTO categories (id, category_name, path, category_img, image) VALUES
+		(1, 'Old', '/1/', '/old.png', NULL), (2, 'Both', '/2/', '/ignored.png', '/kept.png')`)
+	m.up(15)
+
+	var got string
+	if err := m.db.QueryRow(`SELECT string_agg(image, ',' ORDER BY id) FROM categories`).Scan(&got); err != nil || got != "/old.png,/kept.png" {
+		t.Errorf("category images %q (%v), want /old.png,/kept.png", got, err)
+	}
+}
+
+// Every migration reverts cleanly and applies again, and an applied
+// migration that was edited stops the runner.
+func TestMigrationRunner(t *testing.T) {
+	m := newMigrationDB(t)
+	runner, err := migrate.New(m.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	all := len(m.migrations)
+
+	if done, err := runner.Up(ctx); err != nil || len(done) != all {
+		t.Fatalf("applied %d of %d migrations: %v", len(done), all, err)
+	}
+	done, err := runner.Down(ctx, 2)
+	if err != nil || len(done) != 2 || done[0].Version != all || done[1].Version != all-1 {
+		t.Fatalf("reverted %+v (%v), want the last two newest first", done, err)
+	}
+	if pending, err := runner.Pending(ctx); err != nil || len(pending) != 2 {
+		t.Errorf("%d pending after reverting two (%v)", len(pending), err)
+	}
+	if done, err := runner.Down(ctx, all); err != nil || len(done) != all-2 {
+		t.Fatalf("reverted %d of the remaining %d: %v", len(done), all-2, err)
+	}
+	if done, err := runner.Up(ctx); err != nil || len(done) != all {
+		t.Fatalf("applied %d of %d migrations again: %v", len(done), all, err)
+	}
+
+	m.exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 1`)
+	if _, err := runner.Up(ctx); !errors.Is(err, migrate.ErrChecksumMismatch) {
+		t.Errorf("up after editing an applied migration: %v, want ErrChecksumMismatch", err)
+	}
+	statuses, err := runner.Status(ctx)
+	if err != nil || len(statuses) != all || !statuses[0].Modified || statuses[1].Modified {
+		t.Errorf("statuses %+v (%v), want only the first modified", statuses, err)
+	}
+}
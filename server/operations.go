@@ -0,0 +1,454 @@
+// server/operations.go
+package server
+
+import (
+	"maps"
+	"net/http"
+	"time"
+
+	"my-api/countries"
+	"my-api/models"
+	"my-api/openapi"
+	"my-api/store"
+)
+
+var apiInfo = openapi.Info{
+	Title:   "my-api",
+	Version: "1.0.0",
+	Description: "E-commerce API. Errors are answered as problem details (RFC 7807) " +
+		"with a stable code; see the Problem schema.",
+}
+
+// operations describes every route for the OpenAPI document, keyed by
+// method and gin path. A route missing from it fails the tests.
+var operations = merged(
+	serviceOperations,
+	publicOperations,
+	openapi.Require("admin", adminOperations),
+	openapi.Require("user", userOperations),
+)
+
+// merged returns the entries of every table, or the properties of every
+// object.
+func merged[M ~map[string]V, V any](tables ...M) M {
+	all := M{}
+	for _, table := range tables {
+		maps.Copy(all, table)
+	}
+	return all
+}
+
+// withMessage adds the human-readable message most responses carry.
+func withMessage(fields openapi.Object) openapi.Object {
+	fields["message"] = ""
+	return fields
+}
+
+var (
+	message = openapi.Object{"message": ""}
+
+	paging = []openapi.Param{
+		{Name: "limit", Value: 0, Binding: "min=1,max=100", Description: "Page size, 20 by default."},
+		{Name: "offset", Value: 0, Binding: "min=0", Description: "Items to skip."},
+	}
+	page = openapi.Object{"limit": 0, "offset": 0}
+
+	profile = openapi.Object{
+		"id":           0,
+		"username":     "",
+		"email":        "",
+		"role":         "",
+		"phone_number": (*string)(nil),
+		"image":        (*string)(nil),
+		"is_verified":  false,
+		"is_blocked":   false,
+	}
+
+	// usageAddress is an address as the shipping and billing address
+	// routes return it.
+	usageAddress = openapi.Object{
+		"id":             0,
+		"recipient_name": "",
+		"phone":          (*string)(nil),
+		"address_line1":  "",
+		"address_line2":  (*string)(nil),
+		"city":           "",
+		"region":         (*string)(nil),
+		"country":        "",
+		"postal_code":    "",
+		"type":           "",
+		"is_default":     false,
+	}
+
+	addressLabel = openapi.Object{"address_id": 0, "lines": []string{}, "label": ""}
+	labelQuery   = []openapi.Param{{Name: "from", Value: "", Description: "Country the label is sent from; its own country line is left out."}}
+
+	productImages = withMessage(openapi.Object{"image": "", "images": []models.ProductImage{}})
+	bulkResult    = withMessage(openapi.Object{"action": "", "updated": 0, "results": []store.BulkResult{}})
+	uploaded      = withMessage(openapi.Object{"media": models.Media{}})
+)
+
+// serviceOperations are the routes main adds besides the API's.
+var serviceOperations = map[string]openapi.Operation{
+	"GET /healthz": {Summary: "Liveness probe", Tag: "Service", Response: openapi.Object{"status": ""}},
+	"GET /readyz": {Summary: "Readiness probe", Tag: "Service",
+		Description: "Answers 503 while the database or the migrations are not ready; each check is ok or unavailable.",
+		Response:    openapi.Object{"status": "", "checks": map[string]string{}}},
+	"GET /metrics": {Summary: "Prometheus metrics", Tag: "Service",
+		Description: "Needs `Authorization: Bearer <METRICS_TOKEN>` when a token is configured.",
+		ContentType: "text/plain"},
+	"GET /openapi.json": {Summary: "This document", Tag: "Service", ContentType: "application/json"},
+}
+
+var publicOperations = map[string]openapi.Operation{
+	"POST /register": {Summary: "Register a user", Tag: "Auth",
+		Response: withMessage(openapi.Object{"user_id": 0, "role": ""})},
+	"POST /login": {Summary: "Log in", Tag: "Auth",
+		Description: "The identifier is the username or email.",
+		Response:    withMessage(openapi.Object{"access_token": "", "refresh_token": "", "role": ""})},
+	"POST /refresh": {Summary: "Exchange a refresh token for an access token", Tag: "Auth",
+		Response: withMessage(openapi.Object{"access_token": "", "role": ""})},
+
+	"GET /products":     {Summary: "List products", Tag: "Products", Response: openapi.Object{"products": []models.Product{}}},
+	"GET /products/:id": {Summary: "Get a product", Tag: "Products", Response: openapi.Object{"product": models.Product{}}},
+	"GET /products/:id/reviews": {Summary: "List the approved reviews of a product", Tag: "Reviews",
+		Query: append([]openapi.Param{{Name: "sort", Value: "", Binding: "oneof=recent helpful rating_high rating_low",
+			Description: "recent by default."}}, paging...),
+		Response: merged(page, openapi.Object{
+			"product_id":   0,
+			"rating":       0.0,
+			"rating_count": 0,
+			"distribution": map[int]int{},
+			"reviews":      []models.ProductReview{},
+		})},
+	"GET /wishlists/shared/:token": {Summary: "Get a shared wishlist", Tag: "Wishlists",
+		Response: openapi.Object{"wishlist": models.Wishlist{}}},
+
+	"GET /brands":     {Summary: "List brands", Tag: "Brands", Response: openapi.Object{"brands": []models.Brand{}}},
+	"GET /brands/:id": {Summary: "Get a brand", Tag: "Brands", Response: openapi.Object{"brand": models.Brand{}}},
+	"GET /categories": {Summary: "Get the category tree", Tag: "Categories",
+		Response: openapi.Object{"categories": []models.Category{}}},
+	"GET /categories/:id": {Summary: "Get a category", Tag: "Categories",
+		Response: openapi.Object{"category": models.Category{}}},
+	"GET /categories/:id/products": {Summary: "List the products of a category and those below it", Tag: "Categories",
+		Response: openapi.Object{"products": []models.Product{}}},
+	"GET /countries": {Summary: "List the countries addresses can be in", Tag: "Addresses",
+		Response: openapi.Object{"countries": []countries.Country{}}},
+	"GET /media/:id": {Summary: "Get an uploaded file", Tag: "Media",
+		Description: "With any of w, h, fit and format, a resized and re-encoded variant of the image.",
+		Query: []openapi.Param{
+			{Name: "w", Value: 0, Binding: "min=1", Description: "Width in pixels."},
+			{Name: "h", Value: 0, Binding: "min=1", Description: "Height in pixels."},
+			{Name: "fit", Value: "", Binding: "oneof=contain cover"},
+			{Name: "format", Value: "", Binding: "oneof=jpeg png webp"},
+		},
+		ContentType: "*/*"},
+}
+
+var adminOperations = map[string]openapi.Operation{
+	"POST /admin/products": {Summary: "Create a product", Tag: "Products", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{
+			"product":    models.Product{},
+			"attributes": []models.ProductAttribute{},
+			"variations": []models.VariationProduct{},
+		})},
+	"GET /admin/products":     {Summary: "List products", Tag: "Products", Response: openapi.Object{"products": []models.Product{}}},
+	"GET /admin/products/:id": {Summary: "Get a product", Tag: "Products", Response: openapi.Object{"product": models.Product{}}},
+	"POST /admin/products/:id/images": {Summary: "Upload images to a product's gallery", Tag: "Media",
+		Files: "files", Response: productImages},
+	"PUT /admin/products/:id/images": {Summary: "Set a product's gallery to uploaded media", Tag: "Media",
+		Response: productImages},
+
+	"POST /admin/attributes": {Summary: "Create an attribute", Tag: "Attributes",
+		Response: withMessage(openapi.Object{"attribute_id": 0, "attribute_name": ""})},
+	"GET /admin/attributes": {Summary: "List attributes", Tag: "Attributes",
+		Response: openapi.Object{"attributes": []models.Attribute{}}},
+	"GET /admin/attributes/:id": {Summary: "Get an attribute", Tag: "Attributes", Response: models.Attribute{}},
+	"PUT /admin/attributes/:id": {Summary: "Update an attribute", Tag: "Attributes",
+		Response: withMessage(openapi.Object{"attribute_name": "", "status": 0})},
+	"DELETE /admin/attributes/:id": {Summary: "Delete an attribute", Tag: "Attributes", Response: message},
+
+	"POST /admin/attribute-values": {Summary: "Create an attribute value", Tag: "Attributes",
+		Response: withMessage(openapi.Object{"value_id": 0, "attribute_id": 0, "value": ""})},
+	"GET /admin/attribute-values/:attribute_id": {Summary: "List the values of an attribute", Tag: "Attributes",
+		Response: openapi.Object{"attribute_values": []models.AttributeValue{}}},
+	"GET /admin/attribute-value/:id": {Summary: "Get an attribute value", Tag: "Attributes", Response: models.AttributeValue{}},
+	"PUT /admin/attribute-value/:id": {Summary: "Update an attribute value", Tag: "Attributes",
+		Response: message},
+	"DELETE /admin/attribute-value/:id": {Summary: "Delete an attribute value", Tag: "Attributes", Response: message},
+
+	"POST /admin/brands": {Summary: "Create a brand", Tag: "Brands", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{"id": 0})},
+	"PUT /admin/brands/:id": {Summary: "Update a brand", Tag: "Brands",
+		Response: withMessage(openapi.Object{"id": 0})},
+	"DELETE /admin/brands/:id": {Summary: "Delete a brand", Tag: "Brands", Response: withMessage(openapi.Object{"id": 0})},
+	"PUT /admin/brands/:id/image": {Summary: "Upload a brand's image", Tag: "Media",
+		File: "file", Response: withMessage(openapi.Object{"id": 0, "image": ""})},
+
+	"POST /admin/categories": {Summary: "Create a category", Tag: "Categories", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{"category": models.Category{}})},
+	"GET /admin/categories/:id": {Summary: "Get a category", Tag: "Categories",
+		Response: openapi.Object{"category": models.Category{}}},
+	"PUT /admin/categories/:id": {Summary: "Update a category", Tag: "Categories",
+		Response: withMessage(openapi.Object{"id": 0})},
+	"DELETE /admin/categories/:id": {Summary: "Delete a category", Tag: "Categories",
+		Response: withMessage(openapi.Object{"id": 0})},
+	"POST /admin/categories/:id/move": {Summary: "Move a category in the tree", Tag: "Categories",
+		Description: "A null parent_id makes it top-level; position 0 puts it last.",
+		Response:    withMessage(openapi.Object{"category": models.Category{}})},
+	"PUT /admin/categories/:id/image": {Summary: "Upload a category's image", Tag: "Media",
+		File: "file", Response: withMessage(openapi.Object{"id": 0, "image": ""})},
+
+	"GET /admin/session": {Summary: "Get the current admin's profile and sessions", Tag: "Account",
+		Response: sessionResponse},
+	"POST /admin/address": {Summary: "Create an address for a user", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+	"PUT /admin/address/:id": {Summary: "Update a user's address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+	"GET /admin/address/:id/label": {Summary: "Format an address for a printed label", Tag: "Addresses",
+		Query: labelQuery, Response: addressLabel},
+
+	"POST /admin/users": {Summary: "Create a user", Tag: "Users",
+		Response: withMessage(openapi.Object{"user_id": 0})},
+	"GET /admin/users": {Summary: "List users", Tag: "Users",
+		Description: "One page at a time: the next is requested with the returned next_cursor.",
+		Query: []openapi.Param{
+			{Name: "q", Value: "", Description: "Matches the username, email or phone number."},
+			{Name: "role", Value: "", Binding: "oneof=user admin"},
+			{Name: "is_verified", Value: false},
+			{Name: "is_blocked", Value: false},
+			{Name: "created_from", Value: "", Description: "YYYY-MM-DD or RFC 3339."},
+			{Name: "created_to", Value: "", Description: "YYYY-MM-DD or RFC 3339."},
+			{Name: "deleted", Value: false, Description: "List soft-deleted users instead."},
+			{Name: "limit", Value: 0, Binding: "min=1,max=100", Description: "Page size, 20 by default."},
+			{Name: "cursor", Value: ""},
+		},
+		Response: withMessage(openapi.Object{"users": []models.User{}, "limit": 0, "next_cursor": (*string)(nil)})},
+	"POST /admin/users/bulk/block": {Summary: "Block users", Tag: "Users",
+		Response: bulkResult},
+	"POST /admin/users/bulk/unblock": {Summary: "Unblock users", Tag: "Users",
+		Response: bulkResult},
+	"POST /admin/users/bulk/verify": {Summary: "Verify users", Tag: "Users",
+		Response: bulkResult},
+	"POST /admin/users/bulk/role": {Summary: "Set the role of users", Tag: "Users",
+		Response: bulkResult},
+	"GET /admin/users/:id": {Summary: "Get a user", Tag: "Users",
+		Response: withMessage(openapi.Object{"user": models.User{}})},
+	"PUT /admin/users/:id": {Summary: "Update a user", Tag: "Users",
+		Response: withMessage(openapi.Object{"user_id": 0})},
+	"DELETE /admin/users/:id": {Summary: "Soft-delete a user", Tag: "Users",
+		Response: withMessage(openapi.Object{"user_id": 0, "restorable_until": time.Time{}})},
+	"POST /admin/users/:id/restore": {Summary: "Restore a soft-deleted user", Tag: "Users",
+		Response: withMessage(openapi.Object{"user_id": 0})},
+	"POST /admin/users/:id/erase": {Summary: "Anonymize a user's personal data", Tag: "Users",
+		Response: withMessage(openapi.Object{"user_id": 0})},
+	"POST /admin/users/:id/impersonate": {Summary: "Get an access token acting as a user", Tag: "Users",
+		Description: "The token works until its session expires or is ended by logging out.",
+		Response: withMessage(openapi.Object{
+			"access_token":    "",
+			"expires_at":      time.Time{},
+			"session_id":      0,
+			"impersonator_id": 0,
+			"user":            openapi.Object{"id": 0, "username": "", "role": ""},
+		})},
+	"GET /admin/impersonations": {Summary: "List requests made while impersonating", Tag: "Users",
+		Query:    append([]openapi.Param{{Name: "user_id", Value: 0}, {Name: "impersonator_id", Value: 0}}, paging...),
+		Response: merged(page, openapi.Object{"requests": []models.ImpersonationRequest{}})},
+	"GET /admin/audit": {Summary: "List the changes made by admins", Tag: "Audit",
+		Query: append([]openapi.Param{
+			{Name: "actor_id", Value: 0},
+			{Name: "entity_type", Value: ""},
+			{Name: "entity_id", Value: 0},
+			{Name: "action", Value: ""},
+			{Name: "from", Value: "", Description: "YYYY-MM-DD or RFC 3339."},
+			{Name: "to", Value: "", Description: "YYYY-MM-DD or RFC 3339."},
+		}, paging...),
+		Response: merged(page, openapi.Object{"entries": []models.AuditEntry{}})},
+
+	"POST /admin/media": {Summary: "Upload a file", Tag: "Media", Status: http.StatusCreated,
+		Description: "Uploading a file already stored answers 200 with its media.",
+		File:        "file", Response: uploaded},
+	"GET /admin/media": {Summary: "List uploaded files", Tag: "Media",
+		Query: paging, Response: merged(page, openapi.Object{"media": []models.Media{}})},
+	"DELETE /admin/media/:id": {Summary: "Delete an uploaded file", Tag: "Media",
+		Response: withMessage(openapi.Object{"id": 0})},
+
+	"POST /admin/shipping-zones": {Summary: "Create a shipping zone", Tag: "Shipping", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{"zone_id": 0})},
+	"GET /admin/shipping-zones": {Summary: "List shipping zones", Tag: "Shipping",
+		Response: openapi.Object{"shipping_zones": []models.ShippingZone{}}},
+	"GET /admin/shipping-zones/:id": {Summary: "Get a shipping zone", Tag: "Shipping",
+		Response: openapi.Object{"shipping_zone": models.ShippingZone{}}},
+	"PUT /admin/shipping-zones/:id": {Summary: "Update a shipping zone", Tag: "Shipping",
+		Response: withMessage(openapi.Object{"zone_id": 0})},
+	"DELETE /admin/shipping-zones/:id": {Summary: "Delete a shipping zone", Tag: "Shipping",
+		Response: withMessage(openapi.Object{"zone_id": 0})},
+	"POST /admin/shipping-zones/:id/methods": {Summary: "Add a shipping method to a zone", Tag: "Shipping",
+		Status: http.StatusCreated, Response: withMessage(openapi.Object{"method_id": 0, "zone_id": 0})},
+	"PUT /admin/shipping-methods/:id": {Summary: "Update a shipping method", Tag: "Shipping",
+		Response: withMessage(openapi.Object{"method_id": 0})},
+	"DELETE /admin/shipping-methods/:id": {Summary: "Delete a shipping method", Tag: "Shipping",
+		Response: withMessage(openapi.Object{"method_id": 0})},
+
+	"GET /admin/reviews": {Summary: "List reviews for moderation", Tag: "Reviews",
+		Query: append([]openapi.Param{
+			{Name: "product_id", Value: 0},
+			{Name: "status", Value: "", Binding: "oneof=pending approved rejected hidden"},
+		}, paging...),
+		Response: merged(page, openapi.Object{"reviews": []models.ProductReview{}})},
+	"PATCH /admin/reviews/:id/status": {Summary: "Moderate a review", Tag: "Reviews",
+		Response: withMessage(openapi.Object{"review_id": 0, "status": ""})},
+	"DELETE /admin/reviews/:id": {Summary: "Delete a review", Tag: "Reviews",
+		Response: withMessage(openapi.Object{"review_id": 0})},
+}
+
+// sessionResponse is the profile of the current user with their addresses
+// and sessions.
+var sessionResponse = merged(profile, openapi.Object{
+	"addresses":          []models.Address{},
+	"shipping_addresses": []models.ShippingAddress{},
+	"billing_addresses":  []models.BillingAddress{},
+	"login_sessions":     []models.LoginSession{},
+})
+
+var userOperations = map[string]openapi.Operation{
+	"GET /user/products":     {Summary: "List products", Tag: "Products", Response: openapi.Object{"products": []models.Product{}}},
+	"GET /user/products/:id": {Summary: "Get a product", Tag: "Products", Response: openapi.Object{"product": models.Product{}}},
+
+	"GET /user/session": {Summary: "Get the current user's profile and sessions", Tag: "Account",
+		Response: sessionResponse},
+	"GET /user/me": {Summary: "Get the current user's profile", Tag: "Account",
+		Response: openapi.Object{
+			"user":               merged(profile, openapi.Object{"created_at": time.Time{}, "updated_at": time.Time{}}),
+			"addresses":          []models.Address{},
+			"shipping_addresses": []models.ShippingAddress{},
+			"billing_addresses":  []models.BillingAddress{},
+		}},
+	"POST /user/logout": {Summary: "Log out of every session", Tag: "Auth", Response: message,
+		Description: "With an impersonation token, only the impersonation session ends, and the token stops working."},
+	"GET /user/export": {Summary: "Download everything stored about the current user", Tag: "Account",
+		Description: "As one JSON document, or with format=zip a ZIP archive of one JSON file per section. Refused with an impersonation token.",
+		Query:       []openapi.Param{{Name: "format", Value: "", Binding: "oneof=json zip", Description: "json by default."}},
+		Response: openapi.Object{
+			"exported_at":            time.Time{},
+			"user":                   models.User{},
+			"addresses":              []models.Address{},
+			"login_sessions":         []models.LoginSession{},
+			"cart_items":             []models.CartItem{},
+			"wishlists":              []models.Wishlist{},
+			"wishlist_notifications": []models.WishlistNotification{},
+			"reviews":                []models.ProductReview{},
+			"orders":                 []models.Order{},
+		}},
+	"DELETE /user/account": {Summary: "Delete the current user's account", Tag: "Account",
+		Description: "The account can be restored by an admin until restorable_until.",
+		Response:    withMessage(openapi.Object{"restorable_until": time.Time{}})},
+	"PUT /user/image": {Summary: "Upload the current user's profile image", Tag: "Media",
+		File: "file", Response: withMessage(openapi.Object{"image": ""})},
+
+	"POST /user/address": {Summary: "Add an address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0, "type": "", "address": models.Address{}})},
+	"GET /user/addresses": {Summary: "List the user's addresses", Tag: "Addresses",
+		Query:    []openapi.Param{{Name: "usage", Value: "", Binding: "oneof=shipping billing", Description: "Only the addresses used for it."}},
+		Response: withMessage(openapi.Object{"addresses": []models.Address{}})},
+	"GET /user/address/:id": {Summary: "Get an address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address": models.Address{}})},
+	"PUT /user/address/:id": {Summary: "Update an address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+	"DELETE /user/address/:id": {Summary: "Delete an address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+	"GET /user/address/:id/label": {Summary: "Format an address for a printed label", Tag: "Addresses",
+		Query: labelQuery, Response: addressLabel},
+	"PATCH /user/address/:id/default": {Summary: "Make an address the default for a usage", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+
+	"POST /user/shipping-address": {Summary: "Add a shipping address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0, "is_default": false, "type": ""})},
+	"GET /user/shipping-addresses": {Summary: "List shipping addresses", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"addresses": []openapi.Object{usageAddress}})},
+	"GET /user/shipping-address/:id": {Summary: "Get a shipping address", Tag: "Addresses",
+		Response: openapi.Object{"address": usageAddress}},
+	"PUT /user/shipping-address/:id": {Summary: "Update a shipping address", Tag: "Addresses",
+		Response: message},
+	"DELETE /user/shipping-address/:id": {Summary: "Delete a shipping address", Tag: "Addresses", Response: message},
+	"PATCH /user/shipping-address/:id/default": {Summary: "Make an address the default shipping address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+	"POST /user/billing-address": {Summary: "Add a billing address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0, "is_default": false, "type": ""})},
+	"GET /user/billing-addresses": {Summary: "List billing addresses", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"addresses": []openapi.Object{usageAddress}})},
+	"GET /user/billing-address/:id": {Summary: "Get a billing address", Tag: "Addresses",
+		Response: openapi.Object{"address": usageAddress}},
+	"PUT /user/billing-address/:id": {Summary: "Update a billing address", Tag: "Addresses",
+		Response: message},
+	"DELETE /user/billing-address/:id": {Summary: "Delete a billing address", Tag: "Addresses", Response: message},
+	"PATCH /user/billing-address/:id/default": {Summary: "Make an address the default billing address", Tag: "Addresses",
+		Response: withMessage(openapi.Object{"address_id": 0})},
+
+	"GET /user/cart": {Summary: "Get the cart", Tag: "Cart",
+		Response: openapi.Object{"items": []models.CartItem{}, "subtotal": 0.0}},
+	"POST /user/cart/items": {Summary: "Add a product to the cart", Tag: "Cart",
+		Response: withMessage(openapi.Object{"item_id": 0, "quantity": 0})},
+	"PUT /user/cart/items/:id": {Summary: "Change the quantity of a cart item", Tag: "Cart",
+		Response: withMessage(openapi.Object{"item_id": 0, "quantity": 0})},
+	"DELETE /user/cart/items/:id": {Summary: "Remove a cart item", Tag: "Cart",
+		Response: withMessage(openapi.Object{"item_id": 0})},
+	"POST /user/cart/items/:id/save-for-later": {Summary: "Move a cart item to the default wishlist", Tag: "Cart",
+		Response: withMessage(openapi.Object{"wishlist_id": 0})},
+	"POST /user/cart/shipping-options": {Summary: "Quote the shipping options of the cart", Tag: "Cart",
+		Description: "Quotes the methods of the most specific zone of the address: by postal code, then country, then \"*\". " +
+			"A product weight that cannot be read is refused with 400.",
+		Response: openapi.Object{
+			"shipping_address_id": 0,
+			"subtotal":            0.0,
+			"total_weight":        0.0,
+			"zone_id":             0,
+			"zone_name":           "",
+			"shipping_options":    []models.ShippingOption{},
+		}},
+
+	"POST /user/orders": {Summary: "Check the cart out as an order", Tag: "Orders", Status: http.StatusCreated,
+		Description: "Orders every cart item at its current price and empties the cart. Refused with an impersonation token.",
+		Response:    withMessage(openapi.Object{"order": models.Order{}})},
+	"GET /user/orders": {Summary: "List the current user's orders", Tag: "Orders",
+		Query: paging, Response: merged(page, openapi.Object{"orders": []models.Order{}})},
+	"GET /user/orders/:id": {Summary: "Get an order", Tag: "Orders", Response: models.Order{}},
+
+	"POST /user/products/:id/reviews": {Summary: "Review a product", Tag: "Reviews", Status: http.StatusCreated,
+		Description: "Only customers who ordered the product, in an order that was not cancelled, may review it; others get a 403.",
+		Response:    withMessage(openapi.Object{"review_id": 0, "status": ""})},
+	"PUT /user/reviews/:id": {Summary: "Update a review", Tag: "Reviews",
+		Response: withMessage(openapi.Object{"review_id": 0, "status": ""})},
+	"DELETE /user/reviews/:id": {Summary: "Delete a review", Tag: "Reviews",
+		Response: withMessage(openapi.Object{"review_id": 0})},
+	"POST /user/reviews/:id/vote": {Summary: "Vote on whether a review is helpful", Tag: "Reviews",
+		Response: withMessage(openapi.Object{"review_id": 0, "helpful_count": 0, "not_helpful_count": 0})},
+
+	"GET /user/wishlists": {Summary: "List wishlists", Tag: "Wishlists",
+		Response: openapi.Object{"wishlists": []models.Wishlist{}}},
+	"POST /user/wishlists": {Summary: "Create a wishlist", Tag: "Wishlists", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{"wishlist_id": 0, "name": ""})},
+	"GET /user/wishlists/:id": {Summary: "Get a wishlist with its items", Tag: "Wishlists",
+		Response: openapi.Object{"wishlist": models.Wishlist{}}},
+	"PUT /user/wishlists/:id": {Summary: "Rename a wishlist", Tag: "Wishlists",
+		Response: withMessage(openapi.Object{"wishlist_id": 0})},
+	"DELETE /user/wishlists/:id": {Summary: "Delete a wishlist", Tag: "Wishlists",
+		Response: withMessage(openapi.Object{"wishlist_id": 0})},
+	"POST /user/wishlists/:id/items": {Summary: "Add a product to a wishlist", Tag: "Wishlists", Status: http.StatusCreated,
+		Response: withMessage(openapi.Object{"wishlist_id": 0, "item_id": 0})},
+	"DELETE /user/wishlists/:id/items/:item_id": {Summary: "Remove an item from a wishlist", Tag: "Wishlists",
+		Response: withMessage(openapi.Object{"item_id": 0})},
+	"POST /user/wishlists/:id/move-to-cart": {Summary: "Move wishlist items to the cart", Tag: "Wishlists",
+		Description: "Without a body, every item is moved.",
+		Response:    withMessage(openapi.Object{"wishlist_id": 0, "moved_item_ids": []int{}})},
+	"POST /user/wishlists/:id/share": {Summary: "Share a wishlist by link", Tag: "Wishlists",
+		Response: withMessage(openapi.Object{"wishlist_id": 0, "share_token": "", "share_path": ""})},
+	"DELETE /user/wishlists/:id/share": {Summary: "Revoke a wishlist's link", Tag: "Wishlists",
+		Response: withMessage(openapi.Object{"wishlist_id": 0})},
+	"GET /user/wishlist-notifications": {Summary: "List price drop and back in stock notifications", Tag: "Wishlists",
+		Query:    append([]openapi.Param{{Name: "unread", Value: false, Description: "Only unread notifications."}}, paging...),
+		Response: merged(page, openapi.Object{"notifications": []models.WishlistNotification{}})},
+	"POST /user/wishlist-notifications/read": {Summary: "Mark notifications as read", Tag: "Wishlists",
+		Description: "Without a body, every notification is marked.",
+		Response:    withMessage(openapi.Object{"updated": 0})},
+}
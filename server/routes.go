@@ -0,0 +1,251 @@
+// server/routes.go
+package server
+
+import (
+	"my-api/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// groups are the route groups a resource adds its routes to: public
+// routes, and those requiring an access token of the admin or user role.
+type groups struct {
+	public gin.IRouter
+	admin  gin.IRouter
+	user   gin.IRouter
+}
+
+// registerRoutes adds the routes of the API to r, resource by resource.
+// Each is described in operations, for the OpenAPI document.
+func registerRoutes(r gin.IRouter, d Deps) {
+	g := groups{
+		public: r,
+		admin:  r.Group("/admin", handlers.JWTAuthMiddleware(d.Tokens, d.Stores.Sessions, d.Clock, "admin"), handlers.AuditActorMiddleware()),
+		user:   r.Group("/user", handlers.JWTAuthMiddleware(d.Tokens, d.Stores.Sessions, d.Clock, "user")),
+	}
+	for _, register := range []func(groups, Deps){
+		authRoutes,
+		accountRoutes,
+		userRoutes,
+		addressRoutes,
+		productRoutes,
+		brandRoutes,
+		categoryRoutes,
+		attributeRoutes,
+		mediaRoutes,
+		reviewRoutes,
+		cartRoutes,
+		orderRoutes,
+		shippingRoutes,
+		wishlistRoutes,
+		auditRoutes,
+	} {
+		register(g, d)
+	}
+}
+
+// authRoutes: registration, logins and the session of the token.
+func authRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.POST("/register", handlers.Register(st.Users))
+	g.public.POST("/login", handlers.Login(st.Users, st.Sessions, d.Tokens))
+	g.public.POST("/refresh", handlers.RefreshToken(st.Users, d.Tokens))
+
+	g.admin.GET("/session", handlers.Session(st.Users, st.Addresses, st.Sessions))
+
+	g.user.GET("/session", handlers.Session(st.Users, st.Addresses, st.Sessions))
+	g.user.GET("/me", handlers.GetUserDetails(st.Users, st.Addresses))
+	g.user.POST("/logout", handlers.Logout(st.Sessions))
+}
+
+// accountRoutes: what users do with their own account.
+func accountRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.user.GET("/export", handlers.ExportUserData(st.Users, st.Addresses, st.Sessions, st.Carts, st.Wishlists, st.Reviews, st.Orders, d.Clock))
+	g.user.DELETE("/account", handlers.DeleteAccount(st.Users, d.Mailer, d.Clock))
+	g.user.PUT("/image", handlers.UploadUserImage(st.Users, st.Media, d.Blobs))
+}
+
+// userRoutes: the management of users by admins.
+func userRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.admin.POST("/users", handlers.AdminCreateUser(st.Users))
+	g.admin.GET("/users", handlers.AdminGetAllUsers(st.Users))
+	g.admin.POST("/users/bulk/block", handlers.AdminBulkBlockUsers(st.Users))
+	g.admin.POST("/users/bulk/unblock", handlers.AdminBulkUnblockUsers(st.Users))
+	g.admin.POST("/users/bulk/verify", handlers.AdminBulkVerifyUsers(st.Users))
+	g.admin.POST("/users/bulk/role", handlers.AdminBulkSetUserRole(st.Users))
+	g.admin.GET("/users/:id", handlers.AdminGetUser(st.Users))
+	g.admin.PUT("/users/:id", handlers.AdminUpdateUser(st.Users))
+	g.admin.DELETE("/users/:id", handlers.AdminDeleteUser(st.Users, d.Mailer))
+	g.admin.POST("/users/:id/restore", handlers.AdminRestoreUser(st.Users))
+	g.admin.POST("/users/:id/erase", handlers.AdminEraseUser(st.Users, d.Mailer))
+	g.admin.POST("/users/:id/impersonate", handlers.AdminImpersonateUser(st.Users, st.Sessions, d.Tokens, d.Clock))
+	g.admin.GET("/impersonations", handlers.AdminGetImpersonations(st.Sessions))
+}
+
+// addressRoutes: the address books, and the countries addresses can be in.
+func addressRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/countries", handlers.GetCountries())
+
+	g.admin.POST("/address", handlers.AdminCreateAddress(st.Addresses))
+	g.admin.PUT("/address/:id", handlers.AdminUpdateAddress(st.Addresses))
+	g.admin.GET("/address/:id/label", handlers.AdminGetAddressLabel(st.Addresses))
+
+	g.user.POST("/address", handlers.AddAddress(st.Addresses))
+	g.user.GET("/addresses", handlers.GetAddresses(st.Addresses))
+	g.user.PUT("/address/:id", handlers.UpdateAddress(st.Addresses))
+	g.user.GET("/address/:id", handlers.GetAddressByID(st.Addresses))
+	g.user.DELETE("/address/:id", handlers.DeleteAddress(st.Addresses))
+	g.user.GET("/address/:id/label", handlers.GetAddressLabel(st.Addresses))
+
+	g.user.POST("/shipping-address", handlers.AddShippingAddress(st.Addresses))
+	g.user.GET("/shipping-addresses", handlers.GetShippingAddresses(st.Addresses))
+	g.user.GET("/shipping-address/:id", handlers.GetSingleShippingAddress(st.Addresses))
+	g.user.PUT("/shipping-address/:id", handlers.UpdateShippingAddress(st.Addresses))
+	g.user.DELETE("/shipping-address/:id", handlers.DeleteShippingAddress(st.Addresses))
+	g.user.POST("/billing-address", handlers.AddBillingAddress(st.Addresses))
+	g.user.GET("/billing-addresses", handlers.GetBillingAddresses(st.Addresses))
+	g.user.GET("/billing-address/:id", handlers.GetSingleBillingAddress(st.Addresses))
+	g.user.PUT("/billing-address/:id", handlers.UpdateBillingAddress(st.Addresses))
+	g.user.DELETE("/billing-address/:id", handlers.DeleteBillingAddress(st.Addresses))
+
+	g.user.PATCH("/address/:id/default", handlers.SetDefaultAddress(st.Addresses))
+	g.user.PATCH("/shipping-address/:id/default", handlers.SetDefaultShippingAddress(st.Addresses))
+	g.user.PATCH("/billing-address/:id/default", handlers.SetDefaultBillingAddress(st.Addresses))
+}
+
+func productRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/products", handlers.GetAllProducts(st.Products))
+	g.public.GET("/products/:id", handlers.GetProductByID(st.Products))
+
+	g.admin.POST("/products", handlers.AddProduct(st.Products))
+	g.admin.GET("/products", handlers.GetAllProducts(st.Products))
+	g.admin.GET("/products/:id", handlers.GetProductByID(st.Products))
+	g.admin.POST("/products/:id/images", handlers.AddProductImages(st.Products, st.Media, d.Blobs))
+	g.admin.PUT("/products/:id/images", handlers.SetProductImages(st.Products, st.Media))
+
+	g.user.GET("/products/:id", handlers.GetProductByID(st.Products))
+	g.user.GET("/products", handlers.GetAllProducts(st.Products))
+}
+
+func brandRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/brands", handlers.GetAllBrands(st.Catalog))
+	g.public.GET("/brands/:id", handlers.GetBrandByID(st.Catalog))
+
+	g.admin.POST("/brands", handlers.CreateBrand(st.Catalog))
+	g.admin.PUT("/brands/:id", handlers.UpdateBrand(st.Catalog))
+	g.admin.DELETE("/brands/:id", handlers.DeleteBrand(st.Catalog))
+	g.admin.PUT("/brands/:id/image", handlers.UploadBrandImage(st.Catalog, st.Media, d.Blobs))
+}
+
+func categoryRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/categories", handlers.GetAllCategories(st.Catalog))
+	g.public.GET("/categories/:id", handlers.GetCategoryByID(st.Catalog))
+	g.public.GET("/categories/:id/products", handlers.GetCategoryProducts(st.Catalog, st.Products))
+
+	g.admin.POST("/categories", handlers.CreateCategory(st.Catalog))
+	g.admin.PUT("/categories/:id", handlers.UpdateCategory(st.Catalog))
+	g.admin.DELETE("/categories/:id", handlers.DeleteCategory(st.Catalog))
+	g.admin.GET("/categories/:id", handlers.GetCategoryByID(st.Catalog))
+	g.admin.POST("/categories/:id/move", handlers.MoveCategory(st.Catalog))
+	g.admin.PUT("/categories/:id/image", handlers.UploadCategoryImage(st.Catalog, st.Media, d.Blobs))
+}
+
+// attributeRoutes: product attributes and their values.
+func attributeRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.admin.POST("/attributes", handlers.CreateAttribute(st.Catalog))
+	g.admin.GET("/attributes", handlers.GetAllAttributes(st.Catalog))
+	g.admin.GET("/attributes/:id", handlers.GetAttributeByID(st.Catalog))
+	g.admin.PUT("/attributes/:id", handlers.UpdateAttribute(st.Catalog))
+	g.admin.DELETE("/attributes/:id", handlers.DeleteAttribute(st.Catalog))
+
+	g.admin.POST("/attribute-values", handlers.CreateAttributeValue(st.Catalog))
+	g.admin.GET("/attribute-values/:attribute_id", handlers.GetAllAttributeValues(st.Catalog))
+	g.admin.GET("/attribute-value/:id", handlers.GetAttributeValue(st.Catalog))
+	g.admin.PUT("/attribute-value/:id", handlers.UpdateAttributeValue(st.Catalog))
+	g.admin.DELETE("/attribute-value/:id", handlers.DeleteAttributeValue(st.Catalog))
+}
+
+// mediaRoutes: the media library; images of products, brands, categories
+// and users are uploaded with them.
+func mediaRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/media/:id", handlers.ServeMedia(st.Media, d.Blobs, d.Variants))
+
+	g.admin.POST("/media", handlers.UploadMedia(st.Media, d.Blobs))
+	g.admin.GET("/media", handlers.AdminGetMedia(st.Media))
+	g.admin.DELETE("/media/:id", handlers.AdminDeleteMedia(st.Media, d.Blobs))
+}
+
+func reviewRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/products/:id/reviews", handlers.GetProductReviews(st.Reviews))
+
+	g.admin.GET("/reviews", handlers.AdminGetReviews(st.Reviews))
+	g.admin.PATCH("/reviews/:id/status", handlers.ModerateReview(st.Reviews))
+	g.admin.DELETE("/reviews/:id", handlers.AdminDeleteReview(st.Reviews))
+
+	g.user.POST("/products/:id/reviews", handlers.CreateReview(st.Reviews, st.Orders))
+	g.user.PUT("/reviews/:id", handlers.UpdateReview(st.Reviews))
+	g.user.DELETE("/reviews/:id", handlers.DeleteReview(st.Reviews))
+	g.user.POST("/reviews/:id/vote", handlers.VoteReview(st.Reviews))
+}
+
+func cartRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.user.GET("/cart", handlers.GetCart(st.Carts))
+	g.user.POST("/cart/items", handlers.AddCartItem(st.Carts))
+	g.user.PUT("/cart/items/:id", handlers.UpdateCartItem(st.Carts))
+	g.user.DELETE("/cart/items/:id", handlers.RemoveCartItem(st.Carts))
+	g.user.POST("/cart/items/:id/save-for-later", handlers.SaveCartItemForLater(st.Wishlists))
+	g.user.POST("/cart/shipping-options", handlers.GetShippingOptions(st.Addresses, st.Carts, st.Shipping))
+}
+
+// orderRoutes: checking carts out as orders.
+func orderRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.user.POST("/orders", handlers.PlaceOrder(st.Orders))
+	g.user.GET("/orders", handlers.GetOrders(st.Orders))
+	g.user.GET("/orders/:id", handlers.GetOrder(st.Orders))
+}
+
+// shippingRoutes: the shipping zones and methods carts are shipped by.
+func shippingRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.admin.POST("/shipping-zones", handlers.CreateShippingZone(st.Shipping))
+	g.admin.GET("/shipping-zones", handlers.GetAllShippingZones(st.Shipping))
+	g.admin.GET("/shipping-zones/:id", handlers.GetShippingZoneByID(st.Shipping))
+	g.admin.PUT("/shipping-zones/:id", handlers.UpdateShippingZone(st.Shipping))
+	g.admin.DELETE("/shipping-zones/:id", handlers.DeleteShippingZone(st.Shipping))
+	g.admin.POST("/shipping-zones/:id/methods", handlers.CreateShippingMethod(st.Shipping))
+	g.admin.PUT("/shipping-methods/:id", handlers.UpdateShippingMethod(st.Shipping))
+	g.admin.DELETE("/shipping-methods/:id", handlers.DeleteShippingMethod(st.Shipping))
+}
+
+func wishlistRoutes(g groups, d Deps) {
+	st := d.Stores
+	g.public.GET("/wishlists/shared/:token", handlers.GetSharedWishlist(st.Wishlists))
+
+	g.user.GET("/wishlists", handlers.GetWishlists(st.Wishlists))
+	g.user.POST("/wishlists", handlers.CreateWishlist(st.Wishlists))
+	g.user.GET("/wishlists/:id", handlers.GetWishlist(st.Wishlists))
+	g.user.PUT("/wishlists/:id", handlers.RenameWishlist(st.Wishlists))
+	g.user.DELETE("/wishlists/:id", handlers.DeleteWishlist(st.Wishlists))
+	g.user.POST("/wishlists/:id/items", handlers.AddWishlistItem(st.Wishlists))
+	g.user.DELETE("/wishlists/:id/items/:item_id", handlers.RemoveWishlistItem(st.Wishlists))
+	g.user.POST("/wishlists/:id/move-to-cart", handlers.MoveWishlistToCart(st.Wishlists))
+	g.user.POST("/wishlists/:id/share", handlers.ShareWishlist(st.Wishlists))
+	g.user.DELETE("/wishlists/:id/share", handlers.UnshareWishlist(st.Wishlists))
+	g.user.GET("/wishlist-notifications", handlers.GetWishlistNotifications(st.Wishlists))
+	g.user.POST("/wishlist-notifications/read", handlers.MarkWishlistNotificationsRead(st.Wishlists))
+}
+
+func auditRoutes(g groups, d Deps) {
+	g.admin.GET("/audit", handlers.AdminGetAuditLog(d.Stores.Audit))
+}
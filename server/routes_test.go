@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"my-api/openapi"
+	"my-api/store/blob"
+	"my-api/store/memory"
+	"my-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	blobs, err := blob.NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	registerRoutes(r, Deps{Stores: memory.New(), Blobs: blobs, Variants: blobs, Tokens: utils.NewTokenService("secret", nil)})
+
+	missing, stale := openapi.Undocumented(r.Routes(), operations)
+	for _, route := range missing {
+		t.Errorf("%s is missing from operations", route)
+	}
+	for _, route := range stale {
+		if _, ok := serviceOperations[route]; !ok {
+			t.Errorf("operations describes %s, which is not routed", route)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	blobs, err := blob.NewDisk(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	registerRoutes(r, Deps{Stores: memory.New(), Blobs: blobs, Variants: blobs, Tokens: utils.NewTokenService("secret", nil)})
+
+	doc := openapi.Build(apiInfo, r.Routes(), operations)
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// every reference resolves
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			raw, _ := json.Marshal(op)
+			for _, ref := range refs(string(raw)) {
+				if _, ok := doc.Components.Schemas[ref]; !ok {
+					t.Errorf("%s %s refers to unknown schema %s", method, path, ref)
+				}
+			}
+		}
+	}
+
+	register := doc.Paths["/register"]["post"]
+	schema := doc.Components.Schemas["RegisterInput"]
+	if register == nil || schema == nil {
+		t.Fatal("POST /register or RegisterInput is not documented")
+	}
+	if got := strings.Join(schema.Required, ","); got != "username,email,password" {
+		t.Errorf("RegisterInput requires %s", got)
+	}
+	if email := schema.Properties["email"]; email.Format != "email" {
+		t.Errorf("RegisterInput.email has format %q", email.Format)
+	}
+	if password := schema.Properties["password"]; password.MinLength == nil || *password.MinLength != 8 {
+		t.Errorf("RegisterInput.password has minLength %v", password.MinLength)
+	}
+
+	user := doc.Paths["/admin/users/{id}"]["get"]
+	if user == nil || len(user.Security) == 0 || len(user.Parameters) != 1 || user.Parameters[0].Name != "id" {
+		t.Errorf("GET /admin/users/{id} is %+v", user)
+	}
+}
+
+// refs returns the names of the schemas referred to in the JSON of doc.
+func refs(doc string) []string {
+	var names []string
+	for _, part := range strings.Split(doc, `"$ref":"#/components/schemas/`)[1:] {
+		name, _, _ := strings.Cut(part, `"`)
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,67 @@
+// server/serve.go
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/signal"
+	"syscall"
+
+	"my-api/config"
+)
+
+// New returns the server of handler on addr, with the connection timeouts
+// of cfg.
+func New(addr string, handler http.Handler, cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// Run runs servers until SIGINT or SIGTERM, or until one of them fails,
+// then stops accepting connections and gives in-flight requests until
+// cfg.ShutdownTimeout to finish.
+func Run(cfg config.ServerConfig, servers ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	failed := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func() {
+			log.Printf("Listening on %s", srv.Addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s: %w", srv.Addr, err)
+			}
+		}()
+	}
+
+	var err error
+	select {
+	case err = <-failed:
+	case <-ctx.Done():
+	}
+	// a second signal kills the process right away
+	stop()
+
+	log.Printf("Shutting down, waiting up to %s for requests to finish", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	for _, srv := range servers {
+		if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+			err = errors.Join(err, fmt.Errorf("shutdown %s: %w", srv.Addr, shutdownErr))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("Server stopped")
+	return nil
+}
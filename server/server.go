@@ -0,0 +1,111 @@
+// Package server builds the HTTP API: the gin router with its middleware,
+// probes and routes, from the dependencies main wires up, and the servers
+// that run it.
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"my-api/config"
+	"my-api/handlers"
+	"my-api/mail"
+	"my-api/metrics"
+	"my-api/migrate"
+	"my-api/openapi"
+	"my-api/store"
+	"my-api/utils"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// Deps are what the handlers of the API depend on.
+type Deps struct {
+	Config     config.Config
+	Logger     *slog.Logger
+	DB         *sql.DB
+	Migrations *migrate.Runner
+	Stores     *store.Stores
+	// Blobs keeps the uploaded files, Variants the resized copies of images.
+	Blobs    store.BlobStore
+	Variants store.BlobStore
+	// Clock tells the time of tokens, impersonations, account deletions and
+	// exports; time.Now if nil.
+	Clock func() time.Time
+	// Tokens signs and verifies access tokens; if nil, a service of
+	// Config.JWTSecret on Clock.
+	Tokens *utils.TokenService
+	// Mailer sends the notices of account deletions and erasures; if nil,
+	// they are only logged.
+	Mailer mail.Mailer
+}
+
+// NewRouter returns the gin engine of the API: its middleware, probes and
+// routes, and /metrics unless they have their own address.
+func NewRouter(d Deps) *gin.Engine {
+	if d.Clock == nil {
+		d.Clock = time.Now
+	}
+	if d.Tokens == nil {
+		d.Tokens = utils.NewTokenService(d.Config.JWTSecret, d.Clock)
+	}
+	if d.Mailer == nil {
+		d.Mailer = mail.Log{}
+	}
+	cfg := d.Config
+
+	r := gin.New()
+	r.Use(handlers.Tracing(cfg.Tracing.ServiceName), handlers.RequestLogger(d.Logger), handlers.Metrics(), handlers.Recovery())
+
+	r.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", handlers.RequestIDHeader, "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"Content-Length", handlers.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	r.Use(handlers.ImpersonationAuditMiddleware(d.Stores.Sessions))
+	r.NoRoute(handlers.NoRoute())
+
+	// the document of every route; requests are checked against it
+	spec := openapi.New(r, apiInfo, operations)
+	if cfg.Validation.Enabled {
+		r.Use(spec.Validate(int64(cfg.Validation.MaxBodyBytes)))
+	}
+
+	// Probes
+	r.GET("/healthz", handlers.Healthz())
+	r.GET("/readyz", handlers.Readyz(
+		handlers.ReadinessCheck{Name: "database", Check: d.DB.PingContext},
+		handlers.ReadinessCheck{Name: "migrations", Check: migrationsApplied(d.Migrations)},
+	))
+
+	if cfg.Metrics.Enabled && cfg.Metrics.Address == "" {
+		r.GET("/metrics", gin.WrapH(metrics.Handler(cfg.Metrics.Token)))
+	}
+
+	// API routes, documented at /openapi.json and /docs/
+	registerRoutes(r, d)
+	spec.Serve(r)
+	return r
+}
+
+// migrationsApplied is the readiness check that every migration has been
+// applied.
+func migrationsApplied(runner *migrate.Runner) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		pending, err := runner.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending, next is %04d_%s", len(pending), pending[0].Version, pending[0].Name)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,16 @@
+//go:build integration
+
+package server
+
+import (
+	"testing"
+
+	"my-api/store"
+	"my-api/store/storetest"
+)
+
+// TestStoreContract runs the contract of the stores, which the memory store
+// runs in the unit tests, on Postgres.
+func TestStoreContract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *store.Stores { return newTestApp(t).Stores })
+}
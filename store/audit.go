@@ -0,0 +1,132 @@
+// store/audit.go
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"my-api/models"
+)
+
+// Actor is who makes the changes of a request, for the audit log.
+type Actor struct {
+	UserID    int
+	IPAddress string
+	UserAgent string
+}
+
+type actorKey struct{}
+
+// WithActor returns a context whose store changes are recorded in the audit
+// log as made by actor. Changes without an actor are not audited.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, if any.
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// Audited entity types.
+const (
+	EntityUser           = "user"
+	EntityAddress        = "address"
+	EntityBrand          = "brand"
+	EntityCategory       = "category"
+	EntityAttribute      = "attribute"
+	EntityAttributeValue = "attribute_value"
+	EntityProduct        = "product"
+	EntityShippingZone   = "shipping_zone"
+	EntityShippingMethod = "shipping_method"
+	EntityReview         = "review"
+	EntityMedia          = "media"
+)
+
+// Audited actions besides the UserBulkAction names.
+const (
+	AuditCreate   = "create"
+	AuditUpdate   = "update"
+	AuditDelete   = "delete"
+	AuditRestore  = "restore"
+	AuditErase    = "erase"
+	AuditModerate = "moderate"
+	AuditMove     = "move"
+)
+
+// hiddenFields are never written to the audit log; unchangedFields change
+// with every update and only clutter it.
+var (
+	hiddenFields    = []string{"password"}
+	unchangedFields = []string{"updated_at"}
+)
+
+// PersonalFields are removed from a user's audit entries when the user is
+// erased.
+var PersonalFields = []string{"username", "email", "phone_number", "image"}
+
+// AuditDiff reduces the JSON objects of an entity before and after a change
+// to the fields that differ. A nil before (creation) or after (deletion)
+// keeps the whole other side. Erasures keep no personal fields.
+func AuditDiff(action string, before, after []byte) (json.RawMessage, json.RawMessage, error) {
+	var old, cur map[string]json.RawMessage
+	if before != nil {
+		if err := json.Unmarshal(before, &old); err != nil {
+			return nil, nil, err
+		}
+	}
+	if after != nil {
+		if err := json.Unmarshal(after, &cur); err != nil {
+			return nil, nil, err
+		}
+	}
+	omit := hiddenFields
+	if action == AuditErase {
+		omit = slices.Concat(hiddenFields, PersonalFields)
+	}
+	for _, field := range omit {
+		delete(old, field)
+		delete(cur, field)
+	}
+	if old != nil && cur != nil {
+		for _, field := range unchangedFields {
+			delete(old, field)
+			delete(cur, field)
+		}
+		for field, value := range old {
+			if other, ok := cur[field]; ok && bytes.Equal(value, other) {
+				delete(old, field)
+				delete(cur, field)
+			}
+		}
+	}
+	return marshalFields(old), marshalFields(cur), nil
+}
+
+func marshalFields(fields map[string]json.RawMessage) json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	data, _ := json.Marshal(fields)
+	return data
+}
+
+// AuditFilter selects audit entries; zero values match any.
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	EntityType string
+	EntityID   int
+	From, To   *time.Time
+	Limit      int
+	Offset     int
+}
+
+type AuditStore interface {
+	// List returns matching entries, newest first.
+	List(ctx context.Context, filter AuditFilter) ([]models.AuditEntry, error)
+}
@@ -0,0 +1,42 @@
+// store/media.go
+package store
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"my-api/models"
+)
+
+// MediaURL is the path the API serves a media file at.
+func MediaURL(id int) string {
+	return "/media/" + strconv.Itoa(id)
+}
+
+// MediaStore keeps the metadata of uploaded files and which products show
+// them. Their content lives in a BlobStore.
+type MediaStore interface {
+	// Create stores media unless a file with the same Hash exists, and
+	// returns the stored media and whether it is new.
+	Create(ctx context.Context, media models.Media) (models.Media, bool, error)
+	Get(ctx context.Context, id int) (models.Media, error)
+	// List returns media newest first.
+	List(ctx context.Context, limit, offset int) ([]models.Media, error)
+	// Delete yields ErrConflict while a product shows the media.
+	Delete(ctx context.Context, id int) error
+
+	// SetProductImages replaces the product's images with mediaIDs in that
+	// order and makes the first one the product's image. Unknown IDs yield
+	// an *InvalidRefError for "media_id" or "product_id".
+	SetProductImages(ctx context.Context, productID int, mediaIDs []int) error
+}
+
+// BlobStore stores file contents by key.
+type BlobStore interface {
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get yields ErrNotFound for an unknown key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete succeeds for unknown keys.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,242 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type AddressStore struct {
+	s *state
+}
+
+// usageFlags returns pointers to the flag and default fields of usage.
+func usageFlags(addr *models.Address, usage store.AddressUsage) (flag, isDefault *bool, err error) {
+	switch usage {
+	case store.UsageShipping:
+		return &addr.IsShipping, &addr.IsDefaultShipping, nil
+	case store.UsageBilling:
+		return &addr.IsBilling, &addr.IsDefaultBilling, nil
+	}
+	return nil, nil, fmt.Errorf("memory: unknown address usage %q", usage)
+}
+
+// usedFor reports whether addr is flagged for usage; every address matches
+// the empty usage.
+func usedFor(addr models.Address, usage store.AddressUsage) bool {
+	switch usage {
+	case "":
+		return true
+	case store.UsageShipping:
+		return addr.IsShipping
+	case store.UsageBilling:
+		return addr.IsBilling
+	}
+	return false
+}
+
+// hasDefault reports whether the user has a default address for usage
+// other than exceptID.
+func (st *AddressStore) hasDefault(userID int, usage store.AddressUsage, exceptID int) bool {
+	for _, addr := range st.s.addresses {
+		if addr.UserID != userID || addr.ID == exceptID {
+			continue
+		}
+		if (usage == store.UsageShipping && addr.IsDefaultShipping) ||
+			(usage == store.UsageBilling && addr.IsDefaultBilling) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDefaults drops the defaults of usages addr is not flagged for and
+// makes it the default of the others when the user has none yet.
+func (st *AddressStore) applyDefaults(addr *models.Address) {
+	addr.IsDefaultShipping = addr.IsShipping &&
+		(addr.IsDefaultShipping || !st.hasDefault(addr.UserID, store.UsageShipping, addr.ID))
+	addr.IsDefaultBilling = addr.IsBilling &&
+		(addr.IsDefaultBilling || !st.hasDefault(addr.UserID, store.UsageBilling, addr.ID))
+}
+
+func (st *AddressStore) List(ctx context.Context, userID int, usage store.AddressUsage) ([]models.Address, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var addresses []models.Address
+	for _, id := range sortedIDs(st.s.addresses) {
+		if addr := st.s.addresses[id]; addr.UserID == userID && usedFor(addr, usage) {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses, nil
+}
+
+func (st *AddressStore) Get(ctx context.Context, userID, id int, usage store.AddressUsage) (models.Address, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	addr, ok := st.s.addresses[id]
+	if !ok || !owns(userID, addr.UserID) || !usedFor(addr, usage) {
+		return models.Address{}, store.ErrNotFound
+	}
+	return addr, nil
+}
+
+func (st *AddressStore) GetDefault(ctx context.Context, userID int, usage store.AddressUsage) (models.Address, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	for _, id := range sortedIDs(st.s.addresses) {
+		addr := st.s.addresses[id]
+		if addr.UserID != userID {
+			continue
+		}
+		_, isDefault, err := usageFlags(&addr, usage)
+		if err != nil {
+			return models.Address{}, err
+		}
+		if *isDefault {
+			return addr, nil
+		}
+	}
+	return models.Address{}, store.ErrNotFound
+}
+
+func (st *AddressStore) Create(ctx context.Context, addr models.Address) (models.Address, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.users[addr.UserID]; !ok {
+		return addr, &store.InvalidRefError{Field: "user_id"}
+	}
+	addr.ID = st.s.nextID("addresses")
+	addr.IsDefaultShipping, addr.IsDefaultBilling = false, false
+	st.applyDefaults(&addr)
+	addr.CreatedAt = time.Now()
+	st.s.addresses[addr.ID] = addr
+	st.s.audit(ctx, store.AuditCreate, store.EntityAddress, addr.ID, nil, addr)
+	return addr, nil
+}
+
+func (st *AddressStore) Update(ctx context.Context, userID int, addr models.Address) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.addresses[addr.ID]
+	if !ok || !owns(userID, before.UserID) {
+		return store.ErrNotFound
+	}
+	current := before
+	current.RecipientName = addr.RecipientName
+	current.Phone = addr.Phone
+	current.AddressLine1 = addr.AddressLine1
+	current.AddressLine2 = addr.AddressLine2
+	current.City = addr.City
+	current.Region = addr.Region
+	current.Country = addr.Country
+	current.PostalCode = addr.PostalCode
+	current.Type = addr.Type
+	current.IsShipping = addr.IsShipping
+	current.IsBilling = addr.IsBilling
+	st.applyDefaults(&current)
+	st.s.addresses[addr.ID] = current
+	st.s.audit(ctx, store.AuditUpdate, store.EntityAddress, addr.ID, before, current)
+	return nil
+}
+
+func (st *AddressStore) Delete(ctx context.Context, userID, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	addr, ok := st.s.addresses[id]
+	if !ok || !owns(userID, addr.UserID) {
+		return store.ErrNotFound
+	}
+	delete(st.s.addresses, id)
+	st.s.audit(ctx, store.AuditDelete, store.EntityAddress, id, addr, nil)
+	return nil
+}
+
+func (st *AddressStore) RemoveUsage(ctx context.Context, userID, id int, usage store.AddressUsage) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	addr, ok := st.s.addresses[id]
+	if !ok || !owns(userID, addr.UserID) {
+		return store.ErrNotFound
+	}
+	flag, isDefault, err := usageFlags(&addr, usage)
+	if err != nil {
+		return err
+	}
+	if !*flag {
+		return store.ErrNotFound
+	}
+	*flag, *isDefault = false, false
+	if !addr.IsShipping && !addr.IsBilling {
+		delete(st.s.addresses, id)
+		return nil
+	}
+	st.s.addresses[id] = addr
+	return nil
+}
+
+func (st *AddressStore) SetDefault(ctx context.Context, userID, id int, usage store.AddressUsage) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	addr, ok := st.s.addresses[id]
+	if !ok || !owns(userID, addr.UserID) {
+		return store.ErrNotFound
+	}
+	if flag, _, err := usageFlags(&addr, usage); err != nil {
+		return err
+	} else if !*flag {
+		return store.ErrNotFound
+	}
+
+	for otherID, other := range st.s.addresses {
+		if other.UserID != addr.UserID {
+			continue
+		}
+		_, isDefault, _ := usageFlags(&other, usage)
+		if *isDefault != (otherID == id) {
+			*isDefault = otherID == id
+			st.s.addresses[otherID] = other
+		}
+	}
+	return nil
+}
+
+// legacyAddressID is the ID of a row of the shipping or billing address
+// tables the address book replaced.
+type legacyAddressID struct {
+	usage store.AddressUsage
+	id    int
+}
+
+// AddLegacyID records that the shipping or billing address legacyID became
+// the address addressID, as migration 0005 does in Postgres for the rows of
+// the old tables, which the memory store never had.
+func (st *AddressStore) AddLegacyID(usage store.AddressUsage, legacyID, addressID int) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.s.legacyAddressIDs[legacyAddressID{usage, legacyID}] = addressID
+}
+
+func (st *AddressStore) ResolveLegacyID(ctx context.Context, usage store.AddressUsage, id int) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	// the mapping goes with the address, like the Postgres cascade
+	if addressID, ok := st.s.legacyAddressIDs[legacyAddressID{usage, id}]; ok {
+		if _, exists := st.s.addresses[addressID]; exists {
+			return addressID, nil
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type AuditStore struct {
+	s *state
+}
+
+// audit records a change of the entity in the audit log when ctx carries
+// an actor, like the Postgres store does in the change's transaction.
+// before and after are the entity's values, nil on creation and deletion.
+func (s *state) audit(ctx context.Context, action, entity string, id int, before, after any) {
+	actor, ok := store.ActorFrom(ctx)
+	if !ok {
+		return
+	}
+	// model values always marshal, so neither can fail
+	old, _ := marshalEntity(before)
+	cur, _ := marshalEntity(after)
+	old, cur, _ = store.AuditDiff(action, old, cur)
+	s.auditLog = append(s.auditLog, models.AuditEntry{
+		ID:         s.nextID("audit_log"),
+		ActorID:    actor.UserID,
+		Action:     action,
+		EntityType: entity,
+		EntityID:   id,
+		Before:     old,
+		After:      cur,
+		IPAddress:  actor.IPAddress,
+		UserAgent:  actor.UserAgent,
+		CreatedAt:  time.Now(),
+	})
+}
+
+func marshalEntity(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// scrubAudit removes the personal data of an erased user from the audit
+// log: their personal fields and the addresses they had.
+func (s *state) scrubAudit(userID int) {
+	addressIDs := make(map[int]bool)
+	for id, addr := range s.addresses {
+		if addr.UserID == userID {
+			addressIDs[id] = true
+		}
+	}
+	for _, entry := range s.auditLog {
+		if entry.EntityType == store.EntityAddress && (entryUserID(entry.Before) == userID || entryUserID(entry.After) == userID) {
+			addressIDs[entry.EntityID] = true
+		}
+	}
+
+	for i, entry := range s.auditLog {
+		switch {
+		case entry.EntityType == store.EntityUser && entry.EntityID == userID:
+			s.auditLog[i].Before = withoutFields(entry.Before, store.PersonalFields)
+			s.auditLog[i].After = withoutFields(entry.After, store.PersonalFields)
+		case entry.EntityType == store.EntityAddress && addressIDs[entry.EntityID]:
+			s.auditLog[i].Before, s.auditLog[i].After = nil, nil
+		}
+	}
+}
+
+func entryUserID(data json.RawMessage) int {
+	var fields struct {
+		UserID int `json:"user_id"`
+	}
+	if data != nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields.UserID
+}
+
+func withoutFields(data json.RawMessage, names []string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if data == nil || json.Unmarshal(data, &fields) != nil {
+		return data
+	}
+	for _, name := range names {
+		delete(fields, name)
+	}
+	out, _ := json.Marshal(fields)
+	return out
+}
+
+func (st *AuditStore) List(ctx context.Context, filter store.AuditFilter) ([]models.AuditEntry, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var matching []models.AuditEntry
+	for _, entry := range slices.Backward(st.s.auditLog) {
+		if (filter.ActorID == 0 || entry.ActorID == filter.ActorID) &&
+			(filter.Action == "" || entry.Action == filter.Action) &&
+			(filter.EntityType == "" || entry.EntityType == filter.EntityType) &&
+			(filter.EntityID == 0 || entry.EntityID == filter.EntityID) &&
+			(filter.From == nil || !entry.CreatedAt.Before(*filter.From)) &&
+			(filter.To == nil || entry.CreatedAt.Before(*filter.To)) {
+			matching = append(matching, entry)
+		}
+	}
+	entries := []models.AuditEntry{}
+	for i := filter.Offset; i < len(matching) && len(entries) < filter.Limit; i++ {
+		entries = append(entries, matching[i])
+	}
+	return entries, nil
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type CartStore struct {
+	s *state
+}
+
+func sameVariation(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// variationOf returns the variation of productID, if variationID names one.
+func (s *state) variationOf(productID int, variationID *int) (models.VariationProduct, bool) {
+	if variationID == nil {
+		return models.VariationProduct{}, false
+	}
+	vp, ok := s.variations[*variationID]
+	return vp, ok && vp.ProductID == productID
+}
+
+// addToCart adds quantity to the user's matching line, creating it if needed.
+func (s *state) addToCart(userID, productID int, variationID *int, quantity int) models.CartItem {
+	now := time.Now()
+	for id, item := range s.cartItems {
+		if item.UserID == userID && item.ProductID == productID && sameVariation(item.VariationID, variationID) {
+			item.Quantity += quantity
+			s.cartItems[id] = item
+			return item
+		}
+	}
+	item := models.CartItem{
+		ID:          s.nextID("cart_items"),
+		UserID:      userID,
+		ProductID:   productID,
+		VariationID: variationID,
+		Quantity:    quantity,
+		CreatedAt:   now,
+	}
+	s.cartItems[item.ID] = item
+	return item
+}
+
+func (st *CartStore) ListItems(ctx context.Context, userID int) ([]models.CartItem, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	items := []models.CartItem{}
+	for _, id := range sortedIDs(st.s.cartItems) {
+		if item := st.s.cartItems[id]; item.UserID == userID {
+			items = append(items, st.s.withProduct(item))
+		}
+	}
+	return items, nil
+}
+
+// withProduct adds the product data needed for pricing and shipping to a
+// cart line.
+func (s *state) withProduct(item models.CartItem) models.CartItem {
+	product := s.products[item.ProductID]
+	item.ProductName = product.ProductName
+	item.Weight = product.Weight
+	item.SKU = nil
+	item.CurrentStock = product.CurrentStock
+	item.UnitPrice = 0
+	if product.Price != nil {
+		item.UnitPrice = *product.Price
+	}
+	if vp, ok := s.variationOf(item.ProductID, item.VariationID); ok {
+		sku := vp.SKU
+		item.SKU = &sku
+		item.CurrentStock = vp.CurrentStock
+		if vp.SalePrice != nil {
+			item.UnitPrice = *vp.SalePrice
+		} else if vp.DefaultSellPrice != nil {
+			item.UnitPrice = *vp.DefaultSellPrice
+		}
+	}
+	return item
+}
+
+func (st *CartStore) AddItem(ctx context.Context, userID, productID int, variationID *int, quantity int) (int, int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if variationID != nil {
+		if _, ok := st.s.variationOf(productID, variationID); !ok {
+			return 0, 0, &store.InvalidRefError{Field: "variation_id"}
+		}
+	}
+	if _, ok := st.s.products[productID]; !ok {
+		return 0, 0, &store.InvalidRefError{Field: "product_id"}
+	}
+	if _, ok := st.s.users[userID]; !ok {
+		return 0, 0, &store.InvalidRefError{Field: "user_id"}
+	}
+
+	item := st.s.addToCart(userID, productID, variationID, quantity)
+	return item.ID, item.Quantity, nil
+}
+
+func (st *CartStore) UpdateItem(ctx context.Context, userID, itemID, quantity int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	item, ok := st.s.cartItems[itemID]
+	if !ok || item.UserID != userID {
+		return store.ErrNotFound
+	}
+	item.Quantity = quantity
+	st.s.cartItems[itemID] = item
+	return nil
+}
+
+func (st *CartStore) RemoveItem(ctx context.Context, userID, itemID int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	item, ok := st.s.cartItems[itemID]
+	if !ok || item.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(st.s.cartItems, itemID)
+	return nil
+}
@@ -0,0 +1,468 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type CatalogStore struct {
+	s *state
+}
+
+func (st *CatalogStore) CreateBrand(ctx context.Context, brand models.Brand) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	brand.ID = st.s.nextID("brands")
+	brand.CreatedAt = time.Now()
+	brand.UpdatedAt = brand.CreatedAt
+	st.s.brands[brand.ID] = brand
+	st.s.audit(ctx, store.AuditCreate, store.EntityBrand, brand.ID, nil, brand)
+	return brand.ID, nil
+}
+
+func (st *CatalogStore) ListBrands(ctx context.Context) ([]models.Brand, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var brands []models.Brand
+	for _, id := range sortedIDs(st.s.brands) {
+		brands = append(brands, st.withBrandCounts(st.s.brands[id]))
+	}
+	return brands, nil
+}
+
+// withBrandCounts returns the brand with its product counts.
+func (st *CatalogStore) withBrandCounts(brand models.Brand) models.Brand {
+	brand.ProductsCount, brand.TotalProductsCount = 0, 0
+	for _, product := range st.s.products {
+		if product.BrandID == brand.ID {
+			brand.TotalProductsCount++
+			if product.Status == 1 {
+				brand.ProductsCount++
+			}
+		}
+	}
+	return brand
+}
+
+func (st *CatalogStore) GetBrand(ctx context.Context, id int) (models.Brand, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	brand, ok := st.s.brands[id]
+	if !ok {
+		return models.Brand{}, store.ErrNotFound
+	}
+	return st.withBrandCounts(brand), nil
+}
+
+func (st *CatalogStore) UpdateBrand(ctx context.Context, brand models.Brand) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	current, ok := st.s.brands[brand.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	brand.CreatedBy = current.CreatedBy
+	brand.CreatedAt = current.CreatedAt
+	brand.UpdatedAt = time.Now()
+	st.s.brands[brand.ID] = brand
+	st.s.audit(ctx, store.AuditUpdate, store.EntityBrand, brand.ID, current, brand)
+	return nil
+}
+
+func (st *CatalogStore) DeleteBrand(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	brand, ok := st.s.brands[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, product := range st.s.products {
+		if product.BrandID == id {
+			return errReferenced
+		}
+	}
+	delete(st.s.brands, id)
+	st.s.audit(ctx, store.AuditDelete, store.EntityBrand, id, brand, nil)
+	return nil
+}
+
+// treeSlot returns the path and depth of a child of parentID, and the
+// position it takes among the other children but id: position, or after the
+// last one when position is 0 or beyond it. It shifts the children from
+// there on to make room.
+func (st *CatalogStore) treeSlot(parentID *int, position, id int) (string, int, int, error) {
+	path, depth := "/", 0
+	if parentID != nil {
+		parent, ok := st.s.categories[*parentID]
+		if !ok {
+			return "", 0, 0, &store.InvalidRefError{Field: "parent_id"}
+		}
+		path, depth = parent.Path, parent.Depth+1
+	}
+
+	siblings := slices.DeleteFunc(st.children(parentID), func(c models.Category) bool { return c.ID == id })
+	if position < 1 || position > len(siblings) {
+		return path, depth, len(siblings) + 1, nil
+	}
+	for _, sibling := range siblings[position-1:] {
+		sibling.Position++
+		st.s.categories[sibling.ID] = sibling
+	}
+	return path, depth, position, nil
+}
+
+// closeSlot moves the children of parentID after position up by one.
+func (st *CatalogStore) closeSlot(parentID *int, position int) {
+	for _, sibling := range st.children(parentID) {
+		if sibling.Position > position {
+			sibling.Position--
+			st.s.categories[sibling.ID] = sibling
+		}
+	}
+}
+
+// children returns the children of parentID (nil for the top level) in
+// order.
+func (st *CatalogStore) children(parentID *int) []models.Category {
+	var children []models.Category
+	for _, category := range st.s.categories {
+		if sameParent(category.ParentID, parentID) {
+			children = append(children, category)
+		}
+	}
+	slices.SortFunc(children, func(a, b models.Category) int { return cmp.Compare(a.Position, b.Position) })
+	return children
+}
+
+// withCategoryCounts returns the category with the product counts of its
+// subtree.
+func (st *CatalogStore) withCategoryCounts(category models.Category) models.Category {
+	category.ProductsCount, category.TotalProductsCount = 0, 0
+	for _, product := range st.s.products {
+		if strings.HasPrefix(st.s.categories[product.CategoryID].Path, category.Path) {
+			category.TotalProductsCount++
+			if product.Status == 1 {
+				category.ProductsCount++
+			}
+		}
+	}
+	return category
+}
+
+func sameParent(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (st *CatalogStore) CreateCategory(ctx context.Context, category models.Category) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	parentPath, depth, position, err := st.treeSlot(category.ParentID, category.Position, 0)
+	if err != nil {
+		return 0, err
+	}
+	category.ID = st.s.nextID("categories")
+	category.Path = parentPath + strconv.Itoa(category.ID) + "/"
+	category.Depth = depth
+	category.Position = position
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+	category.Children, category.Breadcrumbs = nil, nil
+	st.s.categories[category.ID] = category
+	st.s.audit(ctx, store.AuditCreate, store.EntityCategory, category.ID, nil, category)
+	return category.ID, nil
+}
+
+func (st *CatalogStore) ListCategories(ctx context.Context) ([]models.Category, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var categories []models.Category
+	for _, id := range sortedIDs(st.s.categories) {
+		categories = append(categories, st.withCategoryCounts(st.s.categories[id]))
+	}
+	slices.SortStableFunc(categories, func(a, b models.Category) int {
+		return cmp.Or(cmp.Compare(a.Depth, b.Depth), cmp.Compare(a.Position, b.Position))
+	})
+	return categories, nil
+}
+
+func (st *CatalogStore) GetCategory(ctx context.Context, id int) (models.Category, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	category, ok := st.s.categories[id]
+	if !ok {
+		return models.Category{}, store.ErrNotFound
+	}
+	category = st.withCategoryCounts(category)
+	category.Children = []models.Category{}
+	for _, child := range st.children(&id) {
+		category.Children = append(category.Children, st.withCategoryCounts(child))
+	}
+	category.Breadcrumbs = []models.CategoryCrumb{}
+	for parentID := category.ParentID; parentID != nil; {
+		parent := st.s.categories[*parentID]
+		crumb := models.CategoryCrumb{ID: parent.ID, CategoryName: parent.CategoryName}
+		category.Breadcrumbs = append([]models.CategoryCrumb{crumb}, category.Breadcrumbs...)
+		parentID = parent.ParentID
+	}
+	return category, nil
+}
+
+func (st *CatalogStore) UpdateCategory(ctx context.Context, category models.Category) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	current, ok := st.s.categories[category.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	category.ParentID, category.Path = current.ParentID, current.Path
+	category.Depth, category.Position = current.Depth, current.Position
+	category.CreatedBy = current.CreatedBy
+	category.CreatedAt = current.CreatedAt
+	category.UpdatedAt = time.Now()
+	category.Children, category.Breadcrumbs = nil, nil
+	st.s.categories[category.ID] = category
+	st.s.audit(ctx, store.AuditUpdate, store.EntityCategory, category.ID, current, category)
+	return nil
+}
+
+func (st *CatalogStore) MoveCategory(ctx context.Context, id int, parentID *int, position int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.categories[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if parentID != nil {
+		parent, ok := st.s.categories[*parentID]
+		if !ok {
+			return &store.InvalidRefError{Field: "parent_id"}
+		}
+		if strings.HasPrefix(parent.Path, before.Path) {
+			return store.ErrConflict
+		}
+	}
+
+	// close the gap the category leaves, then make room among the new siblings
+	st.closeSlot(before.ParentID, before.Position)
+	parentPath, depth, position, err := st.treeSlot(parentID, position, id)
+	if err != nil {
+		return err
+	}
+
+	newPath := parentPath + strconv.Itoa(id) + "/"
+	for _, category := range st.s.categories {
+		if strings.HasPrefix(category.Path, before.Path) {
+			category.Path = newPath + category.Path[len(before.Path):]
+			category.Depth += depth - before.Depth
+			st.s.categories[category.ID] = category
+		}
+	}
+	current := st.s.categories[id]
+	current.ParentID, current.Position = parentID, position
+	st.s.categories[id] = current
+	st.s.audit(ctx, store.AuditMove, store.EntityCategory, id, before, current)
+	return nil
+}
+
+func (st *CatalogStore) DeleteCategory(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	category, ok := st.s.categories[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	if len(st.children(&id)) > 0 {
+		return store.ErrConflict
+	}
+	for _, product := range st.s.products {
+		if product.CategoryID == id {
+			return store.ErrConflict
+		}
+	}
+	delete(st.s.categories, id)
+	st.closeSlot(category.ParentID, category.Position)
+	st.s.audit(ctx, store.AuditDelete, store.EntityCategory, id, category, nil)
+	return nil
+}
+
+func (st *CatalogStore) CreateAttribute(ctx context.Context, attr models.Attribute) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	attr.ID = st.s.nextID("attributes")
+	attr.AttributeValues = nil
+	st.s.attributes[attr.ID] = attr
+	st.s.audit(ctx, store.AuditCreate, store.EntityAttribute, attr.ID, nil, attr)
+	return attr.ID, nil
+}
+
+// withActiveValues returns the attribute together with its active values.
+func (st *CatalogStore) withActiveValues(attr models.Attribute) models.Attribute {
+	attr.AttributeValues = nil
+	for _, id := range sortedIDs(st.s.attributeValues) {
+		if val := st.s.attributeValues[id]; val.AttributeID == attr.ID && val.Status == 1 {
+			attr.AttributeValues = append(attr.AttributeValues, st.withUsage(val))
+		}
+	}
+	return attr
+}
+
+func (st *CatalogStore) ListAttributes(ctx context.Context) ([]models.Attribute, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var attributes []models.Attribute
+	for _, id := range sortedIDs(st.s.attributes) {
+		if attr := st.s.attributes[id]; attr.Status == 1 {
+			attributes = append(attributes, st.withActiveValues(attr))
+		}
+	}
+	return attributes, nil
+}
+
+func (st *CatalogStore) GetAttribute(ctx context.Context, id int) (models.Attribute, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	attr, ok := st.s.attributes[id]
+	if !ok || attr.Status != 1 {
+		return models.Attribute{}, store.ErrNotFound
+	}
+	return st.withActiveValues(attr), nil
+}
+
+func (st *CatalogStore) UpdateAttribute(ctx context.Context, attr models.Attribute) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.attributes[attr.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	current := before
+	current.AttributeName = attr.AttributeName
+	current.Status = attr.Status
+	st.s.attributes[attr.ID] = current
+	st.s.audit(ctx, store.AuditUpdate, store.EntityAttribute, attr.ID, before, current)
+	return nil
+}
+
+func (st *CatalogStore) DeleteAttribute(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.attributes[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	attr := before
+	attr.Status = 0
+	st.s.attributes[id] = attr
+	st.s.audit(ctx, store.AuditDelete, store.EntityAttribute, id, before, attr)
+	return nil
+}
+
+func (st *CatalogStore) CreateAttributeValue(ctx context.Context, value models.AttributeValue) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.attributes[value.AttributeID]; !ok {
+		return 0, &store.InvalidRefError{Field: "attribute_id"}
+	}
+	value.ID = st.s.nextID("attribute_values")
+	st.s.attributeValues[value.ID] = value
+	st.s.audit(ctx, store.AuditCreate, store.EntityAttributeValue, value.ID, nil, value)
+	return value.ID, nil
+}
+
+func (st *CatalogStore) ListAttributeValues(ctx context.Context, attributeID int) ([]models.AttributeValue, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var values []models.AttributeValue
+	for _, id := range sortedIDs(st.s.attributeValues) {
+		if val := st.s.attributeValues[id]; val.AttributeID == attributeID {
+			values = append(values, st.withUsage(val))
+		}
+	}
+	return values, nil
+}
+
+func (st *CatalogStore) GetAttributeValue(ctx context.Context, id int) (models.AttributeValue, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	val, ok := st.s.attributeValues[id]
+	if !ok {
+		return models.AttributeValue{}, store.ErrNotFound
+	}
+	return st.withUsage(val), nil
+}
+
+// withUsage returns the attribute value with the number of products that
+// use it.
+func (st *CatalogStore) withUsage(val models.AttributeValue) models.AttributeValue {
+	products := make(map[int]bool)
+	for _, attr := range st.s.productAttributes {
+		if attr.AttributeValueID == val.ID {
+			products[attr.ProductID] = true
+		}
+	}
+	val.UsageCount = len(products)
+	return val
+}
+
+func (st *CatalogStore) UpdateAttributeValue(ctx context.Context, value models.AttributeValue) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.attributeValues[value.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	current := before
+	current.Value = value.Value
+	current.Status = value.Status
+	st.s.attributeValues[value.ID] = current
+	st.s.audit(ctx, store.AuditUpdate, store.EntityAttributeValue, value.ID, before, current)
+	return nil
+}
+
+func (st *CatalogStore) DeleteAttributeValue(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	value, ok := st.s.attributeValues[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, pa := range st.s.productAttributes {
+		if pa.AttributeValueID == id {
+			return errReferenced
+		}
+	}
+	delete(st.s.attributeValues, id)
+	st.s.audit(ctx, store.AuditDelete, store.EntityAttributeValue, id, value, nil)
+	return nil
+}
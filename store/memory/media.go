@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type MediaStore struct {
+	s *state
+}
+
+func (st *MediaStore) Create(ctx context.Context, media models.Media) (models.Media, bool, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	for _, existing := range st.s.media {
+		if existing.Hash == media.Hash {
+			return existing, false, nil
+		}
+	}
+	media.ID = st.s.nextID("media")
+	media.URL = store.MediaURL(media.ID)
+	media.CreatedAt = time.Now()
+	st.s.media[media.ID] = media
+	st.s.audit(ctx, store.AuditCreate, store.EntityMedia, media.ID, nil, media)
+	return media, true, nil
+}
+
+func (st *MediaStore) Get(ctx context.Context, id int) (models.Media, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	media, ok := st.s.media[id]
+	if !ok {
+		return models.Media{}, store.ErrNotFound
+	}
+	return media, nil
+}
+
+func (st *MediaStore) List(ctx context.Context, limit, offset int) ([]models.Media, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	ids := sortedIDs(st.s.media)
+	// IDs follow upload order, so walk them backwards for newest first
+	slices.Reverse(ids)
+	list := []models.Media{}
+	for i := offset; i < len(ids) && len(list) < limit; i++ {
+		list = append(list, st.s.media[ids[i]])
+	}
+	return list, nil
+}
+
+func (st *MediaStore) Delete(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	media, ok := st.s.media[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	for _, mediaIDs := range st.s.productImages {
+		if slices.Contains(mediaIDs, id) {
+			return store.ErrConflict
+		}
+	}
+	delete(st.s.media, id)
+	st.s.audit(ctx, store.AuditDelete, store.EntityMedia, id, media, nil)
+	return nil
+}
+
+func (st *MediaStore) SetProductImages(ctx context.Context, productID int, mediaIDs []int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	product, ok := st.s.products[productID]
+	if !ok {
+		return &store.InvalidRefError{Field: "product_id"}
+	}
+	for _, id := range mediaIDs {
+		if _, ok := st.s.media[id]; !ok {
+			return &store.InvalidRefError{Field: "media_id"}
+		}
+	}
+
+	before := product
+	before.Images = st.s.imagesOf(productID)
+	st.s.productImages[productID] = slices.Clone(mediaIDs)
+	product.Image = ""
+	if len(mediaIDs) > 0 {
+		product.Image = store.MediaURL(mediaIDs[0])
+	}
+	st.s.products[productID] = product
+	product.Images = st.s.imagesOf(productID)
+	st.s.audit(ctx, store.AuditUpdate, store.EntityProduct, productID, before, product)
+	return nil
+}
+
+// imagesOf returns the gallery of a product in order.
+func (s *state) imagesOf(productID int) []models.ProductImage {
+	var images []models.ProductImage
+	for i, id := range s.productImages[productID] {
+		images = append(images, models.ProductImage{MediaID: id, Position: i + 1, URL: store.MediaURL(id)})
+	}
+	return images
+}
@@ -0,0 +1,171 @@
+// store/memory/memory.go
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+// New returns in-memory implementations of every store sharing one data
+// set, for handler tests and running without a database. Cascades, unique
+// keys and derived values (default addresses, product ratings) follow the
+// Postgres schema.
+func New() *store.Stores {
+	s := &state{
+		seq:               make(map[string]int),
+		users:             make(map[int]userRecord),
+		sessions:          make(map[int]models.LoginSession),
+		addresses:         make(map[int]models.Address),
+		legacyAddressIDs:  make(map[legacyAddressID]int),
+		brands:            make(map[int]models.Brand),
+		categories:        make(map[int]models.Category),
+		attributes:        make(map[int]models.Attribute),
+		attributeValues:   make(map[int]models.AttributeValue),
+		products:          make(map[int]models.Product),
+		productAttributes: make(map[int]models.ProductAttribute),
+		variations:        make(map[int]models.VariationProduct),
+		reviews:           make(map[int]models.ProductReview),
+		votes:             make(map[reviewVote]bool),
+		orders:            make(map[int]models.Order),
+		cartItems:         make(map[int]models.CartItem),
+		zones:             make(map[int]models.ShippingZone),
+		methods:           make(map[int]models.ShippingMethod),
+		wishlists:         make(map[int]models.Wishlist),
+		wishlistItems:     make(map[int]models.WishlistItem),
+		notifications:     make(map[int]models.WishlistNotification),
+		media:             make(map[int]models.Media),
+		productImages:     make(map[int][]int),
+	}
+	return &store.Stores{
+		Users:     &UserStore{s},
+		Sessions:  &SessionStore{s},
+		Addresses: &AddressStore{s},
+		Catalog:   &CatalogStore{s},
+		Products:  &ProductStore{s},
+		Reviews:   &ReviewStore{s},
+		Orders:    &OrderStore{s},
+		Carts:     &CartStore{s},
+		Shipping:  &ShippingStore{s},
+		Wishlists: &WishlistStore{s},
+		Audit:     &AuditStore{s},
+		Media:     &MediaStore{s},
+	}
+}
+
+// errReferenced mirrors a Postgres foreign key violation on delete: the row
+// is still referenced by a table without ON DELETE CASCADE.
+var errReferenced = errors.New("memory: row is still referenced")
+
+// state holds every table. All stores share it and take mu for the whole
+// of each method, which makes multi-row changes atomic like a transaction.
+type state struct {
+	mu  sync.Mutex
+	seq map[string]int
+
+	users          map[int]userRecord
+	sessions       map[int]models.LoginSession
+	impersonations []models.ImpersonationRequest
+
+	addresses map[int]models.Address
+	// legacyAddressIDs maps the IDs of the old shipping and billing
+	// addresses to the addresses they became
+	legacyAddressIDs map[legacyAddressID]int
+
+	brands          map[int]models.Brand
+	categories      map[int]models.Category
+	attributes      map[int]models.Attribute
+	attributeValues map[int]models.AttributeValue
+
+	products          map[int]models.Product
+	productAttributes map[int]models.ProductAttribute
+	variations        map[int]models.VariationProduct
+
+	reviews map[int]models.ProductReview
+	votes   map[reviewVote]bool
+
+	orders map[int]models.Order
+
+	cartItems map[int]models.CartItem
+
+	zones   map[int]models.ShippingZone
+	methods map[int]models.ShippingMethod
+
+	wishlists     map[int]models.Wishlist
+	wishlistItems map[int]models.WishlistItem
+	notifications map[int]models.WishlistNotification
+
+	auditLog []models.AuditEntry
+
+	media         map[int]models.Media
+	productImages map[int][]int // product ID to media IDs in order
+}
+
+// nextID returns the next serial value of table.
+func (s *state) nextID(table string) int {
+	s.seq[table]++
+	return s.seq[table]
+}
+
+// sortedIDs returns the keys of a table in ascending order.
+func sortedIDs[T any](table map[int]T) []int {
+	return slices.Sorted(maps.Keys(table))
+}
+
+// owns reports whether a row of userID passes the ownership check of a
+// method that was given owner (store.AnyUser skips it).
+func owns(owner, userID int) bool {
+	return owner == store.AnyUser || owner == userID
+}
+
+// eraseUser anonymizes the user and removes their personal rows, like the
+// Postgres store's erasure. Reviews and votes stay with the anonymized user.
+func (s *state) eraseUser(id int, now time.Time) {
+	rec := s.users[id]
+	rec.user.Username = fmt.Sprintf("deleted-%d", id)
+	rec.user.Email = fmt.Sprintf("deleted-%d@deleted.invalid", id)
+	rec.passwordHash = ""
+	rec.user.PhoneNumber = nil
+	rec.user.Image = nil
+	rec.user.IsVerified = false
+	rec.user.IsBlocked = true
+	if rec.user.DeletedAt == nil {
+		rec.user.DeletedAt = &now
+	}
+	rec.user.ErasedAt = &now
+	rec.user.UpdatedAt = now
+	s.users[id] = rec
+	s.scrubAudit(id)
+
+	for sid, session := range s.sessions {
+		if session.UserID == id {
+			delete(s.sessions, sid)
+		}
+	}
+	for aid, addr := range s.addresses {
+		if addr.UserID == id {
+			delete(s.addresses, aid)
+		}
+	}
+	for cid, item := range s.cartItems {
+		if item.UserID == id {
+			delete(s.cartItems, cid)
+		}
+	}
+	for nid, n := range s.notifications {
+		if n.UserID == id {
+			delete(s.notifications, nid)
+		}
+	}
+	for wid, w := range s.wishlists {
+		if w.UserID == id {
+			s.deleteWishlist(wid)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"testing"
+
+	"my-api/store"
+	"my-api/store/storetest"
+)
+
+func TestContract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) *store.Stores { return New() })
+}
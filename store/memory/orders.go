@@ -0,0 +1,153 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type OrderStore struct {
+	s *state
+}
+
+// orderStatuses are the statuses the orders table allows.
+var orderStatuses = []string{"placed", "paid", "shipped", "delivered", "cancelled"}
+
+// insertOrder stores order with new IDs for it and its items.
+func (s *state) insertOrder(order models.Order) int {
+	now := time.Now()
+	order.ID = s.nextID("orders")
+	order.Items = slices.Clone(order.Items)
+	for i := range order.Items {
+		order.Items[i].ID = s.nextID("order_items")
+	}
+	order.Total = 0
+	order.CreatedAt, order.UpdatedAt = now, now
+	s.orders[order.ID] = order
+	return order.ID
+}
+
+// withTotal returns a copy of order, with its total, that callers may
+// change.
+func withTotal(order models.Order) models.Order {
+	order.Items = slices.Clone(order.Items)
+	if order.Items == nil {
+		order.Items = []models.OrderItem{}
+	}
+	for _, item := range order.Items {
+		order.Total += float64(item.Quantity) * item.UnitPrice
+	}
+	return order
+}
+
+func (st *OrderStore) Checkout(ctx context.Context, userID int) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.users[userID]; !ok {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	order := models.Order{UserID: userID, Status: "placed"}
+	for _, id := range sortedIDs(st.s.cartItems) {
+		item := st.s.cartItems[id]
+		if item.UserID != userID {
+			continue
+		}
+		item = st.s.withProduct(item)
+		order.Items = append(order.Items, models.OrderItem{
+			ProductID:   item.ProductID,
+			VariationID: item.VariationID,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		})
+		delete(st.s.cartItems, id)
+	}
+	if len(order.Items) == 0 {
+		return 0, &store.CheckError{Field: "items"}
+	}
+	return st.s.insertOrder(order), nil
+}
+
+func (st *OrderStore) Create(ctx context.Context, order models.Order) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if order.Status == "" {
+		order.Status = "placed"
+	}
+	if !slices.Contains(orderStatuses, order.Status) {
+		return 0, &store.CheckError{Field: "status"}
+	}
+	if _, ok := st.s.users[order.UserID]; !ok {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	for _, item := range order.Items {
+		if _, ok := st.s.products[item.ProductID]; !ok {
+			return 0, &store.InvalidRefError{Field: "product_id"}
+		}
+		if item.VariationID != nil {
+			if _, ok := st.s.variations[*item.VariationID]; !ok {
+				return 0, &store.InvalidRefError{Field: "variation_id"}
+			}
+		}
+		if item.Quantity < 1 {
+			return 0, &store.CheckError{Field: "quantity"}
+		}
+	}
+	return st.s.insertOrder(order), nil
+}
+
+func (st *OrderStore) List(ctx context.Context, filter store.OrderFilter) ([]models.Order, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var matching []models.Order
+	for _, order := range st.s.orders {
+		if filter.UserID == 0 || order.UserID == filter.UserID {
+			matching = append(matching, order)
+		}
+	}
+	slices.SortFunc(matching, func(a, b models.Order) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	orders := []models.Order{}
+	for i := filter.Offset; i < len(matching) && len(orders) < filter.Limit; i++ {
+		orders = append(orders, withTotal(matching[i]))
+	}
+	return orders, nil
+}
+
+func (st *OrderStore) Get(ctx context.Context, userID, id int) (models.Order, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	order, ok := st.s.orders[id]
+	if !ok || !owns(userID, order.UserID) {
+		return models.Order{}, store.ErrNotFound
+	}
+	return withTotal(order), nil
+}
+
+func (st *OrderStore) HasPurchased(ctx context.Context, userID, productID int) (bool, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.products[productID]; !ok {
+		return false, store.ErrNotFound
+	}
+	for _, order := range st.s.orders {
+		if order.UserID != userID || order.Status == "cancelled" {
+			continue
+		}
+		if slices.ContainsFunc(order.Items, func(item models.OrderItem) bool { return item.ProductID == productID }) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
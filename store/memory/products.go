@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type ProductStore struct {
+	s *state
+}
+
+func (st *ProductStore) Create(ctx context.Context, product models.Product, attributes []models.ProductAttribute, variations []models.VariationProduct) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.brands[product.BrandID]; product.BrandID != 0 && !ok {
+		return 0, &store.InvalidRefError{Field: "brand_id"}
+	}
+	if _, ok := st.s.categories[product.CategoryID]; !ok {
+		return 0, &store.InvalidRefError{Field: "category_id"}
+	}
+	for _, attr := range attributes {
+		if _, ok := st.s.attributes[attr.AttributeID]; !ok {
+			return 0, &store.InvalidRefError{Field: "attribute_id"}
+		}
+		if val, ok := st.s.attributeValues[attr.AttributeValueID]; !ok || val.AttributeID != attr.AttributeID {
+			return 0, &store.InvalidRefError{Field: "attribute_value_id"}
+		}
+	}
+	skus := make(map[string]bool)
+	for _, variation := range variations {
+		if skus[variation.SKU] {
+			return 0, &store.ConflictError{Field: "sku"}
+		}
+		skus[variation.SKU] = true
+	}
+
+	// everything is validated up front so nothing has to be rolled back
+	product.ID = st.s.nextID("products")
+	product.Rating = 0
+	product.RatingCount = 0
+	st.s.products[product.ID] = product
+
+	for _, attr := range attributes {
+		attr.ID = st.s.nextID("product_attributes")
+		attr.ProductID = product.ID
+		st.s.productAttributes[attr.ID] = attr
+	}
+	for _, variation := range variations {
+		variation.ID = st.s.nextID("variation_products")
+		variation.ProductID = product.ID
+		st.s.variations[variation.ID] = variation
+	}
+	st.s.audit(ctx, store.AuditCreate, store.EntityProduct, product.ID, nil, st.s.products[product.ID])
+	return product.ID, nil
+}
+
+func (st *ProductStore) Get(ctx context.Context, id int) (models.Product, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	product, ok := st.s.products[id]
+	if !ok {
+		return models.Product{}, store.ErrNotFound
+	}
+	product.Images = st.s.imagesOf(id)
+	return product, nil
+}
+
+func (st *ProductStore) List(ctx context.Context) ([]models.Product, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var products []models.Product
+	for _, id := range sortedIDs(st.s.products) {
+		products = append(products, st.s.products[id])
+	}
+	return products, nil
+}
+
+func (st *ProductStore) ListInCategory(ctx context.Context, categoryID int) ([]models.Product, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	category, ok := st.s.categories[categoryID]
+	if !ok {
+		return nil, nil
+	}
+	var products []models.Product
+	for _, id := range sortedIDs(st.s.products) {
+		product := st.s.products[id]
+		if strings.HasPrefix(st.s.categories[product.CategoryID].Path, category.Path) {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,264 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type ReviewStore struct {
+	s *state
+}
+
+type reviewVote struct {
+	reviewID int
+	userID   int
+}
+
+// reviewOrders compares reviews for each storefront sort; ties go to the
+// most recent review.
+var reviewOrders = map[store.ReviewSort]func(a, b models.ProductReview) int{
+	store.ReviewSortRecent: func(a, b models.ProductReview) int { return 0 },
+	store.ReviewSortHelpful: func(a, b models.ProductReview) int {
+		return b.HelpfulCount - a.HelpfulCount
+	},
+	store.ReviewSortRatingHigh: func(a, b models.ProductReview) int { return b.Rating - a.Rating },
+	store.ReviewSortRatingLow:  func(a, b models.ProductReview) int { return a.Rating - b.Rating },
+}
+
+func newestFirst(a, b models.ProductReview) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return b.ID - a.ID
+}
+
+// refreshProductRating recomputes the product's rating average and count
+// from its approved reviews.
+func (s *state) refreshProductRating(productID int) {
+	product, ok := s.products[productID]
+	if !ok {
+		return
+	}
+	var sum, count int
+	for _, review := range s.reviews {
+		if review.ProductID == productID && review.Status == "approved" {
+			sum += review.Rating
+			count++
+		}
+	}
+	product.Rating = 0
+	if count > 0 {
+		product.Rating = math.Round(float64(sum)/float64(count)*100) / 100
+	}
+	product.RatingCount = count
+	s.products[productID] = product
+}
+
+func (s *state) recountVotes(reviewID int) {
+	review, ok := s.reviews[reviewID]
+	if !ok {
+		return
+	}
+	review.HelpfulCount, review.NotHelpfulCount = 0, 0
+	for vote, helpful := range s.votes {
+		if vote.reviewID != reviewID {
+			continue
+		}
+		if helpful {
+			review.HelpfulCount++
+		} else {
+			review.NotHelpfulCount++
+		}
+	}
+	s.reviews[reviewID] = review
+}
+
+// deleteReview removes the review and its votes; the caller refreshes the
+// product rating.
+func (s *state) deleteReview(id int) {
+	delete(s.reviews, id)
+	for vote := range s.votes {
+		if vote.reviewID == id {
+			delete(s.votes, vote)
+		}
+	}
+}
+
+// withUsername fills in the author's username as the Postgres join does.
+func (st *ReviewStore) withUsername(review models.ProductReview) models.ProductReview {
+	review.Username = st.s.users[review.UserID].user.Username
+	return review
+}
+
+// page sorts reviews and applies limit and offset.
+func (st *ReviewStore) page(reviews []models.ProductReview, cmp func(a, b models.ProductReview) int, limit, offset int) []models.ProductReview {
+	slices.SortFunc(reviews, func(a, b models.ProductReview) int {
+		if c := cmp(a, b); c != 0 {
+			return c
+		}
+		return newestFirst(a, b)
+	})
+	page := []models.ProductReview{}
+	for i := offset; i < len(reviews) && len(page) < limit; i++ {
+		page = append(page, st.withUsername(reviews[i]))
+	}
+	return page
+}
+
+func (st *ReviewStore) RatingSummary(ctx context.Context, productID int) (store.RatingSummary, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	summary := store.RatingSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	product, ok := st.s.products[productID]
+	if !ok {
+		return summary, store.ErrNotFound
+	}
+	summary.Rating = product.Rating
+	summary.RatingCount = product.RatingCount
+	for _, review := range st.s.reviews {
+		if review.ProductID == productID && review.Status == "approved" {
+			summary.Distribution[review.Rating]++
+		}
+	}
+	return summary, nil
+}
+
+func (st *ReviewStore) ListApproved(ctx context.Context, productID int, sort store.ReviewSort, limit, offset int) ([]models.ProductReview, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	cmp, ok := reviewOrders[sort]
+	if !ok {
+		cmp = reviewOrders[store.ReviewSortRecent]
+	}
+	var reviews []models.ProductReview
+	for _, review := range st.s.reviews {
+		if review.ProductID == productID && review.Status == "approved" {
+			reviews = append(reviews, review)
+		}
+	}
+	return st.page(reviews, cmp, limit, offset), nil
+}
+
+func (st *ReviewStore) List(ctx context.Context, filter store.ReviewFilter) ([]models.ProductReview, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var reviews []models.ProductReview
+	for _, review := range st.s.reviews {
+		if (filter.Status == "" || review.Status == filter.Status) &&
+			(filter.ProductID == 0 || review.ProductID == filter.ProductID) &&
+			(filter.UserID == 0 || review.UserID == filter.UserID) {
+			reviews = append(reviews, review)
+		}
+	}
+	return st.page(reviews, reviewOrders[store.ReviewSortRecent], filter.Limit, filter.Offset), nil
+}
+
+func (st *ReviewStore) Create(ctx context.Context, review models.ProductReview) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.products[review.ProductID]; !ok {
+		return 0, &store.InvalidRefError{Field: "product_id"}
+	}
+	if _, ok := st.s.users[review.UserID]; !ok {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	for _, other := range st.s.reviews {
+		if other.ProductID == review.ProductID && other.UserID == review.UserID {
+			return 0, &store.ConflictError{Field: "product_id"}
+		}
+	}
+
+	review.ID = st.s.nextID("product_reviews")
+	review.Status = "pending"
+	review.HelpfulCount, review.NotHelpfulCount = 0, 0
+	review.ModeratedBy, review.ModeratedAt = nil, nil
+	review.CreatedAt = time.Now()
+	review.UpdatedAt = review.CreatedAt
+	st.s.reviews[review.ID] = review
+	return review.ID, nil
+}
+
+func (st *ReviewStore) Update(ctx context.Context, review models.ProductReview) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.reviews[review.ID]
+	if !ok || before.UserID != review.UserID {
+		return store.ErrNotFound
+	}
+	current := before
+	current.Rating = review.Rating
+	current.Title = review.Title
+	current.Body = review.Body
+	current.Status = "pending"
+	current.ModeratedBy, current.ModeratedAt = nil, nil
+	current.UpdatedAt = time.Now()
+	st.s.reviews[review.ID] = current
+	st.s.refreshProductRating(current.ProductID)
+	st.s.audit(ctx, store.AuditUpdate, store.EntityReview, review.ID, before, current)
+	return nil
+}
+
+func (st *ReviewStore) Delete(ctx context.Context, userID, reviewID int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	review, ok := st.s.reviews[reviewID]
+	if !ok || !owns(userID, review.UserID) {
+		return store.ErrNotFound
+	}
+	st.s.deleteReview(reviewID)
+	st.s.refreshProductRating(review.ProductID)
+	st.s.audit(ctx, store.AuditDelete, store.EntityReview, reviewID, review, nil)
+	return nil
+}
+
+func (st *ReviewStore) Moderate(ctx context.Context, reviewID int, status string, moderatorID int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.reviews[reviewID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	now := time.Now()
+	review := before
+	review.Status = status
+	review.ModeratedBy = &moderatorID
+	review.ModeratedAt = &now
+	review.UpdatedAt = now
+	st.s.reviews[reviewID] = review
+	st.s.refreshProductRating(review.ProductID)
+	st.s.audit(ctx, store.AuditModerate, store.EntityReview, reviewID, before, review)
+	return nil
+}
+
+func (st *ReviewStore) Vote(ctx context.Context, userID, reviewID int, helpful bool) (int, int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	review, ok := st.s.reviews[reviewID]
+	if !ok || review.Status != "approved" {
+		return 0, 0, store.ErrNotFound
+	}
+	if review.UserID == userID {
+		return 0, 0, store.ErrForbidden
+	}
+	if _, ok := st.s.users[userID]; !ok {
+		return 0, 0, &store.InvalidRefError{Field: "user_id"}
+	}
+
+	st.s.votes[reviewVote{reviewID: reviewID, userID: userID}] = helpful
+	st.s.recountVotes(reviewID)
+	review = st.s.reviews[reviewID]
+	return review.HelpfulCount, review.NotHelpfulCount, nil
+}
@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type ShippingStore struct {
+	s *state
+}
+
+// copyZone returns the zone with its methods, detached from the tables.
+func (st *ShippingStore) copyZone(zone models.ShippingZone, activeOnly bool) models.ShippingZone {
+	zone.Countries = slices.Clone(zone.Countries)
+	zone.PostalCodePatterns = slices.Clone(zone.PostalCodePatterns)
+	zone.Methods = []models.ShippingMethod{}
+	for _, id := range sortedIDs(st.s.methods) {
+		method := st.s.methods[id]
+		if method.ZoneID != zone.ID || (activeOnly && method.Status != 1) {
+			continue
+		}
+		method.Tiers = slices.Clone(method.Tiers)
+		zone.Methods = append(zone.Methods, method)
+	}
+	return zone
+}
+
+// storeTiers numbers the method's tiers and orders them by min_value.
+func (st *ShippingStore) storeTiers(methodID int, tiers []models.ShippingRateTier) []models.ShippingRateTier {
+	stored := make([]models.ShippingRateTier, 0, len(tiers))
+	for _, tier := range tiers {
+		tier.ID = st.s.nextID("shipping_rate_tiers")
+		tier.MethodID = methodID
+		stored = append(stored, tier)
+	}
+	slices.SortStableFunc(stored, func(a, b models.ShippingRateTier) int {
+		return cmp.Compare(a.MinValue, b.MinValue)
+	})
+	return stored
+}
+
+func (st *ShippingStore) CreateZone(ctx context.Context, zone models.ShippingZone) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	zone.ID = st.s.nextID("shipping_zones")
+	zone.Countries = slices.Clone(zone.Countries)
+	zone.PostalCodePatterns = slices.Clone(zone.PostalCodePatterns)
+	zone.Methods = nil
+	zone.CreatedAt = time.Now()
+	zone.UpdatedAt = zone.CreatedAt
+	st.s.zones[zone.ID] = zone
+	st.s.audit(ctx, store.AuditCreate, store.EntityShippingZone, zone.ID, nil, zone)
+	return zone.ID, nil
+}
+
+func (st *ShippingStore) ListZones(ctx context.Context, activeOnly bool) ([]models.ShippingZone, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	zones := []models.ShippingZone{}
+	for _, id := range sortedIDs(st.s.zones) {
+		zone := st.s.zones[id]
+		if activeOnly && zone.Status != 1 {
+			continue
+		}
+		zones = append(zones, st.copyZone(zone, activeOnly))
+	}
+	slices.SortStableFunc(zones, func(a, b models.ShippingZone) int {
+		return cmp.Compare(a.Position, b.Position)
+	})
+	return zones, nil
+}
+
+func (st *ShippingStore) GetZone(ctx context.Context, id int) (models.ShippingZone, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	zone, ok := st.s.zones[id]
+	if !ok {
+		return models.ShippingZone{}, store.ErrNotFound
+	}
+	return st.copyZone(zone, false), nil
+}
+
+func (st *ShippingStore) UpdateZone(ctx context.Context, zone models.ShippingZone) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.zones[zone.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	current := before
+	current.ZoneName = zone.ZoneName
+	current.Countries = slices.Clone(zone.Countries)
+	current.PostalCodePatterns = slices.Clone(zone.PostalCodePatterns)
+	current.Position = zone.Position
+	current.Status = zone.Status
+	current.UpdatedAt = time.Now()
+	st.s.zones[zone.ID] = current
+	st.s.audit(ctx, store.AuditUpdate, store.EntityShippingZone, zone.ID, before, current)
+	return nil
+}
+
+func (st *ShippingStore) DeleteZone(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	zone, ok := st.s.zones[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	delete(st.s.zones, id)
+	for methodID, method := range st.s.methods {
+		if method.ZoneID == id {
+			delete(st.s.methods, methodID)
+		}
+	}
+	st.s.audit(ctx, store.AuditDelete, store.EntityShippingZone, id, zone, nil)
+	return nil
+}
+
+func (st *ShippingStore) CreateMethod(ctx context.Context, method models.ShippingMethod) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.zones[method.ZoneID]; !ok {
+		return 0, &store.InvalidRefError{Field: "zone_id"}
+	}
+	method.ID = st.s.nextID("shipping_methods")
+	method.Tiers = st.storeTiers(method.ID, method.Tiers)
+	method.CreatedAt = time.Now()
+	st.s.methods[method.ID] = method
+	st.s.audit(ctx, store.AuditCreate, store.EntityShippingMethod, method.ID, nil, method)
+	return method.ID, nil
+}
+
+func (st *ShippingStore) UpdateMethod(ctx context.Context, method models.ShippingMethod) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	before, ok := st.s.methods[method.ID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	current := before
+	current.MethodName = method.MethodName
+	current.RateType = method.RateType
+	current.BaseRate = method.BaseRate
+	current.FreeShippingThreshold = method.FreeShippingThreshold
+	current.EstimatedDays = method.EstimatedDays
+	current.Status = method.Status
+	current.Tiers = st.storeTiers(method.ID, method.Tiers)
+	st.s.methods[method.ID] = current
+	st.s.audit(ctx, store.AuditUpdate, store.EntityShippingMethod, method.ID, before, current)
+	return nil
+}
+
+func (st *ShippingStore) DeleteMethod(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	method, ok := st.s.methods[id]
+	if !ok {
+		return store.ErrNotFound
+	}
+	delete(st.s.methods, id)
+	st.s.audit(ctx, store.AuditDelete, store.EntityShippingMethod, id, method, nil)
+	return nil
+}
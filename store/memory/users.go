@@ -0,0 +1,401 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type userRecord struct {
+	user         models.User
+	passwordHash string
+}
+
+type UserStore struct {
+	s *state
+}
+
+// conflict returns the unique key of users that user would violate.
+func (st *UserStore) conflict(user models.User) error {
+	for _, rec := range st.s.users {
+		if rec.user.ID == user.ID {
+			continue
+		}
+		if rec.user.Username == user.Username {
+			return &store.ConflictError{Field: "username"}
+		}
+		if rec.user.Email == user.Email {
+			return &store.ConflictError{Field: "email"}
+		}
+	}
+	return nil
+}
+
+func credentials(rec userRecord) store.Credentials {
+	return store.Credentials{
+		ID:           rec.user.ID,
+		Username:     rec.user.Username,
+		Email:        rec.user.Email,
+		PasswordHash: rec.passwordHash,
+		Role:         rec.user.Role,
+		IsBlocked:    rec.user.IsBlocked,
+	}
+}
+
+func (st *UserStore) Create(ctx context.Context, user models.User, passwordHash string) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	user.ID = 0
+	if err := st.conflict(user); err != nil {
+		return 0, err
+	}
+	user.ID = st.s.nextID("users")
+	user.Password = ""
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	st.s.users[user.ID] = userRecord{user: user, passwordHash: passwordHash}
+	st.s.audit(ctx, store.AuditCreate, store.EntityUser, user.ID, nil, user)
+	return user.ID, nil
+}
+
+func (st *UserStore) Get(ctx context.Context, id int) (models.User, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.s.users[id]
+	if !ok {
+		return models.User{}, store.ErrNotFound
+	}
+	return rec.user, nil
+}
+
+// userMatches reports whether user passes every condition of f except the
+// page position and size.
+func userMatches(f store.UserFilter, user models.User) bool {
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		phone := ""
+		if user.PhoneNumber != nil {
+			phone = *user.PhoneNumber
+		}
+		if !strings.Contains(strings.ToLower(user.Username), search) &&
+			!strings.Contains(strings.ToLower(user.Email), search) &&
+			!strings.Contains(strings.ToLower(phone), search) {
+			return false
+		}
+	}
+	switch {
+	case f.Deleted != (user.DeletedAt != nil),
+		f.Role != "" && user.Role != f.Role,
+		f.IsVerified != nil && user.IsVerified != *f.IsVerified,
+		f.IsBlocked != nil && user.IsBlocked != *f.IsBlocked,
+		f.CreatedFrom != nil && user.CreatedAt.Before(*f.CreatedFrom),
+		f.CreatedTo != nil && !user.CreatedAt.Before(*f.CreatedTo):
+		return false
+	}
+	return true
+}
+
+// newestUserFirst orders users by created_at, then ID, descending.
+func newestUserFirst(a, b models.User) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return b.ID - a.ID
+}
+
+func (st *UserStore) List(ctx context.Context, filter store.UserFilter) ([]models.User, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	users := []models.User{}
+	for _, rec := range st.s.users {
+		if userMatches(filter, rec.user) {
+			users = append(users, rec.user)
+		}
+	}
+	slices.SortFunc(users, newestUserFirst)
+	if filter.After != nil {
+		after := models.User{ID: filter.After.ID, CreatedAt: filter.After.CreatedAt}
+		start, found := slices.BinarySearchFunc(users, after, newestUserFirst)
+		if found {
+			start++
+		}
+		users = users[start:]
+	}
+	if len(users) > filter.Limit {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+func (st *UserStore) Update(ctx context.Context, user models.User) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.activeUser(user.ID)
+	if !ok {
+		return store.ErrNotFound
+	}
+	if err := st.conflict(user); err != nil {
+		return err
+	}
+	user.Password = ""
+	user.CreatedAt = rec.user.CreatedAt
+	user.UpdatedAt = time.Now()
+	before := rec.user
+	rec.user = user
+	st.s.users[user.ID] = rec
+	st.s.audit(ctx, store.AuditUpdate, store.EntityUser, user.ID, before, user)
+	return nil
+}
+
+func (st *UserStore) BulkUpdate(ctx context.Context, action store.UserBulkAction, ids []int, role string) ([]store.BulkResult, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var change func(user *models.User) bool
+	switch action {
+	case store.UserBulkBlock:
+		change = func(user *models.User) bool { return setFlag(&user.IsBlocked, true) }
+	case store.UserBulkUnblock:
+		change = func(user *models.User) bool { return setFlag(&user.IsBlocked, false) }
+	case store.UserBulkVerify:
+		change = func(user *models.User) bool { return setFlag(&user.IsVerified, true) }
+	case store.UserBulkSetRole:
+		change = func(user *models.User) bool {
+			changed := user.Role != role
+			user.Role = role
+			return changed
+		}
+	default:
+		return nil, fmt.Errorf("memory: unknown bulk action %q", action)
+	}
+
+	now := time.Now()
+	results := make([]store.BulkResult, len(ids))
+	for i, id := range ids {
+		results[i] = store.BulkResult{UserID: id, Status: store.BulkNotFound}
+		rec, ok := st.activeUser(id)
+		if !ok {
+			continue
+		}
+		results[i].Status = store.BulkUnchanged
+		before := rec.user
+		if change(&rec.user) {
+			rec.user.UpdatedAt = now
+			st.s.users[id] = rec
+			results[i].Status = store.BulkUpdated
+			st.s.audit(ctx, string(action), store.EntityUser, id, before, rec.user)
+		}
+	}
+	return results, nil
+}
+
+// setFlag sets *flag to value and reports whether that changed it.
+func setFlag(flag *bool, value bool) bool {
+	changed := *flag != value
+	*flag = value
+	return changed
+}
+
+// activeUser returns the record of a user that is not soft-deleted.
+func (st *UserStore) activeUser(id int) (userRecord, bool) {
+	rec, ok := st.s.users[id]
+	return rec, ok && rec.user.DeletedAt == nil
+}
+
+func (st *UserStore) Delete(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.activeUser(id)
+	if !ok {
+		return store.ErrNotFound
+	}
+	now := time.Now()
+	before := rec.user
+	rec.user.DeletedAt = &now
+	rec.user.UpdatedAt = now
+	st.s.users[id] = rec
+	st.s.audit(ctx, store.AuditDelete, store.EntityUser, id, before, rec.user)
+	for sid, session := range st.s.sessions {
+		if session.UserID == id {
+			delete(st.s.sessions, sid)
+		}
+	}
+	return nil
+}
+
+func (st *UserStore) Restore(ctx context.Context, id int, since time.Time) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.s.users[id]
+	if !ok || rec.user.DeletedAt == nil || rec.user.DeletedAt.Before(since) || rec.user.ErasedAt != nil {
+		return store.ErrNotFound
+	}
+	before := rec.user
+	rec.user.DeletedAt = nil
+	rec.user.UpdatedAt = time.Now()
+	st.s.users[id] = rec
+	st.s.audit(ctx, store.AuditRestore, store.EntityUser, id, before, rec.user)
+	return nil
+}
+
+func (st *UserStore) Erase(ctx context.Context, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.s.users[id]
+	if !ok || rec.user.ErasedAt != nil {
+		return store.ErrNotFound
+	}
+	st.s.eraseUser(id, time.Now())
+	st.s.audit(ctx, store.AuditErase, store.EntityUser, id, rec.user, st.s.users[id].user)
+	return nil
+}
+
+func (st *UserStore) PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	now := time.Now()
+	erased := 0
+	for id, rec := range st.s.users {
+		if rec.user.DeletedAt != nil && rec.user.DeletedAt.Before(cutoff) && rec.user.ErasedAt == nil {
+			st.s.eraseUser(id, now)
+			erased++
+		}
+	}
+	return erased, nil
+}
+
+func (st *UserStore) CredentialsByIdentifier(ctx context.Context, identifier string) (store.Credentials, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	for _, id := range sortedIDs(st.s.users) {
+		rec := st.s.users[id]
+		if rec.user.DeletedAt == nil && (rec.user.Username == identifier || rec.user.Email == identifier) {
+			return credentials(rec), nil
+		}
+	}
+	return store.Credentials{}, store.ErrNotFound
+}
+
+func (st *UserStore) CredentialsByID(ctx context.Context, id int) (store.Credentials, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	rec, ok := st.activeUser(id)
+	if !ok {
+		return store.Credentials{}, store.ErrNotFound
+	}
+	return credentials(rec), nil
+}
+
+type SessionStore struct {
+	s *state
+}
+
+func (st *SessionStore) Create(ctx context.Context, session models.LoginSession) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.users[session.UserID]; !ok {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	session.ID = st.s.nextID("login_sessions")
+	session.LoginAt = time.Now()
+	st.s.sessions[session.ID] = session
+	return session.ID, nil
+}
+
+func (st *SessionStore) Get(ctx context.Context, userID, id int) (models.LoginSession, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	session, ok := st.s.sessions[id]
+	if !ok || session.UserID != userID {
+		return models.LoginSession{}, store.ErrNotFound
+	}
+	return session, nil
+}
+
+func (st *SessionStore) ListByUser(ctx context.Context, userID int) ([]models.LoginSession, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	sessions := []models.LoginSession{}
+	for _, id := range sortedIDs(st.s.sessions) {
+		if session := st.s.sessions[id]; session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	// newest first; IDs break ties between logins in the same instant
+	slices.Reverse(sessions)
+	slices.SortStableFunc(sessions, func(a, b models.LoginSession) int {
+		return b.LoginAt.Compare(a.LoginAt)
+	})
+	return sessions, nil
+}
+
+func (st *SessionStore) Delete(ctx context.Context, userID, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if session, ok := st.s.sessions[id]; !ok || session.UserID != userID {
+		return store.ErrNotFound
+	}
+	delete(st.s.sessions, id)
+	return nil
+}
+
+func (st *SessionStore) DeleteByUser(ctx context.Context, userID int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	for id, session := range st.s.sessions {
+		if session.UserID == userID {
+			delete(st.s.sessions, id)
+		}
+	}
+	return nil
+}
+
+func (st *SessionStore) LogImpersonation(ctx context.Context, req models.ImpersonationRequest) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	req.ID = st.s.nextID("impersonation_requests")
+	req.CreatedAt = time.Now()
+	st.s.impersonations = append(st.s.impersonations, req)
+	return nil
+}
+
+func (st *SessionStore) ListImpersonations(ctx context.Context, filter store.ImpersonationFilter) ([]models.ImpersonationRequest, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var matching []models.ImpersonationRequest
+	// the log is appended to in ID order, so walk it backwards for newest first
+	for i := len(st.s.impersonations) - 1; i >= 0; i-- {
+		req := st.s.impersonations[i]
+		if (filter.UserID == 0 || req.UserID == filter.UserID) &&
+			(filter.ImpersonatorID == 0 || req.ImpersonatorID == filter.ImpersonatorID) {
+			matching = append(matching, req)
+		}
+	}
+	requests := []models.ImpersonationRequest{}
+	for i := filter.Offset; i < len(matching) && len(requests) < filter.Limit; i++ {
+		requests = append(requests, matching[i])
+	}
+	return requests, nil
+}
@@ -0,0 +1,371 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type WishlistStore struct {
+	s *state
+}
+
+// currentPrice is the price a wishlist item is shown and recorded at: the
+// variation's sale (or default sell) price, else the product price.
+func (s *state) currentPrice(productID int, variationID *int) *float64 {
+	if vp, ok := s.variationOf(productID, variationID); ok {
+		if vp.SalePrice != nil {
+			return vp.SalePrice
+		}
+		return vp.DefaultSellPrice
+	}
+	return s.products[productID].Price
+}
+
+// deleteWishlist removes the list and its items; notifications about the
+// items are kept.
+func (s *state) deleteWishlist(id int) {
+	delete(s.wishlists, id)
+	for itemID, item := range s.wishlistItems {
+		if item.WishlistID == id {
+			s.deleteWishlistItem(itemID)
+		}
+	}
+}
+
+func (s *state) deleteWishlistItem(id int) {
+	delete(s.wishlistItems, id)
+	for nid, n := range s.notifications {
+		if n.WishlistItemID != nil && *n.WishlistItemID == id {
+			n.WishlistItemID = nil
+			s.notifications[nid] = n
+		}
+	}
+}
+
+// insertWishlistItem adds a product or variation to a list at its current
+// price. It reports false when the item is already on the list.
+func (s *state) insertWishlistItem(wishlistID int, input store.WishlistItemInput) (int, bool) {
+	for _, item := range s.wishlistItems {
+		if item.WishlistID == wishlistID && item.ProductID == input.ProductID &&
+			sameVariation(item.VariationID, input.VariationID) {
+			return 0, false
+		}
+	}
+	item := models.WishlistItem{
+		ID:                s.nextID("wishlist_items"),
+		WishlistID:        wishlistID,
+		ProductID:         input.ProductID,
+		VariationID:       input.VariationID,
+		PriceAtAdd:        s.currentPrice(input.ProductID, input.VariationID),
+		NotifyPriceDrop:   input.NotifyPriceDrop,
+		NotifyBackInStock: input.NotifyBackInStock,
+		AddedAt:           time.Now(),
+	}
+	s.wishlistItems[item.ID] = item
+	return item.ID, true
+}
+
+// ownedWishlist returns the list if it belongs to userID.
+func (st *WishlistStore) ownedWishlist(userID, id int) (models.Wishlist, error) {
+	w, ok := st.s.wishlists[id]
+	if !ok || w.UserID != userID {
+		return models.Wishlist{}, store.ErrNotFound
+	}
+	return w, nil
+}
+
+func (st *WishlistStore) nameTaken(userID int, name string, exceptID int) bool {
+	for _, w := range st.s.wishlists {
+		if w.UserID == userID && w.Name == name && w.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// withItems returns the list with its items, newest first, and the product
+// data they are shown with.
+func (st *WishlistStore) withItems(w models.Wishlist) models.Wishlist {
+	w.Items = []models.WishlistItem{}
+	for _, item := range st.s.wishlistItems {
+		if item.WishlistID != w.ID {
+			continue
+		}
+		product := st.s.products[item.ProductID]
+		item.ProductName = product.ProductName
+		item.Image = product.Image
+		item.SKU = nil
+		item.CurrentStock = product.CurrentStock
+		item.CurrentPrice = st.s.currentPrice(item.ProductID, item.VariationID)
+		if vp, ok := st.s.variationOf(item.ProductID, item.VariationID); ok {
+			sku := vp.SKU
+			item.SKU = &sku
+			item.CurrentStock = vp.CurrentStock
+			if vp.Image != "" {
+				item.Image = vp.Image
+			}
+		}
+		w.Items = append(w.Items, item)
+	}
+	slices.SortFunc(w.Items, func(a, b models.WishlistItem) int {
+		if c := b.AddedAt.Compare(a.AddedAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+	w.ItemCount = len(w.Items)
+	return w
+}
+
+func (st *WishlistStore) List(ctx context.Context, userID int) ([]models.Wishlist, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	wishlists := []models.Wishlist{}
+	for _, id := range sortedIDs(st.s.wishlists) {
+		w := st.s.wishlists[id]
+		if w.UserID != userID {
+			continue
+		}
+		for _, item := range st.s.wishlistItems {
+			if item.WishlistID == w.ID {
+				w.ItemCount++
+			}
+		}
+		wishlists = append(wishlists, w)
+	}
+	return wishlists, nil
+}
+
+func (st *WishlistStore) Create(ctx context.Context, userID int, name string) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, ok := st.s.users[userID]; !ok {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	if st.nameTaken(userID, name, 0) {
+		return 0, &store.ConflictError{Field: "name"}
+	}
+	w := models.Wishlist{ID: st.s.nextID("wishlists"), UserID: userID, Name: name, CreatedAt: time.Now()}
+	w.UpdatedAt = w.CreatedAt
+	st.s.wishlists[w.ID] = w
+	return w.ID, nil
+}
+
+func (st *WishlistStore) Get(ctx context.Context, userID, id int) (models.Wishlist, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	w, err := st.ownedWishlist(userID, id)
+	if err != nil {
+		return w, err
+	}
+	return st.withItems(w), nil
+}
+
+func (st *WishlistStore) Rename(ctx context.Context, userID, id int, name string) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	w, err := st.ownedWishlist(userID, id)
+	if err != nil {
+		return err
+	}
+	if st.nameTaken(userID, name, id) {
+		return &store.ConflictError{Field: "name"}
+	}
+	w.Name = name
+	w.UpdatedAt = time.Now()
+	st.s.wishlists[id] = w
+	return nil
+}
+
+func (st *WishlistStore) Delete(ctx context.Context, userID, id int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, err := st.ownedWishlist(userID, id); err != nil {
+		return err
+	}
+	st.s.deleteWishlist(id)
+	return nil
+}
+
+func (st *WishlistStore) AddItem(ctx context.Context, userID, wishlistID int, item store.WishlistItemInput) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	w, err := st.ownedWishlist(userID, wishlistID)
+	if err != nil {
+		return 0, err
+	}
+	if item.VariationID != nil {
+		if _, ok := st.s.variationOf(item.ProductID, item.VariationID); !ok {
+			return 0, &store.InvalidRefError{Field: "variation_id"}
+		}
+	} else if _, ok := st.s.products[item.ProductID]; !ok {
+		return 0, &store.InvalidRefError{Field: "product_id"}
+	}
+
+	itemID, ok := st.s.insertWishlistItem(wishlistID, item)
+	if !ok {
+		return 0, &store.ConflictError{Field: "product_id"}
+	}
+	w.UpdatedAt = time.Now()
+	st.s.wishlists[wishlistID] = w
+	return itemID, nil
+}
+
+func (st *WishlistStore) RemoveItem(ctx context.Context, userID, wishlistID, itemID int) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	item, ok := st.s.wishlistItems[itemID]
+	if !ok || item.WishlistID != wishlistID {
+		return store.ErrNotFound
+	}
+	if _, err := st.ownedWishlist(userID, wishlistID); err != nil {
+		return err
+	}
+	st.s.deleteWishlistItem(itemID)
+	return nil
+}
+
+func (st *WishlistStore) MoveToCart(ctx context.Context, userID, wishlistID int, itemIDs []int) ([]int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	if _, err := st.ownedWishlist(userID, wishlistID); err != nil {
+		return nil, err
+	}
+
+	movedIDs := []int{}
+	for _, id := range sortedIDs(st.s.wishlistItems) {
+		item := st.s.wishlistItems[id]
+		if item.WishlistID != wishlistID || (len(itemIDs) > 0 && !slices.Contains(itemIDs, id)) {
+			continue
+		}
+		st.s.deleteWishlistItem(id)
+		st.s.addToCart(userID, item.ProductID, item.VariationID, 1)
+		movedIDs = append(movedIDs, id)
+	}
+	return movedIDs, nil
+}
+
+func (st *WishlistStore) SaveForLater(ctx context.Context, userID, cartItemID int, listName string) (int, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	cartItem, ok := st.s.cartItems[cartItemID]
+	if !ok || cartItem.UserID != userID {
+		return 0, store.ErrNotFound
+	}
+	delete(st.s.cartItems, cartItemID)
+
+	now := time.Now()
+	var list models.Wishlist
+	for _, w := range st.s.wishlists {
+		if w.UserID == userID && w.Name == listName {
+			list = w
+			break
+		}
+	}
+	if list.ID == 0 {
+		list = models.Wishlist{ID: st.s.nextID("wishlists"), UserID: userID, Name: listName, CreatedAt: now}
+	}
+	list.UpdatedAt = now
+	st.s.wishlists[list.ID] = list
+
+	// an item already on the list is simply kept there
+	st.s.insertWishlistItem(list.ID, store.WishlistItemInput{
+		ProductID:         cartItem.ProductID,
+		VariationID:       cartItem.VariationID,
+		NotifyPriceDrop:   true,
+		NotifyBackInStock: true,
+	})
+	return list.ID, nil
+}
+
+func (st *WishlistStore) SetShareToken(ctx context.Context, userID, id int, token *string) error {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	w, err := st.ownedWishlist(userID, id)
+	if err != nil {
+		return err
+	}
+	if token != nil {
+		for _, other := range st.s.wishlists {
+			if other.ID != id && other.ShareToken != nil && *other.ShareToken == *token {
+				return &store.ConflictError{Field: "share_token"}
+			}
+		}
+		t := *token
+		token = &t
+	}
+	w.ShareToken = token
+	w.UpdatedAt = time.Now()
+	st.s.wishlists[id] = w
+	return nil
+}
+
+func (st *WishlistStore) GetShared(ctx context.Context, token string) (models.Wishlist, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	for _, w := range st.s.wishlists {
+		if w.ShareToken != nil && *w.ShareToken == token && st.s.users[w.UserID].user.DeletedAt == nil {
+			// the public view leaves out the owner and the token
+			shared := models.Wishlist{ID: w.ID, Name: w.Name, CreatedAt: w.CreatedAt, UpdatedAt: w.UpdatedAt}
+			return st.withItems(shared), nil
+		}
+	}
+	return models.Wishlist{}, store.ErrNotFound
+}
+
+func (st *WishlistStore) ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]models.WishlistNotification, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	var matching []models.WishlistNotification
+	for _, n := range st.s.notifications {
+		if n.UserID == userID && (!unreadOnly || n.ReadAt == nil) {
+			n.ProductName = st.s.products[n.ProductID].ProductName
+			matching = append(matching, n)
+		}
+	}
+	slices.SortFunc(matching, func(a, b models.WishlistNotification) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return b.ID - a.ID
+	})
+
+	notifications := []models.WishlistNotification{}
+	for i := offset; i < len(matching) && len(notifications) < limit; i++ {
+		notifications = append(notifications, matching[i])
+	}
+	return notifications, nil
+}
+
+func (st *WishlistStore) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int64, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+
+	now := time.Now()
+	var updated int64
+	for id, n := range st.s.notifications {
+		if n.UserID != userID || n.ReadAt != nil || (len(ids) > 0 && !slices.Contains(ids, id)) {
+			continue
+		}
+		n.ReadAt = &now
+		st.s.notifications[id] = n
+		updated++
+	}
+	return updated, nil
+}
@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type AddressStore struct {
+	db *sql.DB
+}
+
+const addressColumns = `id, user_id, recipient_name, phone, address_line1, address_line2,
+	city, region, country, postal_code, type,
+	is_shipping, is_billing, is_default_shipping, is_default_billing, created_at`
+
+func scanAddress(row rowScanner, addr *models.Address) error {
+	return row.Scan(&addr.ID, &addr.UserID, &addr.RecipientName, &addr.Phone, &addr.AddressLine1,
+		&addr.AddressLine2, &addr.City, &addr.Region, &addr.Country, &addr.PostalCode, &addr.Type,
+		&addr.IsShipping, &addr.IsBilling,
+		&addr.IsDefaultShipping, &addr.IsDefaultBilling, &addr.CreatedAt)
+}
+
+// usageColumns names the flag and default columns of each usage.
+var usageColumns = map[store.AddressUsage]struct{ flag, isDefault string }{
+	store.UsageShipping: {"is_shipping", "is_default_shipping"},
+	store.UsageBilling:  {"is_billing", "is_default_billing"},
+}
+
+// usageFilter returns the WHERE condition selecting addresses flagged for
+// usage; an empty usage selects all of them.
+func usageFilter(usage store.AddressUsage) (string, error) {
+	if usage == "" {
+		return "TRUE", nil
+	}
+	cols, ok := usageColumns[usage]
+	if !ok {
+		return "", fmt.Errorf("postgres: unknown address usage %q", usage)
+	}
+	return cols.flag, nil
+}
+
+func (s *AddressStore) List(ctx context.Context, userID int, usage store.AddressUsage) ([]models.Address, error) {
+	filter, err := usageFilter(usage)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+addressColumns+`
+		FROM addresses
+		WHERE user_id = $1 AND `+filter+`
+		ORDER BY id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []models.Address
+	for rows.Next() {
+		var addr models.Address
+		if err := scanAddress(rows, &addr); err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, addr)
+	}
+	return addresses, rows.Err()
+}
+
+func (s *AddressStore) Get(ctx context.Context, userID, id int, usage store.AddressUsage) (models.Address, error) {
+	var addr models.Address
+	filter, err := usageFilter(usage)
+	if err != nil {
+		return addr, err
+	}
+	err = scanAddress(s.db.QueryRowContext(ctx, `
+		SELECT `+addressColumns+`
+		FROM addresses
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND `+filter+`
+	`, id, userID), &addr)
+	return addr, notFound(err)
+}
+
+func (s *AddressStore) GetDefault(ctx context.Context, userID int, usage store.AddressUsage) (models.Address, error) {
+	var addr models.Address
+	cols, ok := usageColumns[usage]
+	if !ok {
+		return addr, fmt.Errorf("postgres: unknown address usage %q", usage)
+	}
+	err := scanAddress(s.db.QueryRowContext(ctx, `
+		SELECT `+addressColumns+`
+		FROM addresses
+		WHERE user_id = $1 AND `+cols.isDefault+`
+	`, userID), &addr)
+	return addr, notFound(err)
+}
+
+func (s *AddressStore) Create(ctx context.Context, addr models.Address) (models.Address, error) {
+	_, err := audited(ctx, s.db, store.AuditCreate, store.EntityAddress, 0, func(tx *sql.Tx) (int, error) {
+		err := scanAddress(tx.QueryRowContext(ctx, `
+			INSERT INTO addresses (user_id, recipient_name, phone, address_line1, address_line2,
+			                       city, region, country, postal_code, type,
+			                       is_shipping, is_billing, is_default_shipping, is_default_billing, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12,
+			        $11 AND NOT EXISTS (SELECT 1 FROM addresses WHERE user_id = $1 AND is_default_shipping),
+			        $12 AND NOT EXISTS (SELECT 1 FROM addresses WHERE user_id = $1 AND is_default_billing),
+			        NOW())
+			RETURNING `+addressColumns,
+			addr.UserID, addr.RecipientName, addr.Phone, addr.AddressLine1, addr.AddressLine2,
+			addr.City, addr.Region, addr.Country, addr.PostalCode, addr.Type,
+			addr.IsShipping, addr.IsBilling), &addr)
+		if isViolation(err, foreignKeyViolation) {
+			return 0, &store.InvalidRefError{Field: "user_id"}
+		}
+		return addr.ID, err
+	})
+	return addr, err
+}
+
+func (s *AddressStore) Update(ctx context.Context, userID int, addr models.Address) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityAddress, addr.ID, `
+		UPDATE addresses a
+		SET recipient_name = $1, phone = $2, address_line1 = $3, address_line2 = $4,
+		    city = $5, region = $6, country = $7, postal_code = $8, type = $9,
+		    is_shipping = $10, is_billing = $11,
+		    is_default_shipping = $10 AND (a.is_default_shipping OR NOT EXISTS (
+		        SELECT 1 FROM addresses o WHERE o.user_id = a.user_id AND o.is_default_shipping)),
+		    is_default_billing = $11 AND (a.is_default_billing OR NOT EXISTS (
+		        SELECT 1 FROM addresses o WHERE o.user_id = a.user_id AND o.is_default_billing))
+		WHERE a.id = $12 AND ($13 = 0 OR a.user_id = $13)
+	`, addr.RecipientName, addr.Phone, addr.AddressLine1, addr.AddressLine2,
+		addr.City, addr.Region, addr.Country, addr.PostalCode, addr.Type,
+		addr.IsShipping, addr.IsBilling, addr.ID, userID)
+}
+
+func (s *AddressStore) Delete(ctx context.Context, userID, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityAddress, id, `
+		DELETE FROM addresses
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+	`, id, userID)
+}
+
+func (s *AddressStore) RemoveUsage(ctx context.Context, userID, id int, usage store.AddressUsage) error {
+	cols, ok := usageColumns[usage]
+	if !ok {
+		return fmt.Errorf("postgres: unknown address usage %q", usage)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var stillUsed bool
+	err = tx.QueryRowContext(ctx, `
+		UPDATE addresses SET `+cols.flag+` = FALSE, `+cols.isDefault+` = FALSE
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND `+cols.flag+`
+		RETURNING is_shipping OR is_billing
+	`, id, userID).Scan(&stillUsed)
+	if err != nil {
+		return notFound(err)
+	}
+	if !stillUsed {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM addresses WHERE id = $1`, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *AddressStore) SetDefault(ctx context.Context, userID, id int, usage store.AddressUsage) error {
+	cols, ok := usageColumns[usage]
+	if !ok {
+		return fmt.Errorf("postgres: unknown address usage %q", usage)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var ownerID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id FROM addresses
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2) AND `+cols.flag+`
+		FOR UPDATE
+	`, id, userID).Scan(&ownerID)
+	if err != nil {
+		return notFound(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE addresses SET `+cols.isDefault+` = FALSE
+		WHERE user_id = $1 AND `+cols.isDefault+`
+	`, ownerID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE addresses SET `+cols.isDefault+` = TRUE WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *AddressStore) ResolveLegacyID(ctx context.Context, usage store.AddressUsage, id int) (int, error) {
+	var addressID int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT address_id FROM legacy_address_ids WHERE usage = $1 AND legacy_id = $2
+	`, usage, id).Scan(&addressID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return id, nil
+	}
+	return addressID, err
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+// auditTables maps audited entity types to their tables.
+var auditTables = map[string]string{
+	store.EntityUser:           "users",
+	store.EntityAddress:        "addresses",
+	store.EntityBrand:          "brands",
+	store.EntityCategory:       "categories",
+	store.EntityAttribute:      "attributes",
+	store.EntityAttributeValue: "attribute_values",
+	store.EntityProduct:        "products",
+	store.EntityShippingZone:   "shipping_zones",
+	store.EntityShippingMethod: "shipping_methods",
+	store.EntityReview:         "product_reviews",
+	store.EntityMedia:          "media",
+}
+
+// auditChildren adds rows of other tables that belong to an entity to its
+// snapshot, as a JSON object merged into the row t.
+var auditChildren = map[string]string{
+	store.EntityShippingMethod: `jsonb_build_object('tiers', COALESCE((
+		SELECT jsonb_agg(jsonb_build_object('min_value', r.min_value, 'max_value', r.max_value, 'rate', r.rate)
+		                 ORDER BY r.min_value)
+		FROM shipping_rate_tiers r WHERE r.method_id = t.id), '[]'::jsonb))`,
+	store.EntityProduct: `jsonb_build_object('images', COALESCE((
+		SELECT jsonb_agg(i.media_id ORDER BY i.position)
+		FROM product_images i WHERE i.product_id = t.id), '[]'::jsonb))`,
+}
+
+// snapshot returns the row of the entity as a JSON object, or nil when
+// there is none. It locks the row until the transaction ends.
+func snapshot(ctx context.Context, tx *sql.Tx, entity string, id int) (json.RawMessage, error) {
+	table, ok := auditTables[entity]
+	if !ok {
+		return nil, fmt.Errorf("postgres: unknown audit entity %q", entity)
+	}
+	row := `to_jsonb(t)`
+	if extra, ok := auditChildren[entity]; ok {
+		row += ` || ` + extra
+	}
+	var data []byte
+	err := tx.QueryRowContext(ctx, `SELECT `+row+` FROM `+table+` t WHERE id = $1 FOR UPDATE OF t`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return data, err
+}
+
+// recordAudit writes the change of the entity from before to after to the
+// audit log when ctx carries an actor.
+func recordAudit(ctx context.Context, tx *sql.Tx, action, entity string, id int, before, after json.RawMessage) error {
+	actor, ok := store.ActorFrom(ctx)
+	if !ok {
+		return nil
+	}
+	before, after, err := store.AuditDiff(action, before, after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (actor_id, action, entity_type, entity_id, before, after, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, actor.UserID, action, entity, id, nullJSON(before), nullJSON(after), actor.IPAddress, actor.UserAgent)
+	return err
+}
+
+// nullJSON passes a nil JSON value as SQL NULL.
+func nullJSON(data json.RawMessage) any {
+	if data == nil {
+		return nil
+	}
+	return []byte(data)
+}
+
+// auditBefore returns the entity's state before a change in tx, when the
+// change is audited.
+func auditBefore(ctx context.Context, tx *sql.Tx, entity string, id int) (json.RawMessage, error) {
+	if _, ok := store.ActorFrom(ctx); !ok || id == 0 {
+		return nil, nil
+	}
+	return snapshot(ctx, tx, entity, id)
+}
+
+// auditAfter records a change made in tx, given the entity's state from
+// auditBefore (nil for creations).
+func auditAfter(ctx context.Context, tx *sql.Tx, action, entity string, id int, before json.RawMessage) error {
+	if _, ok := store.ActorFrom(ctx); !ok {
+		return nil
+	}
+	after, err := snapshot(ctx, tx, entity, id)
+	if err != nil {
+		return err
+	}
+	return recordAudit(ctx, tx, action, entity, id, before, after)
+}
+
+// audited runs change in a transaction and records it in the audit log
+// within the same transaction. id is the entity change applies to, or 0
+// when change creates it; change returns the entity's ID. Constraint
+// violations are returned as store errors.
+func audited(ctx context.Context, db *sql.DB, action, entity string, id int, change func(tx *sql.Tx) (int, error)) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := auditBefore(ctx, tx, entity, id)
+	if err != nil {
+		return 0, err
+	}
+	id, err = change(tx)
+	if err != nil {
+		return id, storeError(err)
+	}
+	if err := auditAfter(ctx, tx, action, entity, id, before); err != nil {
+		return 0, err
+	}
+	return id, storeError(tx.Commit())
+}
+
+// auditedExec is audited for a single statement on an existing entity.
+func auditedExec(ctx context.Context, db *sql.DB, action, entity string, id int, query string, args ...any) error {
+	_, err := audited(ctx, db, action, entity, id, func(tx *sql.Tx) (int, error) {
+		return id, expectRows(tx.ExecContext(ctx, query, args...))
+	})
+	return err
+}
+
+func (s *AuditStore) List(ctx context.Context, filter store.AuditFilter) ([]models.AuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, actor_id, action, entity_type, entity_id, before, after, ip_address, user_agent, created_at
+		FROM audit_log
+		WHERE ($1 = 0 OR actor_id = $1)
+		  AND ($2 = '' OR action = $2)
+		  AND ($3 = '' OR entity_type = $3)
+		  AND ($4 = 0 OR entity_id = $4)
+		  AND ($5::timestamp IS NULL OR created_at >= $5)
+		  AND ($6::timestamp IS NULL OR created_at < $6)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $7 OFFSET $8
+	`, filter.ActorID, filter.Action, filter.EntityType, filter.EntityID,
+		utc(filter.From), utc(filter.To), filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&entry.ID, &entry.ActorID, &entry.Action, &entry.EntityType, &entry.EntityID,
+			&before, &after, &entry.IPAddress, &entry.UserAgent, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Before, entry.After = before, after
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
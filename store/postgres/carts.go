@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type CartStore struct {
+	db *sql.DB
+}
+
+// ListItems loads the user's cart lines together with the product data
+// needed for pricing and shipping (unit price, weight).
+func (s *CartStore) ListItems(ctx context.Context, userID int) ([]models.CartItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT ci.id, ci.user_id, ci.product_id, ci.variation_id, ci.quantity,
+		       p.product_name, vp.sku, p.weight,
+		       COALESCE(vp.sale_price, vp.default_sell_price, p.price, 0),
+		       COALESCE(vp.current_stock, p.current_stock, 0), ci.created_at
+		FROM cart_items ci
+		JOIN products p ON p.id = ci.product_id
+		LEFT JOIN variation_products vp ON vp.id = ci.variation_id
+		WHERE ci.user_id = $1
+		ORDER BY ci.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		err := rows.Scan(&item.ID, &item.UserID, &item.ProductID, &item.VariationID, &item.Quantity,
+			&item.ProductName, &item.SKU, &item.Weight, &item.UnitPrice, &item.CurrentStock, &item.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *CartStore) AddItem(ctx context.Context, userID, productID int, variationID *int, quantity int) (int, int, error) {
+	if variationID != nil {
+		var valid bool
+		err := s.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM variation_products WHERE id = $1 AND product_id = $2)
+		`, *variationID, productID).Scan(&valid)
+		if err != nil {
+			return 0, 0, err
+		}
+		if !valid {
+			return 0, 0, &store.InvalidRefError{Field: "variation_id"}
+		}
+	}
+
+	var itemID, newQuantity int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO cart_items (user_id, product_id, variation_id, quantity, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (user_id, product_id, COALESCE(variation_id, 0))
+		DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity, updated_at = NOW()
+		RETURNING id, quantity
+	`, userID, productID, variationID, quantity).Scan(&itemID, &newQuantity)
+	if isViolation(err, foreignKeyViolation) {
+		return 0, 0, &store.InvalidRefError{Field: "product_id"}
+	}
+	return itemID, newQuantity, err
+}
+
+func (s *CartStore) UpdateItem(ctx context.Context, userID, itemID, quantity int) error {
+	return expectRows(s.db.ExecContext(ctx, `
+		UPDATE cart_items SET quantity = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+	`, quantity, itemID, userID))
+}
+
+func (s *CartStore) RemoveItem(ctx context.Context, userID, itemID int) error {
+	return expectRows(s.db.ExecContext(ctx, `
+		DELETE FROM cart_items WHERE id = $1 AND user_id = $2
+	`, itemID, userID))
+}
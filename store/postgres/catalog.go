@@ -0,0 +1,613 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+type CatalogStore struct {
+	db *sql.DB
+}
+
+// brandSelect reads brands with their product counts, grouped in one pass
+// over products.
+const brandSelect = `
+	SELECT id, brand_name, image, status,
+		is_feature, is_publish, is_special,
+		is_approved_by_admin, is_visible_to_guest,
+		created_by, updated_by, created_at, updated_at,
+		COALESCE(c.published, 0), COALESCE(c.total, 0)
+	FROM brands b
+	LEFT JOIN (
+		SELECT brand_id, COUNT(*) FILTER (WHERE status = 1) AS published, COUNT(*) AS total
+		FROM products
+		GROUP BY brand_id
+	) c ON c.brand_id = b.id`
+
+func scanBrand(row rowScanner, brand *models.Brand) error {
+	return row.Scan(
+		&brand.ID, &brand.BrandName, &brand.Image, &brand.Status,
+		&brand.IsFeature, &brand.IsPublish, &brand.IsSpecial,
+		&brand.IsApprovedByAdmin, &brand.IsVisibleToGuest,
+		&brand.CreatedBy, &brand.UpdatedBy, &brand.CreatedAt, &brand.UpdatedAt,
+		&brand.ProductsCount, &brand.TotalProductsCount,
+	)
+}
+
+func (s *CatalogStore) CreateBrand(ctx context.Context, brand models.Brand) (int, error) {
+	return audited(ctx, s.db, store.AuditCreate, store.EntityBrand, 0, func(tx *sql.Tx) (int, error) {
+		var brandID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO brands (
+				brand_name, image, status,
+				is_feature, is_publish, is_special,
+				is_approved_by_admin, is_visible_to_guest,
+				created_by, updated_by, created_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+			RETURNING id
+		`, brand.BrandName, brand.Image, brand.Status,
+			brand.IsFeature, brand.IsPublish, brand.IsSpecial,
+			brand.IsApprovedByAdmin, brand.IsVisibleToGuest,
+			brand.CreatedBy, brand.UpdatedBy,
+		).Scan(&brandID)
+		return brandID, err
+	})
+}
+
+func (s *CatalogStore) ListBrands(ctx context.Context) ([]models.Brand, error) {
+	rows, err := s.db.QueryContext(ctx, brandSelect+` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var brands []models.Brand
+	for rows.Next() {
+		var brand models.Brand
+		if err := scanBrand(rows, &brand); err != nil {
+			return nil, err
+		}
+		brands = append(brands, brand)
+	}
+	return brands, rows.Err()
+}
+
+func (s *CatalogStore) GetBrand(ctx context.Context, id int) (models.Brand, error) {
+	var brand models.Brand
+	err := scanBrand(s.db.QueryRowContext(ctx, brandSelect+` WHERE id = $1`, id), &brand)
+	return brand, notFound(err)
+}
+
+func (s *CatalogStore) UpdateBrand(ctx context.Context, brand models.Brand) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityBrand, brand.ID, `
+		UPDATE brands SET
+			brand_name = $1,
+			image = $2,
+			status = $3,
+			is_feature = $4,
+			is_publish = $5,
+			is_special = $6,
+			is_approved_by_admin = $7,
+			is_visible_to_guest = $8,
+			updated_by = $9,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $10
+	`, brand.BrandName, brand.Image, brand.Status,
+		brand.IsFeature, brand.IsPublish, brand.IsSpecial,
+		brand.IsApprovedByAdmin, brand.IsVisibleToGuest,
+		brand.UpdatedBy, brand.ID,
+	)
+}
+
+func (s *CatalogStore) DeleteBrand(ctx context.Context, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityBrand, id, `DELETE FROM brands WHERE id = $1`, id)
+}
+
+const categoryColumns = `id, parent_id, path, depth, position, code, category_name,
+	image, category_visibility, is_special, is_featured, is_approved, is_published,
+	price_visibility, status, created_by, updated_by, created_at, updated_at`
+
+func scanCategory(row rowScanner, category *models.Category) error {
+	return row.Scan(
+		&category.ID, &category.ParentID, &category.Path, &category.Depth, &category.Position,
+		&category.Code, &category.CategoryName,
+		&category.Image, &category.CategoryVisibility, &category.IsSpecial,
+		&category.IsFeatured, &category.IsApproved, &category.IsPublished,
+		&category.PriceVisibility, &category.Status,
+		&category.CreatedBy, &category.UpdatedBy, &category.CreatedAt, &category.UpdatedAt,
+	)
+}
+
+type productCount struct {
+	published, total int
+}
+
+// productCounts holds the product counts of each category.
+type productCounts map[int]productCount
+
+// countProducts counts the products of every category whose path starts
+// with path, including those of the categories below it, from one grouped
+// query.
+func countProducts(ctx context.Context, db *sql.DB, path string) (productCounts, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.path, COUNT(*) FILTER (WHERE p.status = 1), COUNT(*)
+		FROM products p
+		JOIN categories c ON c.id = p.category_id
+		WHERE c.path LIKE $1 || '%'
+		GROUP BY c.path
+	`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(productCounts)
+	for rows.Next() {
+		var categoryPath string
+		var published, total int
+		if err := rows.Scan(&categoryPath, &published, &total); err != nil {
+			return nil, err
+		}
+		// a category's products count for all its ancestors too
+		for _, id := range strings.Split(strings.Trim(categoryPath, "/"), "/") {
+			id, err := strconv.Atoi(id)
+			if err != nil {
+				return nil, err
+			}
+			c := counts[id]
+			counts[id] = productCount{c.published + published, c.total + total}
+		}
+	}
+	return counts, rows.Err()
+}
+
+// fill sets the product counts of category.
+func (counts productCounts) fill(category *models.Category) {
+	c := counts[category.ID]
+	category.ProductsCount, category.TotalProductsCount = c.published, c.total
+}
+
+// changeTree runs a change of the category tree in a transaction that
+// records it in the audit log. Changes of the tree are serialized, so that
+// the positions of siblings stay 1, 2, 3, ...
+func changeTree(ctx context.Context, db *sql.DB, action string, id int, change func(tx *sql.Tx) (int, error)) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return 0, err
+	}
+	before, err := auditBefore(ctx, tx, store.EntityCategory, id)
+	if err != nil {
+		return 0, err
+	}
+	id, err = change(tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := auditAfter(ctx, tx, action, store.EntityCategory, id, before); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// treeSlot returns the path and depth of a child of parentID, and the
+// position it takes among the other children but id: position, or after the
+// last one when position is 0 or beyond it. It shifts the children from
+// there on to make room.
+func treeSlot(ctx context.Context, tx *sql.Tx, parentID *int, position, id int) (string, int, int, error) {
+	path, depth := "/", 0
+	if parentID != nil {
+		err := tx.QueryRowContext(ctx, `SELECT path, depth + 1 FROM categories WHERE id = $1`, *parentID).Scan(&path, &depth)
+		if err == sql.ErrNoRows {
+			return "", 0, 0, &store.InvalidRefError{Field: "parent_id"}
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	var siblings int
+	err := tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM categories
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND id <> $2
+	`, parentID, id).Scan(&siblings)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	if position < 1 || position > siblings {
+		return path, depth, siblings + 1, nil
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE categories SET position = position + 1
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND position >= $2 AND id <> $3
+	`, parentID, position, id)
+	return path, depth, position, err
+}
+
+// closeSlot moves the children of parentID after position up by one.
+func closeSlot(ctx context.Context, tx *sql.Tx, parentID *int, position int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE categories SET position = position - 1
+		WHERE parent_id IS NOT DISTINCT FROM $1 AND position > $2
+	`, parentID, position)
+	return err
+}
+
+func (s *CatalogStore) CreateCategory(ctx context.Context, category models.Category) (int, error) {
+	now := time.Now()
+	return changeTree(ctx, s.db, store.AuditCreate, 0, func(tx *sql.Tx) (int, error) {
+		parentPath, depth, position, err := treeSlot(ctx, tx, category.ParentID, category.Position, 0)
+		if err != nil {
+			return 0, err
+		}
+
+		var categoryID int
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO categories (
+				parent_id, path, depth, position, code, category_name, image,
+				category_visibility, is_special, is_featured, is_approved, is_published,
+				price_visibility, status, created_by, updated_by, created_at, updated_at
+			) VALUES ($1, '', $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			RETURNING id
+		`, category.ParentID, depth, position, category.Code, category.CategoryName,
+			category.Image, category.CategoryVisibility,
+			category.IsSpecial, category.IsFeatured, category.IsApproved, category.IsPublished,
+			category.PriceVisibility, category.Status, category.CreatedBy, category.UpdatedBy, now, now,
+		).Scan(&categoryID)
+		if err != nil {
+			return 0, err
+		}
+		// the path ends with the ID, known only now
+		_, err = tx.ExecContext(ctx, `UPDATE categories SET path = $1 || id || '/' WHERE id = $2`, parentPath, categoryID)
+		return categoryID, err
+	})
+}
+
+func (s *CatalogStore) ListCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY depth, position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []models.Category
+	for rows.Next() {
+		var category models.Category
+		if err := scanCategory(rows, &category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts, err := countProducts(ctx, s.db, "/")
+	if err != nil {
+		return nil, err
+	}
+	for i := range categories {
+		counts.fill(&categories[i])
+	}
+	return categories, nil
+}
+
+func (s *CatalogStore) GetCategory(ctx context.Context, id int) (models.Category, error) {
+	var category models.Category
+	err := scanCategory(s.db.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id), &category)
+	if err != nil {
+		return category, notFound(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+categoryColumns+` FROM categories
+		WHERE parent_id = $1
+		ORDER BY position
+	`, id)
+	if err != nil {
+		return category, err
+	}
+	defer rows.Close()
+	category.Children = []models.Category{}
+	for rows.Next() {
+		var child models.Category
+		if err := scanCategory(rows, &child); err != nil {
+			return category, err
+		}
+		category.Children = append(category.Children, child)
+	}
+	if err := rows.Err(); err != nil {
+		return category, err
+	}
+
+	counts, err := countProducts(ctx, s.db, category.Path)
+	if err != nil {
+		return category, err
+	}
+	counts.fill(&category)
+	for i := range category.Children {
+		counts.fill(&category.Children[i])
+	}
+
+	// every ancestor's path is a prefix of this one
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT id, category_name FROM categories
+		WHERE $1 LIKE path || '%' AND id <> $2
+		ORDER BY depth
+	`, category.Path, id)
+	if err != nil {
+		return category, err
+	}
+	defer rows.Close()
+	category.Breadcrumbs = []models.CategoryCrumb{}
+	for rows.Next() {
+		var crumb models.CategoryCrumb
+		if err := rows.Scan(&crumb.ID, &crumb.CategoryName); err != nil {
+			return category, err
+		}
+		category.Breadcrumbs = append(category.Breadcrumbs, crumb)
+	}
+	return category, rows.Err()
+}
+
+func (s *CatalogStore) UpdateCategory(ctx context.Context, category models.Category) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityCategory, category.ID, `
+		UPDATE categories SET
+			code = $1, category_name = $2, image = $3,
+			category_visibility = $4, is_special = $5, is_featured = $6,
+			is_approved = $7, is_published = $8, price_visibility = $9,
+			status = $10, updated_by = $11, updated_at = $12
+		WHERE id = $13
+	`, category.Code, category.CategoryName, category.Image,
+		category.CategoryVisibility, category.IsSpecial, category.IsFeatured,
+		category.IsApproved, category.IsPublished, category.PriceVisibility,
+		category.Status, category.UpdatedBy, time.Now(), category.ID,
+	)
+}
+
+func (s *CatalogStore) MoveCategory(ctx context.Context, id int, parentID *int, position int) error {
+	_, err := changeTree(ctx, s.db, store.AuditMove, id, func(tx *sql.Tx) (int, error) {
+		var current models.Category
+		err := tx.QueryRowContext(ctx, `SELECT parent_id, path, depth, position FROM categories WHERE id = $1`, id).
+			Scan(&current.ParentID, &current.Path, &current.Depth, &current.Position)
+		if err != nil {
+			return 0, notFound(err)
+		}
+		if parentID != nil {
+			var inSubtree bool
+			err := tx.QueryRowContext(ctx, `SELECT path LIKE $2 || '%' FROM categories WHERE id = $1`, *parentID, current.Path).
+				Scan(&inSubtree)
+			if err == sql.ErrNoRows {
+				return 0, &store.InvalidRefError{Field: "parent_id"}
+			}
+			if err != nil {
+				return 0, err
+			}
+			if inSubtree {
+				return 0, store.ErrConflict
+			}
+		}
+
+		// close the gap the category leaves, then make room among the new siblings
+		if err := closeSlot(ctx, tx, current.ParentID, current.Position); err != nil {
+			return 0, err
+		}
+		parentPath, depth, position, err := treeSlot(ctx, tx, parentID, position, id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE categories SET parent_id = $1, position = $2 WHERE id = $3`, parentID, position, id)
+		if err != nil {
+			return 0, err
+		}
+
+		newPath := parentPath + strconv.Itoa(id) + "/"
+		_, err = tx.ExecContext(ctx, `
+			UPDATE categories SET
+				path = $1 || substr(path, length($2) + 1),
+				depth = depth + $3
+			WHERE path LIKE $2 || '%'
+		`, newPath, current.Path, depth-current.Depth)
+		return id, err
+	})
+	return err
+}
+
+func (s *CatalogStore) DeleteCategory(ctx context.Context, id int) error {
+	_, err := changeTree(ctx, s.db, store.AuditDelete, id, func(tx *sql.Tx) (int, error) {
+		var current models.Category
+		err := tx.QueryRowContext(ctx, `
+			DELETE FROM categories WHERE id = $1
+			RETURNING parent_id, position
+		`, id).Scan(&current.ParentID, &current.Position)
+		if isViolation(err, foreignKeyViolation) {
+			// children or products still refer to it
+			return 0, store.ErrConflict
+		}
+		if err != nil {
+			return 0, notFound(err)
+		}
+		return id, closeSlot(ctx, tx, current.ParentID, current.Position)
+	})
+	return err
+}
+
+func (s *CatalogStore) CreateAttribute(ctx context.Context, attr models.Attribute) (int, error) {
+	return audited(ctx, s.db, store.AuditCreate, store.EntityAttribute, 0, func(tx *sql.Tx) (int, error) {
+		var attributeID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO attributes (attribute_name, status)
+			VALUES ($1, $2)
+			RETURNING id
+		`, attr.AttributeName, attr.Status).Scan(&attributeID)
+		return attributeID, err
+	})
+}
+
+// attributeValueSelect reads attribute values with the number of products
+// that use each one.
+const attributeValueSelect = `
+	SELECT id, attribute_id, value, status, COALESCE(u.products, 0)
+	FROM attribute_values v
+	LEFT JOIN (
+		SELECT attribute_value_id, COUNT(DISTINCT product_id) AS products
+		FROM product_attributes
+		GROUP BY attribute_value_id
+	) u ON u.attribute_value_id = v.id`
+
+func scanAttributeValue(row rowScanner, val *models.AttributeValue) error {
+	return row.Scan(&val.ID, &val.AttributeID, &val.Value, &val.Status, &val.UsageCount)
+}
+
+// activeValuesByAttribute loads the active values of the given attributes.
+func (s *CatalogStore) activeValuesByAttribute(ctx context.Context, attributeIDs []int) (map[int][]models.AttributeValue, error) {
+	rows, err := s.db.QueryContext(ctx, attributeValueSelect+`
+		WHERE attribute_id = ANY($1) AND status = 1
+		ORDER BY id
+	`, pq.Array(attributeIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[int][]models.AttributeValue)
+	for rows.Next() {
+		var val models.AttributeValue
+		if err := scanAttributeValue(rows, &val); err != nil {
+			return nil, err
+		}
+		values[val.AttributeID] = append(values[val.AttributeID], val)
+	}
+	return values, rows.Err()
+}
+
+func (s *CatalogStore) ListAttributes(ctx context.Context) ([]models.Attribute, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, attribute_name, status FROM attributes WHERE status = 1 ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attributes []models.Attribute
+	var attributeIDs []int
+	for rows.Next() {
+		var attr models.Attribute
+		if err := rows.Scan(&attr.ID, &attr.AttributeName, &attr.Status); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, attr)
+		attributeIDs = append(attributeIDs, attr.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(attributeIDs) == 0 {
+		return attributes, nil
+	}
+
+	values, err := s.activeValuesByAttribute(ctx, attributeIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range attributes {
+		attributes[i].AttributeValues = values[attributes[i].ID]
+	}
+	return attributes, nil
+}
+
+func (s *CatalogStore) GetAttribute(ctx context.Context, id int) (models.Attribute, error) {
+	var attr models.Attribute
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, attribute_name, status
+		FROM attributes
+		WHERE id = $1 AND status = 1
+	`, id).Scan(&attr.ID, &attr.AttributeName, &attr.Status)
+	if err != nil {
+		return attr, notFound(err)
+	}
+
+	values, err := s.activeValuesByAttribute(ctx, []int{id})
+	if err != nil {
+		return attr, err
+	}
+	attr.AttributeValues = values[id]
+	return attr, nil
+}
+
+func (s *CatalogStore) UpdateAttribute(ctx context.Context, attr models.Attribute) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityAttribute, attr.ID, `
+		UPDATE attributes
+		SET attribute_name = $1, status = $2
+		WHERE id = $3
+	`, attr.AttributeName, attr.Status, attr.ID)
+}
+
+func (s *CatalogStore) DeleteAttribute(ctx context.Context, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityAttribute, id, `UPDATE attributes SET status = 0 WHERE id = $1`, id)
+}
+
+func (s *CatalogStore) CreateAttributeValue(ctx context.Context, value models.AttributeValue) (int, error) {
+	return audited(ctx, s.db, store.AuditCreate, store.EntityAttributeValue, 0, func(tx *sql.Tx) (int, error) {
+		var valueID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO attribute_values (attribute_id, value, status)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		`, value.AttributeID, value.Value, value.Status).Scan(&valueID)
+		if isViolation(err, foreignKeyViolation) {
+			return 0, &store.InvalidRefError{Field: "attribute_id"}
+		}
+		return valueID, err
+	})
+}
+
+func (s *CatalogStore) ListAttributeValues(ctx context.Context, attributeID int) ([]models.AttributeValue, error) {
+	rows, err := s.db.QueryContext(ctx, attributeValueSelect+`
+		WHERE attribute_id = $1
+		ORDER BY id
+	`, attributeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []models.AttributeValue
+	for rows.Next() {
+		var val models.AttributeValue
+		if err := scanAttributeValue(rows, &val); err != nil {
+			return nil, err
+		}
+		values = append(values, val)
+	}
+	return values, rows.Err()
+}
+
+func (s *CatalogStore) GetAttributeValue(ctx context.Context, id int) (models.AttributeValue, error) {
+	var val models.AttributeValue
+	err := scanAttributeValue(s.db.QueryRowContext(ctx, attributeValueSelect+` WHERE id = $1`, id), &val)
+	return val, notFound(err)
+}
+
+func (s *CatalogStore) UpdateAttributeValue(ctx context.Context, value models.AttributeValue) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityAttributeValue, value.ID, `
+		UPDATE attribute_values
+		SET value = $1, status = $2
+		WHERE id = $3
+	`, value.Value, value.Status, value.ID)
+}
+
+func (s *CatalogStore) DeleteAttributeValue(ctx context.Context, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityAttributeValue, id, `DELETE FROM attribute_values WHERE id = $1`, id)
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+const mediaColumns = `id, hash, mime_type, size, filename, uploaded_by, created_at`
+
+func scanMedia(row rowScanner, media *models.Media) error {
+	err := row.Scan(&media.ID, &media.Hash, &media.MimeType, &media.Size,
+		&media.Filename, &media.UploadedBy, &media.CreatedAt)
+	media.URL = store.MediaURL(media.ID)
+	return err
+}
+
+func (s *MediaStore) Create(ctx context.Context, media models.Media) (models.Media, bool, error) {
+	var created models.Media
+	id, err := audited(ctx, s.db, store.AuditCreate, store.EntityMedia, 0, func(tx *sql.Tx) (int, error) {
+		err := scanMedia(tx.QueryRowContext(ctx, `
+			INSERT INTO media (hash, mime_type, size, filename, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (hash) DO NOTHING
+			RETURNING `+mediaColumns,
+			media.Hash, media.MimeType, media.Size, media.Filename, media.UploadedBy,
+		), &created)
+		return created.ID, err
+	})
+	if err == sql.ErrNoRows {
+		// already uploaded; nothing was changed, so nothing is audited
+		existing, err := s.getByHash(ctx, media.Hash)
+		return existing, false, err
+	}
+	if err != nil {
+		return models.Media{}, false, err
+	}
+	created.ID = id
+	return created, true, nil
+}
+
+func (s *MediaStore) getByHash(ctx context.Context, hash string) (models.Media, error) {
+	var media models.Media
+	err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE hash = $1`, hash), &media)
+	return media, notFound(err)
+}
+
+func (s *MediaStore) Get(ctx context.Context, id int) (models.Media, error) {
+	var media models.Media
+	err := scanMedia(s.db.QueryRowContext(ctx, `SELECT `+mediaColumns+` FROM media WHERE id = $1`, id), &media)
+	return media, notFound(err)
+}
+
+func (s *MediaStore) List(ctx context.Context, limit, offset int) ([]models.Media, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+mediaColumns+` FROM media
+		ORDER BY created_at DESC, id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Media{}
+	for rows.Next() {
+		var media models.Media
+		if err := scanMedia(rows, &media); err != nil {
+			return nil, err
+		}
+		list = append(list, media)
+	}
+	return list, rows.Err()
+}
+
+func (s *MediaStore) Delete(ctx context.Context, id int) error {
+	// a product still showing it yields store.ErrConflict
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityMedia, id, `DELETE FROM media WHERE id = $1`, id)
+}
+
+func (s *MediaStore) SetProductImages(ctx context.Context, productID int, mediaIDs []int) error {
+	_, err := audited(ctx, s.db, store.AuditUpdate, store.EntityProduct, productID, func(tx *sql.Tx) (int, error) {
+		ok, err := exists(ctx, tx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, productID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, &store.InvalidRefError{Field: "product_id"}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM product_images WHERE product_id = $1`, productID); err != nil {
+			return 0, err
+		}
+		for i, mediaID := range mediaIDs {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO product_images (product_id, media_id, position)
+				VALUES ($1, $2, $3)
+			`, productID, mediaID, i+1)
+			if isViolation(err, foreignKeyViolation) {
+				return 0, &store.InvalidRefError{Field: "media_id"}
+			}
+			if err != nil {
+				return 0, err
+			}
+		}
+
+		image := ""
+		if len(mediaIDs) > 0 {
+			image = store.MediaURL(mediaIDs[0])
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE products SET image = $1 WHERE id = $2`, image, productID)
+		return productID, err
+	})
+	return err
+}
+
+// productImages returns the gallery of a product in order.
+func productImages(ctx context.Context, db *sql.DB, productID int) ([]models.ProductImage, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT media_id, position FROM product_images
+		WHERE product_id = $1
+		ORDER BY position
+	`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var images []models.ProductImage
+	for rows.Next() {
+		var image models.ProductImage
+		if err := rows.Scan(&image.MediaID, &image.Position); err != nil {
+			return nil, err
+		}
+		image.URL = store.MediaURL(image.MediaID)
+		images = append(images, image)
+	}
+	return images, rows.Err()
+}
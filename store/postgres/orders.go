@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+type OrderStore struct {
+	db *sql.DB
+}
+
+const orderColumns = `id, user_id, status, created_at, updated_at`
+
+func scanOrder(row rowScanner, order *models.Order) error {
+	return row.Scan(&order.ID, &order.UserID, &order.Status, &order.CreatedAt, &order.UpdatedAt)
+}
+
+// loadOrderItems adds their items, and the totals, to orders.
+func loadOrderItems(ctx context.Context, db *sql.DB, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(orders))
+	ids := make([]int, len(orders))
+	for i, order := range orders {
+		index[order.ID] = i
+		ids[i] = order.ID
+		orders[i].Items = []models.OrderItem{}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT order_id, id, product_id, variation_id, quantity, unit_price
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID int
+		var item models.OrderItem
+		err := rows.Scan(&orderID, &item.ID, &item.ProductID, &item.VariationID, &item.Quantity, &item.UnitPrice)
+		if err != nil {
+			return err
+		}
+		order := &orders[index[orderID]]
+		order.Items = append(order.Items, item)
+		order.Total += float64(item.Quantity) * item.UnitPrice
+	}
+	return rows.Err()
+}
+
+func (s *OrderStore) Checkout(ctx context.Context, userID int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO orders (user_id, status, created_at, updated_at)
+		VALUES ($1, 'placed', NOW(), NOW())
+		RETURNING id
+	`, userID).Scan(&orderID)
+	if err != nil {
+		return 0, storeError(err)
+	}
+
+	// deleting the cart lines locks them, so a concurrent checkout of the
+	// same cart finds it empty
+	result, err := tx.ExecContext(ctx, `
+		WITH cart AS (
+			DELETE FROM cart_items WHERE user_id = $2
+			RETURNING id, product_id, variation_id, quantity
+		)
+		INSERT INTO order_items (order_id, product_id, variation_id, quantity, unit_price)
+		SELECT $1, c.product_id, c.variation_id, c.quantity,
+		       COALESCE(vp.sale_price, vp.default_sell_price, p.price, 0)
+		FROM cart c
+		JOIN products p ON p.id = c.product_id
+		LEFT JOIN variation_products vp ON vp.id = c.variation_id
+		ORDER BY c.id
+	`, orderID, userID)
+	if err != nil {
+		return 0, storeError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, &store.CheckError{Field: "items"}
+	}
+	return orderID, tx.Commit()
+}
+
+func (s *OrderStore) Create(ctx context.Context, order models.Order) (int, error) {
+	status := order.Status
+	if status == "" {
+		status = "placed"
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var orderID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO orders (user_id, status, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING id
+	`, order.UserID, status).Scan(&orderID)
+	if err != nil {
+		return 0, storeError(err)
+	}
+
+	for _, item := range order.Items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_items (order_id, product_id, variation_id, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5)
+		`, orderID, item.ProductID, item.VariationID, item.Quantity, item.UnitPrice)
+		if err != nil {
+			return 0, storeError(err)
+		}
+	}
+	return orderID, tx.Commit()
+}
+
+func (s *OrderStore) List(ctx context.Context, filter store.OrderFilter) ([]models.Order, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE ($1 = 0 OR user_id = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, filter.UserID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []models.Order{}
+	for rows.Next() {
+		var order models.Order
+		if err := scanOrder(rows, &order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return orders, loadOrderItems(ctx, s.db, orders)
+}
+
+func (s *OrderStore) Get(ctx context.Context, userID, id int) (models.Order, error) {
+	var order models.Order
+	err := scanOrder(s.db.QueryRowContext(ctx, `
+		SELECT `+orderColumns+`
+		FROM orders
+		WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+	`, id, userID), &order)
+	if err != nil {
+		return order, notFound(err)
+	}
+	orders := []models.Order{order}
+	err = loadOrderItems(ctx, s.db, orders)
+	return orders[0], err
+}
+
+func (s *OrderStore) HasPurchased(ctx context.Context, userID, productID int) (bool, error) {
+	var known, purchased bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM products WHERE id = $2),
+		       EXISTS (
+		           SELECT 1 FROM order_items oi
+		           JOIN orders o ON o.id = oi.order_id
+		           WHERE o.user_id = $1 AND oi.product_id = $2 AND o.status <> 'cancelled'
+		       )
+	`, userID, productID).Scan(&known, &purchased)
+	if err != nil {
+		return false, err
+	}
+	if !known {
+		return false, store.ErrNotFound
+	}
+	return purchased, nil
+}
@@ -0,0 +1,123 @@
+// store/postgres/postgres.go
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+// New returns Postgres-backed implementations of every store.
+func New(db *sql.DB) *store.Stores {
+	return &store.Stores{
+		Users:     &UserStore{db: db},
+		Sessions:  &SessionStore{db: db},
+		Addresses: &AddressStore{db: db},
+		Catalog:   &CatalogStore{db: db},
+		Products:  &ProductStore{db: db},
+		Reviews:   &ReviewStore{db: db},
+		Orders:    &OrderStore{db: db},
+		Carts:     &CartStore{db: db},
+		Shipping:  &ShippingStore{db: db},
+		Wishlists: &WishlistStore{db: db},
+		Audit:     &AuditStore{db: db},
+		Media:     &MediaStore{db: db},
+	}
+}
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+)
+
+// pqError returns the Postgres error wrapped in err, if any.
+func pqError(err error) *pq.Error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr
+	}
+	return nil
+}
+
+func isViolation(err error, code string) bool {
+	pqErr := pqError(err)
+	return pqErr != nil && string(pqErr.Code) == code
+}
+
+// keyColumns matches the columns in the detail of a unique or foreign key
+// violation, e.g. "Key (user_id, name)=(1, Gifts) already exists."
+var keyColumns = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+// storeError maps the constraint violations Postgres reports to the
+// store's errors: a duplicate to a *store.ConflictError, a missing
+// reference to a *store.InvalidRefError, a row still referenced to
+// store.ErrConflict and a failed check to a *store.CheckError. Other errors
+// are returned as they are.
+func storeError(err error) error {
+	pqErr := pqError(err)
+	if pqErr == nil {
+		return err
+	}
+	field := pqErr.Column
+	if m := keyColumns.FindStringSubmatch(pqErr.Detail); m != nil {
+		field = m[1]
+	}
+	switch pqErr.Code {
+	case uniqueViolation:
+		return &store.ConflictError{Field: field}
+	case foreignKeyViolation:
+		if strings.Contains(pqErr.Detail, "is still referenced") {
+			return store.ErrConflict
+		}
+		return &store.InvalidRefError{Field: field}
+	case checkViolation:
+		// constraints are named <table>_<column>_check by default
+		field = strings.TrimSuffix(strings.TrimPrefix(pqErr.Constraint, pqErr.Table+"_"), "_check")
+		return &store.CheckError{Field: field}
+	}
+	return err
+}
+
+// notFound maps sql.ErrNoRows to store.ErrNotFound.
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+	return err
+}
+
+// utc returns t in UTC: the timestamp columns have no time zone and hold
+// UTC, and a time in another zone would be compared by its wall clock.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// expectRows returns store.ErrNotFound when an UPDATE or DELETE matched nothing.
+func expectRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
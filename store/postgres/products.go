@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type ProductStore struct {
+	db *sql.DB
+}
+
+const productColumns = `id, brand_id, category_id, product_name, product_code,
+	tax_type, image, serial_number, is_saleable, is_barcode, is_warranty,
+	is_variation, status, rating, rating_count, current_stock, price`
+
+func scanProduct(row rowScanner, product *models.Product) error {
+	return row.Scan(
+		&product.ID, &product.BrandID, &product.CategoryID,
+		&product.ProductName, &product.ProductCode, &product.TaxType, &product.Image,
+		&product.SerialNumber, &product.IsSaleable, &product.IsBarcode, &product.IsWarranty,
+		&product.IsVariation, &product.Status, &product.Rating, &product.RatingCount,
+		&product.CurrentStock, &product.Price,
+	)
+}
+
+// exists reports whether query, a SELECT EXISTS(...), is true.
+func exists(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	var ok bool
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&ok)
+	return ok, err
+}
+
+func (s *ProductStore) Create(ctx context.Context, product models.Product, attributes []models.ProductAttribute, variations []models.VariationProduct) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	refs := []struct {
+		field string
+		skip  bool
+		query string
+		arg   interface{}
+	}{
+		{"brand_id", product.BrandID == 0, `SELECT EXISTS (SELECT 1 FROM brands WHERE id = $1)`, product.BrandID},
+		{"category_id", false, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, product.CategoryID},
+	}
+	for _, ref := range refs {
+		if ref.skip {
+			continue
+		}
+		ok, err := exists(ctx, tx, ref.query, ref.arg)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, &store.InvalidRefError{Field: ref.field}
+		}
+	}
+
+	var productID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO products (
+			brand_id, category_id, p_code, weight, product_name,
+			product_code, price, tax_type, image, serial_number, is_saleable,
+			is_barcode, is_warranty, is_variation, status, current_stock
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id
+	`, product.BrandID, product.CategoryID, product.PCode, product.Weight,
+		product.ProductName, product.ProductCode, product.Price, product.TaxType, product.Image,
+		product.SerialNumber, product.IsSaleable, product.IsBarcode, product.IsWarranty,
+		product.IsVariation, product.Status, product.CurrentStock,
+	).Scan(&productID)
+	if err != nil {
+		if isViolation(err, uniqueViolation) {
+			return 0, &store.ConflictError{Field: "product_code"}
+		}
+		return 0, err
+	}
+
+	for _, attr := range attributes {
+		ok, err := exists(ctx, tx, `SELECT EXISTS (SELECT 1 FROM attributes WHERE id = $1)`, attr.AttributeID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, &store.InvalidRefError{Field: "attribute_id"}
+		}
+		ok, err = exists(ctx, tx, `SELECT EXISTS (SELECT 1 FROM attribute_values WHERE id = $1 AND attribute_id = $2)`,
+			attr.AttributeValueID, attr.AttributeID)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, &store.InvalidRefError{Field: "attribute_value_id"}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO product_attributes (product_id, attribute_id, attribute_value_id)
+			VALUES ($1, $2, $3)
+		`, productID, attr.AttributeID, attr.AttributeValueID)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, variation := range variations {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO variation_products (product_id, sku, sale_price, default_sell_price, discount, image, current_stock)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, productID, variation.SKU, variation.SalePrice, variation.DefaultSellPrice,
+			variation.Discount, variation.Image, variation.CurrentStock)
+		if err != nil {
+			if isViolation(err, uniqueViolation) {
+				return 0, &store.ConflictError{Field: "sku"}
+			}
+			return 0, err
+		}
+	}
+
+	if err := auditAfter(ctx, tx, store.AuditCreate, store.EntityProduct, productID, nil); err != nil {
+		return 0, err
+	}
+	return productID, tx.Commit()
+}
+
+func (s *ProductStore) Get(ctx context.Context, id int) (models.Product, error) {
+	var product models.Product
+	err := scanProduct(s.db.QueryRowContext(ctx, `SELECT `+productColumns+` FROM products WHERE id = $1`, id), &product)
+	if err != nil {
+		return product, notFound(err)
+	}
+	product.Images, err = productImages(ctx, s.db, id)
+	return product, err
+}
+
+func (s *ProductStore) List(ctx context.Context) ([]models.Product, error) {
+	return s.list(ctx, `SELECT `+productColumns+` FROM products ORDER BY id`)
+}
+
+func (s *ProductStore) ListInCategory(ctx context.Context, categoryID int) ([]models.Product, error) {
+	// the paths of the categories below start with the category's path
+	return s.list(ctx, `
+		SELECT `+productColumns+` FROM products
+		WHERE category_id IN (
+			SELECT id FROM categories
+			WHERE path LIKE (SELECT path FROM categories WHERE id = $1) || '%'
+		)
+		ORDER BY id
+	`, categoryID)
+}
+
+func (s *ProductStore) list(ctx context.Context, query string, args ...any) ([]models.Product, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
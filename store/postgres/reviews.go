@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+)
+
+type ReviewStore struct {
+	db *sql.DB
+}
+
+const reviewColumns = `
+	r.id, r.product_id, r.user_id, u.username, r.rating, r.title, r.body, r.status,
+	r.helpful_count, r.not_helpful_count, r.moderated_by, r.moderated_at, r.created_at, r.updated_at`
+
+var reviewSortOrders = map[store.ReviewSort]string{
+	store.ReviewSortRecent:     "r.created_at DESC",
+	store.ReviewSortHelpful:    "r.helpful_count DESC, r.created_at DESC",
+	store.ReviewSortRatingHigh: "r.rating DESC, r.created_at DESC",
+	store.ReviewSortRatingLow:  "r.rating ASC, r.created_at DESC",
+}
+
+func scanReview(row rowScanner, review *models.ProductReview) error {
+	return row.Scan(&review.ID, &review.ProductID, &review.UserID, &review.Username, &review.Rating,
+		&review.Title, &review.Body, &review.Status, &review.HelpfulCount, &review.NotHelpfulCount,
+		&review.ModeratedBy, &review.ModeratedAt, &review.CreatedAt, &review.UpdatedAt)
+}
+
+func collectReviews(rows *sql.Rows) ([]models.ProductReview, error) {
+	defer rows.Close()
+
+	reviews := []models.ProductReview{}
+	for rows.Next() {
+		var review models.ProductReview
+		if err := scanReview(rows, &review); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, rows.Err()
+}
+
+// refreshProductRating recomputes the product's rating average and count
+// from its approved reviews. It must run in the same transaction as the
+// change that affected them.
+func refreshProductRating(ctx context.Context, tx *sql.Tx, productID int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE products SET
+			rating = COALESCE((
+				SELECT ROUND(AVG(rating)::numeric, 2) FROM product_reviews
+				WHERE product_id = $1 AND status = 'approved'
+			), 0),
+			rating_count = (
+				SELECT COUNT(*) FROM product_reviews
+				WHERE product_id = $1 AND status = 'approved'
+			)
+		WHERE id = $1
+	`, productID)
+	return err
+}
+
+// changeReview runs a statement on the review returning its product_id and
+// refreshes that product's rating in the same transaction.
+func (s *ReviewStore) changeReview(ctx context.Context, action string, reviewID int, query string, args ...interface{}) error {
+	_, err := audited(ctx, s.db, action, store.EntityReview, reviewID, func(tx *sql.Tx) (int, error) {
+		var productID int
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(&productID); err != nil {
+			return reviewID, notFound(err)
+		}
+		return reviewID, refreshProductRating(ctx, tx, productID)
+	})
+	return err
+}
+
+func (s *ReviewStore) RatingSummary(ctx context.Context, productID int) (store.RatingSummary, error) {
+	summary := store.RatingSummary{Distribution: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	err := s.db.QueryRowContext(ctx, `SELECT rating, rating_count FROM products WHERE id = $1`, productID).
+		Scan(&summary.Rating, &summary.RatingCount)
+	if err != nil {
+		return summary, notFound(err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT rating, COUNT(*) FROM product_reviews
+		WHERE product_id = $1 AND status = 'approved'
+		GROUP BY rating
+	`, productID)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var stars, count int
+		if err := rows.Scan(&stars, &count); err != nil {
+			return summary, err
+		}
+		summary.Distribution[stars] = count
+	}
+	return summary, rows.Err()
+}
+
+func (s *ReviewStore) ListApproved(ctx context.Context, productID int, sort store.ReviewSort, limit, offset int) ([]models.ProductReview, error) {
+	orderBy, ok := reviewSortOrders[sort]
+	if !ok {
+		orderBy = reviewSortOrders[store.ReviewSortRecent]
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+reviewColumns+`
+		FROM product_reviews r JOIN users u ON u.id = r.user_id
+		WHERE r.product_id = $1 AND r.status = 'approved'
+		ORDER BY `+orderBy+`
+		LIMIT $2 OFFSET $3
+	`, productID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectReviews(rows)
+}
+
+func (s *ReviewStore) List(ctx context.Context, filter store.ReviewFilter) ([]models.ProductReview, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+reviewColumns+`
+		FROM product_reviews r JOIN users u ON u.id = r.user_id
+		WHERE ($1 = '' OR r.status = $1) AND ($2 = 0 OR r.product_id = $2) AND ($3 = 0 OR r.user_id = $3)
+		ORDER BY r.created_at DESC
+		LIMIT $4 OFFSET $5
+	`, filter.Status, filter.ProductID, filter.UserID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	return collectReviews(rows)
+}
+
+func (s *ReviewStore) Create(ctx context.Context, review models.ProductReview) (int, error) {
+	var reviewID int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO product_reviews (product_id, user_id, rating, title, body, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 'pending', NOW(), NOW())
+		RETURNING id
+	`, review.ProductID, review.UserID, review.Rating, review.Title, review.Body).Scan(&reviewID)
+	switch {
+	case isViolation(err, uniqueViolation):
+		return 0, &store.ConflictError{Field: "product_id"}
+	case isViolation(err, foreignKeyViolation):
+		return 0, &store.InvalidRefError{Field: "product_id"}
+	}
+	return reviewID, err
+}
+
+func (s *ReviewStore) Update(ctx context.Context, review models.ProductReview) error {
+	return s.changeReview(ctx, store.AuditUpdate, review.ID, `
+		UPDATE product_reviews
+		SET rating = $1, title = $2, body = $3, status = 'pending',
+		    moderated_by = NULL, moderated_at = NULL, updated_at = NOW()
+		WHERE id = $4 AND user_id = $5
+		RETURNING product_id
+	`, review.Rating, review.Title, review.Body, review.ID, review.UserID)
+}
+
+func (s *ReviewStore) Delete(ctx context.Context, userID, reviewID int) error {
+	return s.changeReview(ctx, store.AuditDelete, reviewID, `
+		DELETE FROM product_reviews WHERE id = $1 AND ($2 = 0 OR user_id = $2)
+		RETURNING product_id
+	`, reviewID, userID)
+}
+
+func (s *ReviewStore) Moderate(ctx context.Context, reviewID int, status string, moderatorID int) error {
+	return s.changeReview(ctx, store.AuditModerate, reviewID, `
+		UPDATE product_reviews
+		SET status = $1, moderated_by = $2, moderated_at = NOW(), updated_at = NOW()
+		WHERE id = $3
+		RETURNING product_id
+	`, status, moderatorID, reviewID)
+}
+
+func (s *ReviewStore) Vote(ctx context.Context, userID, reviewID int, helpful bool) (int, int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var authorID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id FROM product_reviews WHERE id = $1 AND status = 'approved'
+	`, reviewID).Scan(&authorID)
+	if err != nil {
+		return 0, 0, notFound(err)
+	}
+	if authorID == userID {
+		return 0, 0, store.ErrForbidden
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO review_votes (review_id, user_id, is_helpful, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (review_id, user_id) DO UPDATE SET is_helpful = EXCLUDED.is_helpful
+	`, reviewID, userID, helpful)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var helpfulCount, notHelpfulCount int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE product_reviews SET
+			helpful_count = (SELECT COUNT(*) FROM review_votes WHERE review_id = $1 AND is_helpful),
+			not_helpful_count = (SELECT COUNT(*) FROM review_votes WHERE review_id = $1 AND NOT is_helpful)
+		WHERE id = $1
+		RETURNING helpful_count, not_helpful_count
+	`, reviewID).Scan(&helpfulCount, &notHelpfulCount)
+	if err != nil {
+		return 0, 0, err
+	}
+	return helpfulCount, notHelpfulCount, tx.Commit()
+}
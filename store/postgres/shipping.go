@@ -0,0 +1,262 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+type ShippingStore struct {
+	db *sql.DB
+}
+
+const zoneColumns = `id, zone_name, countries, postal_code_patterns, position, status, created_at, updated_at`
+
+func scanZone(row rowScanner, zone *models.ShippingZone) error {
+	return row.Scan(&zone.ID, &zone.ZoneName, pq.Array(&zone.Countries), pq.Array(&zone.PostalCodePatterns),
+		&zone.Position, &zone.Status, &zone.CreatedAt, &zone.UpdatedAt)
+}
+
+// methodsByZone fetches the methods (with tiers) of the given zones, keyed
+// by zone ID
+func (s *ShippingStore) methodsByZone(ctx context.Context, zoneIDs []int, activeOnly bool) (map[int][]models.ShippingMethod, error) {
+	methodsByZone := make(map[int][]models.ShippingMethod)
+	if len(zoneIDs) == 0 {
+		return methodsByZone, nil
+	}
+
+	query := `
+		SELECT id, zone_id, method_name, rate_type, base_rate, free_shipping_threshold,
+		       estimated_days, status, created_at
+		FROM shipping_methods
+		WHERE zone_id = ANY($1)`
+	if activeOnly {
+		query += ` AND status = 1`
+	}
+	query += ` ORDER BY id`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(zoneIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var methods []models.ShippingMethod
+	methodIDs := []int{}
+	for rows.Next() {
+		var method models.ShippingMethod
+		err := rows.Scan(&method.ID, &method.ZoneID, &method.MethodName, &method.RateType, &method.BaseRate,
+			&method.FreeShippingThreshold, &method.EstimatedDays, &method.Status, &method.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		method.Tiers = []models.ShippingRateTier{}
+		methods = append(methods, method)
+		methodIDs = append(methodIDs, method.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(methodIDs) > 0 {
+		tierRows, err := s.db.QueryContext(ctx, `
+			SELECT id, method_id, min_value, max_value, rate
+			FROM shipping_rate_tiers
+			WHERE method_id = ANY($1)
+			ORDER BY min_value
+		`, pq.Array(methodIDs))
+		if err != nil {
+			return nil, err
+		}
+		defer tierRows.Close()
+
+		tiersByMethod := make(map[int][]models.ShippingRateTier)
+		for tierRows.Next() {
+			var tier models.ShippingRateTier
+			if err := tierRows.Scan(&tier.ID, &tier.MethodID, &tier.MinValue, &tier.MaxValue, &tier.Rate); err != nil {
+				return nil, err
+			}
+			tiersByMethod[tier.MethodID] = append(tiersByMethod[tier.MethodID], tier)
+		}
+		if err := tierRows.Err(); err != nil {
+			return nil, err
+		}
+		for i := range methods {
+			if tiers, ok := tiersByMethod[methods[i].ID]; ok {
+				methods[i].Tiers = tiers
+			}
+		}
+	}
+
+	for _, method := range methods {
+		methodsByZone[method.ZoneID] = append(methodsByZone[method.ZoneID], method)
+	}
+	return methodsByZone, nil
+}
+
+func (s *ShippingStore) CreateZone(ctx context.Context, zone models.ShippingZone) (int, error) {
+	return audited(ctx, s.db, store.AuditCreate, store.EntityShippingZone, 0, func(tx *sql.Tx) (int, error) {
+		var zoneID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO shipping_zones (zone_name, countries, postal_code_patterns, position, status, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+			RETURNING id
+		`, zone.ZoneName, pq.Array(zone.Countries), pq.Array(zone.PostalCodePatterns),
+			zone.Position, zone.Status).Scan(&zoneID)
+		return zoneID, err
+	})
+}
+
+func (s *ShippingStore) ListZones(ctx context.Context, activeOnly bool) ([]models.ShippingZone, error) {
+	query := `SELECT ` + zoneColumns + ` FROM shipping_zones`
+	if activeOnly {
+		query += ` WHERE status = 1`
+	}
+	query += ` ORDER BY position, id`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	zones := []models.ShippingZone{}
+	zoneIDs := []int{}
+	for rows.Next() {
+		var zone models.ShippingZone
+		if err := scanZone(rows, &zone); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+		zoneIDs = append(zoneIDs, zone.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	methodsByZone, err := s.methodsByZone(ctx, zoneIDs, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	for i := range zones {
+		zones[i].Methods = methodsByZone[zones[i].ID]
+		if zones[i].Methods == nil {
+			zones[i].Methods = []models.ShippingMethod{}
+		}
+	}
+	return zones, nil
+}
+
+func (s *ShippingStore) GetZone(ctx context.Context, id int) (models.ShippingZone, error) {
+	var zone models.ShippingZone
+	err := scanZone(s.db.QueryRowContext(ctx, `SELECT `+zoneColumns+` FROM shipping_zones WHERE id = $1`, id), &zone)
+	if err != nil {
+		return zone, notFound(err)
+	}
+
+	methodsByZone, err := s.methodsByZone(ctx, []int{zone.ID}, false)
+	if err != nil {
+		return zone, err
+	}
+	zone.Methods = methodsByZone[zone.ID]
+	if zone.Methods == nil {
+		zone.Methods = []models.ShippingMethod{}
+	}
+	return zone, nil
+}
+
+func (s *ShippingStore) UpdateZone(ctx context.Context, zone models.ShippingZone) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityShippingZone, zone.ID, `
+		UPDATE shipping_zones
+		SET zone_name = $1, countries = $2, postal_code_patterns = $3, position = $4, status = $5, updated_at = NOW()
+		WHERE id = $6
+	`, zone.ZoneName, pq.Array(zone.Countries), pq.Array(zone.PostalCodePatterns),
+		zone.Position, zone.Status, zone.ID)
+}
+
+func (s *ShippingStore) DeleteZone(ctx context.Context, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityShippingZone, id, `DELETE FROM shipping_zones WHERE id = $1`, id)
+}
+
+func insertRateTiers(ctx context.Context, tx *sql.Tx, methodID int, tiers []models.ShippingRateTier) error {
+	for _, tier := range tiers {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO shipping_rate_tiers (method_id, min_value, max_value, rate)
+			VALUES ($1, $2, $3, $4)
+		`, methodID, tier.MinValue, tier.MaxValue, tier.Rate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ShippingStore) CreateMethod(ctx context.Context, method models.ShippingMethod) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var methodID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO shipping_methods (zone_id, method_name, rate_type, base_rate, free_shipping_threshold, estimated_days, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id
+	`, method.ZoneID, method.MethodName, method.RateType, method.BaseRate, method.FreeShippingThreshold,
+		method.EstimatedDays, method.Status).Scan(&methodID)
+	if err != nil {
+		if isViolation(err, foreignKeyViolation) {
+			return 0, &store.InvalidRefError{Field: "zone_id"}
+		}
+		return 0, err
+	}
+
+	if err := insertRateTiers(ctx, tx, methodID, method.Tiers); err != nil {
+		return 0, err
+	}
+	if err := auditAfter(ctx, tx, store.AuditCreate, store.EntityShippingMethod, methodID, nil); err != nil {
+		return 0, err
+	}
+	return methodID, tx.Commit()
+}
+
+func (s *ShippingStore) UpdateMethod(ctx context.Context, method models.ShippingMethod) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditBefore(ctx, tx, store.EntityShippingMethod, method.ID)
+	if err != nil {
+		return err
+	}
+	err = expectRows(tx.ExecContext(ctx, `
+		UPDATE shipping_methods
+		SET method_name = $1, rate_type = $2, base_rate = $3, free_shipping_threshold = $4,
+		    estimated_days = $5, status = $6
+		WHERE id = $7
+	`, method.MethodName, method.RateType, method.BaseRate, method.FreeShippingThreshold,
+		method.EstimatedDays, method.Status, method.ID))
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM shipping_rate_tiers WHERE method_id = $1`, method.ID); err != nil {
+		return err
+	}
+	if err := insertRateTiers(ctx, tx, method.ID, method.Tiers); err != nil {
+		return err
+	}
+	if err := auditAfter(ctx, tx, store.AuditUpdate, store.EntityShippingMethod, method.ID, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *ShippingStore) DeleteMethod(ctx context.Context, id int) error {
+	return auditedExec(ctx, s.db, store.AuditDelete, store.EntityShippingMethod, id, `DELETE FROM shipping_methods WHERE id = $1`, id)
+}
@@ -0,0 +1,427 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+type UserStore struct {
+	db *sql.DB
+}
+
+const userColumns = `id, username, email, role, phone_number, image, is_verified, is_blocked,
+	created_at, updated_at, deleted_at, erased_at`
+
+func scanUser(row rowScanner, user *models.User) error {
+	return row.Scan(&user.ID, &user.Username, &user.Email, &user.Role,
+		&user.PhoneNumber, &user.Image, &user.IsVerified, &user.IsBlocked,
+		&user.CreatedAt, &user.UpdatedAt, &user.DeletedAt, &user.ErasedAt)
+}
+
+func (s *UserStore) Create(ctx context.Context, user models.User, passwordHash string) (int, error) {
+	userID, err := audited(ctx, s.db, store.AuditCreate, store.EntityUser, 0, func(tx *sql.Tx) (int, error) {
+		var userID int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO users (username, email, role, password, phone_number, image, is_verified, is_blocked, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+			RETURNING id
+		`, user.Username, user.Email, user.Role, passwordHash,
+			user.PhoneNumber, user.Image, user.IsVerified, user.IsBlocked).Scan(&userID)
+		return userID, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (s *UserStore) Get(ctx context.Context, id int) (models.User, error) {
+	var user models.User
+	err := scanUser(s.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE id = $1`, id), &user)
+	return user, notFound(err)
+}
+
+// likePattern matches values containing s, with LIKE wildcards in s escaped.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+func (s *UserStore) List(ctx context.Context, filter store.UserFilter) ([]models.User, error) {
+	var afterCreatedAt *time.Time
+	afterID := 0
+	if filter.After != nil {
+		afterCreatedAt, afterID = utc(&filter.After.CreatedAt), filter.After.ID
+	}
+	search := ""
+	if filter.Search != "" {
+		search = likePattern(filter.Search)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE ($1 = '' OR username ILIKE $1 OR email ILIKE $1 OR phone_number ILIKE $1)
+		  AND ($2 = '' OR role = $2)
+		  AND ($3::boolean IS NULL OR is_verified = $3)
+		  AND ($4::boolean IS NULL OR is_blocked = $4)
+		  AND ($5::timestamp IS NULL OR created_at >= $5)
+		  AND ($6::timestamp IS NULL OR created_at < $6)
+		  AND ($7::timestamp IS NULL OR (created_at, id) < ($7, $8))
+		  AND (deleted_at IS NOT NULL) = $9
+		ORDER BY created_at DESC, id DESC
+		LIMIT $10
+	`, search, filter.Role, filter.IsVerified, filter.IsBlocked,
+		utc(filter.CreatedFrom), utc(filter.CreatedTo), afterCreatedAt, afterID, filter.Deleted, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *UserStore) Update(ctx context.Context, user models.User) error {
+	return auditedExec(ctx, s.db, store.AuditUpdate, store.EntityUser, user.ID, `
+		UPDATE users
+		SET username = $1, email = $2, role = $3, phone_number = $4, image = $5,
+			is_verified = $6, is_blocked = $7, updated_at = NOW()
+		WHERE id = $8 AND deleted_at IS NULL
+	`, user.Username, user.Email, user.Role, user.PhoneNumber, user.Image,
+		user.IsVerified, user.IsBlocked, user.ID)
+}
+
+// bulkChanges holds, per action, the SET clause and the condition of the
+// users it actually changes; $2 is the role of UserBulkSetRole.
+var bulkChanges = map[store.UserBulkAction]struct{ set, changes string }{
+	store.UserBulkBlock:   {"is_blocked = TRUE", "NOT is_blocked"},
+	store.UserBulkUnblock: {"is_blocked = FALSE", "is_blocked"},
+	store.UserBulkVerify:  {"is_verified = TRUE", "NOT is_verified"},
+	store.UserBulkSetRole: {"role = $2", "role <> $2"},
+}
+
+func (s *UserStore) BulkUpdate(ctx context.Context, action store.UserBulkAction, ids []int, role string) ([]store.BulkResult, error) {
+	change, ok := bulkChanges[action]
+	if !ok {
+		return nil, fmt.Errorf("postgres: unknown bulk action %q", action)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	found, err := collectIDs(tx.QueryContext(ctx, `SELECT id FROM users WHERE id = ANY($1) AND deleted_at IS NULL FOR UPDATE`, pq.Array(ids)))
+	if err != nil {
+		return nil, err
+	}
+	before := make(map[int]json.RawMessage)
+	for id := range found {
+		if before[id], err = auditBefore(ctx, tx, store.EntityUser, id); err != nil {
+			return nil, err
+		}
+	}
+	args := []any{pq.Array(ids)}
+	if action == store.UserBulkSetRole {
+		args = append(args, role)
+	}
+	updated, err := collectIDs(tx.QueryContext(ctx, `
+		UPDATE users SET `+change.set+`, updated_at = NOW()
+		WHERE id = ANY($1) AND deleted_at IS NULL AND `+change.changes+`
+		RETURNING id
+	`, args...))
+	if err != nil {
+		return nil, err
+	}
+	for id := range updated {
+		if err := auditAfter(ctx, tx, string(action), store.EntityUser, id, before[id]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	results := make([]store.BulkResult, len(ids))
+	for i, id := range ids {
+		results[i] = store.BulkResult{UserID: id, Status: store.BulkNotFound}
+		if updated[id] {
+			results[i].Status = store.BulkUpdated
+		} else if found[id] {
+			results[i].Status = store.BulkUnchanged
+		}
+	}
+	return results, nil
+}
+
+// collectIDs reads a single-column result of IDs into a set.
+func collectIDs(rows *sql.Rows, err error) (map[int]bool, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+func (s *UserStore) Delete(ctx context.Context, id int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := auditBefore(ctx, tx, store.EntityUser, id)
+	if err != nil {
+		return err
+	}
+	err = expectRows(tx.ExecContext(ctx, `
+		UPDATE users SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM login_sessions WHERE user_id = $1`, id); err != nil {
+		return err
+	}
+	if err := auditAfter(ctx, tx, store.AuditDelete, store.EntityUser, id, before); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *UserStore) Restore(ctx context.Context, id int, since time.Time) error {
+	return auditedExec(ctx, s.db, store.AuditRestore, store.EntityUser, id, `
+		UPDATE users SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at >= $2 AND erased_at IS NULL
+	`, id, since)
+}
+
+// erasedRows are the tables whose rows of an erased user are removed.
+// Wishlist items go with their lists; reviews and votes stay.
+var erasedRows = []string{"login_sessions", "addresses", "cart_items", "wishlist_notifications", "wishlists"}
+
+func (s *UserStore) Erase(ctx context.Context, id int) error {
+	_, err := audited(ctx, s.db, store.AuditErase, store.EntityUser, id, func(tx *sql.Tx) (int, error) {
+		return id, eraseUser(ctx, tx, id)
+	})
+	return err
+}
+
+// eraseUser anonymizes the user's row and deletes their personal rows,
+// including the personal data the audit log holds about them.
+func eraseUser(ctx context.Context, tx *sql.Tx, id int) error {
+	err := expectRows(tx.ExecContext(ctx, `
+		UPDATE users
+		SET username = 'deleted-' || id, email = 'deleted-' || id || '@deleted.invalid',
+		    password = '', phone_number = NULL, image = NULL,
+		    is_verified = FALSE, is_blocked = TRUE,
+		    deleted_at = COALESCE(deleted_at, NOW()), erased_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND erased_at IS NULL
+	`, id))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE audit_log SET before = before - $2::text[], after = after - $2::text[]
+		WHERE entity_type = 'user' AND entity_id = $1
+	`, id, pq.Array(store.PersonalFields))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE audit_log SET before = NULL, after = NULL
+		WHERE entity_type = 'address' AND entity_id IN (
+			SELECT id FROM addresses WHERE user_id = $1
+			UNION
+			SELECT entity_id FROM audit_log
+			WHERE entity_type = 'address' AND $1::text IN (before->>'user_id', after->>'user_id')
+		)
+	`, id)
+	if err != nil {
+		return err
+	}
+	for _, table := range erasedRows {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = $1`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UserStore) PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	ids, err := collectIDs(tx.QueryContext(ctx, `
+		SELECT id FROM users
+		WHERE deleted_at < $1 AND erased_at IS NULL
+		FOR UPDATE
+	`, cutoff))
+	if err != nil {
+		return 0, err
+	}
+	for id := range ids {
+		if err := eraseUser(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+func (s *UserStore) CredentialsByIdentifier(ctx context.Context, identifier string) (store.Credentials, error) {
+	var cred store.Credentials
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, email, password, role, is_blocked
+		FROM users
+		WHERE (username = $1 OR email = $1) AND deleted_at IS NULL
+	`, identifier).Scan(&cred.ID, &cred.Username, &cred.Email, &cred.PasswordHash, &cred.Role, &cred.IsBlocked)
+	return cred, notFound(err)
+}
+
+func (s *UserStore) CredentialsByID(ctx context.Context, id int) (store.Credentials, error) {
+	var cred store.Credentials
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, username, email, password, role, is_blocked
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&cred.ID, &cred.Username, &cred.Email, &cred.PasswordHash, &cred.Role, &cred.IsBlocked)
+	return cred, notFound(err)
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+func (s *SessionStore) Create(ctx context.Context, session models.LoginSession) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO login_sessions (user_id, browser, os, device, ip_address, login_at, impersonator_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6, $7)
+		RETURNING id
+	`, session.UserID, session.Browser, session.OS, session.Device, session.IPAddress,
+		session.ImpersonatorID, session.ExpiresAt).Scan(&id)
+	if isViolation(err, foreignKeyViolation) {
+		return 0, &store.InvalidRefError{Field: "user_id"}
+	}
+	return id, err
+}
+
+const sessionColumns = `id, user_id, browser, os, device, ip_address, login_at, impersonator_id, expires_at`
+
+func scanSession(row rowScanner, session *models.LoginSession) error {
+	return row.Scan(&session.ID, &session.UserID, &session.Browser, &session.OS,
+		&session.Device, &session.IPAddress, &session.LoginAt, &session.ImpersonatorID, &session.ExpiresAt)
+}
+
+func (s *SessionStore) Get(ctx context.Context, userID, id int) (models.LoginSession, error) {
+	var session models.LoginSession
+	err := scanSession(s.db.QueryRowContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM login_sessions
+		WHERE id = $1 AND user_id = $2
+	`, id, userID), &session)
+	return session, notFound(err)
+}
+
+func (s *SessionStore) ListByUser(ctx context.Context, userID int) ([]models.LoginSession, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM login_sessions
+		WHERE user_id = $1
+		ORDER BY login_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.LoginSession{}
+	for rows.Next() {
+		var session models.LoginSession
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SessionStore) Delete(ctx context.Context, userID, id int) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM login_sessions WHERE id = $1 AND user_id = $2`, id, userID)
+	return expectRows(result, err)
+}
+
+func (s *SessionStore) DeleteByUser(ctx context.Context, userID int) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+func (s *SessionStore) LogImpersonation(ctx context.Context, req models.ImpersonationRequest) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO impersonation_requests
+			(session_id, impersonator_id, user_id, method, path, status, ip_address, user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, req.SessionID, req.ImpersonatorID, req.UserID, req.Method, req.Path, req.Status,
+		req.IPAddress, req.UserAgent)
+	return err
+}
+
+func (s *SessionStore) ListImpersonations(ctx context.Context, filter store.ImpersonationFilter) ([]models.ImpersonationRequest, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, session_id, impersonator_id, user_id, method, path, status, ip_address, user_agent, created_at
+		FROM impersonation_requests
+		WHERE ($1 = 0 OR user_id = $1) AND ($2 = 0 OR impersonator_id = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, filter.UserID, filter.ImpersonatorID, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.ImpersonationRequest{}
+	for rows.Next() {
+		var req models.ImpersonationRequest
+		err := rows.Scan(&req.ID, &req.SessionID, &req.ImpersonatorID, &req.UserID, &req.Method,
+			&req.Path, &req.Status, &req.IPAddress, &req.UserAgent, &req.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
@@ -0,0 +1,353 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"my-api/models"
+	"my-api/store"
+
+	"github.com/lib/pq"
+)
+
+type WishlistStore struct {
+	db *sql.DB
+}
+
+// wishlistOwnedBy returns store.ErrNotFound unless the list belongs to userID.
+func wishlistOwnedBy(ctx context.Context, tx *sql.Tx, wishlistID, userID int) error {
+	var owned bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM wishlists WHERE id = $1 AND user_id = $2)
+	`, wishlistID, userID).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s *WishlistStore) loadItems(ctx context.Context, wishlistID int) ([]models.WishlistItem, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT wi.id, wi.wishlist_id, wi.product_id, wi.variation_id, p.product_name, vp.sku,
+		       COALESCE(NULLIF(vp.image, ''), p.image, ''),
+		       CASE WHEN wi.variation_id IS NULL THEN p.price
+		            ELSE COALESCE(vp.sale_price, vp.default_sell_price) END,
+		       wi.price_at_add, COALESCE(vp.current_stock, p.current_stock, 0),
+		       wi.notify_price_drop, wi.notify_back_in_stock, wi.added_at
+		FROM wishlist_items wi
+		JOIN products p ON p.id = wi.product_id
+		LEFT JOIN variation_products vp ON vp.id = wi.variation_id
+		WHERE wi.wishlist_id = $1
+		ORDER BY wi.added_at DESC, wi.id DESC
+	`, wishlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.WishlistItem{}
+	for rows.Next() {
+		var item models.WishlistItem
+		err := rows.Scan(&item.ID, &item.WishlistID, &item.ProductID, &item.VariationID, &item.ProductName,
+			&item.SKU, &item.Image, &item.CurrentPrice, &item.PriceAtAdd, &item.CurrentStock,
+			&item.NotifyPriceDrop, &item.NotifyBackInStock, &item.AddedAt)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// insertWishlistItem adds a product or variation to a list, recording its
+// current price so later price drops can be shown. It returns sql.ErrNoRows
+// when the item is already on the list.
+func insertWishlistItem(ctx context.Context, tx *sql.Tx, wishlistID int, item store.WishlistItemInput) (int, error) {
+	var itemID int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO wishlist_items (wishlist_id, product_id, variation_id, price_at_add,
+		                            notify_price_drop, notify_back_in_stock, added_at)
+		SELECT $1, p.id, vp.id,
+		       CASE WHEN vp.id IS NULL THEN p.price ELSE COALESCE(vp.sale_price, vp.default_sell_price) END,
+		       $4, $5, NOW()
+		FROM products p
+		LEFT JOIN variation_products vp ON vp.id = $3 AND vp.product_id = p.id
+		WHERE p.id = $2
+		ON CONFLICT (wishlist_id, product_id, COALESCE(variation_id, 0)) DO NOTHING
+		RETURNING id
+	`, wishlistID, item.ProductID, item.VariationID, item.NotifyPriceDrop, item.NotifyBackInStock).Scan(&itemID)
+	return itemID, err
+}
+
+func (s *WishlistStore) List(ctx context.Context, userID int) ([]models.Wishlist, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT w.id, w.user_id, w.name, w.share_token, COUNT(wi.id), w.created_at, w.updated_at
+		FROM wishlists w
+		LEFT JOIN wishlist_items wi ON wi.wishlist_id = w.id
+		WHERE w.user_id = $1
+		GROUP BY w.id
+		ORDER BY w.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	wishlists := []models.Wishlist{}
+	for rows.Next() {
+		var w models.Wishlist
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.ItemCount, &w.CreatedAt, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		wishlists = append(wishlists, w)
+	}
+	return wishlists, rows.Err()
+}
+
+func (s *WishlistStore) Create(ctx context.Context, userID int, name string) (int, error) {
+	var wishlistID int
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO wishlists (user_id, name, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		RETURNING id
+	`, userID, name).Scan(&wishlistID)
+	if isViolation(err, uniqueViolation) {
+		return 0, &store.ConflictError{Field: "name"}
+	}
+	return wishlistID, err
+}
+
+func (s *WishlistStore) Get(ctx context.Context, userID, id int) (models.Wishlist, error) {
+	var w models.Wishlist
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, name, share_token, created_at, updated_at
+		FROM wishlists WHERE id = $1 AND user_id = $2
+	`, id, userID).Scan(&w.ID, &w.UserID, &w.Name, &w.ShareToken, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return w, notFound(err)
+	}
+
+	w.Items, err = s.loadItems(ctx, w.ID)
+	w.ItemCount = len(w.Items)
+	return w, err
+}
+
+func (s *WishlistStore) Rename(ctx context.Context, userID, id int, name string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE wishlists SET name = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+	`, name, id, userID)
+	if isViolation(err, uniqueViolation) {
+		return &store.ConflictError{Field: "name"}
+	}
+	return expectRows(result, err)
+}
+
+func (s *WishlistStore) Delete(ctx context.Context, userID, id int) error {
+	return expectRows(s.db.ExecContext(ctx, `DELETE FROM wishlists WHERE id = $1 AND user_id = $2`, id, userID))
+}
+
+func (s *WishlistStore) AddItem(ctx context.Context, userID, wishlistID int, item store.WishlistItemInput) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := wishlistOwnedBy(ctx, tx, wishlistID, userID); err != nil {
+		return 0, err
+	}
+
+	var valid bool
+	if item.VariationID != nil {
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM variation_products WHERE id = $1 AND product_id = $2)
+		`, *item.VariationID, item.ProductID).Scan(&valid)
+	} else {
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)`, item.ProductID).Scan(&valid)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if !valid {
+		if item.VariationID != nil {
+			return 0, &store.InvalidRefError{Field: "variation_id"}
+		}
+		return 0, &store.InvalidRefError{Field: "product_id"}
+	}
+
+	itemID, err := insertWishlistItem(ctx, tx, wishlistID, item)
+	if err == sql.ErrNoRows {
+		return 0, &store.ConflictError{Field: "product_id"}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE wishlists SET updated_at = NOW() WHERE id = $1`, wishlistID); err != nil {
+		return 0, err
+	}
+	return itemID, tx.Commit()
+}
+
+func (s *WishlistStore) RemoveItem(ctx context.Context, userID, wishlistID, itemID int) error {
+	return expectRows(s.db.ExecContext(ctx, `
+		DELETE FROM wishlist_items wi
+		USING wishlists w
+		WHERE wi.id = $1 AND wi.wishlist_id = $2 AND w.id = wi.wishlist_id AND w.user_id = $3
+	`, itemID, wishlistID, userID))
+}
+
+func (s *WishlistStore) MoveToCart(ctx context.Context, userID, wishlistID int, itemIDs []int) ([]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := wishlistOwnedBy(ctx, tx, wishlistID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM wishlist_items
+		WHERE wishlist_id = $1 AND (COALESCE(cardinality($2::int[]), 0) = 0 OR id = ANY($2))
+		RETURNING id, product_id, variation_id
+	`, wishlistID, pq.Array(itemIDs))
+	if err != nil {
+		return nil, err
+	}
+	type movedItem struct {
+		ID          int
+		ProductID   int
+		VariationID *int
+	}
+	var moved []movedItem
+	for rows.Next() {
+		var item movedItem
+		if err := rows.Scan(&item.ID, &item.ProductID, &item.VariationID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		moved = append(moved, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	movedIDs := []int{}
+	for _, item := range moved {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO cart_items (user_id, product_id, variation_id, quantity, created_at, updated_at)
+			VALUES ($1, $2, $3, 1, NOW(), NOW())
+			ON CONFLICT (user_id, product_id, COALESCE(variation_id, 0))
+			DO UPDATE SET quantity = cart_items.quantity + 1, updated_at = NOW()
+		`, userID, item.ProductID, item.VariationID)
+		if err != nil {
+			return nil, err
+		}
+		movedIDs = append(movedIDs, item.ID)
+	}
+	return movedIDs, tx.Commit()
+}
+
+func (s *WishlistStore) SaveForLater(ctx context.Context, userID, cartItemID int, listName string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	item := store.WishlistItemInput{NotifyPriceDrop: true, NotifyBackInStock: true}
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM cart_items WHERE id = $1 AND user_id = $2
+		RETURNING product_id, variation_id
+	`, cartItemID, userID).Scan(&item.ProductID, &item.VariationID)
+	if err != nil {
+		return 0, notFound(err)
+	}
+
+	var wishlistID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO wishlists (user_id, name, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (user_id, name) DO UPDATE SET updated_at = NOW()
+		RETURNING id
+	`, userID, listName).Scan(&wishlistID)
+	if err != nil {
+		return 0, err
+	}
+
+	// an item already on the list is simply kept there
+	if _, err := insertWishlistItem(ctx, tx, wishlistID, item); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return wishlistID, tx.Commit()
+}
+
+func (s *WishlistStore) SetShareToken(ctx context.Context, userID, id int, token *string) error {
+	return expectRows(s.db.ExecContext(ctx, `
+		UPDATE wishlists SET share_token = $1, updated_at = NOW()
+		WHERE id = $2 AND user_id = $3
+	`, token, id, userID))
+}
+
+func (s *WishlistStore) GetShared(ctx context.Context, token string) (models.Wishlist, error) {
+	var w models.Wishlist
+	err := s.db.QueryRowContext(ctx, `
+		SELECT w.id, w.name, w.created_at, w.updated_at
+		FROM wishlists w
+		JOIN users u ON u.id = w.user_id
+		WHERE w.share_token = $1 AND u.deleted_at IS NULL
+	`, token).Scan(&w.ID, &w.Name, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return w, notFound(err)
+	}
+
+	w.Items, err = s.loadItems(ctx, w.ID)
+	w.ItemCount = len(w.Items)
+	return w, err
+}
+
+func (s *WishlistStore) ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]models.WishlistNotification, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT n.id, n.user_id, n.wishlist_item_id, n.product_id, n.variation_id, p.product_name,
+		       n.type, n.old_price, n.new_price, n.created_at, n.read_at
+		FROM wishlist_notifications n
+		JOIN products p ON p.id = n.product_id
+		WHERE n.user_id = $1 AND (NOT $2 OR n.read_at IS NULL)
+		ORDER BY n.created_at DESC, n.id DESC
+		LIMIT $3 OFFSET $4
+	`, userID, unreadOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.WishlistNotification{}
+	for rows.Next() {
+		var n models.WishlistNotification
+		err := rows.Scan(&n.ID, &n.UserID, &n.WishlistItemID, &n.ProductID, &n.VariationID, &n.ProductName,
+			&n.Type, &n.OldPrice, &n.NewPrice, &n.CreatedAt, &n.ReadAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (s *WishlistStore) MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int64, error) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE wishlist_notifications SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL AND (COALESCE(cardinality($2::int[]), 0) = 0 OR id = ANY($2))
+	`, userID, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,404 @@
+// store/store.go
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"my-api/models"
+)
+
+var (
+	ErrNotFound         = errors.New("store: not found")
+	ErrConflict         = errors.New("store: conflict")
+	ErrInvalidReference = errors.New("store: invalid reference")
+	ErrForbidden        = errors.New("store: forbidden")
+	ErrInvalid          = errors.New("store: invalid value")
+)
+
+// ConflictError reports a unique constraint violation on Field
+// (e.g. "username", "email"). It matches ErrConflict with errors.Is.
+type ConflictError struct {
+	Field string
+}
+
+func (e *ConflictError) Error() string { return "store: duplicate " + e.Field }
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// InvalidRefError reports a foreign key (e.g. "brand_id") that does not
+// resolve. It matches ErrInvalidReference with errors.Is.
+type InvalidRefError struct {
+	Field string
+}
+
+func (e *InvalidRefError) Error() string { return "store: invalid " + e.Field }
+
+func (e *InvalidRefError) Is(target error) bool { return target == ErrInvalidReference }
+
+// CheckError reports a value of Field (e.g. "quantity") refused by a check
+// constraint. It matches ErrInvalid with errors.Is.
+type CheckError struct {
+	Field string
+}
+
+func (e *CheckError) Error() string { return "store: invalid " + e.Field }
+
+func (e *CheckError) Is(target error) bool { return target == ErrInvalid }
+
+// AnyUser can be passed as the owner of a user-scoped record to skip the
+// ownership check (admin operations).
+const AnyUser = 0
+
+// Stores bundles one implementation of every aggregate store.
+type Stores struct {
+	Users     UserStore
+	Sessions  SessionStore
+	Addresses AddressStore
+	Catalog   CatalogStore
+	Products  ProductStore
+	Reviews   ReviewStore
+	Orders    OrderStore
+	Carts     CartStore
+	Shipping  ShippingStore
+	Wishlists WishlistStore
+	Audit     AuditStore
+	Media     MediaStore
+}
+
+// Credentials is the subset of a user needed to authenticate and mint tokens.
+type Credentials struct {
+	ID           int
+	Username     string
+	Email        string
+	PasswordHash string
+	Role         string
+	IsBlocked    bool
+}
+
+// UserFilter selects users for the admin listing. Zero values match any
+// user. Users are ordered newest first; After continues a listing behind
+// the last user of the previous page.
+type UserFilter struct {
+	Search      string // part of the username, email or phone number
+	Role        string
+	IsVerified  *bool
+	IsBlocked   *bool
+	CreatedFrom *time.Time // inclusive
+	CreatedTo   *time.Time // exclusive
+	Deleted     bool       // list soft-deleted users instead of active ones
+	After       *UserCursor
+	Limit       int
+}
+
+// UserCursor is the position of a user in the admin listing.
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// UserBulkAction is a change applied to many users at once.
+type UserBulkAction string
+
+const (
+	UserBulkBlock   UserBulkAction = "block"
+	UserBulkUnblock UserBulkAction = "unblock"
+	UserBulkVerify  UserBulkAction = "verify"
+	UserBulkSetRole UserBulkAction = "set_role"
+)
+
+// Outcomes of a bulk action for one user.
+const (
+	BulkUpdated   = "updated"
+	BulkUnchanged = "unchanged" // the user already had the requested state
+	BulkNotFound  = "not_found"
+)
+
+type BulkResult struct {
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+type UserStore interface {
+	// Create inserts a user and returns its ID; duplicates yield a
+	// *ConflictError for "username" or "email".
+	Create(ctx context.Context, user models.User, passwordHash string) (int, error)
+	Get(ctx context.Context, id int) (models.User, error)
+	List(ctx context.Context, filter UserFilter) ([]models.User, error)
+	// Update saves the profile, role and flags of user.ID.
+	Update(ctx context.Context, user models.User) error
+	// BulkUpdate applies action (with role for UserBulkSetRole) to every
+	// user in ids within one transaction and returns one result per ID, in
+	// the order given.
+	BulkUpdate(ctx context.Context, action UserBulkAction, ids []int, role string) ([]BulkResult, error)
+	// Delete soft-deletes the user and ends their sessions. Get still
+	// returns deleted users; List, Update, BulkUpdate and the credential
+	// lookups treat them as not found.
+	Delete(ctx context.Context, id int) error
+	// Restore undeletes a user deleted at or after since and not erased.
+	Restore(ctx context.Context, id int, since time.Time) error
+	// Erase anonymizes the user's row and removes their sessions, addresses,
+	// cart, wishlists and notifications in one transaction. The row itself
+	// stays so reviews (and orders) keep pointing at it.
+	Erase(ctx context.Context, id int) error
+	// PurgeDeleted erases every user soft-deleted before cutoff and returns
+	// how many there were.
+	PurgeDeleted(ctx context.Context, cutoff time.Time) (int, error)
+	// CredentialsByIdentifier looks a user up by username or email.
+	CredentialsByIdentifier(ctx context.Context, identifier string) (Credentials, error)
+	CredentialsByID(ctx context.Context, id int) (Credentials, error)
+}
+
+// ImpersonationFilter selects logged impersonation requests; zero values
+// match any.
+type ImpersonationFilter struct {
+	UserID         int
+	ImpersonatorID int
+	Limit, Offset  int
+}
+
+type SessionStore interface {
+	// Create records a login and returns the new session's ID.
+	Create(ctx context.Context, session models.LoginSession) (int, error)
+	// Get returns a session of the user; sessions of other users are not
+	// found.
+	Get(ctx context.Context, userID, id int) (models.LoginSession, error)
+	ListByUser(ctx context.Context, userID int) ([]models.LoginSession, error)
+	// Delete ends one session of the user; sessions of other users are not
+	// found.
+	Delete(ctx context.Context, userID, id int) error
+	DeleteByUser(ctx context.Context, userID int) error
+	// LogImpersonation records a request made with an impersonation token.
+	LogImpersonation(ctx context.Context, req models.ImpersonationRequest) error
+	// ListImpersonations returns logged requests, newest first.
+	ListImpersonations(ctx context.Context, filter ImpersonationFilter) ([]models.ImpersonationRequest, error)
+}
+
+// AddressUsage is a purpose an address book entry can be flagged for.
+type AddressUsage string
+
+const (
+	UsageShipping AddressUsage = "shipping"
+	UsageBilling  AddressUsage = "billing"
+)
+
+// AddressStore manages the users' address books. Each user has at most one
+// default address per usage. Methods taking a userID only touch that user's
+// addresses unless AnyUser is passed, and methods taking a usage treat
+// addresses not flagged for it as not found (an empty usage matches any).
+type AddressStore interface {
+	List(ctx context.Context, userID int, usage AddressUsage) ([]models.Address, error)
+	Get(ctx context.Context, userID, id int, usage AddressUsage) (models.Address, error)
+	GetDefault(ctx context.Context, userID int, usage AddressUsage) (models.Address, error)
+	// Create stores the address and returns it as saved: for every usage it
+	// is flagged for, it becomes the default when the user has none yet. An
+	// unknown user yields an *InvalidRefError for "user_id".
+	Create(ctx context.Context, addr models.Address) (models.Address, error)
+	// Update saves the fields and usage flags of addr.ID. Dropping a usage
+	// drops its default too; adding one follows the same rule as Create.
+	Update(ctx context.Context, userID int, addr models.Address) error
+	Delete(ctx context.Context, userID, id int) error
+	// RemoveUsage unflags the address for usage and deletes it when it is
+	// left without any usage.
+	RemoveUsage(ctx context.Context, userID, id int, usage AddressUsage) error
+	SetDefault(ctx context.Context, userID, id int, usage AddressUsage) error
+	// ResolveLegacyID returns the ID of the address that the shipping or
+	// billing address of id became when the address book replaced their
+	// tables, or id itself when it was not one of them.
+	ResolveLegacyID(ctx context.Context, usage AddressUsage, id int) (int, error)
+}
+
+// CatalogStore manages brands, the category tree and attributes. Brands,
+// categories and attribute values are read with their product counts.
+type CatalogStore interface {
+	CreateBrand(ctx context.Context, brand models.Brand) (int, error)
+	ListBrands(ctx context.Context) ([]models.Brand, error)
+	GetBrand(ctx context.Context, id int) (models.Brand, error)
+	UpdateBrand(ctx context.Context, brand models.Brand) error
+	DeleteBrand(ctx context.Context, id int) error
+
+	// CreateCategory inserts the category under ParentID (nil for the top
+	// level) at Position among its siblings, or last when Position is 0. An
+	// unknown parent yields an *InvalidRefError for "parent_id".
+	CreateCategory(ctx context.Context, category models.Category) (int, error)
+	// ListCategories returns every category without children, ordered by
+	// depth and then position.
+	ListCategories(ctx context.Context) ([]models.Category, error)
+	// GetCategory includes the category's children and breadcrumbs.
+	GetCategory(ctx context.Context, id int) (models.Category, error)
+	// UpdateCategory saves everything but the category's place in the tree.
+	UpdateCategory(ctx context.Context, category models.Category) error
+	// MoveCategory puts the category with its subtree under parentID (nil
+	// for the top level) at position among its new siblings, or last when
+	// position is 0, closing the gap it leaves. Moving a category into its
+	// own subtree yields ErrConflict, an unknown parent an *InvalidRefError
+	// for "parent_id".
+	MoveCategory(ctx context.Context, id int, parentID *int, position int) error
+	// DeleteCategory yields ErrConflict while the category has children or
+	// products.
+	DeleteCategory(ctx context.Context, id int) error
+
+	CreateAttribute(ctx context.Context, attr models.Attribute) (int, error)
+	// ListAttributes and GetAttribute only return active attributes, with
+	// their active values.
+	ListAttributes(ctx context.Context) ([]models.Attribute, error)
+	GetAttribute(ctx context.Context, id int) (models.Attribute, error)
+	UpdateAttribute(ctx context.Context, attr models.Attribute) error
+	// DeleteAttribute soft-deletes by setting status to 0.
+	DeleteAttribute(ctx context.Context, id int) error
+
+	// CreateAttributeValue returns an *InvalidRefError for "attribute_id".
+	CreateAttributeValue(ctx context.Context, value models.AttributeValue) (int, error)
+	ListAttributeValues(ctx context.Context, attributeID int) ([]models.AttributeValue, error)
+	GetAttributeValue(ctx context.Context, id int) (models.AttributeValue, error)
+	UpdateAttributeValue(ctx context.Context, value models.AttributeValue) error
+	DeleteAttributeValue(ctx context.Context, id int) error
+}
+
+type ProductStore interface {
+	// Create inserts the product with its attributes and variations in one
+	// transaction. Unknown references yield an *InvalidRefError naming the
+	// field (brand_id, category_id, attribute_id, attribute_value_id).
+	Create(ctx context.Context, product models.Product, attributes []models.ProductAttribute, variations []models.VariationProduct) (int, error)
+	// Get includes the product's images.
+	Get(ctx context.Context, id int) (models.Product, error)
+	List(ctx context.Context) ([]models.Product, error)
+	// ListInCategory returns the products of the category and of every
+	// category below it.
+	ListInCategory(ctx context.Context, categoryID int) ([]models.Product, error)
+}
+
+// ReviewSort orders approved reviews on the storefront.
+type ReviewSort string
+
+const (
+	ReviewSortRecent     ReviewSort = "recent"
+	ReviewSortHelpful    ReviewSort = "helpful"
+	ReviewSortRatingHigh ReviewSort = "rating_high"
+	ReviewSortRatingLow  ReviewSort = "rating_low"
+)
+
+type ReviewFilter struct {
+	Status    string // empty for any
+	ProductID int    // 0 for any
+	UserID    int    // 0 for any
+	Limit     int
+	Offset    int
+}
+
+// RatingSummary is a product's maintained rating with the per-star breakdown
+// of its approved reviews.
+type RatingSummary struct {
+	Rating       float64
+	RatingCount  int
+	Distribution map[int]int
+}
+
+// ReviewStore keeps products.rating and rating_count in sync with the
+// approved reviews on every change.
+type ReviewStore interface {
+	RatingSummary(ctx context.Context, productID int) (RatingSummary, error)
+	ListApproved(ctx context.Context, productID int, sort ReviewSort, limit, offset int) ([]models.ProductReview, error)
+	List(ctx context.Context, filter ReviewFilter) ([]models.ProductReview, error)
+	// Create stores a pending review; a second review of the same product
+	// yields ErrConflict and an unknown product an *InvalidRefError.
+	Create(ctx context.Context, review models.ProductReview) (int, error)
+	// Update edits the author's review and sends it back to moderation.
+	Update(ctx context.Context, review models.ProductReview) error
+	Delete(ctx context.Context, userID, reviewID int) error
+	Moderate(ctx context.Context, reviewID int, status string, moderatorID int) error
+	// Vote records the user's helpfulness vote on an approved review and
+	// returns the new counters; voting on one's own review yields ErrForbidden.
+	Vote(ctx context.Context, userID, reviewID int, helpful bool) (int, int, error)
+}
+
+// OrderFilter selects orders, newest first; zero values match any.
+type OrderFilter struct {
+	UserID        int
+	Limit, Offset int
+}
+
+// OrderStore keeps the orders customers placed; a product is open to the
+// reviews of the customers who ordered it.
+type OrderStore interface {
+	// Checkout places an order of the user's cart lines, priced as the cart
+	// prices them, and empties the cart in one transaction. An empty cart
+	// yields a *CheckError for "items".
+	Checkout(ctx context.Context, userID int) (int, error)
+	// Create stores the order with its items in one transaction, as placed
+	// unless it has a status. An unknown user or product yields an
+	// *InvalidRefError (user_id, product_id), and an unknown status or a
+	// quantity below 1 a *CheckError.
+	Create(ctx context.Context, order models.Order) (int, error)
+	// List returns the matching orders with their items.
+	List(ctx context.Context, filter OrderFilter) ([]models.Order, error)
+	// Get returns the order with its items; orders of other users than
+	// userID are not found unless AnyUser is passed.
+	Get(ctx context.Context, userID, id int) (models.Order, error)
+	// HasPurchased reports whether the user ordered the product in an order
+	// that was not cancelled; an unknown product yields ErrNotFound.
+	HasPurchased(ctx context.Context, userID, productID int) (bool, error)
+}
+
+type CartStore interface {
+	ListItems(ctx context.Context, userID int) ([]models.CartItem, error)
+	// AddItem adds quantity to the matching line (creating it if needed) and
+	// returns the line ID and its new quantity.
+	AddItem(ctx context.Context, userID, productID int, variationID *int, quantity int) (int, int, error)
+	UpdateItem(ctx context.Context, userID, itemID, quantity int) error
+	RemoveItem(ctx context.Context, userID, itemID int) error
+}
+
+type ShippingStore interface {
+	CreateZone(ctx context.Context, zone models.ShippingZone) (int, error)
+	// ListZones returns zones ordered by position with their methods and tiers.
+	ListZones(ctx context.Context, activeOnly bool) ([]models.ShippingZone, error)
+	GetZone(ctx context.Context, id int) (models.ShippingZone, error)
+	UpdateZone(ctx context.Context, zone models.ShippingZone) error
+	DeleteZone(ctx context.Context, id int) error
+
+	// CreateMethod stores a method with its tiers; an unknown zone yields an
+	// *InvalidRefError for "zone_id".
+	CreateMethod(ctx context.Context, method models.ShippingMethod) (int, error)
+	// UpdateMethod replaces the method's settings and tiers.
+	UpdateMethod(ctx context.Context, method models.ShippingMethod) error
+	DeleteMethod(ctx context.Context, id int) error
+}
+
+type WishlistItemInput struct {
+	ProductID         int
+	VariationID       *int
+	NotifyPriceDrop   bool
+	NotifyBackInStock bool
+}
+
+// WishlistStore manages a user's named lists. Every method taking a userID
+// treats lists of other users as not found.
+type WishlistStore interface {
+	List(ctx context.Context, userID int) ([]models.Wishlist, error)
+	Create(ctx context.Context, userID int, name string) (int, error)
+	// Get returns the list with its items.
+	Get(ctx context.Context, userID, id int) (models.Wishlist, error)
+	Rename(ctx context.Context, userID, id int, name string) error
+	Delete(ctx context.Context, userID, id int) error
+	// AddItem records the item's current price; an item already on the list
+	// yields ErrConflict and an unknown product or variation an *InvalidRefError.
+	AddItem(ctx context.Context, userID, wishlistID int, item WishlistItemInput) (int, error)
+	RemoveItem(ctx context.Context, userID, wishlistID, itemID int) error
+	// MoveToCart moves the given items (all items when itemIDs is empty) into
+	// the user's cart atomically and returns the moved item IDs.
+	MoveToCart(ctx context.Context, userID, wishlistID int, itemIDs []int) ([]int, error)
+	// SaveForLater moves a cart line into the named list, creating it if needed.
+	SaveForLater(ctx context.Context, userID, cartItemID int, listName string) (int, error)
+	// SetShareToken sets or, with nil, clears the public share token.
+	SetShareToken(ctx context.Context, userID, id int, token *string) error
+	// GetShared returns the list shared by token, without its owner; the
+	// lists of soft-deleted users are not found.
+	GetShared(ctx context.Context, token string) (models.Wishlist, error)
+
+	ListNotifications(ctx context.Context, userID int, unreadOnly bool, limit, offset int) ([]models.WishlistNotification, error)
+	// MarkNotificationsRead marks the given (or all, when ids is empty)
+	// unread notifications as read and returns how many changed.
+	MarkNotificationsRead(ctx context.Context, userID int, ids []int) (int64, error)
+}
@@ -0,0 +1,691 @@
+// Package storetest checks that an implementation of the stores keeps the
+// contract documented in package store, so that handlers tested on the
+// memory store behave the same on Postgres. The memory store runs it in its
+// unit tests, the Postgres store in the integration tests of package server.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"my-api/internal/testutil"
+	"my-api/models"
+	"my-api/store"
+)
+
+// Run runs every contract test on the stores open returns, fresh for each
+// test.
+func Run(t *testing.T, open func(t *testing.T) *store.Stores) {
+	tests := []struct {
+		name string
+		test func(f *fixtures)
+	}{
+		{"Users", testUsers},
+		{"UserListing", testUserListing},
+		{"UserDeletion", testUserDeletion},
+		{"UserBulkUpdate", testUserBulkUpdate},
+		{"Sessions", testSessions},
+		{"Addresses", testAddresses},
+		{"CategoryTree", testCategoryTree},
+		{"Products", testProducts},
+		{"ProductCounts", testProductCounts},
+		{"Reviews", testReviews},
+		{"Orders", testOrders},
+		{"Checkout", testCheckout},
+		{"Carts", testCarts},
+		{"Shipping", testShipping},
+		{"Wishlists", testWishlists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := open(t)
+			tt.test(&fixtures{Fixtures: testutil.NewFixtures(t, st, nil), t: t, st: st, ctx: context.Background()})
+		})
+	}
+}
+
+// fixtures stores the records the tests start from, with unique names.
+type fixtures struct {
+	*testutil.Fixtures
+	t   *testing.T
+	st  *store.Stores
+	ctx context.Context
+}
+
+func (f *fixtures) user() models.User {
+	return f.User().User
+}
+
+func (f *fixtures) category(parentID *int) int {
+	return f.Category(func(c *models.Category) { c.ParentID = parentID }).ID
+}
+
+// product stores a product priced 10 in categoryID, or a new category
+// when it is 0.
+func (f *fixtures) product(categoryID int) int {
+	return f.Product(func(p *models.Product) { p.CategoryID = categoryID }).ID
+}
+
+func (f *fixtures) address(userID int, shipping, billing bool) models.Address {
+	f.t.Helper()
+	addr, err := f.st.Addresses.Create(f.ctx, models.Address{
+		UserID: userID, RecipientName: "Alice", AddressLine1: fmt.Sprintf("%d Main St", f.Next()),
+		City: "Berlin", Country: "DE", PostalCode: "10115", Type: "home", IsShipping: shipping, IsBilling: billing,
+	})
+	if err != nil {
+		f.t.Fatal("Failed to store address: ", err)
+	}
+	return addr
+}
+
+// wantErr fails the test unless err matches target, and when target is a
+// *store.ConflictError or *store.InvalidRefError, has its field.
+func (f *fixtures) wantErr(what string, err, target error) {
+	f.t.Helper()
+	var conflict *store.ConflictError
+	var invalidRef *store.InvalidRefError
+	var check *store.CheckError
+	switch want := target.(type) {
+	case *store.ConflictError:
+		if !errors.As(err, &conflict) || conflict.Field != want.Field {
+			f.t.Errorf("%s: error %v, want a conflict on %s", what, err, want.Field)
+		}
+	case *store.InvalidRefError:
+		if !errors.As(err, &invalidRef) || invalidRef.Field != want.Field {
+			f.t.Errorf("%s: error %v, want an invalid %s", what, err, want.Field)
+		}
+	case *store.CheckError:
+		if !errors.As(err, &check) || check.Field != want.Field {
+			f.t.Errorf("%s: error %v, want a check failing on %s", what, err, want.Field)
+		}
+	default:
+		if !errors.Is(err, target) {
+			f.t.Errorf("%s: error %v, want %v", what, err, target)
+		}
+	}
+}
+
+func (f *fixtures) must(err error) {
+	f.t.Helper()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func testUsers(f *fixtures) {
+	account := f.User()
+	u := account.User
+	got, err := f.st.Users.Get(f.ctx, u.ID)
+	f.must(err)
+	if got.Username != u.Username || got.Email != u.Email || got.Role != "user" || got.CreatedAt.IsZero() {
+		f.t.Errorf("stored user %+v, want %+v", got, u)
+	}
+	_, err = f.st.Users.Get(f.ctx, u.ID+1000)
+	f.wantErr("unknown user", err, store.ErrNotFound)
+
+	_, err = f.st.Users.Create(f.ctx, models.User{Username: u.Username, Email: "other@example.com", Role: "user"}, "hash")
+	f.wantErr("duplicate username", err, &store.ConflictError{Field: "username"})
+	_, err = f.st.Users.Create(f.ctx, models.User{Username: "other", Email: u.Email, Role: "user"}, "hash")
+	f.wantErr("duplicate email", err, &store.ConflictError{Field: "email"})
+
+	for _, identifier := range []string{u.Username, u.Email} {
+		cred, err := f.st.Users.CredentialsByIdentifier(f.ctx, identifier)
+		f.must(err)
+		if cred.ID != u.ID || cred.PasswordHash != account.PasswordHash {
+			f.t.Errorf("credentials of %s: %+v", identifier, cred)
+		}
+	}
+	_, err = f.st.Users.CredentialsByIdentifier(f.ctx, "nobody")
+	f.wantErr("credentials of an unknown user", err, store.ErrNotFound)
+
+	u.Role, u.IsBlocked = "admin", true
+	f.must(f.st.Users.Update(f.ctx, u))
+	cred, err := f.st.Users.CredentialsByID(f.ctx, u.ID)
+	f.must(err)
+	if cred.Role != "admin" || !cred.IsBlocked {
+		f.t.Errorf("credentials after update %+v, want a blocked admin", cred)
+	}
+}
+
+func testUserListing(f *fixtures) {
+	var ids []int
+	for range 5 {
+		ids = append(ids, f.user().ID)
+	}
+	slices.Reverse(ids)
+
+	// pages of two, newest first
+	var listed []int
+	filter := store.UserFilter{Limit: 2}
+	for {
+		page, err := f.st.Users.List(f.ctx, filter)
+		f.must(err)
+		for _, u := range page {
+			listed = append(listed, u.ID)
+		}
+		if len(page) < filter.Limit {
+			break
+		}
+		last := page[len(page)-1]
+		filter.After = &store.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	if !slices.Equal(listed, ids) {
+		f.t.Errorf("listed %v, want %v", listed, ids)
+	}
+
+	found, err := f.st.Users.List(f.ctx, store.UserFilter{Search: "USER3@EXAMPLE", Limit: 10})
+	f.must(err)
+	if len(found) != 1 || found[0].ID != ids[2] {
+		f.t.Errorf("search found %+v, want user %d", found, ids[2])
+	}
+
+	hourAgo, inHour := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	for _, filter := range []struct {
+		name string
+		f    store.UserFilter
+		want int
+	}{
+		{"created in the last hour", store.UserFilter{CreatedFrom: &hourAgo, CreatedTo: &inHour, Limit: 10}, 5},
+		{"created from in an hour", store.UserFilter{CreatedFrom: &inHour, Limit: 10}, 0},
+		{"created before an hour ago", store.UserFilter{CreatedTo: &hourAgo, Limit: 10}, 0},
+		{"admins", store.UserFilter{Role: "admin", Limit: 10}, 0},
+	} {
+		got, err := f.st.Users.List(f.ctx, filter.f)
+		f.must(err)
+		if len(got) != filter.want {
+			f.t.Errorf("%s: %d users, want %d", filter.name, len(got), filter.want)
+		}
+	}
+}
+
+func testUserDeletion(f *fixtures) {
+	u, other := f.user(), f.user()
+	before := time.Now().Add(-time.Minute)
+	f.must(f.st.Users.Delete(f.ctx, u.ID))
+	f.wantErr("deleting again", f.st.Users.Delete(f.ctx, u.ID), store.ErrNotFound)
+
+	got, err := f.st.Users.Get(f.ctx, u.ID)
+	f.must(err)
+	if got.DeletedAt == nil {
+		f.t.Error("deleted user has no deleted_at")
+	}
+	_, err = f.st.Users.CredentialsByIdentifier(f.ctx, u.Username)
+	f.wantErr("credentials of a deleted user", err, store.ErrNotFound)
+	f.wantErr("updating a deleted user", f.st.Users.Update(f.ctx, u), store.ErrNotFound)
+
+	active, err := f.st.Users.List(f.ctx, store.UserFilter{Limit: 10})
+	f.must(err)
+	deleted, err := f.st.Users.List(f.ctx, store.UserFilter{Deleted: true, Limit: 10})
+	f.must(err)
+	if len(active) != 1 || active[0].ID != other.ID || len(deleted) != 1 || deleted[0].ID != u.ID {
+		f.t.Errorf("active users %v and deleted %v, want %d and %d", active, deleted, other.ID, u.ID)
+	}
+
+	f.wantErr("restoring a user deleted before since", f.st.Users.Restore(f.ctx, u.ID, time.Now().Add(time.Hour)), store.ErrNotFound)
+	f.must(f.st.Users.Restore(f.ctx, u.ID, before))
+	_, err = f.st.Users.CredentialsByIdentifier(f.ctx, u.Username)
+	f.must(err)
+
+	f.must(f.st.Users.Erase(f.ctx, u.ID))
+	got, err = f.st.Users.Get(f.ctx, u.ID)
+	f.must(err)
+	if got.Username == u.Username || got.Email == u.Email || got.ErasedAt == nil {
+		f.t.Errorf("erased user %+v still has their name", got)
+	}
+}
+
+func testUserBulkUpdate(f *fixtures) {
+	blocked, active := f.user(), f.user()
+	blocked.IsBlocked = true
+	f.must(f.st.Users.Update(f.ctx, blocked))
+
+	results, err := f.st.Users.BulkUpdate(f.ctx, store.UserBulkBlock, []int{active.ID, blocked.ID, active.ID + 1000}, "")
+	f.must(err)
+	want := []store.BulkResult{
+		{UserID: active.ID, Status: store.BulkUpdated},
+		{UserID: blocked.ID, Status: store.BulkUnchanged},
+		{UserID: active.ID + 1000, Status: store.BulkNotFound},
+	}
+	if !slices.Equal(results, want) {
+		f.t.Errorf("bulk block %+v, want %+v", results, want)
+	}
+	if got, err := f.st.Users.Get(f.ctx, active.ID); err != nil || !got.IsBlocked {
+		f.t.Errorf("user %d not blocked: %v", active.ID, err)
+	}
+}
+
+func testSessions(f *fixtures) {
+	u, other := f.user(), f.user()
+	kept, err := f.st.Sessions.Create(f.ctx, models.LoginSession{UserID: u.ID, Browser: "Firefox"})
+	f.must(err)
+	ended, err := f.st.Sessions.Create(f.ctx, models.LoginSession{UserID: u.ID, Browser: "Chrome"})
+	f.must(err)
+
+	if session, err := f.st.Sessions.Get(f.ctx, u.ID, kept); err != nil || session.Browser != "Firefox" {
+		f.t.Errorf("session %d: %+v (%v)", kept, session, err)
+	}
+	_, err = f.st.Sessions.Get(f.ctx, other.ID, kept)
+	f.wantErr("session of another user", err, store.ErrNotFound)
+
+	f.wantErr("ending the session of another user", f.st.Sessions.Delete(f.ctx, other.ID, ended), store.ErrNotFound)
+	f.must(f.st.Sessions.Delete(f.ctx, u.ID, ended))
+	_, err = f.st.Sessions.Get(f.ctx, u.ID, ended)
+	f.wantErr("ended session", err, store.ErrNotFound)
+	f.wantErr("ending a session twice", f.st.Sessions.Delete(f.ctx, u.ID, ended), store.ErrNotFound)
+	if sessions, err := f.st.Sessions.ListByUser(f.ctx, u.ID); err != nil || len(sessions) != 1 || sessions[0].ID != kept {
+		f.t.Errorf("sessions %+v (%v), want only %d", sessions, err, kept)
+	}
+
+	// an empty log is an empty list, not nil, so that it encodes as []
+	requests, err := f.st.Sessions.ListImpersonations(f.ctx, store.ImpersonationFilter{UserID: u.ID, Limit: 10})
+	if err != nil || requests == nil || len(requests) != 0 {
+		f.t.Errorf("impersonation log %#v (%v), want an empty list", requests, err)
+	}
+	if sessions, err := f.st.Sessions.ListByUser(f.ctx, other.ID); err != nil || sessions == nil || len(sessions) != 0 {
+		f.t.Errorf("sessions of a user who never logged in %#v (%v), want an empty list", sessions, err)
+	}
+}
+
+func testAddresses(f *fixtures) {
+	u, other := f.user(), f.user()
+	_, err := f.st.Addresses.Create(f.ctx, models.Address{UserID: u.ID + 1000, RecipientName: "Nobody", AddressLine1: "1 Main St",
+		City: "Berlin", Country: "DE", Type: "home", IsShipping: true})
+	f.wantErr("address of an unknown user", err, &store.InvalidRefError{Field: "user_id"})
+
+	// the first address of a usage becomes its default
+	first := f.address(u.ID, true, false)
+	second := f.address(u.ID, true, true)
+	if !first.IsDefaultShipping || first.IsDefaultBilling || second.IsDefaultShipping || !second.IsDefaultBilling {
+		f.t.Errorf("defaults of first %+v and second %+v", first, second)
+	}
+	def, err := f.st.Addresses.GetDefault(f.ctx, u.ID, store.UsageShipping)
+	f.must(err)
+	if def.ID != first.ID {
+		f.t.Errorf("default shipping address %d, want %d", def.ID, first.ID)
+	}
+	f.must(f.st.Addresses.SetDefault(f.ctx, u.ID, second.ID, store.UsageShipping))
+	if def, err = f.st.Addresses.GetDefault(f.ctx, u.ID, store.UsageShipping); err != nil || def.ID != second.ID {
+		f.t.Errorf("default shipping address %d (%v) after SetDefault, want %d", def.ID, err, second.ID)
+	}
+	_, err = f.st.Addresses.GetDefault(f.ctx, other.ID, store.UsageShipping)
+	f.wantErr("default of a user without addresses", err, store.ErrNotFound)
+
+	// addresses of other users and of other usages are not found
+	_, err = f.st.Addresses.Get(f.ctx, other.ID, first.ID, "")
+	f.wantErr("address of another user", err, store.ErrNotFound)
+	_, err = f.st.Addresses.Get(f.ctx, u.ID, first.ID, store.UsageBilling)
+	f.wantErr("shipping address as billing", err, store.ErrNotFound)
+	if _, err = f.st.Addresses.Get(f.ctx, store.AnyUser, first.ID, store.UsageShipping); err != nil {
+		f.t.Errorf("address of any user: %v", err)
+	}
+	f.wantErr("deleting the address of another user", f.st.Addresses.Delete(f.ctx, other.ID, first.ID), store.ErrNotFound)
+
+	billing, err := f.st.Addresses.List(f.ctx, u.ID, store.UsageBilling)
+	f.must(err)
+	if len(billing) != 1 || billing[0].ID != second.ID {
+		f.t.Errorf("billing addresses %+v, want %d", billing, second.ID)
+	}
+
+	// removing the last usage deletes the address
+	f.must(f.st.Addresses.RemoveUsage(f.ctx, u.ID, second.ID, store.UsageShipping))
+	if _, err = f.st.Addresses.Get(f.ctx, u.ID, second.ID, store.UsageBilling); err != nil {
+		f.t.Errorf("address left with billing: %v", err)
+	}
+	f.must(f.st.Addresses.RemoveUsage(f.ctx, u.ID, second.ID, store.UsageBilling))
+	_, err = f.st.Addresses.Get(f.ctx, u.ID, second.ID, "")
+	f.wantErr("address without usage", err, store.ErrNotFound)
+}
+
+func testCategoryTree(f *fixtures) {
+	top := f.category(nil)
+	child := f.category(&top)
+	grandchild := f.category(&child)
+	unknown := top + 1000
+
+	_, err := f.st.Catalog.CreateCategory(f.ctx, models.Category{CategoryName: "Orphan", ParentID: &unknown, Status: 1})
+	f.wantErr("unknown parent", err, &store.InvalidRefError{Field: "parent_id"})
+
+	got, err := f.st.Catalog.GetCategory(f.ctx, grandchild)
+	f.must(err)
+	var crumbs []int
+	for _, c := range got.Breadcrumbs {
+		crumbs = append(crumbs, c.ID)
+	}
+	if !slices.Equal(crumbs, []int{top, child}) {
+		f.t.Errorf("breadcrumbs %v, want %v", crumbs, []int{top, child})
+	}
+
+	f.wantErr("moving below itself", f.st.Catalog.MoveCategory(f.ctx, top, &grandchild, 0), store.ErrConflict)
+	f.wantErr("moving below its child", f.st.Catalog.MoveCategory(f.ctx, child, &grandchild, 0), store.ErrConflict)
+	f.wantErr("moving into itself", f.st.Catalog.MoveCategory(f.ctx, top, &top, 0), store.ErrConflict)
+	got, err = f.st.Catalog.GetCategory(f.ctx, grandchild)
+	f.must(err)
+	if len(got.Breadcrumbs) != 2 || got.Breadcrumbs[1].ID != child || got.Depth != 2 {
+		f.t.Errorf("category after refused moves %+v, want it still below %d and %d", got, top, child)
+	}
+	f.wantErr("moving below an unknown parent", f.st.Catalog.MoveCategory(f.ctx, child, &unknown, 0), &store.InvalidRefError{Field: "parent_id"})
+	f.wantErr("deleting a category with children", f.st.Catalog.DeleteCategory(f.ctx, child), store.ErrConflict)
+
+	// moving a category takes its subtree along
+	f.must(f.st.Catalog.MoveCategory(f.ctx, child, nil, 1))
+	got, err = f.st.Catalog.GetCategory(f.ctx, grandchild)
+	f.must(err)
+	if len(got.Breadcrumbs) != 1 || got.Breadcrumbs[0].ID != child {
+		f.t.Errorf("breadcrumbs after move %+v, want %d", got.Breadcrumbs, child)
+	}
+	f.must(f.st.Catalog.DeleteCategory(f.ctx, grandchild))
+	f.must(f.st.Catalog.DeleteCategory(f.ctx, child))
+	_, err = f.st.Catalog.GetCategory(f.ctx, child)
+	f.wantErr("deleted category", err, store.ErrNotFound)
+}
+
+func testProducts(f *fixtures) {
+	top := f.category(nil)
+	sub := f.category(&top)
+	inTop, inSub, elsewhere := f.product(top), f.product(sub), f.product(0)
+
+	products, err := f.st.Products.ListInCategory(f.ctx, top)
+	f.must(err)
+	var ids []int
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int{inTop, inSub}) {
+		f.t.Errorf("products below category %d: %v, want %v", top, ids, []int{inTop, inSub})
+	}
+	f.wantErr("deleting a category with products", f.st.Catalog.DeleteCategory(f.ctx, sub), store.ErrConflict)
+
+	p, err := f.st.Products.Get(f.ctx, elsewhere)
+	f.must(err)
+	p.ProductCode = "P-OTHER"
+	_, err = f.st.Products.Create(f.ctx, p, nil, []models.VariationProduct{{SKU: "S-1"}, {SKU: "S-1"}})
+	f.wantErr("duplicate SKU", err, &store.ConflictError{Field: "sku"})
+	p.BrandID = p.BrandID + 1000
+	_, err = f.st.Products.Create(f.ctx, p, nil, nil)
+	f.wantErr("unknown brand", err, &store.InvalidRefError{Field: "brand_id"})
+}
+
+// A category counts the products below it too; published products have
+// status 1.
+func testProductCounts(f *fixtures) {
+	top := f.category(nil)
+	sub := f.category(&top)
+	empty := f.category(nil)
+	brand := f.Brand().ID
+	f.Product(func(p *models.Product) { p.CategoryID, p.BrandID = top, brand })
+	f.Product(func(p *models.Product) { p.CategoryID, p.BrandID, p.Status = sub, brand, 0 })
+	f.Product(func(p *models.Product) { p.CategoryID = sub })
+
+	want := map[int][2]int{top: {2, 3}, sub: {1, 2}, empty: {0, 0}}
+	check := func(how string, c models.Category) {
+		f.t.Helper()
+		if w, ok := want[c.ID]; ok && (c.ProductsCount != w[0] || c.TotalProductsCount != w[1]) {
+			f.t.Errorf("%s: category %d counts %d of %d products, want %d of %d", how, c.ID, c.ProductsCount, c.TotalProductsCount, w[0], w[1])
+		}
+	}
+	categories, err := f.st.Catalog.ListCategories(f.ctx)
+	f.must(err)
+	for _, c := range categories {
+		check("listed", c)
+	}
+	got, err := f.st.Catalog.GetCategory(f.ctx, top)
+	f.must(err)
+	check("got", got)
+	if len(got.Children) != 1 {
+		f.t.Fatalf("children %+v, want %d", got.Children, sub)
+	}
+	check("child", got.Children[0])
+	got, err = f.st.Catalog.GetCategory(f.ctx, empty)
+	f.must(err)
+	check("got", got)
+
+	b, err := f.st.Catalog.GetBrand(f.ctx, brand)
+	f.must(err)
+	if b.ProductsCount != 1 || b.TotalProductsCount != 2 {
+		f.t.Errorf("brand counts %d of %d products, want 1 of 2", b.ProductsCount, b.TotalProductsCount)
+	}
+}
+
+func testReviews(f *fixtures) {
+	author, voter := f.user(), f.user()
+	productID := f.product(0)
+
+	reviewID, err := f.st.Reviews.Create(f.ctx, models.ProductReview{ProductID: productID, UserID: author.ID, Rating: 4, Title: "Good", Body: "Fits."})
+	f.must(err)
+	_, err = f.st.Reviews.Create(f.ctx, models.ProductReview{ProductID: productID, UserID: author.ID, Rating: 5, Title: "Again", Body: "Still."})
+	f.wantErr("second review", err, &store.ConflictError{Field: "product_id"})
+	_, err = f.st.Reviews.Create(f.ctx, models.ProductReview{ProductID: productID + 1000, UserID: author.ID, Rating: 5, Title: "?", Body: "?"})
+	f.wantErr("review of an unknown product", err, &store.InvalidRefError{Field: "product_id"})
+
+	// only approved reviews count and can be voted on
+	_, _, err = f.st.Reviews.Vote(f.ctx, voter.ID, reviewID, true)
+	f.wantErr("vote on a pending review", err, store.ErrNotFound)
+	f.must(f.st.Reviews.Moderate(f.ctx, reviewID, "approved", voter.ID))
+	summary, err := f.st.Reviews.RatingSummary(f.ctx, productID)
+	f.must(err)
+	if summary.Rating != 4 || summary.RatingCount != 1 || summary.Distribution[4] != 1 {
+		f.t.Errorf("rating summary %+v, want one 4", summary)
+	}
+
+	helpful, notHelpful, err := f.st.Reviews.Vote(f.ctx, voter.ID, reviewID, true)
+	f.must(err)
+	if helpful != 1 || notHelpful != 0 {
+		f.t.Errorf("votes %d/%d, want 1/0", helpful, notHelpful)
+	}
+	// voting again replaces the vote
+	if helpful, notHelpful, err = f.st.Reviews.Vote(f.ctx, voter.ID, reviewID, false); err != nil || helpful != 0 || notHelpful != 1 {
+		f.t.Errorf("votes %d/%d (%v) after changing the vote, want 0/1", helpful, notHelpful, err)
+	}
+	_, _, err = f.st.Reviews.Vote(f.ctx, author.ID, reviewID, true)
+	f.wantErr("vote on one's own review", err, store.ErrForbidden)
+
+	// editing sends the review back to moderation
+	f.must(f.st.Reviews.Update(f.ctx, models.ProductReview{ID: reviewID, UserID: author.ID, Rating: 2, Title: "Worse", Body: "Tore."}))
+	if summary, err = f.st.Reviews.RatingSummary(f.ctx, productID); err != nil || summary.RatingCount != 0 {
+		f.t.Errorf("rating summary %+v (%v) after edit, want no rating", summary, err)
+	}
+	f.wantErr("deleting the review of another user", f.st.Reviews.Delete(f.ctx, voter.ID, reviewID), store.ErrNotFound)
+	f.must(f.st.Reviews.Delete(f.ctx, author.ID, reviewID))
+}
+
+func testOrders(f *fixtures) {
+	u, other := f.user(), f.user()
+	productID, cancelledID := f.product(0), f.product(0)
+
+	_, err := f.st.Orders.Create(f.ctx, models.Order{UserID: u.ID, Items: []models.OrderItem{{ProductID: productID, Quantity: 2, UnitPrice: 9.5}}})
+	f.must(err)
+	_, err = f.st.Orders.Create(f.ctx, models.Order{UserID: u.ID, Status: "cancelled", Items: []models.OrderItem{{ProductID: cancelledID, Quantity: 1}}})
+	f.must(err)
+
+	for _, tt := range []struct {
+		what              string
+		userID, productID int
+		want              bool
+	}{
+		{"ordered product", u.ID, productID, true},
+		{"product of a cancelled order", u.ID, cancelledID, false},
+		{"product another user ordered", other.ID, productID, false},
+	} {
+		if got, err := f.st.Orders.HasPurchased(f.ctx, tt.userID, tt.productID); err != nil || got != tt.want {
+			f.t.Errorf("%s: purchased %v (%v), want %v", tt.what, got, err, tt.want)
+		}
+	}
+	_, err = f.st.Orders.HasPurchased(f.ctx, u.ID, productID+1000)
+	f.wantErr("purchase of an unknown product", err, store.ErrNotFound)
+
+	_, err = f.st.Orders.Create(f.ctx, models.Order{UserID: u.ID, Items: []models.OrderItem{{ProductID: productID + 1000, Quantity: 1}}})
+	f.wantErr("order of an unknown product", err, &store.InvalidRefError{Field: "product_id"})
+	_, err = f.st.Orders.Create(f.ctx, models.Order{UserID: u.ID, Items: []models.OrderItem{{ProductID: productID, Quantity: 0}}})
+	f.wantErr("quantity below 1", err, &store.CheckError{Field: "quantity"})
+	_, err = f.st.Orders.Create(f.ctx, models.Order{UserID: u.ID, Status: "lost"})
+	f.wantErr("unknown status", err, &store.CheckError{Field: "status"})
+}
+
+func testCheckout(f *fixtures) {
+	u, other := f.user(), f.user()
+	productID := f.product(0)
+
+	_, err := f.st.Orders.Checkout(f.ctx, u.ID)
+	f.wantErr("checkout of an empty cart", err, &store.CheckError{Field: "items"})
+
+	_, _, err = f.st.Carts.AddItem(f.ctx, u.ID, productID, nil, 3)
+	f.must(err)
+	orderID, err := f.st.Orders.Checkout(f.ctx, u.ID)
+	f.must(err)
+	if items, err := f.st.Carts.ListItems(f.ctx, u.ID); err != nil || len(items) != 0 {
+		f.t.Errorf("cart after checkout %+v (%v), want it empty", items, err)
+	}
+
+	order, err := f.st.Orders.Get(f.ctx, u.ID, orderID)
+	f.must(err)
+	if order.Status != "placed" || len(order.Items) != 1 || order.Items[0].ProductID != productID ||
+		order.Items[0].Quantity != 3 || order.Items[0].UnitPrice != 10 || order.Total != 30 {
+		f.t.Errorf("order %+v, want 3 of product %d at 10", order, productID)
+	}
+	_, err = f.st.Orders.Get(f.ctx, other.ID, orderID)
+	f.wantErr("order of another user", err, store.ErrNotFound)
+	if _, err := f.st.Orders.Get(f.ctx, store.AnyUser, orderID); err != nil {
+		f.t.Errorf("order of any user: %v", err)
+	}
+
+	orders, err := f.st.Orders.List(f.ctx, store.OrderFilter{UserID: u.ID, Limit: 10})
+	f.must(err)
+	if len(orders) != 1 || orders[0].ID != orderID || len(orders[0].Items) != 1 {
+		f.t.Errorf("orders of the user %+v, want the placed order", orders)
+	}
+	orders, err = f.st.Orders.List(f.ctx, store.OrderFilter{UserID: other.ID, Limit: 10})
+	if err != nil || orders == nil || len(orders) != 0 {
+		f.t.Errorf("orders of another user %#v (%v), want an empty list", orders, err)
+	}
+}
+
+func testCarts(f *fixtures) {
+	u, other := f.user(), f.user()
+	productID := f.product(0)
+
+	itemID, quantity, err := f.st.Carts.AddItem(f.ctx, u.ID, productID, nil, 2)
+	f.must(err)
+	// the same product adds to its line
+	again, quantity, err := f.st.Carts.AddItem(f.ctx, u.ID, productID, nil, 1)
+	f.must(err)
+	if again != itemID || quantity != 3 {
+		f.t.Errorf("adding again gave line %d with %d, want %d with 3", again, quantity, itemID)
+	}
+	_, _, err = f.st.Carts.AddItem(f.ctx, u.ID, productID+1000, nil, 1)
+	f.wantErr("unknown product", err, &store.InvalidRefError{Field: "product_id"})
+	variationID := 1000
+	_, _, err = f.st.Carts.AddItem(f.ctx, u.ID, productID, &variationID, 1)
+	f.wantErr("unknown variation", err, &store.InvalidRefError{Field: "variation_id"})
+
+	items, err := f.st.Carts.ListItems(f.ctx, u.ID)
+	f.must(err)
+	if len(items) != 1 || items[0].Quantity != 3 || items[0].UnitPrice != 10 {
+		f.t.Errorf("cart %+v, want 3 at 10", items)
+	}
+	if items, err = f.st.Carts.ListItems(f.ctx, other.ID); err != nil || len(items) != 0 {
+		f.t.Errorf("cart of another user %+v (%v), want it empty", items, err)
+	}
+
+	f.wantErr("updating the line of another user", f.st.Carts.UpdateItem(f.ctx, other.ID, itemID, 5), store.ErrNotFound)
+	f.wantErr("removing the line of another user", f.st.Carts.RemoveItem(f.ctx, other.ID, itemID), store.ErrNotFound)
+	f.must(f.st.Carts.UpdateItem(f.ctx, u.ID, itemID, 5))
+	f.must(f.st.Carts.RemoveItem(f.ctx, u.ID, itemID))
+	f.wantErr("removing a removed line", f.st.Carts.RemoveItem(f.ctx, u.ID, itemID), store.ErrNotFound)
+}
+
+func testShipping(f *fixtures) {
+	second, err := f.st.Shipping.CreateZone(f.ctx, models.ShippingZone{ZoneName: "Europe", Countries: []string{"DE", "FR"}, Position: 2, Status: 1})
+	f.must(err)
+	first, err := f.st.Shipping.CreateZone(f.ctx, models.ShippingZone{ZoneName: "Berlin", Countries: []string{"DE"},
+		PostalCodePatterns: []string{"10*"}, Position: 1, Status: 1})
+	f.must(err)
+
+	_, err = f.st.Shipping.CreateMethod(f.ctx, models.ShippingMethod{ZoneID: second + 1000, MethodName: "Lost", RateType: "flat", Status: 1})
+	f.wantErr("method of an unknown zone", err, &store.InvalidRefError{Field: "zone_id"})
+	max := 5.0
+	_, err = f.st.Shipping.CreateMethod(f.ctx, models.ShippingMethod{ZoneID: first, MethodName: "Courier", RateType: "weight", Status: 1,
+		Tiers: []models.ShippingRateTier{{MinValue: 0, MaxValue: &max, Rate: 3}, {MinValue: 5, Rate: 8}}})
+	f.must(err)
+	_, err = f.st.Shipping.CreateMethod(f.ctx, models.ShippingMethod{ZoneID: first, MethodName: "Retired", RateType: "flat", BaseRate: 1, Status: 0})
+	f.must(err)
+
+	zones, err := f.st.Shipping.ListZones(f.ctx, true)
+	f.must(err)
+	if len(zones) != 2 || zones[0].ID != first || zones[1].ID != second {
+		f.t.Fatalf("zones %+v, want %d then %d", zones, first, second)
+	}
+	if methods := zones[0].Methods; len(methods) != 1 || methods[0].MethodName != "Courier" || len(methods[0].Tiers) != 2 {
+		f.t.Errorf("active methods %+v, want Courier with two tiers", methods)
+	}
+	zone, err := f.st.Shipping.GetZone(f.ctx, first)
+	f.must(err)
+	if len(zone.Methods) != 2 || !slices.Equal(zone.PostalCodePatterns, []string{"10*"}) {
+		f.t.Errorf("zone %+v, want both methods and its pattern", zone)
+	}
+	f.must(f.st.Shipping.DeleteZone(f.ctx, first))
+	_, err = f.st.Shipping.GetZone(f.ctx, first)
+	f.wantErr("deleted zone", err, store.ErrNotFound)
+}
+
+func testWishlists(f *fixtures) {
+	u, other := f.user(), f.user()
+	productID := f.product(0)
+
+	listID, err := f.st.Wishlists.Create(f.ctx, u.ID, "Birthday")
+	f.must(err)
+	_, err = f.st.Wishlists.Create(f.ctx, u.ID, "Birthday")
+	f.wantErr("duplicate list name", err, &store.ConflictError{Field: "name"})
+	_, err = f.st.Wishlists.Get(f.ctx, other.ID, listID)
+	f.wantErr("list of another user", err, store.ErrNotFound)
+
+	_, err = f.st.Wishlists.AddItem(f.ctx, u.ID, listID, store.WishlistItemInput{ProductID: productID})
+	f.must(err)
+	_, err = f.st.Wishlists.AddItem(f.ctx, u.ID, listID, store.WishlistItemInput{ProductID: productID})
+	f.wantErr("item already on the list", err, &store.ConflictError{Field: "product_id"})
+	_, err = f.st.Wishlists.AddItem(f.ctx, u.ID, listID, store.WishlistItemInput{ProductID: productID + 1000})
+	f.wantErr("unknown product", err, &store.InvalidRefError{Field: "product_id"})
+	_, err = f.st.Wishlists.AddItem(f.ctx, other.ID, listID, store.WishlistItemInput{ProductID: productID})
+	f.wantErr("adding to the list of another user", err, store.ErrNotFound)
+
+	token := "share-token"
+	f.must(f.st.Wishlists.SetShareToken(f.ctx, u.ID, listID, &token))
+	shared, err := f.st.Wishlists.GetShared(f.ctx, token)
+	f.must(err)
+	if shared.ID != listID || len(shared.Items) != 1 {
+		f.t.Errorf("shared list %+v, want %d with its item", shared, listID)
+	}
+	// the lists of a deleted account are no longer shared
+	owner := f.user()
+	ownerList, err := f.st.Wishlists.Create(f.ctx, owner.ID, "Wedding")
+	f.must(err)
+	ownerToken := "deleted-owner-token"
+	f.must(f.st.Wishlists.SetShareToken(f.ctx, owner.ID, ownerList, &ownerToken))
+	f.must(f.st.Users.Delete(f.ctx, owner.ID))
+	_, err = f.st.Wishlists.GetShared(f.ctx, ownerToken)
+	f.wantErr("shared list of a deleted user", err, store.ErrNotFound)
+
+	moved, err := f.st.Wishlists.MoveToCart(f.ctx, u.ID, listID, nil)
+	f.must(err)
+	list, err := f.st.Wishlists.Get(f.ctx, u.ID, listID)
+	f.must(err)
+	items, err := f.st.Carts.ListItems(f.ctx, u.ID)
+	f.must(err)
+	if len(moved) != 1 || len(list.Items) != 0 || len(items) != 1 || items[0].ProductID != productID {
+		f.t.Errorf("moved %v, leaving %+v on the list and %+v in the cart", moved, list.Items, items)
+	}
+
+	// and back again
+	savedTo, err := f.st.Wishlists.SaveForLater(f.ctx, u.ID, items[0].ID, "Later")
+	f.must(err)
+	if items, err = f.st.Carts.ListItems(f.ctx, u.ID); err != nil || len(items) != 0 {
+		f.t.Errorf("cart %+v (%v) after saving for later, want it empty", items, err)
+	}
+	if list, err = f.st.Wishlists.Get(f.ctx, u.ID, savedTo); err != nil || list.Name != "Later" || len(list.Items) != 1 {
+		f.t.Errorf("saved for later to %+v (%v)", list, err)
+	}
+}
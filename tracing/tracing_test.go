@@ -0,0 +1,29 @@
+package tracing
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"my-api/config"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), config.TracingConfig{Exporter: ExporterNone, ServiceName: "test", SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	// incoming trace IDs are propagated even without an exporter
+	if fields := otel.GetTextMapPropagator().Fields(); !slices.Contains(fields, "traceparent") {
+		t.Errorf("propagated fields %v, want traceparent", fields)
+	}
+
+	if _, err := Setup(context.Background(), config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+}
@@ -3,13 +3,37 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtKey = []byte(os.Getenv("JWT_SECRET")) // Must be set in env
+// errNoJWTSecret reports a token service without a key.
+var errNoJWTSecret = errors.New("utils: JWT secret is not set")
+
+// TokenService signs and verifies the tokens of the API with one secret
+// key. Its clock stamps and checks their issue and expiry times.
+type TokenService struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewTokenService returns the service of the configured secret, with now
+// as its clock (time.Now if nil).
+func NewTokenService(secret string, now func() time.Time) *TokenService {
+	if now == nil {
+		now = time.Now
+	}
+	return &TokenService{key: []byte(secret), now: now}
+}
+
+// sign signs the token's claims with the key.
+func (s *TokenService) sign(token *jwt.Token) (string, error) {
+	if len(s.key) == 0 {
+		return "", errNoJWTSecret
+	}
+	return token.SignedString(s.key)
+}
 
 type Claims struct {
 	UserID   uint   `json:"user_id"`
@@ -18,10 +42,18 @@ type Claims struct {
 	Password string `json:"password"` // Hashed; insecure, see security notes
 	Role     string `json:"role"`
 	Type     string `json:"type"` // "access" or "refresh"
+	// ImpersonatorID is set on access tokens an admin obtained to act as the
+	// user; SessionID is the login session opened for it.
+	ImpersonatorID uint `json:"impersonator_id,omitempty"`
+	SessionID      int  `json:"session_id,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userID uint, username, email, password, role string) (string, error) {
+// ImpersonationTokenTTL is how long an impersonation token is valid. It
+// cannot be refreshed.
+const ImpersonationTokenTTL = 10 * time.Minute
+
+func (s *TokenService) GenerateAccessToken(userID uint, username, email, password, role string) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
@@ -30,15 +62,15 @@ func GenerateAccessToken(userID uint, username, email, password, role string) (s
 		Role:     role,
 		Type:     "access",
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(s.now().Add(15 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(s.now()),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return s.sign(token)
 }
 
-func GenerateRefreshToken(userID uint, username, email, password, role string) (string, error) {
+func (s *TokenService) GenerateRefreshToken(userID uint, username, email, password, role string) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
@@ -47,19 +79,42 @@ func GenerateRefreshToken(userID uint, username, email, password, role string) (
 		Role:     role,
 		Type:     "refresh",
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(s.now().Add(7 * 24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(s.now()),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return s.sign(token)
+}
+
+// GenerateImpersonationToken issues an access token for userID that carries
+// the admin acting as them. It has no password hash and expires at expiresAt.
+func (s *TokenService) GenerateImpersonationToken(userID uint, username, email, role string, impersonatorID uint, sessionID int, expiresAt time.Time) (string, error) {
+	claims := Claims{
+		UserID:         userID,
+		Username:       username,
+		Email:          email,
+		Role:           role,
+		Type:           "access",
+		ImpersonatorID: impersonatorID,
+		SessionID:      sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(s.now()),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtKey)
+	return s.sign(token)
 }
 
-func ParseToken(tokenString string) (*Claims, error) {
+func (s *TokenService) ParseToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+		if len(s.key) == 0 {
+			return nil, errNoJWTSecret
+		}
+		return s.key, nil
+	}, jwt.WithTimeFunc(s.now))
 	if err != nil {
 		return nil, err
 	}